@@ -15,7 +15,9 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/joho/godotenv"
+	"github.com/tcmartin/flowlib"
 	"github.com/tcmartin/flowrunner/pkg/api"
 	"github.com/tcmartin/flowrunner/pkg/auth"
 	"github.com/tcmartin/flowrunner/pkg/config"
@@ -39,6 +41,15 @@ const (
 	AppName    = "flowrunner"
 )
 
+// Application modes, selected by the first command-line argument
+const (
+	// modeServer serves the API, the default
+	modeServer = "server"
+
+	// modeWorker runs the executions queued by API servers
+	modeWorker = "worker"
+)
+
 func main() {
 	// Load environment variables from .env file
 	_ = godotenv.Load()
@@ -58,8 +69,14 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// "flowrunner worker" runs queued executions instead of the API server
+	mode := modeServer
+	if flag.Arg(0) == modeWorker {
+		mode = modeWorker
+	}
+
 	// Initialize the application
-	app, err := NewApp(cfg)
+	app, err := NewApp(cfg, mode)
 	if err != nil {
 		log.Fatalf("Failed to initialize application: %v", err)
 	}
@@ -165,6 +182,9 @@ func overrideConfigFromEnv(cfg *config.Config) {
 			cfg.Server.Port = p
 		}
 	}
+	if publicURL := os.Getenv("FLOWRUNNER_PUBLIC_URL"); publicURL != "" {
+		cfg.Server.PublicURL = publicURL
+	}
 
 	// Storage configuration
 	if storageType := os.Getenv("FLOWRUNNER_STORAGE_TYPE"); storageType != "" {
@@ -216,6 +236,59 @@ func overrideConfigFromEnv(cfg *config.Config) {
 	if encryptionKey := os.Getenv("FLOWRUNNER_ENCRYPTION_KEY"); encryptionKey != "" {
 		cfg.Auth.EncryptionKey = encryptionKey
 	}
+
+	// Execution configuration
+	if maxConcurrent := os.Getenv("FLOWRUNNER_MAX_CONCURRENT_EXECUTIONS"); maxConcurrent != "" {
+		if n, err := strconv.Atoi(maxConcurrent); err == nil {
+			cfg.Execution.MaxConcurrent = n
+		}
+	}
+	if maxPerAccount := os.Getenv("FLOWRUNNER_MAX_CONCURRENT_EXECUTIONS_PER_ACCOUNT"); maxPerAccount != "" {
+		if n, err := strconv.Atoi(maxPerAccount); err == nil {
+			cfg.Execution.MaxConcurrentPerAccount = n
+		}
+	}
+	if maxPerFlow := os.Getenv("FLOWRUNNER_MAX_CONCURRENT_EXECUTIONS_PER_FLOW"); maxPerFlow != "" {
+		if n, err := strconv.Atoi(maxPerFlow); err == nil {
+			cfg.Execution.MaxConcurrentPerFlow = n
+		}
+	}
+	if idempotencyWindow := os.Getenv("FLOWRUNNER_IDEMPOTENCY_WINDOW"); idempotencyWindow != "" {
+		if n, err := strconv.Atoi(idempotencyWindow); err == nil {
+			cfg.Execution.IdempotencyWindow = n
+		}
+	}
+	if maxNodeVisits := os.Getenv("FLOWRUNNER_MAX_NODE_VISITS"); maxNodeVisits != "" {
+		if n, err := strconv.Atoi(maxNodeVisits); err == nil {
+			cfg.Execution.MaxNodeVisits = n
+		}
+	}
+	if maxVisitsPerNode := os.Getenv("FLOWRUNNER_MAX_VISITS_PER_NODE"); maxVisitsPerNode != "" {
+		if n, err := strconv.Atoi(maxVisitsPerNode); err == nil {
+			cfg.Execution.MaxVisitsPerNode = n
+		}
+	}
+
+	// Queue configuration
+	if queueType := os.Getenv("FLOWRUNNER_QUEUE_TYPE"); queueType != "" {
+		cfg.Queue.Type = queueType
+	}
+	if addr := os.Getenv("FLOWRUNNER_REDIS_ADDR"); addr != "" {
+		cfg.Queue.Redis.Addr = addr
+	}
+	if password := os.Getenv("FLOWRUNNER_REDIS_PASSWORD"); password != "" {
+		cfg.Queue.Redis.Password = password
+	}
+	if leaseTimeout := os.Getenv("FLOWRUNNER_QUEUE_LEASE_TIMEOUT"); leaseTimeout != "" {
+		if n, err := strconv.Atoi(leaseTimeout); err == nil {
+			cfg.Queue.LeaseTimeout = n
+		}
+	}
+	if concurrency := os.Getenv("FLOWRUNNER_WORKER_CONCURRENCY"); concurrency != "" {
+		if n, err := strconv.Atoi(concurrency); err == nil {
+			cfg.Queue.WorkerConcurrency = n
+		}
+	}
 }
 
 // generateRandomKey generates a random key of the specified length
@@ -227,15 +300,59 @@ func generateRandomKey(length int) (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// runtimeNodeFactoryAdapter adapts a runtime node factory to the node
+// factory interface of the YAML loader
+type runtimeNodeFactoryAdapter struct {
+	factory runtime.NodeFactory
+}
+
+// CreateNode implements plugins.NodeFactory
+func (a *runtimeNodeFactoryAdapter) CreateNode(nodeDef plugins.NodeDefinition) (flowlib.Node, error) {
+	return a.factory(nodeDef.Params)
+}
+
+// runtimeFlowRegistry serves the flow definitions of the flow registry to the runtime
+type runtimeFlowRegistry struct {
+	registry registry.FlowRegistry
+}
+
+// GetFlow implements runtime.FlowRegistry
+func (r *runtimeFlowRegistry) GetFlow(accountID, flowID string) (*runtime.Flow, error) {
+	content, err := r.registry.Get(accountID, flowID)
+	if err != nil {
+		return nil, err
+	}
+	return &runtime.Flow{ID: flowID, YAML: content}, nil
+}
+
+// GetFlowVersion implements runtime.VersionedFlowRegistry
+func (r *runtimeFlowRegistry) GetFlowVersion(accountID, flowID, version string) (*runtime.Flow, error) {
+	content, err := r.registry.GetVersion(accountID, flowID, version)
+	if err != nil {
+		return nil, err
+	}
+	return &runtime.Flow{ID: flowID, YAML: content}, nil
+}
+
 // App represents the flowrunner application
 type App struct {
 	config          *config.Config
 	server          *api.Server
 	storageProvider storage.StorageProvider
+
+	// worker is set in worker mode instead of server. It runs until
+	// workerCtx is canceled by stopWorker, then closes workerDone.
+	worker     *runtime.Worker
+	workerCtx  context.Context
+	stopWorker context.CancelFunc
+	workerDone chan struct{}
+
+	// redisClient connects to the work queue, if there is one
+	redisClient *redis.Client
 }
 
-// NewApp creates a new application instance
-func NewApp(cfg *config.Config) (*App, error) {
+// NewApp creates a new application instance running in the given mode
+func NewApp(cfg *config.Config, mode string) (*App, error) {
 	// Initialize storage provider
 	var storageProvider storage.StorageProvider
 	var err error
@@ -302,19 +419,10 @@ func NewApp(cfg *config.Config) (*App, error) {
 		log.Fatalf("Failed to register mcp plugin: %v", err)
 	}
 
-	// Create an adapter for the runtime node factories
-	type RuntimeNodeFactoryAdapter struct {
-		factory runtime.NodeFactory
-	}
-
-	func (a *RuntimeNodeFactoryAdapter) CreateNode(nodeDef plugins.NodeDefinition) (flowlib.Node, error) {
-		return a.factory(nodeDef.Params)
-	}
-
 	// Register core node types
 	nodeFactories := make(map[string]plugins.NodeFactory)
 	for nodeType, factory := range runtime.CoreNodeTypes() {
-		nodeFactories[nodeType] = &RuntimeNodeFactoryAdapter{factory: factory}
+		nodeFactories[nodeType] = &runtimeNodeFactoryAdapter{factory: factory}
 	}
 
 	yamlLoader := loader.NewYAMLLoader(nodeFactories, pluginRegistry)
@@ -350,29 +458,108 @@ func NewApp(cfg *config.Config) (*App, error) {
 		return nil, fmt.Errorf("encryption key is required for secret vault")
 	}
 
-	// Create API server
-	server := api.NewServer(cfg, flowRegistry, accountService, secretVault, pluginRegistry)
-
-	return &App{
+	app := &App{
 		config:          cfg,
-		server:          server,
 		storageProvider: storageProvider,
-	}, nil
+	}
+
+	// With a work queue, API servers queue executions and workers run them
+	var queue runtime.WorkQueue
+	switch cfg.Queue.Type {
+	case "":
+	case "redis":
+		log.Printf("Connecting to Redis work queue at %s", cfg.Queue.Redis.Addr)
+		app.redisClient = redis.NewClient(&redis.Options{
+			Addr:     cfg.Queue.Redis.Addr,
+			Password: cfg.Queue.Redis.Password,
+			DB:       cfg.Queue.Redis.DB,
+		})
+		queue, err = runtime.NewRedisWorkQueue(context.Background(), app.redisClient, runtime.RedisWorkQueueOptions{
+			Stream:       cfg.Queue.Stream,
+			LeaseTimeout: time.Duration(cfg.Queue.LeaseTimeout) * time.Second,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Redis work queue: %w", err)
+		}
+		if cfg.Storage.Type == "memory" {
+			log.Println("Warning: in-memory storage is not shared with workers in other processes")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported queue type: %s", cfg.Queue.Type)
+	}
+
+	flows := &runtimeFlowRegistry{registry: flowRegistry}
+	executionStore := storageProvider.GetExecutionStore()
+
+	var signalLinks runtime.SignalLinks
+	if cfg.Server.PublicURL != "" && cfg.Auth.JWTSecret != "" {
+		signalLinks = runtime.SignalLinks{BaseURL: cfg.Server.PublicURL, Key: []byte(cfg.Auth.JWTSecret)}
+	}
+
+	if mode == modeWorker {
+		if queue == nil {
+			return nil, fmt.Errorf("worker mode requires a work queue, set FLOWRUNNER_QUEUE_TYPE=redis")
+		}
+		app.worker = runtime.NewWorker(flows, yamlLoader, executionStore, secretVault, queue, runtime.WorkerOptions{
+			Concurrency: cfg.Queue.WorkerConcurrency,
+			VisitLimits: flowlib.VisitLimits{
+				MaxVisits:        cfg.Execution.MaxNodeVisits,
+				MaxVisitsPerNode: cfg.Execution.MaxVisitsPerNode,
+			},
+			SignalLinks: signalLinks,
+		})
+		app.workerCtx, app.stopWorker = context.WithCancel(context.Background())
+		app.workerDone = make(chan struct{})
+		return app, nil
+	}
+
+	var flowRuntime runtime.FlowRuntime
+	if queue != nil {
+		flowRuntime = runtime.NewFlowRuntimeWithQueue(flows, yamlLoader, executionStore, secretVault, queue)
+	} else {
+		flowRuntime = runtime.NewFlowRuntimeWithStoreAndSecrets(flows, yamlLoader, executionStore, secretVault)
+	}
+
+	// Create API server
+	app.server = api.NewServerWithRuntime(cfg, flowRegistry, accountService, secretVault, flowRuntime, pluginRegistry)
+
+	return app, nil
 }
 
 // Start starts the application
 func (a *App) Start() error {
 	fmt.Printf("Starting %s version %s\n", AppName, AppVersion)
-	return a.server.Start()
+	if a.worker == nil {
+		return a.server.Start()
+	}
+
+	defer close(a.workerDone)
+	log.Printf("Starting worker %s", a.worker.ID())
+	return a.worker.Run(a.workerCtx)
 }
 
 // Stop stops the application gracefully
 func (a *App) Stop(ctx context.Context) error {
-	// Stop the server
-	if err := a.server.Stop(ctx); err != nil {
+	if a.worker != nil {
+		// Running executions stop without releasing their lease, so that
+		// another worker continues them
+		a.stopWorker()
+		select {
+		case <-a.workerDone:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	} else if err := a.server.Stop(ctx); err != nil {
+		// Stop the server
 		return err
 	}
 
+	if a.redisClient != nil {
+		if err := a.redisClient.Close(); err != nil {
+			return fmt.Errorf("failed to close Redis client: %w", err)
+		}
+	}
+
 	// Close storage
 	if err := a.storageProvider.Close(); err != nil {
 		return fmt.Errorf("failed to close storage: %w", err)
@@ -12,7 +12,11 @@ package flowlib
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 )
@@ -89,6 +93,40 @@ type Node interface {
 	Run(shared any) (Action, error)
 }
 
+// ContextNode is implemented by nodes that can stop early when their
+// context is canceled.
+type ContextNode interface {
+	Node
+	RunWithContext(ctx context.Context, shared any) (Action, error)
+}
+
+// RunNode runs n with ctx if it supports cancellation, falling back to Run.
+// It returns ctx.Err() without running n if ctx is already done.
+func RunNode(ctx context.Context, n Node, shared any) (Action, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if cn, ok := n.(ContextNode); ok {
+		return cn.RunWithContext(ctx, shared)
+	}
+	return n.Run(shared)
+}
+
+// sleepContext waits for d, returning early with ctx.Err() if ctx is canceled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
 type baseNode struct {
 	params     map[string]any
 	successors map[Action]Node
@@ -120,13 +158,121 @@ func (b *baseNode) Run(shared any) (Action, error) {
 	return DefaultAction, nil // overridden by NodeWithRetry
 }
 
+/* ---------- Retry policy ---------- */
+
+// Backoff strategies understood by RetryPolicy
+const (
+	BackoffFixed       = "fixed"
+	BackoffExponential = "exponential"
+)
+
+// RetryPolicy controls how a failed exec is retried.
+type RetryPolicy struct {
+	MaxRetries int              // maximum number of attempts; values below 1 mean one
+	Wait       time.Duration    // delay before the first retry
+	Backoff    string           // BackoffFixed (default) or BackoffExponential
+	MaxWait    time.Duration    // caps the delay between attempts when > 0
+	Jitter     bool             // spreads each delay randomly over [delay/2, delay]
+	RetryOn    func(error) bool // reports whether an error is retried; nil retries every error
+}
+
+// RetryConfigurable is implemented by nodes whose retry behavior can be replaced.
+type RetryConfigurable interface {
+	SetRetryPolicy(p RetryPolicy)
+}
+
+// Delay returns the wait before the given retry, counting the first retry as 1.
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	d := p.Wait
+	if p.Backoff == BackoffExponential {
+		for i := 1; i < retry && d > 0 && d < math.MaxInt64/2; i++ {
+			if p.MaxWait > 0 && d >= p.MaxWait {
+				break
+			}
+			d *= 2
+		}
+	}
+	if p.MaxWait > 0 && d > p.MaxWait {
+		d = p.MaxWait
+	}
+	if p.Jitter && d > 1 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d-d/2)+1))
+	}
+	return d
+}
+
+// Retryable reports whether err may be retried under p.
+func (p RetryPolicy) Retryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	return p.RetryOn == nil || p.RetryOn(err)
+}
+
+// Attempt describes one finished exec attempt of a node.
+type Attempt struct {
+	Node        Node
+	Number      int           // 1-based attempt number
+	MaxAttempts int           // attempts allowed by the policy
+	Err         error         // nil if the attempt succeeded
+	RetryIn     time.Duration // delay before the next attempt, if one follows
+	WillRetry   bool
+}
+
+// RetryObserver is called after every attempt of a node that may retry.
+type RetryObserver func(Attempt)
+
+type retryObserverKey struct{}
+
+// WithRetryObserver returns a context that reports node attempts to obs.
+func WithRetryObserver(ctx context.Context, obs RetryObserver) context.Context {
+	return context.WithValue(ctx, retryObserverKey{}, obs)
+}
+
+// Do calls fn until it succeeds, fails with an error that is not retryable,
+// runs out of attempts or ctx is done. Attempts are reported to the
+// RetryObserver in ctx. If ctx ends the retries, its error is returned.
+func (p RetryPolicy) Do(ctx context.Context, n Node, fn func() (any, error)) (any, error) {
+	attempts := max(1, p.MaxRetries)
+	obs, _ := ctx.Value(retryObserverKey{}).(RetryObserver)
+
+	var e any
+	var err error
+	for i := 1; i <= attempts; i++ {
+		if cerr := ctx.Err(); cerr != nil {
+			return nil, cerr
+		}
+		e, err = fn()
+		a := Attempt{Node: n, Number: i, MaxAttempts: attempts, Err: err}
+		if err != nil && i < attempts && p.Retryable(err) {
+			a.WillRetry = true
+			a.RetryIn = p.Delay(i)
+		}
+		if obs != nil && attempts > 1 {
+			obs(a)
+		}
+		if !a.WillRetry {
+			break
+		}
+		if cerr := sleepContext(ctx, a.RetryIn); cerr != nil {
+			return nil, cerr
+		}
+	}
+	return e, err
+}
+
 /* ---------- NodeWithRetry ---------- */
 
 type NodeWithRetry struct {
 	baseNode
 	MaxRetries int
 	Wait       time.Duration
+	Backoff    string
+	MaxWait    time.Duration
+	Jitter     bool
+	RetryOn    func(error) bool
 	execFn     func(any) (any, error)
+	execCtxFn  func(context.Context, any) (any, error)
 }
 
 func NewNode(maxRetries int, wait time.Duration) *NodeWithRetry {
@@ -140,8 +286,44 @@ func NewNode(maxRetries int, wait time.Duration) *NodeWithRetry {
 	return node
 }
 
+// RetryPolicy returns the retry settings of the node.
+func (n *NodeWithRetry) RetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: n.MaxRetries,
+		Wait:       n.Wait,
+		Backoff:    n.Backoff,
+		MaxWait:    n.MaxWait,
+		Jitter:     n.Jitter,
+		RetryOn:    n.RetryOn,
+	}
+}
+
+// SetRetryPolicy replaces the retry settings of the node.
+func (n *NodeWithRetry) SetRetryPolicy(p RetryPolicy) {
+	n.MaxRetries = p.MaxRetries
+	n.Wait = p.Wait
+	n.Backoff = p.Backoff
+	n.MaxWait = p.MaxWait
+	n.Jitter = p.Jitter
+	n.RetryOn = p.RetryOn
+}
+
 func (n *NodeWithRetry) SetExecFn(fn func(any) (any, error)) {
 	n.execFn = fn
+	n.execCtxFn = nil
+}
+
+// SetExecFnWithContext sets an exec function that receives the run context,
+// so long-running work can be aborted on cancellation.
+func (n *NodeWithRetry) SetExecFnWithContext(fn func(context.Context, any) (any, error)) {
+	n.execCtxFn = fn
+}
+
+func (n *NodeWithRetry) execWithContext(ctx context.Context, p any) (any, error) {
+	if n.execCtxFn != nil {
+		return n.execCtxFn(ctx, p)
+	}
+	return n.execFn(p)
 }
 
 func (n *NodeWithRetry) SetPrepFn(fn func(any) (any, error)) {
@@ -154,29 +336,28 @@ func (n *NodeWithRetry) ExecFallback(prepRes any, execErr error) (any, error) {
 
 // Run executes Prep, then Exec with retry/backoff, then Post.
 func (n *NodeWithRetry) Run(shared any) (Action, error) {
+	return n.RunWithContext(context.Background(), shared)
+}
+
+// RunWithContext is Run, but stops retrying and waiting once ctx is canceled.
+func (n *NodeWithRetry) RunWithContext(ctx context.Context, shared any) (Action, error) {
 	// 1) Prep
 	p, err := n.prep(shared)
 	if err != nil {
 		return "", err
 	}
 	// 2) Exec w/ retry
-	var e any
-	for i := 0; i < max(1, n.MaxRetries); i++ {
-		e, err = n.execFn(p)
-		if err == nil {
-			break
-		}
-		if i == n.MaxRetries-1 {
-			e, err = n.ExecFallback(p, err)
-			break
+	e, err := n.RetryPolicy().Do(ctx, n, func() (any, error) {
+		return n.execWithContext(ctx, p)
+	})
+	if err != nil {
+		if cerr := ctx.Err(); cerr != nil {
+			return "", cerr
 		}
-		if n.Wait > 0 {
-			time.Sleep(n.Wait)
+		if e, err = n.ExecFallback(p, err); err != nil {
+			return "", err
 		}
 	}
-	if err != nil {
-		return "", err
-	}
 	// 3) Post
 	return n.post(shared, p, e)
 }
@@ -188,41 +369,43 @@ type BatchNode struct{ *NodeWithRetry }
 func NewBatchNode(r int, w time.Duration) *BatchNode { return &BatchNode{NewNode(r, w)} }
 
 func (bn *BatchNode) Run(shared any) (Action, error) {
+	return bn.RunWithContext(context.Background(), shared)
+}
+
+// RunWithContext is Run, but checks ctx between items, retries and waits.
+func (bn *BatchNode) RunWithContext(ctx context.Context, shared any) (Action, error) {
 	// 1) Prep
 	p, err := bn.prep(shared)
 	if err != nil {
 		return "", err
 	}
 	// 2) Exec w/ retry
-	var e any
-	for i := 0; i < max(1, bn.MaxRetries); i++ {
-		e, err = bn.exec(p)
-		if err == nil {
-			break
-		}
-		if i == bn.MaxRetries-1 {
-			e, err = bn.ExecFallback(p, err)
-			break
+	e, err := bn.RetryPolicy().Do(ctx, bn, func() (any, error) {
+		return bn.exec(ctx, p)
+	})
+	if err != nil {
+		if cerr := ctx.Err(); cerr != nil {
+			return "", cerr
 		}
-		if bn.Wait > 0 {
-			time.Sleep(bn.Wait)
+		if e, err = bn.ExecFallback(p, err); err != nil {
+			return "", err
 		}
 	}
-	if err != nil {
-		return "", err
-	}
 	// 3) Post
 	return bn.post(shared, p, e)
 }
 
-func (bn *BatchNode) exec(items any) (any, error) {
+func (bn *BatchNode) exec(ctx context.Context, items any) (any, error) {
 	slice, ok := items.([]any)
 	if !ok {
 		return nil, fmt.Errorf("BatchNode expects []any, got %T", items)
 	}
 	out := make([]any, 0, len(slice))
 	for _, v := range slice {
-		res, err := bn.execFn(v)
+		if err := ctx.Err(); err != nil {
+			return out, err
+		}
+		res, err := bn.execWithContext(ctx, v)
 		if err != nil {
 			return out, err
 		}
@@ -234,13 +417,25 @@ func (bn *BatchNode) exec(items any) (any, error) {
 /* ---------- Flow orchestrator (sync) ---------- */
 
 type Flow struct {
-	start Node
+	start        Node
+	errorHandler Node
 }
 
 func (f *Flow) Start() Node {
 	return f.start
 }
 
+// ErrorHandler returns the node that handles a failed run of the flow, if any.
+// Flow itself does not run it; orchestrators that report failures do.
+func (f *Flow) ErrorHandler() Node {
+	return f.errorHandler
+}
+
+// SetErrorHandler sets the node that handles a failed run of the flow.
+func (f *Flow) SetErrorHandler(n Node) {
+	f.errorHandler = n
+}
+
 func NewFlow(start Node) *Flow {
 	return &Flow{start: start}
 }
@@ -257,11 +452,22 @@ func (f *Flow) getNext(curr Node, act Action) Node {
 }
 
 func (f *Flow) Run(shared any) (Action, error) {
+	return f.RunWithContext(context.Background(), shared)
+}
+
+// RunWithContext runs the flow until it ends or ctx is canceled. Cancellation
+// is checked between nodes and passed to nodes that implement ContextNode.
+// The run fails with a *VisitLimitError once it exceeds the VisitLimits in ctx.
+func (f *Flow) RunWithContext(ctx context.Context, shared any) (Action, error) {
+	visits := NewVisitCounter(VisitLimitsFrom(ctx), 0)
 	curr := f.start
 	var last Action
 	var err error
 	for curr != nil {
-		last, err = curr.Run(shared)
+		if err := visits.Visit(curr); err != nil {
+			return last, err
+		}
+		last, err = RunNode(ctx, curr, shared)
 		if err != nil {
 			return last, err
 		}
@@ -270,6 +476,72 @@ func (f *Flow) Run(shared any) (Action, error) {
 	return last, nil
 }
 
+/* ---------- Visit limits ---------- */
+
+// VisitLimits bounds how often one run of a flow enters nodes, so that a flow
+// whose routing loops fails instead of running forever. Zero means no limit.
+type VisitLimits struct {
+	MaxVisits        int // visits of all nodes together
+	MaxVisitsPerNode int // visits of any single node
+}
+
+type visitLimitsKey struct{}
+
+// WithVisitLimits returns a context whose flow runs are bounded by limits.
+func WithVisitLimits(ctx context.Context, limits VisitLimits) context.Context {
+	return context.WithValue(ctx, visitLimitsKey{}, limits)
+}
+
+// VisitLimitsFrom returns the visit limits stored in ctx, or no limits.
+func VisitLimitsFrom(ctx context.Context) VisitLimits {
+	limits, _ := ctx.Value(visitLimitsKey{}).(VisitLimits)
+	return limits
+}
+
+// VisitLimitError is returned when a run exceeds its visit limits. NodeID is
+// the node_id parameter of the node the run was about to enter.
+type VisitLimitError struct {
+	NodeID  string
+	Visits  int
+	Limit   int
+	PerNode bool // the limit of a single node was exceeded
+}
+
+func (e *VisitLimitError) Error() string {
+	if e.PerNode {
+		return fmt.Sprintf("node %q would be visited %d times, more than the limit of %d visits per node; the flow may be stuck in a cycle", e.NodeID, e.Visits, e.Limit)
+	}
+	return fmt.Sprintf("flow exceeded the limit of %d node visits at node %q; it may be stuck in a cycle", e.Limit, e.NodeID)
+}
+
+// VisitCounter counts the node visits of one run against its limits.
+type VisitCounter struct {
+	limits  VisitLimits
+	visits  int
+	perNode map[Node]int
+}
+
+// NewVisitCounter returns a counter for a run that already made visits node
+// visits, e.g. before it was interrupted and resumed.
+func NewVisitCounter(limits VisitLimits, visits int) *VisitCounter {
+	return &VisitCounter{limits: limits, visits: visits, perNode: make(map[Node]int)}
+}
+
+// Visit records a visit of n, or returns a *VisitLimitError without recording
+// it if the visit exceeds the limits.
+func (c *VisitCounter) Visit(n Node) error {
+	nodeID, _ := n.Params()["node_id"].(string)
+	if c.limits.MaxVisits > 0 && c.visits+1 > c.limits.MaxVisits {
+		return &VisitLimitError{NodeID: nodeID, Visits: c.visits + 1, Limit: c.limits.MaxVisits}
+	}
+	if c.limits.MaxVisitsPerNode > 0 && c.perNode[n]+1 > c.limits.MaxVisitsPerNode {
+		return &VisitLimitError{NodeID: nodeID, Visits: c.perNode[n] + 1, Limit: c.limits.MaxVisitsPerNode, PerNode: true}
+	}
+	c.visits++
+	c.perNode[n]++
+	return nil
+}
+
 /* ---------- Async primitives ---------- */
 
 type Result struct {
@@ -310,22 +582,11 @@ func (an *asyncNode) RunAsync(ctx context.Context, shared any) <-chan Result {
 			return
 		}
 		// Exec w/ retry
-		var e any
-		for i := 0; i < max(1, an.MaxRetries); i++ {
-			e, err = an.execAsyncFn(ctx, p)
-			if err == nil {
-				break
-			}
-			if i == an.MaxRetries-1 {
-				e, err = an.ExecFallbackAsync(ctx, p, err)
-				break
-			}
-			select {
-			case <-ctx.Done():
-				ch <- Result{"", nil, ctx.Err()}
-				return
-			case <-time.After(an.Wait):
-			}
+		e, err := an.RetryPolicy().Do(ctx, an, func() (any, error) {
+			return an.execAsyncFn(ctx, p)
+		})
+		if err != nil && ctx.Err() == nil {
+			e, err = an.ExecFallbackAsync(ctx, p, err)
 		}
 		if err != nil {
 			ch <- Result{"", nil, err}
@@ -361,7 +622,9 @@ func NewAsyncSplitNode() *AsyncSplitNode {
 	return &AsyncSplitNode{newBaseNode()}
 }
 
-// RunAsync executes all successors in parallel and returns when all complete
+// RunAsync executes all successors in parallel and returns when all complete.
+// Every branch runs on its own BranchScope of a map shared state; once all
+// branches succeeded their changes are merged in the order of their actions.
 func (as *AsyncSplitNode) RunAsync(ctx context.Context, shared any) <-chan Result {
 	ch := make(chan Result, 1)
 	go func() {
@@ -377,10 +640,20 @@ func (as *AsyncSplitNode) RunAsync(ctx context.Context, shared any) <-chan Resul
 		var wg sync.WaitGroup
 		errCh := make(chan error, len(successors))
 
+		// Every branch runs on its own scope of a map shared state
+		sharedMap, isMap := shared.(map[string]any)
+		scopes := make(map[Action]*BranchScope, len(successors))
+
 		// Launch all successors in parallel
 		for action, n := range successors {
+			branchShared := shared
+			if isMap {
+				scopes[action] = ForkScope(sharedMap)
+				branchShared = scopes[action].State()
+			}
+
 			wg.Add(1)
-			go func(a Action, node Node) {
+			go func(a Action, node Node, shared any) {
 				defer wg.Done()
 
 				var err error
@@ -388,13 +661,13 @@ func (as *AsyncSplitNode) RunAsync(ctx context.Context, shared any) <-chan Resul
 					r := <-asyncNode.RunAsync(ctx, shared)
 					err = r.Err
 				} else {
-					_, err = node.Run(shared)
+					_, err = RunNode(ctx, node, shared)
 				}
 
 				if err != nil {
 					errCh <- fmt.Errorf("AsyncSplitNode action %q failed: %w", a, err)
 				}
-			}(action, n)
+			}(action, n, branchShared)
 		}
 
 		// Wait for all to complete or context cancellation
@@ -417,6 +690,15 @@ func (as *AsyncSplitNode) RunAsync(ctx context.Context, shared any) <-chan Resul
 		case err := <-errCh:
 			ch <- Result{"", nil, err}
 		default:
+			// Merge the branch changes in action order, so the last action wins conflicts
+			actions := make([]Action, 0, len(scopes))
+			for action := range scopes {
+				actions = append(actions, action)
+			}
+			sort.Strings(actions)
+			for _, action := range actions {
+				MergeChanges(sharedMap, scopes[action].Changes(false), false)
+			}
 			ch <- Result{DefaultAction, nil, nil}
 		}
 	}()
@@ -425,7 +707,11 @@ func (as *AsyncSplitNode) RunAsync(ctx context.Context, shared any) <-chan Resul
 
 // Run implements the Node interface for non-async usage
 func (as *AsyncSplitNode) Run(shared any) (Action, error) {
-	ctx := context.Background()
+	return as.RunWithContext(context.Background(), shared)
+}
+
+// RunWithContext runs all successors in parallel and waits, honoring ctx
+func (as *AsyncSplitNode) RunWithContext(ctx context.Context, shared any) (Action, error) {
 	r := <-as.RunAsync(ctx, shared)
 	return r.Act, r.Err
 }
@@ -442,8 +728,14 @@ func NewSplitNode() *SplitNode {
 
 // Run executes all successors in parallel and waits for them to complete.
 // This enables true fan-out behavior where multiple branches run simultaneously.
-// Uses thread-safe shared state synchronization for proper map-reduce patterns.
+// Every branch runs on its own BranchScope; mapper results reach the shared
+// state through the thread-safe collector as mapper_results.
 func (s *SplitNode) Run(shared any) (Action, error) {
+	return s.RunWithContext(context.Background(), shared)
+}
+
+// RunWithContext is Run with ctx passed to every branch.
+func (s *SplitNode) RunWithContext(ctx context.Context, shared any) (Action, error) {
 	successors := s.successors
 	if len(successors) == 0 {
 		warn("SplitNode has no successors")
@@ -482,16 +774,22 @@ func (s *SplitNode) Run(shared any) (Action, error) {
 	var wg sync.WaitGroup
 	errCh := make(chan error, len(successors))
 
-	// Launch all successors in parallel with thread-safe shared state
+	// Launch all successors in parallel, each on its own scope of the shared
+	// state. The scopes share the thread-safe results collector.
 	for action, n := range successors {
+		branchShared := syncShared
+		if syncMap, ok := syncShared.(map[string]any); ok {
+			branchShared = ForkScope(syncMap).State()
+		}
+
 		wg.Add(1)
-		go func(a Action, node Node) {
+		go func(a Action, node Node, shared any) {
 			defer wg.Done()
-			_, err := node.Run(syncShared)
+			_, err := RunNode(ctx, node, shared)
 			if err != nil {
 				errCh <- fmt.Errorf("SplitNode action %q failed: %w", a, err)
 			}
-		}(action, n)
+		}(action, n, branchShared)
 	}
 
 	// Wait for all to complete
@@ -724,15 +1022,20 @@ func (af *AsyncFlow) RunAsync(ctx context.Context, shared any) <-chan Result {
 	ch := make(chan Result, 1)
 	go func() {
 		defer close(ch)
+		visits := NewVisitCounter(VisitLimitsFrom(ctx), 0)
 		curr := af.start
 		var last Action
 		var err error
 		for curr != nil {
+			if err := visits.Visit(curr); err != nil {
+				ch <- Result{"", nil, err}
+				return
+			}
 			if asyncNode, ok := curr.(AsyncNode); ok {
 				r := <-asyncNode.RunAsync(ctx, shared)
 				last, err = r.Act, r.Err
 			} else {
-				last, err = curr.Run(shared)
+				last, err = RunNode(ctx, curr, shared)
 			}
 			if err != nil {
 				ch <- Result{"", nil, err}
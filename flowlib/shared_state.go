@@ -0,0 +1,132 @@
+package flowlib
+
+import (
+	"reflect"
+	"strings"
+)
+
+/* ---------- Branch scopes of the shared state ---------- */
+
+// BranchScope is the shared state of one parallel branch. The branch runs on
+// its own copy of the state it was forked from, so it never writes to a map
+// that its parent or a sibling branch uses. What the branch changed is
+// merged back explicitly with Changes and MergeChanges.
+type BranchScope struct {
+	base  map[string]any
+	state map[string]any
+}
+
+// ForkScope returns a branch scope of parent. Maps and slices are copied;
+// other values, such as pointers to thread-safe collectors, are shared.
+// parent must not change while the scope is forked.
+func ForkScope(parent map[string]any) *BranchScope {
+	base := make(map[string]any, len(parent))
+	for key, value := range parent {
+		base[key] = value
+	}
+	return &BranchScope{
+		base:  base,
+		state: CopyValue(parent).(map[string]any),
+	}
+}
+
+// State returns the map the branch runs on
+func (s *BranchScope) State() map[string]any {
+	return s.state
+}
+
+// Changes returns the values the branch added or changed. With deep, changed
+// objects only hold their changed keys. Keys starting with "_" hold runtime
+// internals and are never reported.
+func (s *BranchScope) Changes(deep bool) map[string]any {
+	changes := make(map[string]any)
+	for key, value := range s.state {
+		if strings.HasPrefix(key, "_") {
+			continue
+		}
+		previous, existed := s.base[key]
+		if existed && reflect.DeepEqual(previous, value) {
+			continue
+		}
+		if deep {
+			value = changedValue(previous, value)
+		}
+		changes[key] = value
+	}
+	return changes
+}
+
+// MergeChanges writes changes into shared. With deep, objects present in
+// both are merged key by key. Objects in shared are replaced, never modified,
+// so scopes forked from shared keep their view of it.
+func MergeChanges(shared, changes map[string]any, deep bool) {
+	for key, value := range changes {
+		if deep {
+			value = mergeDeep(shared[key], value)
+		}
+		shared[key] = value
+	}
+}
+
+// CopyValue returns a deep copy of the maps and slices in value
+func CopyValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(v))
+		for key, item := range v {
+			copied[key] = CopyValue(item)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(v))
+		for i, item := range v {
+			copied[i] = CopyValue(item)
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+// changedValue returns the parts of value that differ from previous: for two
+// objects the changed keys, otherwise value itself
+func changedValue(previous, value any) any {
+	previousMap, ok := previous.(map[string]any)
+	if !ok {
+		return value
+	}
+	valueMap, ok := value.(map[string]any)
+	if !ok {
+		return value
+	}
+	changed := make(map[string]any)
+	for key, v := range valueMap {
+		p, existed := previousMap[key]
+		if existed && reflect.DeepEqual(p, v) {
+			continue
+		}
+		changed[key] = changedValue(p, v)
+	}
+	return changed
+}
+
+// mergeDeep returns a copy of dst with the keys of src merged in, recursing
+// into objects present in both
+func mergeDeep(dst, src any) any {
+	dstMap, ok := dst.(map[string]any)
+	if !ok {
+		return src
+	}
+	srcMap, ok := src.(map[string]any)
+	if !ok {
+		return src
+	}
+	merged := make(map[string]any, len(dstMap)+len(srcMap))
+	for key, value := range dstMap {
+		merged[key] = value
+	}
+	for key, value := range srcMap {
+		merged[key] = mergeDeep(merged[key], value)
+	}
+	return merged
+}
@@ -2,10 +2,12 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -308,6 +310,467 @@ func TestFlowExecutionAPI(t *testing.T) {
 	})
 }
 
+func TestFlowExecutionAPI_IdempotencyKey(t *testing.T) {
+	server, mockFlowRegistry, _, accountID := setupTestServer()
+
+	// Idempotency keys are kept by execution stores that support them
+	yamlLoader := loader.NewYAMLLoader(map[string]plugins.NodeFactory{"base": &loader.BaseNodeFactory{}}, plugins.NewPluginRegistry())
+	server.flowRuntime = runtime.NewFlowRuntimeWithStore(mockFlowRegistry, yamlLoader, storage.NewMemoryExecutionStore())
+
+	flowDef := &runtime.Flow{
+		ID:   "test-flow",
+		YAML: "metadata:\n  name: test-flow\nnodes:\n  start:\n    type: base\n",
+	}
+	mockFlowRegistry.On("GetFlow", accountID, "test-flow").Return(flowDef, nil)
+
+	run := func(key string) string {
+		req := httptest.NewRequest("POST", "/api/v1/flows/test-flow/run", bytes.NewBufferString("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		req.SetBasicAuth("testuser", "testpass")
+
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		var response map[string]interface{}
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		return response["execution_id"].(string)
+	}
+
+	first := run("delivery-1")
+	assert.Equal(t, first, run("delivery-1"))
+	assert.NotEqual(t, first, run("delivery-2"))
+}
+
+func TestExecutionTraceAPI(t *testing.T) {
+	server, mockFlowRegistry, _, accountID := setupTestServer()
+
+	// Traces are kept by execution stores that support them
+	yamlLoader := loader.NewYAMLLoader(map[string]plugins.NodeFactory{"base": &loader.BaseNodeFactory{}}, plugins.NewPluginRegistry())
+	server.flowRuntime = runtime.NewFlowRuntimeWithStore(mockFlowRegistry, yamlLoader, storage.NewMemoryExecutionStore())
+
+	flowDef := &runtime.Flow{
+		ID:   "test-flow",
+		YAML: "metadata:\n  name: test-flow\nnodes:\n  start:\n    type: base\n",
+	}
+	mockFlowRegistry.On("GetFlow", accountID, "test-flow").Return(flowDef, nil)
+
+	rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/flows/test-flow/run?wait=5s", map[string]interface{}{})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var execution map[string]interface{}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&execution))
+	executionID := execution["id"].(string)
+
+	t.Run("get trace", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "GET", "/api/v1/executions/"+executionID+"/trace", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var trace []runtime.NodeTrace
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&trace))
+		if assert.Len(t, trace, 1) {
+			assert.Equal(t, 1, trace[0].Sequence)
+			assert.Equal(t, "start", trace[0].NodeID)
+			assert.Equal(t, "base", trace[0].NodeType)
+			assert.Equal(t, 1, trace[0].Visit)
+			assert.False(t, trace[0].StartTime.IsZero())
+		}
+	})
+
+	t.Run("unknown execution", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "GET", "/api/v1/executions/non-existent/trace", nil)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("execution of another account", func(t *testing.T) {
+		_, err := server.accountService.CreateAccount("otheruser", "otherpass")
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/api/v1/executions/"+executionID+"/trace", nil)
+		req.SetBasicAuth("otheruser", "otherpass")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestExecutionReplayAPI(t *testing.T) {
+	server, mockFlowRegistry, _, accountID := setupTestServer()
+
+	// Replays start from checkpoints kept by the execution store
+	yamlLoader := loader.NewYAMLLoader(map[string]plugins.NodeFactory{"base": &loader.BaseNodeFactory{}}, plugins.NewPluginRegistry())
+	server.flowRuntime = runtime.NewFlowRuntimeWithStore(mockFlowRegistry, yamlLoader, storage.NewMemoryExecutionStore())
+
+	flowDef := &runtime.Flow{
+		ID:   "test-flow",
+		YAML: "metadata:\n  name: test-flow\nnodes:\n  start:\n    type: base\n    next:\n      default: finish\n  finish:\n    type: base\n",
+	}
+	mockFlowRegistry.On("GetFlow", accountID, "test-flow").Return(flowDef, nil)
+
+	rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/flows/test-flow/run?wait=5s", map[string]interface{}{
+		"input": map[string]interface{}{"topic": "go"},
+	})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var execution map[string]interface{}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&execution))
+	executionID := execution["id"].(string)
+
+	t.Run("replay from node", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/executions/"+executionID+"/replay", map[string]interface{}{
+			"from_node": "finish",
+			"state":     map[string]interface{}{"topic": "rust"},
+		})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		var response map[string]interface{}
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		assert.Equal(t, executionID, response["replay_of"])
+		replayID, _ := response["execution_id"].(string)
+		assert.NotEmpty(t, replayID)
+		assert.NotEqual(t, executionID, replayID)
+
+		status, err := server.flowRuntime.(runtime.ExecutionWaiter).Wait(context.Background(), replayID)
+		assert.NoError(t, err)
+		assert.Equal(t, "completed", status.Status)
+		assert.Equal(t, executionID, status.Metadata[runtime.ReplayOfExecutionIDKey])
+		assert.Equal(t, "finish", status.Metadata[runtime.ReplayFromNodeKey])
+
+		// Only the replayed node ran
+		trace, err := server.flowRuntime.(runtime.ExecutionTracer).GetTrace(accountID, replayID)
+		assert.NoError(t, err)
+		if assert.Len(t, trace, 1) {
+			assert.Equal(t, "finish", trace[0].NodeID)
+		}
+	})
+
+	t.Run("missing from_node", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/executions/"+executionID+"/replay", map[string]interface{}{})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("node that did not run", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/executions/"+executionID+"/replay", map[string]interface{}{"from_node": "unknown"})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("unknown execution", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/executions/non-existent/replay", map[string]interface{}{"from_node": "finish"})
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestExecutionSignalAPI(t *testing.T) {
+	server, mockFlowRegistry, _, accountID := setupTestServer()
+
+	notifications := make(chan map[string]interface{}, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		notifications <- body
+	}))
+	defer webhook.Close()
+
+	// Waits are kept by execution stores that support them
+	yamlLoader := loader.NewYAMLLoader(map[string]plugins.NodeFactory{
+		"base":     &loader.BaseNodeFactory{},
+		"approval": &RuntimeNodeFactoryAdapter{factory: runtime.NewApprovalNodeWrapper},
+	}, plugins.NewPluginRegistry())
+	flowRuntime := runtime.NewFlowRuntimeWithStore(mockFlowRegistry, yamlLoader, storage.NewMemoryExecutionStore())
+	flowRuntime.(runtime.SignalLinker).SetSignalLinks(runtime.SignalLinks{BaseURL: "https://flows.example.com", Key: []byte("signing-key")})
+	server.flowRuntime = flowRuntime
+
+	flowDef := &runtime.Flow{
+		ID:   "approval-flow",
+		YAML: "metadata:\n  name: approval-flow\nnodes:\n  approve:\n    type: approval\n    params:\n      expires_in: 1h\n      notify:\n        webhook:\n          url: " + webhook.URL + "\n    next:\n      approved: finish\n  finish:\n    type: base\n",
+	}
+	mockFlowRegistry.On("GetFlow", accountID, "approval-flow").Return(flowDef, nil)
+
+	// A run parked for approval is answered like an asynchronous run
+	rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/flows/approval-flow/run?wait=300ms", map[string]interface{}{})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var execution map[string]interface{}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&execution))
+	executionID := execution["execution_id"].(string)
+
+	var notification map[string]interface{}
+	select {
+	case notification = <-notifications:
+	case <-time.After(5 * time.Second):
+		t.Fatal("approval webhook was not called")
+	}
+	approveURL := notification["approve_url"].(string)
+	assert.Contains(t, approveURL, "https://flows.example.com/api/v1/executions/"+executionID+"/signals/approve?")
+	signalPath := strings.TrimPrefix(approveURL, "https://flows.example.com")
+
+	t.Run("requires authentication without token", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/executions/"+executionID+"/signals/approve?decision=approve", nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("rejects invalid tokens", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/executions/"+executionID+"/signals/approve?decision=reject&token=0.forged", nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("unknown signal", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/executions/"+executionID+"/signals/other", map[string]interface{}{"decision": "approve"})
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("invalid decision", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/executions/"+executionID+"/signals/approve", map[string]interface{}{"decision": "maybe"})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("opening a signed link asks for confirmation", func(t *testing.T) {
+		req := httptest.NewRequest("GET", signalPath, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, rr.Body.String(), `<form method="post"`)
+
+		// The execution still waits for the decision
+		status, err := server.flowRuntime.GetStatus(executionID)
+		assert.NoError(t, err)
+		assert.Equal(t, "waiting", status.Status)
+	})
+
+	t.Run("approve by signed link", func(t *testing.T) {
+		req := httptest.NewRequest("POST", signalPath, nil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response map[string]interface{}
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		assert.Equal(t, "approve", response["decision"])
+
+		assert.Eventually(t, func() bool {
+			status, err := server.flowRuntime.GetStatus(executionID)
+			return err == nil && status.Status == "completed"
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("signal after resume", func(t *testing.T) {
+		req := httptest.NewRequest("POST", signalPath, nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestDeliverEventAPI(t *testing.T) {
+	server, mockFlowRegistry, _, accountID := setupTestServer()
+
+	// Event waits are kept by execution stores that support them, and the
+	// correlation key expression needs the secret vault of the server
+	yamlLoader := loader.NewYAMLLoader(map[string]plugins.NodeFactory{
+		"base":           &loader.BaseNodeFactory{},
+		"wait_for_event": &RuntimeNodeFactoryAdapter{factory: runtime.NewWaitForEventNodeWrapper},
+	}, plugins.NewPluginRegistry())
+	server.flowRuntime = runtime.NewFlowRuntimeWithStoreAndSecrets(mockFlowRegistry, yamlLoader, storage.NewMemoryExecutionStore(), server.secretVault)
+
+	flowDef := &runtime.Flow{
+		ID:   "payment-flow",
+		YAML: "metadata:\n  name: payment-flow\nnodes:\n  payment:\n    type: wait_for_event\n    params:\n      event: payment.completed\n      key: \"${input.order_id}\"\n      timeout: 1h\n    next:\n      default: finish\n  finish:\n    type: base\n",
+	}
+	mockFlowRegistry.On("GetFlow", accountID, "payment-flow").Return(flowDef, nil)
+
+	rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/flows/payment-flow/run", map[string]interface{}{
+		"input": map[string]interface{}{"order_id": "A-100"},
+	})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var execution map[string]interface{}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&execution))
+	executionID := execution["execution_id"].(string)
+	assert.Eventually(t, func() bool {
+		status, err := server.flowRuntime.GetStatus(executionID)
+		return err == nil && status.Status == "waiting"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	deliver := func(key interface{}) []interface{} {
+		rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/events/payment.completed", map[string]interface{}{
+			"key":     key,
+			"payload": map[string]interface{}{"paid": true},
+		})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response map[string]interface{}
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		return response["executions"].([]interface{})
+	}
+
+	t.Run("requires authentication", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/events/payment.completed", nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		assert.Empty(t, deliver("A-200"))
+	})
+
+	t.Run("matching key", func(t *testing.T) {
+		assert.Equal(t, []interface{}{executionID}, deliver("A-100"))
+		assert.Eventually(t, func() bool {
+			status, err := server.flowRuntime.GetStatus(executionID)
+			return err == nil && status.Status == "completed"
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("event after resume", func(t *testing.T) {
+		assert.Empty(t, deliver("A-100"))
+	})
+
+	t.Run("numeric key", func(t *testing.T) {
+		// JSON numbers decode as floats, which must not turn into 1e+06
+		rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/flows/payment-flow/run", map[string]interface{}{
+			"input": map[string]interface{}{"order_id": 1000000},
+		})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var execution map[string]interface{}
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&execution))
+		executionID := execution["execution_id"].(string)
+		assert.Eventually(t, func() bool {
+			status, err := server.flowRuntime.GetStatus(executionID)
+			return err == nil && status.Status == "waiting"
+		}, 5*time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, []interface{}{executionID}, deliver("1000000"))
+	})
+}
+
+func TestFlowSchemaAPI(t *testing.T) {
+	server, mockFlowRegistry, _, accountID := setupTestServer()
+
+	flowYAML := "metadata:\n  name: signup\n  inputs:\n    type: object\n    required: [email]\n    properties:\n      email:\n        type: string\n      seats:\n        type: integer\n  outputs:\n    type: object\nnodes:\n  start:\n    type: base\n"
+	mockFlowRegistry.On("GetFlow", accountID, "signup").Return(&runtime.Flow{ID: "signup", YAML: flowYAML}, nil)
+	mockFlowRegistry.On("Get", accountID, "signup").Return(flowYAML, nil)
+	mockFlowRegistry.On("Get", accountID, "missing").Return("", fmt.Errorf("flow not found"))
+
+	t.Run("publishes schemas", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "GET", "/api/v1/flows/signup/schema", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{
+			"flow_id": "signup",
+			"inputs": {
+				"type": "object",
+				"required": ["email"],
+				"properties": {"email": {"type": "string"}, "seats": {"type": "integer"}}
+			},
+			"outputs": {"type": "object"}
+		}`, rr.Body.String())
+	})
+
+	t.Run("unknown flow", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "GET", "/api/v1/flows/missing/schema", nil)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("rejects input with field errors", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/flows/signup/run", map[string]interface{}{
+			"input": map[string]interface{}{"seats": 1.5},
+		})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+		var response struct {
+			Error  string              `json:"error"`
+			Fields []loader.FieldError `json:"fields"`
+		}
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		assert.Equal(t, []loader.FieldError{
+			{Field: "email", Message: "is required"},
+			{Field: "seats", Message: "must be an integer"},
+		}, response.Fields)
+		assert.Contains(t, response.Error, "flow inputs schema not matched")
+	})
+
+	t.Run("accepts matching input", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/flows/signup/run", map[string]interface{}{
+			"input": map[string]interface{}{"email": "a@example.com", "seats": 2},
+		})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+	})
+}
+
+func TestFlowVarsAPI(t *testing.T) {
+	server, mockFlowRegistry, _, accountID := setupTestServer()
+
+	// Templates are evaluated with the secret vault of the server
+	yamlLoader := loader.NewYAMLLoader(map[string]plugins.NodeFactory{
+		"event.publish": &RuntimeNodeFactoryAdapter{factory: runtime.NewEventPublishNodeWrapper},
+	}, plugins.NewPluginRegistry())
+	server.flowRuntime = runtime.NewFlowRuntimeWithStoreAndSecrets(mockFlowRegistry, yamlLoader, storage.NewMemoryExecutionStore(), server.secretVault)
+
+	flowDef := &runtime.Flow{
+		ID:   "notify",
+		YAML: "metadata:\n  name: notify\nvars:\n  topic: orders.dev\nenvironments:\n  prod:\n    vars:\n      topic: orders\nnodes:\n  publish:\n    type: event.publish\n    params:\n      event: \"${vars.topic}\"\n      key: \"${input.order_id}\"\n",
+	}
+	mockFlowRegistry.On("GetFlow", accountID, "notify").Return(flowDef, nil)
+
+	run := func(environment string) *httptest.ResponseRecorder {
+		return makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/flows/notify/run?wait=5s", map[string]interface{}{
+			"input":       map[string]interface{}{"order_id": "A-100"},
+			"environment": environment,
+		})
+	}
+
+	for environment, topic := range map[string]string{"": "orders.dev", "prod": "orders"} {
+		t.Run("environment "+environment, func(t *testing.T) {
+			rr := run(environment)
+			assert.Equal(t, http.StatusOK, rr.Code)
+
+			var execution map[string]interface{}
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&execution))
+			assert.Equal(t, "completed", execution["status"])
+			results := execution["results"].(map[string]interface{})
+			assert.Equal(t, topic, results["event"])
+			assert.Equal(t, "A-100", results["key"])
+			if environment != "" {
+				assert.Equal(t, environment, execution["metadata"].(map[string]interface{})[runtime.EnvironmentKey])
+			}
+		})
+	}
+
+	t.Run("unknown environment", func(t *testing.T) {
+		rr := run("qa")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), `flow declares no environment "qa"`)
+	})
+}
+
+func TestFlowExecutionAPI_Wait(t *testing.T) {
+	server, mockFlowRegistry, _, accountID := setupTestServer()
+
+	flowDef := &runtime.Flow{
+		ID:   "test-flow",
+		YAML: "metadata:\n  name: test-flow\nnodes:\n  start:\n    type: base\n",
+	}
+	mockFlowRegistry.On("GetFlow", accountID, "test-flow").Return(flowDef, nil)
+
+	t.Run("returns the final status", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/flows/test-flow/run?wait=5s", map[string]interface{}{})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response map[string]interface{}
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		assert.Equal(t, "completed", response["status"])
+		assert.NotEmpty(t, response["id"])
+		assert.Contains(t, response, "results")
+	})
+
+	t.Run("rejects invalid durations", func(t *testing.T) {
+		rr := makeAuthenticatedRequest(server, accountID, "POST", "/api/v1/flows/test-flow/run?wait=soon", map[string]interface{}{})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
 func TestExecutionStatusAPI(t *testing.T) {
 	server, mockFlowRegistry, _, accountID := setupTestServer()
 
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowrunner/pkg/loader"
+	"github.com/tcmartin/flowrunner/pkg/plugins"
+	"github.com/tcmartin/flowrunner/pkg/registry"
+	"github.com/tcmartin/flowrunner/pkg/runtime"
+	"github.com/tcmartin/flowrunner/pkg/services"
+	"github.com/tcmartin/flowrunner/pkg/storage"
+)
+
+// TestParallelLLMFlow runs the flow of demos/test_parallel_llm_flow.go against
+// a stub LLM API: two LLM branches analyze a topic in parallel and a third LLM
+// node synthesizes their answers. Run it with -race to check that the
+// branches never share state.
+func TestParallelLLMFlow(t *testing.T) {
+	var mu sync.Mutex
+	var prompts []string
+	llmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		prompt := request.Messages[len(request.Messages)-1].Content
+
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		mu.Unlock()
+
+		answer := "synthesis"
+		switch {
+		case strings.HasPrefix(prompt, "Technical"):
+			answer = "technical analysis"
+		case strings.HasPrefix(prompt, "Business"):
+			answer = "business impact"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":    "chatcmpl-test",
+			"model": "stub",
+			"choices": []interface{}{map[string]interface{}{
+				"index":         0,
+				"message":       map[string]interface{}{"role": "assistant", "content": answer},
+				"finish_reason": "stop",
+			}},
+		})
+	}))
+	defer llmServer.Close()
+
+	memoryProvider, err := storage.NewProvider(storage.ProviderConfig{
+		Type: storage.MemoryProviderType,
+	})
+	require.NoError(t, err)
+
+	encKey, err := services.GenerateEncryptionKey()
+	require.NoError(t, err)
+	secretVault, err := services.NewExtendedSecretVaultService(memoryProvider.GetSecretStore(), encKey)
+	require.NoError(t, err)
+
+	nodeFactories := make(map[string]plugins.NodeFactory)
+	for nodeType, factory := range runtime.CoreNodeTypes() {
+		nodeFactories[nodeType] = &RuntimeNodeFactoryAdapter{factory: factory}
+	}
+	yamlLoader := loader.NewYAMLLoader(nodeFactories, plugins.NewPluginRegistry())
+	flowRegistry := registry.NewFlowRegistry(memoryProvider.GetFlowStore(), registry.FlowRegistryOptions{
+		YAMLLoader: yamlLoader,
+	})
+
+	llmNode := func(nodeID, prompt, next string) string {
+		node := fmt.Sprintf(`
+  %s:
+    type: llm
+    params:
+      provider: generic
+      api_key: test-key
+      model: stub
+      prompt: "%s"
+      options:
+        base_url: %s`, nodeID, prompt, llmServer.URL)
+		if next != "" {
+			node += fmt.Sprintf(`
+    next:
+      default: %s`, next)
+		}
+		return node
+	}
+	flowYAML := `
+metadata:
+  name: "Parallel LLM Flow"
+  version: "1.0.0"
+
+nodes:
+  fan_out:
+    type: split
+    next:
+      technical: technical
+      business: business` +
+		llmNode("technical", "${'Technical analysis of ' + shared.topic}", "gather") +
+		llmNode("business", "${'Business impact of ' + shared.topic}", "gather") + `
+  gather:
+    type: join
+    params:
+      wait: all
+    next:
+      default: synthesis` +
+		llmNode("synthesis", "${'Combine ' + shared._outputs.technical.content + ' and ' + shared._outputs.business.content}", "") + "\n"
+
+	flowID, err := flowRegistry.Create("test-account", "parallel-llm-flow", flowYAML)
+	require.NoError(t, err)
+
+	flowRuntime := runtime.NewFlowRuntimeWithStoreAndSecrets(&FlowRegistryAdapter{registry: flowRegistry}, yamlLoader, memoryProvider.GetExecutionStore(), secretVault)
+
+	executionID, err := flowRuntime.Execute("test-account", flowID, map[string]interface{}{
+		"topic": "Artificial Intelligence in Healthcare",
+	})
+	require.NoError(t, err)
+
+	var status runtime.ExecutionStatus
+	require.Eventually(t, func() bool {
+		status, err = flowRuntime.GetStatus(executionID)
+		return err == nil && status.Status != "running"
+	}, 10*time.Second, 20*time.Millisecond)
+	require.Equal(t, "completed", status.Status, status.Error)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ElementsMatch(t, []string{
+		"Technical analysis of Artificial Intelligence in Healthcare",
+		"Business impact of Artificial Intelligence in Healthcare",
+		"Combine technical analysis and business impact",
+	}, prompts)
+}
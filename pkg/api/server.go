@@ -3,14 +3,18 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/tcmartin/flowlib"
 	"github.com/tcmartin/flowrunner/pkg/auth"
 	"github.com/tcmartin/flowrunner/pkg/config"
+	"github.com/tcmartin/flowrunner/pkg/loader"
 	"github.com/tcmartin/flowrunner/pkg/middleware"
 	"github.com/tcmartin/flowrunner/pkg/plugins"
 	"github.com/tcmartin/flowrunner/pkg/registry"
@@ -59,6 +63,32 @@ func NewServerWithRuntime(cfg *config.Config, flowRegistry registry.FlowRegistry
 		wsManager:      NewWebSocketManager(flowRuntime),
 	}
 
+	// Apply the configured execution limits if the runtime supports them
+	if limiter, ok := flowRuntime.(runtime.ConcurrencyLimiter); ok && cfg != nil {
+		limiter.SetConcurrencyLimits(runtime.ConcurrencyLimits{
+			MaxConcurrent:           cfg.Execution.MaxConcurrent,
+			MaxConcurrentPerAccount: cfg.Execution.MaxConcurrentPerAccount,
+			MaxConcurrentPerFlow:    cfg.Execution.MaxConcurrentPerFlow,
+		})
+	}
+	if limiter, ok := flowRuntime.(runtime.VisitLimiter); ok && cfg != nil {
+		limiter.SetVisitLimits(flowlib.VisitLimits{
+			MaxVisits:        cfg.Execution.MaxNodeVisits,
+			MaxVisitsPerNode: cfg.Execution.MaxVisitsPerNode,
+		})
+	}
+	// Signed links in approval notifications need the public URL of the server
+	if linker, ok := flowRuntime.(runtime.SignalLinker); ok && cfg != nil && cfg.Server.PublicURL != "" && cfg.Auth.JWTSecret != "" {
+		linker.SetSignalLinks(runtime.SignalLinks{
+			BaseURL: cfg.Server.PublicURL,
+			Key:     []byte(cfg.Auth.JWTSecret),
+		})
+	}
+	// Resume interrupted executions only now, so that they respect the limits
+	if starter, ok := flowRuntime.(runtime.Starter); ok {
+		starter.Start()
+	}
+
 	s.setupRoutes()
 	return s
 }
@@ -118,6 +148,10 @@ func (s *Server) setupRoutes() {
 	accounts := api.PathPrefix("/accounts").Subrouter()
 	accounts.HandleFunc("", s.handleCreateAccount).Methods(http.MethodPost, http.MethodOptions)
 
+	// Signals carry either a signed link token or the credentials of the account
+	api.Handle("/executions/{id}/signals/{name}", signedOrAuthenticated(authMiddleware, http.HandlerFunc(s.handleSignalExecution))).
+		Methods(http.MethodGet, http.MethodPost, http.MethodOptions)
+
 	// Authenticated routes
 	authenticated := api.PathPrefix("").Subrouter()
 	authenticated.Use(authMiddleware.Authenticate)
@@ -137,6 +171,7 @@ func (s *Server) setupRoutes() {
 	flows.HandleFunc("/{id}", s.handleUpdateFlow).Methods(http.MethodPut, http.MethodOptions)
 	flows.HandleFunc("/{id}", s.handleDeleteFlow).Methods(http.MethodDelete, http.MethodOptions)
 	flows.HandleFunc("/{id}/metadata", s.handleUpdateFlowMetadata).Methods(http.MethodPatch, http.MethodOptions)
+	flows.HandleFunc("/{id}/schema", s.handleGetFlowSchema).Methods(http.MethodGet, http.MethodOptions)
 	flows.HandleFunc("/search", s.handleSearchFlows).Methods(http.MethodPost, http.MethodOptions)
 
 	// Flow execution routes
@@ -146,8 +181,13 @@ func (s *Server) setupRoutes() {
 	executions := authenticated.PathPrefix("/executions").Subrouter()
 	executions.HandleFunc("/{id}", s.handleGetExecution).Methods(http.MethodGet, http.MethodOptions)
 	executions.HandleFunc("/{id}/logs", s.handleGetExecutionLogs).Methods(http.MethodGet, http.MethodOptions)
+	executions.HandleFunc("/{id}/trace", s.handleGetExecutionTrace).Methods(http.MethodGet, http.MethodOptions)
+	executions.HandleFunc("/{id}/replay", s.handleReplayExecution).Methods(http.MethodPost, http.MethodOptions)
 	executions.HandleFunc("/{id}", s.handleCancelExecution).Methods(http.MethodDelete, http.MethodOptions)
 
+	// Event routes
+	authenticated.HandleFunc("/events/{name}", s.handleDeliverEvent).Methods(http.MethodPost, http.MethodOptions)
+
 	// WebSocket route for real-time execution updates (authenticated)
 	authenticated.HandleFunc("/ws", s.handleWebSocket).Methods(http.MethodGet)
 
@@ -335,6 +375,37 @@ func (s *Server) handleGetFlow(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(content))
 }
 
+// handleGetFlowSchema handles retrieving the input and output schemas of a flow
+func (s *Server) handleGetFlowSchema(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := middleware.GetAccountID(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	flowID := vars["id"]
+
+	content, err := s.flowRegistry.Get(accountID, flowID)
+	if err != nil {
+		http.Error(w, "Flow not found", http.StatusNotFound)
+		return
+	}
+
+	flowDef, err := loader.ParseFlowDefinition(content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"flow_id": flowID,
+		"inputs":  flowDef.Metadata.Inputs,
+		"outputs": flowDef.Metadata.Outputs,
+	})
+}
+
 // handleUpdateFlow handles updating a flow
 func (s *Server) handleUpdateFlow(w http.ResponseWriter, r *http.Request) {
 	accountID, ok := middleware.GetAccountID(r)
@@ -436,6 +507,9 @@ func (s *Server) handleSearchFlows(w http.ResponseWriter, r *http.Request) {
 
 // Flow execution handlers
 
+// maxRunWait bounds how long a run request waits for its execution to finish
+const maxRunWait = 5 * time.Minute
+
 // handleRunFlow handles executing a flow
 func (s *Server) handleRunFlow(w http.ResponseWriter, r *http.Request) {
 	if s.flowRuntime == nil {
@@ -454,6 +528,17 @@ func (s *Server) handleRunFlow(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		Input map[string]interface{} `json:"input,omitempty"`
+
+		// Debug runs the execution in debug mode, controlled over the WebSocket
+		Debug *struct {
+			Breakpoints []string `json:"breakpoints,omitempty"`
+		} `json:"debug,omitempty"`
+
+		// DryRun simulates the nodes with side effects
+		DryRun bool `json:"dry_run,omitempty"`
+
+		// Environment selects the overlay applied to the flow vars
+		Environment string `json:"environment,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -461,17 +546,79 @@ func (s *Server) handleRunFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// ?wait=30s answers with the final status if the execution finishes in time
+	var wait time.Duration
+	if waitParam := r.URL.Query().Get("wait"); waitParam != "" {
+		var err error
+		wait, err = time.ParseDuration(waitParam)
+		if err != nil || wait <= 0 {
+			http.Error(w, "Invalid wait duration", http.StatusBadRequest)
+			return
+		}
+		wait = min(wait, maxRunWait)
+	}
+
 	// Initialize input if nil
 	if req.Input == nil {
 		req.Input = make(map[string]interface{})
 	}
 
-	executionID, err := s.flowRuntime.Execute(accountID, flowID, req.Input)
+	// Retried requests carrying the same key get the original execution
+	options := runtime.ExecuteOptions{
+		IdempotencyKey: r.Header.Get("Idempotency-Key"),
+		DryRun:         req.DryRun,
+		Environment:    req.Environment,
+	}
+	if s.config != nil && s.config.Execution.IdempotencyWindow > 0 {
+		options.IdempotencyWindow = time.Duration(s.config.Execution.IdempotencyWindow) * time.Second
+	}
+	if req.Debug != nil {
+		options.Debug = true
+		options.Breakpoints = req.Debug.Breakpoints
+	}
+
+	var executionID string
+	var err error
+	if executor, ok := s.flowRuntime.(runtime.OptionsExecutor); ok {
+		executionID, err = executor.ExecuteWithOptions(accountID, flowID, req.Input, options)
+	} else if options.Debug || options.DryRun || options.Environment != "" {
+		http.Error(w, "Execution options not available", http.StatusNotImplemented)
+		return
+	} else {
+		// Runtimes without options have no store to remember idempotency keys in
+		executionID, err = s.flowRuntime.Execute(accountID, flowID, req.Input)
+	}
+	var schemaErr *runtime.SchemaError
+	if errors.As(err, &schemaErr) {
+		// Field-level errors let clients point at the offending form fields
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  err.Error(),
+			"fields": schemaErr.Fields,
+		})
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if waiter, ok := s.flowRuntime.(runtime.ExecutionWaiter); ok && wait > 0 {
+		// Keep the connection open past the server's write timeout
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 5*time.Second))
+
+		ctx, cancel := context.WithTimeout(r.Context(), wait)
+		status, err := waiter.Wait(ctx, executionID)
+		cancel()
+		if err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(executionStatusResponse(status))
+			return
+		}
+		// Still running, answer like an asynchronous run
+	}
+
 	response := map[string]interface{}{
 		"execution_id": executionID,
 		"status":       "running",
@@ -498,6 +645,12 @@ func (s *Server) handleGetExecution(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(executionStatusResponse(status))
+}
+
+// executionStatusResponse builds the JSON body describing an execution status
+func executionStatusResponse(status runtime.ExecutionStatus) map[string]interface{} {
     // Backward/forward compatibility: include both 'results' and legacy 'result'
     resp := map[string]interface{}{
         "id":           status.ID,
@@ -511,13 +664,14 @@ func (s *Server) handleGetExecution(w http.ResponseWriter, r *http.Request) {
         "current_node": status.CurrentNode,
         "metadata":     status.Metadata,
     }
+    if status.QueuePosition > 0 {
+        resp["queue_position"] = status.QueuePosition
+    }
     // Legacy alias expected by some tests
     if status.Results != nil {
         resp["result"] = status.Results
     }
-
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(resp)
+    return resp
 }
 
 // handleGetExecutionLogs handles getting execution logs
@@ -540,6 +694,253 @@ func (s *Server) handleGetExecutionLogs(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(logs)
 }
 
+// handleGetExecutionTrace handles getting the per-node trace of an execution
+func (s *Server) handleGetExecutionTrace(w http.ResponseWriter, r *http.Request) {
+	tracer, ok := s.flowRuntime.(runtime.ExecutionTracer)
+	if !ok {
+		http.Error(w, "Execution traces not available", http.StatusNotImplemented)
+		return
+	}
+
+	accountID, ok := middleware.GetAccountID(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	executionID := vars["id"]
+
+	if _, err := s.flowRuntime.GetStatus(executionID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Executions of other accounts are reported like unknown ones
+	trace, err := tracer.GetTrace(accountID, executionID)
+	if errors.Is(err, runtime.ErrTraceNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trace)
+}
+
+// handleReplayExecution handles starting a new execution from a node of an
+// earlier one
+func (s *Server) handleReplayExecution(w http.ResponseWriter, r *http.Request) {
+	replayer, ok := s.flowRuntime.(runtime.ExecutionReplayer)
+	if !ok {
+		http.Error(w, "Execution replay not available", http.StatusNotImplemented)
+		return
+	}
+
+	accountID, ok := middleware.GetAccountID(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	executionID := vars["id"]
+
+	var req struct {
+		FromNode string                 `json:"from_node"`
+		State    map[string]interface{} `json:"state,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.FromNode == "" {
+		http.Error(w, "from_node is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.flowRuntime.GetStatus(executionID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	replayID, err := replayer.Replay(accountID, executionID, runtime.ReplayOptions{FromNode: req.FromNode, State: req.State})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"execution_id": replayID,
+		"status":       "running",
+		"replay_of":    executionID,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// signedOrAuthenticated passes requests that carry a signed link token on to
+// next, which verifies the token, and authenticates all other requests
+func signedOrAuthenticated(authMiddleware *middleware.AuthMiddleware, next http.Handler) http.Handler {
+	authenticated := authMiddleware.Authenticate(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		authenticated.ServeHTTP(w, r)
+	})
+}
+
+// signalConfirmationPage asks visitors of a signed link to confirm the
+// decision, which its form posts back to the link
+var signalConfirmationPage = template.Must(template.New("signal").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Confirm {{.Decision}}</title></head>
+<body>
+<p>Confirm the {{.Decision}} decision for the {{.Signal}} signal of execution {{.ExecutionID}}.</p>
+<form method="post" action="{{.Action}}"><button type="submit">Confirm {{.Decision}}</button></form>
+</body>
+</html>
+`))
+
+// handleSignalExecution handles signaling an execution that waits in an
+// approval node. Signed links send the decision in the query, API clients
+// in the body. Opening a signed link only renders a confirmation page, so
+// that link scanners and prefetching cannot decide the approval.
+func (s *Server) handleSignalExecution(w http.ResponseWriter, r *http.Request) {
+	signaler, ok := s.flowRuntime.(runtime.ExecutionSignaler)
+	if !ok {
+		http.Error(w, "Execution signals not available", http.StatusNotImplemented)
+		return
+	}
+
+	vars := mux.Vars(r)
+	executionID := vars["id"]
+	signal := runtime.Signal{
+		Name:  vars["name"],
+		Token: r.URL.Query().Get("token"),
+	}
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		signalConfirmationPage.Execute(w, map[string]string{
+			"Decision":    r.URL.Query().Get("decision"),
+			"Signal":      signal.Name,
+			"ExecutionID": executionID,
+			"Action":      r.URL.RequestURI(),
+		})
+		return
+	}
+
+	// Confirmation forms post without a JSON body
+	if r.ContentLength != 0 && r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		var req struct {
+			Decision string                 `json:"decision"`
+			Payload  map[string]interface{} `json:"payload,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		signal.Decision = req.Decision
+		signal.Payload = req.Payload
+	}
+	if signal.Decision == "" {
+		signal.Decision = r.URL.Query().Get("decision")
+	}
+	if signal.Token == "" {
+		accountID, ok := middleware.GetAccountID(r)
+		if !ok {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		signal.AccountID = accountID
+	}
+
+	if err := signaler.Signal(executionID, signal); err != nil {
+		switch {
+		case errors.Is(err, runtime.ErrWaitNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, runtime.ErrSignalUnauthorized):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, runtime.ErrInvalidSignal):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := map[string]interface{}{
+		"execution_id": executionID,
+		"signal":       signal.Name,
+		"decision":     signal.Decision,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleDeliverEvent handles delivering an event to the executions of the
+// account that wait for it
+func (s *Server) handleDeliverEvent(w http.ResponseWriter, r *http.Request) {
+	deliverer, ok := s.flowRuntime.(runtime.EventDeliverer)
+	if !ok {
+		http.Error(w, "Events not available", http.StatusNotImplemented)
+		return
+	}
+
+	accountID, ok := middleware.GetAccountID(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Key     interface{}            `json:"key"`
+		Payload map[string]interface{} `json:"payload,omitempty"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	event := runtime.Event{
+		Name:      mux.Vars(r)["name"],
+		Key:       runtime.EventKey(req.Key),
+		Payload:   req.Payload,
+		AccountID: accountID,
+	}
+
+	delivered, err := deliverer.DeliverEvent(event)
+	if err != nil {
+		if errors.Is(err, runtime.ErrInvalidEvent) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"event":      event.Name,
+		"key":        event.Key,
+		"executions": delivered,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // handleCancelExecution handles canceling an execution
 func (s *Server) handleCancelExecution(w http.ResponseWriter, r *http.Request) {
 	if s.flowRuntime == nil {
@@ -23,6 +23,14 @@ type WebSocketManager struct {
 	
 	// mutex for thread-safe access
 	mu sync.RWMutex
+
+	// writeLocks serialize the writes to each connection, which
+	// gorilla/websocket allows from one goroutine at a time
+	writeLocks   map[*websocket.Conn]*sync.Mutex
+	writeLocksMu sync.Mutex
+
+	// closedWriteLock serializes the late writes to closed connections
+	closedWriteLock sync.Mutex
 	
 	// flowRuntime for accessing execution data
 	flowRuntime runtime.FlowRuntime
@@ -38,7 +46,7 @@ type ConnectionMetadata struct {
 
 // ExecutionUpdate represents a real-time update for a flow execution
 type ExecutionUpdate struct {
-	Type        string                 `json:"type"`        // "log", "status", "complete", "error"
+	Type        string                 `json:"type"`        // "log", "status", "paused", "complete", "error"
 	ExecutionID string                 `json:"execution_id"`
 	Timestamp   time.Time              `json:"timestamp"`
 	NodeID      string                 `json:"node_id,omitempty"`
@@ -46,11 +54,12 @@ type ExecutionUpdate struct {
 	Data        map[string]interface{} `json:"data,omitempty"`
 	Status      *runtime.ExecutionStatus `json:"status,omitempty"`
 	Log         *runtime.ExecutionLog    `json:"log,omitempty"`
+	Pause       *runtime.DebugPause      `json:"pause,omitempty"`
 }
 
 // WebSocketMessage represents incoming WebSocket messages
 type WebSocketMessage struct {
-	Type        string `json:"type"`        // "subscribe", "unsubscribe", "ping"
+	Type        string `json:"type"`        // "subscribe", "unsubscribe", "ping", "step", "continue", "abort"
 	ExecutionID string `json:"execution_id,omitempty"`
 }
 
@@ -68,6 +77,7 @@ func NewWebSocketManager(flowRuntime runtime.FlowRuntime) *WebSocketManager {
 		},
 		connections:    make(map[string]map[*websocket.Conn]bool),
 		connectionMeta: make(map[*websocket.Conn]*ConnectionMetadata),
+		writeLocks:     make(map[*websocket.Conn]*sync.Mutex),
 		flowRuntime:    flowRuntime,
 	}
 }
@@ -80,6 +90,15 @@ func (wsm *WebSocketManager) HandleWebSocket(w http.ResponseWriter, r *http.Requ
 		log.Printf("WebSocket upgrade failed: %v", err)
 		return
 	}
+
+	wsm.writeLocksMu.Lock()
+	wsm.writeLocks[conn] = &sync.Mutex{}
+	wsm.writeLocksMu.Unlock()
+	defer func() {
+		wsm.writeLocksMu.Lock()
+		delete(wsm.writeLocks, conn)
+		wsm.writeLocksMu.Unlock()
+	}()
 	defer conn.Close()
 
 	// Store connection metadata
@@ -159,6 +178,10 @@ func (wsm *WebSocketManager) handleMessage(conn *websocket.Conn, msg *WebSocketM
 			Type:      "pong",
 			Timestamp: time.Now(),
 		})
+	case "step", "continue", "abort":
+		if msg.ExecutionID != "" {
+			wsm.sendDebugCommand(conn, msg.ExecutionID, accountID, runtime.DebugCommand(msg.Type))
+		}
 	default:
 		log.Printf("Unknown WebSocket message type: %s", msg.Type)
 	}
@@ -189,6 +212,19 @@ func (wsm *WebSocketManager) subscribeToExecution(conn *websocket.Conn, executio
 				Status:      &status,
 			})
 		}
+
+		// A paused debug execution sent its pause before the client subscribed
+		if debugger, ok := wsm.flowRuntime.(runtime.ExecutionDebugger); ok {
+			if pause, paused := debugger.GetPause(executionID); paused {
+				wsm.sendMessage(conn, ExecutionUpdate{
+					Type:        "paused",
+					ExecutionID: executionID,
+					Timestamp:   time.Now(),
+					NodeID:      pause.NodeID,
+					Pause:       &pause,
+				})
+			}
+		}
 	}
 
 	// Add connection to execution subscriptions
@@ -208,6 +244,30 @@ func (wsm *WebSocketManager) subscribeToExecution(conn *websocket.Conn, executio
 	go wsm.monitorExecution(executionID)
 }
 
+// sendDebugCommand passes a command to a paused debug execution, reporting
+// failures to the connection
+func (wsm *WebSocketManager) sendDebugCommand(conn *websocket.Conn, executionID, accountID string, command runtime.DebugCommand) {
+	debugger, ok := wsm.flowRuntime.(runtime.ExecutionDebugger)
+	if !ok {
+		wsm.sendMessage(conn, ExecutionUpdate{
+			Type:        "error",
+			ExecutionID: executionID,
+			Timestamp:   time.Now(),
+			Message:     "Debugging is not available",
+		})
+		return
+	}
+
+	if err := debugger.Debug(accountID, executionID, command); err != nil {
+		wsm.sendMessage(conn, ExecutionUpdate{
+			Type:        "error",
+			ExecutionID: executionID,
+			Timestamp:   time.Now(),
+			Message:     err.Error(),
+		})
+	}
+}
+
 // unsubscribeFromExecution unsubscribes a connection from execution updates
 func (wsm *WebSocketManager) unsubscribeFromExecution(conn *websocket.Conn, executionID string) {
 	wsm.mu.Lock()
@@ -244,6 +304,30 @@ func (wsm *WebSocketManager) monitorExecution(executionID string) {
 
 	// Monitor logs and broadcast updates
 	for log := range logsChan {
+		if log.Status != nil {
+			// Node transitions and other status changes
+			wsm.broadcastToExecution(executionID, ExecutionUpdate{
+				Type:        "status",
+				ExecutionID: executionID,
+				Timestamp:   log.Timestamp,
+				NodeID:      log.NodeID,
+				Status:      log.Status,
+			})
+			continue
+		}
+		if log.Pause != nil {
+			// A debug execution waits for a command
+			wsm.broadcastToExecution(executionID, ExecutionUpdate{
+				Type:        "paused",
+				ExecutionID: executionID,
+				Timestamp:   log.Timestamp,
+				NodeID:      log.NodeID,
+				Message:     log.Message,
+				Pause:       log.Pause,
+			})
+			continue
+		}
+
 		update := ExecutionUpdate{
 			Type:        "log",
 			ExecutionID: executionID,
@@ -266,7 +350,7 @@ func (wsm *WebSocketManager) monitorExecution(executionID string) {
 		wsm.broadcastToExecution(executionID, update)
 
 		// If execution is complete, send completion event
-		if status.Status == "completed" || status.Status == "failed" || status.Status == "canceled" {
+		if status.Status == "completed" || status.Status == "failed" || status.Status == "canceled" || status.Status == "timeout" {
 			update := ExecutionUpdate{
 				Type:        "complete",
 				ExecutionID: executionID,
@@ -301,12 +385,27 @@ func (wsm *WebSocketManager) broadcastToExecution(executionID string, update Exe
 	}
 }
 
-// sendMessage sends a message to a WebSocket connection
+// writeLock returns the mutex that serializes the writes to a connection
+func (wsm *WebSocketManager) writeLock(conn *websocket.Conn) *sync.Mutex {
+	wsm.writeLocksMu.Lock()
+	defer wsm.writeLocksMu.Unlock()
+	if lock, exists := wsm.writeLocks[conn]; exists {
+		return lock
+	}
+	return &wsm.closedWriteLock
+}
+
+// sendMessage sends a message to a WebSocket connection. Execution monitors
+// and the reader of the connection both send, so writes are serialized.
 func (wsm *WebSocketManager) sendMessage(conn *websocket.Conn, update ExecutionUpdate) {
+	lock := wsm.writeLock(conn)
+	lock.Lock()
 	// Set write deadline
 	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-	
-	if err := conn.WriteJSON(update); err != nil {
+	err := conn.WriteJSON(update)
+	lock.Unlock()
+
+	if err != nil {
 		log.Printf("Failed to send WebSocket message: %v", err)
 		// Remove the connection on write error
 		wsm.removeConnection(conn)
@@ -345,8 +444,12 @@ func (wsm *WebSocketManager) pingRoutine(conn *websocket.Conn) {
 	for {
 		select {
 		case <-ticker.C:
+			lock := wsm.writeLock(conn)
+			lock.Lock()
 			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			err := conn.WriteMessage(websocket.PingMessage, nil)
+			lock.Unlock()
+			if err != nil {
 				log.Printf("Failed to send ping: %v", err)
 				wsm.removeConnection(conn)
 				return
@@ -23,6 +23,11 @@ func (m *MockFlowRuntimeForWebSocket) Execute(accountID string, flowID string, i
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockFlowRuntimeForWebSocket) ExecuteWithOptions(accountID string, flowID string, input map[string]interface{}, options runtime.ExecuteOptions) (string, error) {
+	args := m.Called(accountID, flowID, input, options)
+	return args.String(0), args.Error(1)
+}
+
 func (m *MockFlowRuntimeForWebSocket) GetStatus(executionID string) (runtime.ExecutionStatus, error) {
 	args := m.Called(executionID)
 	return args.Get(0).(runtime.ExecutionStatus), args.Error(1)
@@ -149,6 +154,63 @@ func TestWebSocketManager_SubscribeToExecution(t *testing.T) {
 	mockRuntime.AssertExpectations(t)
 }
 
+func TestWebSocketManager_ForwardsStatusChanges(t *testing.T) {
+	mockRuntime := &MockFlowRuntimeForWebSocket{}
+	wsManager := NewWebSocketManager(mockRuntime)
+
+	testStatus := runtime.ExecutionStatus{
+		ID:        "test-execution",
+		FlowID:    "test-flow",
+		Status:    "running",
+		StartTime: time.Now(),
+	}
+	progressed := testStatus
+	progressed.CurrentNode = "second"
+	progressed.Progress = 50.0
+
+	// The runtime pushes status changes as "status" log entries
+	logChan := make(chan runtime.ExecutionLog, 1)
+	logChan <- runtime.ExecutionLog{
+		Timestamp: time.Now(),
+		NodeID:    "second",
+		Level:     "status",
+		Message:   "Execution status changed",
+		Status:    &progressed,
+	}
+	close(logChan)
+
+	mockRuntime.On("GetStatus", "test-execution").Return(testStatus, nil)
+	mockRuntime.On("SubscribeToLogs", "test-execution").Return((<-chan runtime.ExecutionLog)(logChan), nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wsManager.HandleWebSocket(w, r, "test-account")
+	}))
+	defer server.Close()
+
+	u := "ws" + strings.TrimPrefix(server.URL, "http") + "/"
+	ws, _, err := websocket.DefaultDialer.Dial(u, nil)
+	assert.NoError(t, err)
+	defer ws.Close()
+
+	err = ws.WriteJSON(WebSocketMessage{Type: "subscribe", ExecutionID: "test-execution"})
+	assert.NoError(t, err)
+
+	// The current status on subscription, then the pushed change
+	var update ExecutionUpdate
+	assert.NoError(t, ws.ReadJSON(&update))
+	assert.Equal(t, "status", update.Type)
+
+	update = ExecutionUpdate{}
+	assert.NoError(t, ws.ReadJSON(&update))
+	assert.Equal(t, "status", update.Type)
+	assert.Equal(t, "second", update.NodeID)
+	if assert.NotNil(t, update.Status) {
+		assert.Equal(t, "second", update.Status.CurrentNode)
+		assert.Equal(t, 50.0, update.Status.Progress)
+	}
+	assert.Nil(t, update.Log)
+}
+
 func TestWebSocketManager_UnsubscribeFromExecution(t *testing.T) {
 	mockRuntime := &MockFlowRuntimeForWebSocket{}
 	wsManager := NewWebSocketManager(mockRuntime)
@@ -296,3 +358,107 @@ func TestWebSocketManager_ExecutionNotFound(t *testing.T) {
 	
 	mockRuntime.AssertExpectations(t)
 }
+
+// MockDebugRuntimeForWebSocket is a runtime that supports debug executions
+type MockDebugRuntimeForWebSocket struct {
+	MockFlowRuntimeForWebSocket
+}
+
+func (m *MockDebugRuntimeForWebSocket) Debug(accountID, executionID string, command runtime.DebugCommand) error {
+	args := m.Called(accountID, executionID, command)
+	return args.Error(0)
+}
+
+func (m *MockDebugRuntimeForWebSocket) GetPause(executionID string) (runtime.DebugPause, bool) {
+	args := m.Called(executionID)
+	return args.Get(0).(runtime.DebugPause), args.Bool(1)
+}
+
+func TestWebSocketManager_DebugCommands(t *testing.T) {
+	mockRuntime := &MockDebugRuntimeForWebSocket{}
+	wsManager := NewWebSocketManager(mockRuntime)
+
+	testStatus := runtime.ExecutionStatus{
+		ID:          "test-execution",
+		FlowID:      "test-flow",
+		Status:      "paused",
+		StartTime:   time.Now(),
+		CurrentNode: "first",
+	}
+	firstPause := runtime.DebugPause{
+		NodeID: "first",
+		Params: map[string]interface{}{"url": "https://example.com"},
+		Shared: map[string]interface{}{"topic": "go"},
+	}
+	secondPause := runtime.DebugPause{NodeID: "second", Step: 1, Shared: map[string]interface{}{"topic": "go"}}
+
+	logChan := make(chan runtime.ExecutionLog, 1)
+	continued := make(chan struct{})
+
+	mockRuntime.On("GetStatus", "test-execution").Return(testStatus, nil)
+	mockRuntime.On("GetPause", "test-execution").Return(firstPause, true)
+	mockRuntime.On("SubscribeToLogs", "test-execution").Return((<-chan runtime.ExecutionLog)(logChan), nil)
+	mockRuntime.On("Debug", "test-account", "test-execution", runtime.DebugContinue).Return(nil).Run(func(mock.Arguments) {
+		// The runtime pauses again at the next breakpoint
+		logChan <- runtime.ExecutionLog{
+			Timestamp: time.Now(),
+			NodeID:    "second",
+			Level:     "paused",
+			Message:   "Execution paused before node",
+			Pause:     &secondPause,
+		}
+		close(continued)
+	})
+	mockRuntime.On("Debug", "test-account", "test-execution", runtime.DebugStep).Return(assert.AnError)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wsManager.HandleWebSocket(w, r, "test-account")
+	}))
+	defer server.Close()
+
+	u := "ws" + strings.TrimPrefix(server.URL, "http") + "/"
+	ws, _, err := websocket.DefaultDialer.Dial(u, nil)
+	assert.NoError(t, err)
+	defer ws.Close()
+
+	assert.NoError(t, ws.WriteJSON(WebSocketMessage{Type: "subscribe", ExecutionID: "test-execution"}))
+
+	// The current status, then the pause the execution is waiting in
+	var update ExecutionUpdate
+	assert.NoError(t, ws.ReadJSON(&update))
+	assert.Equal(t, "status", update.Type)
+
+	update = ExecutionUpdate{}
+	assert.NoError(t, ws.ReadJSON(&update))
+	assert.Equal(t, "paused", update.Type)
+	assert.Equal(t, "first", update.NodeID)
+	if assert.NotNil(t, update.Pause) {
+		assert.Equal(t, "https://example.com", update.Pause.Params["url"])
+		assert.Equal(t, "go", update.Pause.Shared["topic"])
+	}
+
+	assert.NoError(t, ws.WriteJSON(WebSocketMessage{Type: "continue", ExecutionID: "test-execution"}))
+	select {
+	case <-continued:
+	case <-time.After(2 * time.Second):
+		t.Fatal("continue command was not passed to the runtime")
+	}
+
+	update = ExecutionUpdate{}
+	assert.NoError(t, ws.ReadJSON(&update))
+	assert.Equal(t, "paused", update.Type)
+	assert.Equal(t, "second", update.NodeID)
+	if assert.NotNil(t, update.Pause) {
+		assert.Equal(t, 1, update.Pause.Step)
+	}
+
+	// Rejected commands are reported to the client
+	assert.NoError(t, ws.WriteJSON(WebSocketMessage{Type: "step", ExecutionID: "test-execution"}))
+	update = ExecutionUpdate{}
+	assert.NoError(t, ws.ReadJSON(&update))
+	assert.Equal(t, "error", update.Type)
+	assert.Equal(t, "test-execution", update.ExecutionID)
+
+	close(logChan)
+	mockRuntime.AssertExpectations(t)
+}
@@ -24,6 +24,12 @@ type Config struct {
 
 	// Logging configuration
 	Logging LoggingConfig `json:"logging"`
+
+	// Execution configuration
+	Execution ExecutionConfig `json:"execution"`
+
+	// Queue configuration
+	Queue QueueConfig `json:"queue"`
 }
 
 // ServerConfig contains HTTP server settings
@@ -36,6 +42,11 @@ type ServerConfig struct {
 
 	// TLS configuration
 	TLS TLSConfig `json:"tls"`
+
+	// PublicURL is the URL under which clients reach the server, such as
+	// "https://flows.example.com". It is required for the signed links in
+	// approval notifications.
+	PublicURL string `json:"public_url"`
 }
 
 // TLSConfig contains TLS settings
@@ -131,6 +142,62 @@ type LoggingConfig struct {
 	FilePath string `json:"file_path"`
 }
 
+// ExecutionConfig contains flow execution settings
+type ExecutionConfig struct {
+	// MaxConcurrent is the maximum number of executions running at once (0 for no limit)
+	MaxConcurrent int `json:"max_concurrent"`
+
+	// MaxConcurrentPerAccount is the maximum number of executions running at once for one account
+	MaxConcurrentPerAccount int `json:"max_concurrent_per_account"`
+
+	// MaxConcurrentPerFlow is the maximum number of executions running at once for one flow
+	MaxConcurrentPerFlow int `json:"max_concurrent_per_flow"`
+
+	// IdempotencyWindow is the time in seconds during which a repeated
+	// idempotency key returns the original execution
+	IdempotencyWindow int `json:"idempotency_window"`
+
+	// MaxNodeVisits bounds the node visits of an execution whose flow sets
+	// no limit, so that flows stuck in a cycle fail
+	MaxNodeVisits int `json:"max_node_visits"`
+
+	// MaxVisitsPerNode bounds the visits of any single node of an execution
+	// whose flow sets no limit
+	MaxVisitsPerNode int `json:"max_visits_per_node"`
+}
+
+// QueueConfig contains the settings of the work queue shared by API and
+// worker processes
+type QueueConfig struct {
+	// Type of queue to use; empty runs executions in the API process
+	Type string `json:"type"` // "", "redis"
+
+	// Redis configuration
+	Redis RedisConfig `json:"redis"`
+
+	// Stream is the Redis stream holding queued executions
+	Stream string `json:"stream"`
+
+	// LeaseTimeout is the time in seconds after which the executions of an
+	// unresponsive worker are handed to another worker
+	LeaseTimeout int `json:"lease_timeout"`
+
+	// WorkerConcurrency is the number of executions a worker runs at once
+	WorkerConcurrency int `json:"worker_concurrency"`
+}
+
+// RedisConfig contains Redis settings
+type RedisConfig struct {
+	// Addr is the Redis address
+	Addr string `json:"addr"`
+
+	// Password is the Redis password
+	Password string `json:"password"`
+
+	// DB is the Redis database number
+	DB int `json:"db"`
+}
+
 // LoadConfig loads the configuration from a file
 func LoadConfig(path string) (*Config, error) {
 	// Read the file
@@ -184,6 +251,21 @@ func DefaultConfig() *Config {
 			Format: "json",
 			Output: "stdout",
 		},
+		Execution: ExecutionConfig{
+			MaxConcurrent:           100,
+			MaxConcurrentPerAccount: 20,
+			IdempotencyWindow:       86400,
+			MaxNodeVisits:           10000,
+			MaxVisitsPerNode:        1000,
+		},
+		Queue: QueueConfig{
+			Redis: RedisConfig{
+				Addr: "localhost:6379",
+			},
+			Stream:            "flowrunner:executions",
+			LeaseTimeout:      30,
+			WorkerConcurrency: 10,
+		},
 	}
 }
 
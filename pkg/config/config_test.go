@@ -21,6 +21,14 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.Storage.Type != "memory" {
 		t.Errorf("Expected default storage type to be 'memory', got '%s'", cfg.Storage.Type)
 	}
+
+	if cfg.Execution.MaxConcurrent != 100 || cfg.Execution.MaxConcurrentPerAccount != 20 || cfg.Execution.MaxConcurrentPerFlow != 0 {
+		t.Errorf("Unexpected default execution limits: %+v", cfg.Execution)
+	}
+
+	if cfg.Queue.Type != "" {
+		t.Errorf("Expected executions to run in the API process by default, got queue type '%s'", cfg.Queue.Type)
+	}
 }
 
 func TestSaveAndLoadConfig(t *testing.T) {
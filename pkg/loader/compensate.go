@@ -0,0 +1,48 @@
+package loader
+
+import (
+	"fmt"
+
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/plugins"
+)
+
+// CompensateAction is the successor action under which a node's compensation
+// is attached. The runtime follows it only to undo completed nodes after the
+// execution failed.
+const CompensateAction = "compensate"
+
+// compensationScriptType is the node type that runs inline compensate scripts
+const compensationScriptType = "transform"
+
+// createCompensation returns the node that compensates nodeName, or nil if the
+// node declares no compensation
+func (l *DefaultYAMLLoader) createCompensation(nodeName string, def plugins.CompensateDefinition, nodes map[string]flowlib.Node) (flowlib.Node, error) {
+	if def.Node != "" {
+		return nodes[def.Node], nil
+	}
+	if def.Script == "" {
+		return nil, nil
+	}
+
+	factory, exists := l.nodeFactories[compensationScriptType]
+	if !exists {
+		return nil, fmt.Errorf("compensate script of node '%s' requires the '%s' node type", nodeName, compensationScriptType)
+	}
+	node, err := factory.CreateNode(plugins.NodeDefinition{
+		Type:   compensationScriptType,
+		Params: map[string]interface{}{"script": def.Script},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compensation of node '%s': %w", nodeName, err)
+	}
+
+	params := make(map[string]interface{})
+	for k, v := range node.Params() {
+		params[k] = v
+	}
+	params["node_id"] = nodeName + "." + CompensateAction
+	params["node_type"] = compensationScriptType
+	node.SetParams(params)
+	return node, nil
+}
@@ -0,0 +1,99 @@
+package loader
+
+import (
+	"sort"
+)
+
+// FindUnconditionalCycles returns the cycles of a flow definition that no
+// routing leaves: every successor of their nodes is a node of the same cycle.
+// An execution entering such a cycle only stops once it exceeds its node
+// visit limits. The nodes of each cycle are sorted by name.
+func FindUnconditionalCycles(flowDef FlowDefinition) [][]string {
+	names := make([]string, 0, len(flowDef.Nodes))
+	for name := range flowDef.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Tarjan's algorithm for the strongly connected components of the graph
+	index := make(map[string]int, len(names))
+	lowlink := make(map[string]int, len(names))
+	onStack := make(map[string]bool, len(names))
+	var stack []string
+	var components [][]string
+
+	var connect func(name string)
+	connect = func(name string) {
+		index[name] = len(index)
+		lowlink[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, next := range successorNames(flowDef, name) {
+			if _, seen := index[next]; !seen {
+				connect(next)
+				lowlink[name] = min(lowlink[name], lowlink[next])
+			} else if onStack[next] {
+				lowlink[name] = min(lowlink[name], index[next])
+			}
+		}
+
+		if lowlink[name] == index[name] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == name {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+	for _, name := range names {
+		if _, seen := index[name]; !seen {
+			connect(name)
+		}
+	}
+
+	var cycles [][]string
+	for _, component := range components {
+		members := make(map[string]bool, len(component))
+		for _, name := range component {
+			members[name] = true
+		}
+
+		cyclic := len(component) > 1
+		exits := false
+		for _, name := range component {
+			for _, next := range flowDef.Nodes[name].Next {
+				if next == "END" || !members[next] {
+					exits = true
+				} else if next == name {
+					cyclic = true
+				}
+			}
+		}
+		if cyclic && !exits {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// successorNames returns the existing nodes a node routes to, sorted by name
+func successorNames(flowDef FlowDefinition, name string) []string {
+	var successors []string
+	for _, next := range flowDef.Nodes[name].Next {
+		if _, exists := flowDef.Nodes[next]; exists {
+			successors = append(successors, next)
+		}
+	}
+	sort.Strings(successors)
+	return successors
+}
@@ -0,0 +1,100 @@
+package loader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindUnconditionalCycles(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected [][]string
+	}{
+		{
+			name: "no cycle",
+			yaml: `
+nodes:
+  start:
+    type: base
+    next:
+      default: end
+  end:
+    type: base
+`,
+		},
+		{
+			name: "cycle with an exit",
+			yaml: `
+nodes:
+  fetch:
+    type: base
+    next:
+      default: check
+  check:
+    type: condition
+    next:
+      "false": fetch
+      "true": done
+  done:
+    type: base
+`,
+		},
+		{
+			name: "cycle ending the flow",
+			yaml: `
+nodes:
+  fetch:
+    type: base
+    next:
+      default: check
+  check:
+    type: condition
+    next:
+      "false": fetch
+      "true": END
+`,
+		},
+		{
+			name: "unconditional cycle",
+			yaml: `
+nodes:
+  start:
+    type: base
+    next:
+      default: fetch
+  fetch:
+    type: base
+    next:
+      default: check
+  check:
+    type: condition
+    next:
+      "false": fetch
+      "true": fetch
+`,
+			expected: [][]string{{"check", "fetch"}},
+		},
+		{
+			name: "self loop",
+			yaml: `
+nodes:
+  poll:
+    type: base
+    next:
+      default: poll
+`,
+			expected: [][]string{{"poll"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flowDef, err := ParseFlowDefinition(tt.yaml)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, FindUnconditionalCycles(flowDef))
+		})
+	}
+}
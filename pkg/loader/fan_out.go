@@ -0,0 +1,118 @@
+package loader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// JoinAction is the successor action under which a split node finds the join
+// node its branches lead to. The split node runs its branches itself and the
+// execution then continues at the join node.
+const JoinAction = "join"
+
+const (
+	splitNodeType = "split"
+	joinNodeType  = "join"
+)
+
+// IsSplitBranch reports whether a successor action of a split node starts one
+// of its parallel branches. The default, error and timeout actions keep their
+// usual meaning.
+func IsSplitBranch(action string) bool {
+	switch action {
+	case "default", "error", "timeout", CompensateAction, JoinAction:
+		return false
+	}
+	return true
+}
+
+// findJoin returns the join node that the branches of a split node lead to,
+// or "" if every branch ends without one. The branches of nested split nodes
+// are skipped over through their own join node.
+func findJoin(flowDef FlowDefinition, splitName string, resolving map[string]bool) (string, error) {
+	if resolving[splitName] {
+		return "", fmt.Errorf("split node '%s' is reached from its own branches", splitName)
+	}
+	resolving[splitName] = true
+	defer delete(resolving, splitName)
+
+	var queue []string
+	for action, next := range flowDef.Nodes[splitName].Next {
+		if IsSplitBranch(action) {
+			queue = append(queue, next)
+		}
+	}
+
+	joins := make(map[string]bool)
+	visited := make(map[string]bool)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		nodeDef, exists := flowDef.Nodes[name]
+		if !exists || visited[name] {
+			continue
+		}
+		visited[name] = true
+
+		switch nodeDef.Type {
+		case joinNodeType:
+			joins[name] = true
+			continue
+		case splitNodeType:
+			inner, err := findJoin(flowDef, name, resolving)
+			if err != nil {
+				return "", err
+			}
+			for action, next := range nodeDef.Next {
+				if !IsSplitBranch(action) {
+					queue = append(queue, next)
+				}
+			}
+			if inner != "" {
+				for _, next := range flowDef.Nodes[inner].Next {
+					queue = append(queue, next)
+				}
+			}
+			continue
+		}
+		for _, next := range nodeDef.Next {
+			queue = append(queue, next)
+		}
+	}
+
+	names := make([]string, 0, len(joins))
+	for name := range joins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	switch len(names) {
+	case 0:
+		return "", nil
+	case 1:
+		return names[0], nil
+	default:
+		return "", fmt.Errorf("branches of split node '%s' lead to more than one join node: %s", splitName, strings.Join(names, ", "))
+	}
+}
+
+// findJoins returns the join node of every split node that has one
+func findJoins(flowDef FlowDefinition) (map[string]string, error) {
+	joins := make(map[string]string)
+	for nodeName, nodeDef := range flowDef.Nodes {
+		if nodeDef.Type != splitNodeType {
+			continue
+		}
+		if _, reserved := nodeDef.Next[JoinAction]; reserved {
+			return nil, fmt.Errorf("split node '%s' uses the reserved action '%s'", nodeName, JoinAction)
+		}
+		join, err := findJoin(flowDef, nodeName, make(map[string]bool))
+		if err != nil {
+			return nil, err
+		}
+		if join != "" {
+			joins[nodeName] = join
+		}
+	}
+	return joins, nil
+}
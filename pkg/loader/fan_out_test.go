@@ -0,0 +1,150 @@
+package loader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindJoins(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected map[string]string
+		err      string
+	}{
+		{
+			name: "branches meet at a join",
+			yaml: `
+nodes:
+  fan_out:
+    type: split
+    next:
+      orders: fetch_orders
+      users: fetch_users
+      error: alert
+  fetch_orders:
+    type: http.request
+    next:
+      default: parse_orders
+  parse_orders:
+    type: transform
+    next:
+      default: gather
+  fetch_users:
+    type: http.request
+    next:
+      default: gather
+  gather:
+    type: join
+    next:
+      default: report
+  report:
+    type: transform
+  alert:
+    type: email.send
+`,
+			expected: map[string]string{"fan_out": "gather"},
+		},
+		{
+			name: "branches without a join",
+			yaml: `
+nodes:
+  fan_out:
+    type: split
+    next:
+      a: task_a
+      b: task_b
+      default: report
+  task_a:
+    type: transform
+  task_b:
+    type: transform
+  report:
+    type: transform
+`,
+			expected: map[string]string{},
+		},
+		{
+			name: "nested split",
+			yaml: `
+nodes:
+  outer:
+    type: split
+    next:
+      a: inner
+      b: task_b
+  inner:
+    type: split
+    next:
+      a1: task_a1
+      a2: task_a2
+  task_a1:
+    type: transform
+    next:
+      default: inner_join
+  task_a2:
+    type: transform
+    next:
+      default: inner_join
+  inner_join:
+    type: join
+    next:
+      default: outer_join
+  task_b:
+    type: transform
+    next:
+      default: outer_join
+  outer_join:
+    type: join
+`,
+			expected: map[string]string{"outer": "outer_join", "inner": "inner_join"},
+		},
+		{
+			name: "branches meet at different joins",
+			yaml: `
+nodes:
+  fan_out:
+    type: split
+    next:
+      a: join_a
+      b: join_b
+  join_a:
+    type: join
+  join_b:
+    type: join
+`,
+			err: "lead to more than one join node: join_a, join_b",
+		},
+		{
+			name: "reserved action",
+			yaml: `
+nodes:
+  fan_out:
+    type: split
+    next:
+      join: gather
+  gather:
+    type: join
+`,
+			err: "reserved action 'join'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flowDef, err := ParseFlowDefinition(tt.yaml)
+			require.NoError(t, err)
+
+			joins, err := findJoins(flowDef)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, joins)
+		})
+	}
+}
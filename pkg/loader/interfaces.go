@@ -32,6 +32,22 @@ type FlowDefinition struct {
 
 	// Nodes in the flow
 	Nodes map[string]plugins.NodeDefinition `yaml:"nodes" json:"nodes"`
+
+	// Vars are constants of the flow, which node parameters refer to as
+	// ${vars.name}
+	Vars map[string]interface{} `yaml:"vars" json:"vars,omitempty"`
+
+	// Environments override vars per environment, such as dev, staging and
+	// prod, keyed by environment name
+	Environments map[string]EnvironmentDefinition `yaml:"environments" json:"environments,omitempty"`
+}
+
+// EnvironmentDefinition is the overlay a flow applies when it runs in an
+// environment
+type EnvironmentDefinition struct {
+	// Vars replace the flow vars of the same name. Objects are merged, so
+	// an overlay only lists the values that differ.
+	Vars map[string]interface{} `yaml:"vars" json:"vars,omitempty"`
 }
 
 // FlowMetadata contains information about the flow
@@ -44,4 +60,27 @@ type FlowMetadata struct {
 
 	// Version of the flow
 	Version string `yaml:"version" json:"version"`
+
+	// Timeout bounds a whole execution of the flow, e.g. "5m"
+	Timeout string `yaml:"timeout" json:"timeout,omitempty"`
+
+	// OnError names a node that runs when an execution fails, before it is
+	// marked failed
+	OnError string `yaml:"on_error" json:"on_error,omitempty"`
+
+	// MaxNodeVisits bounds the node visits of an execution; zero uses the
+	// server default
+	MaxNodeVisits int `yaml:"max_node_visits" json:"max_node_visits,omitempty"`
+
+	// MaxVisitsPerNode bounds the visits of any single node in an execution;
+	// zero uses the server default
+	MaxVisitsPerNode int `yaml:"max_visits_per_node" json:"max_visits_per_node,omitempty"`
+
+	// Inputs is the JSON schema of the input of an execution; executions
+	// with input that does not match it are rejected
+	Inputs JSONSchema `yaml:"inputs" json:"inputs,omitempty"`
+
+	// Outputs is the JSON schema of the results of an execution; executions
+	// with results that do not match it fail
+	Outputs JSONSchema `yaml:"outputs" json:"outputs,omitempty"`
 }
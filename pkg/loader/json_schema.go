@@ -0,0 +1,410 @@
+package loader
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// JSONSchema is a JSON schema declared in a flow definition, such as the
+// schema of the flow inputs. Validation supports the type, properties,
+// required, additionalProperties, items, enum, const, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, minLength, maxLength, pattern,
+// minItems and maxItems keywords. Other keywords, such as title,
+// description, default and format, are kept as annotations.
+type JSONSchema map[string]interface{}
+
+// FieldError reports a value that does not match a schema
+type FieldError struct {
+	// Field is the path of the value, such as "customer.email" or
+	// "items[2]"; empty for the whole value
+	Field string `json:"field"`
+
+	// Message describes the mismatch, such as "is required"
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return "value " + e.Message
+	}
+	return e.Field + " " + e.Message
+}
+
+// UnmarshalYAML converts the nested objects that YAML decodes with
+// interface{} keys, so that schemas can be marshaled as JSON
+func (s *JSONSchema) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw map[interface{}]interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		*s = nil
+		return nil
+	}
+	*s = JSONSchema(stringKeyed(raw).(map[string]interface{}))
+	return nil
+}
+
+// stringKeyed converts YAML maps with interface{} keys into maps with string keys
+func stringKeyed(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprintf("%v", key)] = stringKeyed(item)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, item := range v {
+			converted[i] = stringKeyed(item)
+		}
+		return converted
+	}
+	return value
+}
+
+// Check reports keywords of the schema that have values validation cannot
+// use, such as unknown types or invalid patterns
+func (s JSONSchema) Check() error {
+	return checkSchema("", s)
+}
+
+func checkSchema(path string, schema map[string]interface{}) error {
+	at := func(keyword string) string {
+		if path == "" {
+			return keyword
+		}
+		return path + "." + keyword
+	}
+
+	if declared, ok := schema["type"]; ok {
+		types, ok := schemaTypes(declared)
+		if !ok {
+			return fmt.Errorf("%s must be a type name or a list of type names", at("type"))
+		}
+		for _, name := range types {
+			switch name {
+			case "object", "array", "string", "number", "integer", "boolean", "null":
+			default:
+				return fmt.Errorf("%s has unknown type %q", at("type"), name)
+			}
+		}
+	}
+
+	if properties, ok := schema["properties"]; ok {
+		propertyMap, ok := properties.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", at("properties"))
+		}
+		for name, property := range propertyMap {
+			propertySchema, ok := property.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s must be a schema", at("properties."+name))
+			}
+			if err := checkSchema(at("properties."+name), propertySchema); err != nil {
+				return err
+			}
+		}
+	}
+
+	if required, ok := schema["required"]; ok {
+		list, ok := required.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be a list of property names", at("required"))
+		}
+		for _, name := range list {
+			if _, ok := name.(string); !ok {
+				return fmt.Errorf("%s must be a list of property names", at("required"))
+			}
+		}
+	}
+
+	switch additional := schema["additionalProperties"].(type) {
+	case nil, bool:
+	case map[string]interface{}:
+		if err := checkSchema(at("additionalProperties"), additional); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s must be a boolean or a schema", at("additionalProperties"))
+	}
+
+	if items, ok := schema["items"]; ok {
+		itemSchema, ok := items.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be a schema", at("items"))
+		}
+		if err := checkSchema(at("items"), itemSchema); err != nil {
+			return err
+		}
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		if _, ok := enum.([]interface{}); !ok {
+			return fmt.Errorf("%s must be a list", at("enum"))
+		}
+	}
+
+	for _, keyword := range []string{"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum"} {
+		if value, ok := schema[keyword]; ok {
+			if _, ok := schemaNumber(value); !ok {
+				return fmt.Errorf("%s must be a number", at(keyword))
+			}
+		}
+	}
+	for _, keyword := range []string{"minLength", "maxLength", "minItems", "maxItems"} {
+		if value, ok := schema[keyword]; ok {
+			if n, ok := schemaNumber(value); !ok || n < 0 || n != math.Trunc(n) {
+				return fmt.Errorf("%s must be a non-negative integer", at(keyword))
+			}
+		}
+	}
+
+	if pattern, ok := schema["pattern"]; ok {
+		expression, ok := pattern.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", at("pattern"))
+		}
+		if _, err := regexp.Compile(expression); err != nil {
+			return fmt.Errorf("%s is not a valid regular expression: %w", at("pattern"), err)
+		}
+	}
+
+	return nil
+}
+
+// Validate returns the field errors of value against the schema, ordered by
+// field, or nil if value matches it
+func (s JSONSchema) Validate(value interface{}) []FieldError {
+	var errs []FieldError
+	validateSchema("", s, value, &errs)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+func validateSchema(field string, schema map[string]interface{}, value interface{}, errs *[]FieldError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if declared, ok := schema["type"]; ok {
+		types, _ := schemaTypes(declared)
+		if !matchesType(types, value) {
+			fail("must be %s", typeDescription(types))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, value) {
+		fail("must be one of %s", formatValues(enum))
+	}
+	if constant, ok := schema["const"]; ok && !equalValues(constant, value) {
+		fail("must be %s", formatValues([]interface{}{constant}))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(field, schema, v, errs)
+	case []interface{}:
+		if n, ok := schemaNumber(schema["minItems"]); ok && float64(len(v)) < n {
+			fail("must have at least %v items", n)
+		}
+		if n, ok := schemaNumber(schema["maxItems"]); ok && float64(len(v)) > n {
+			fail("must have at most %v items", n)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateSchema(fmt.Sprintf("%s[%d]", field, i), items, item, errs)
+			}
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if n, ok := schemaNumber(schema["minLength"]); ok && length < n {
+			fail("must be at least %v characters long", n)
+		}
+		if n, ok := schemaNumber(schema["maxLength"]); ok && length > n {
+			fail("must be at most %v characters long", n)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if expression, err := regexp.Compile(pattern); err == nil && !expression.MatchString(v) {
+				fail("must match pattern %s", pattern)
+			}
+		}
+	default:
+		number, ok := schemaNumber(value)
+		if !ok {
+			return
+		}
+		if n, ok := schemaNumber(schema["minimum"]); ok && number < n {
+			fail("must be at least %v", n)
+		}
+		if n, ok := schemaNumber(schema["maximum"]); ok && number > n {
+			fail("must be at most %v", n)
+		}
+		if n, ok := schemaNumber(schema["exclusiveMinimum"]); ok && number <= n {
+			fail("must be greater than %v", n)
+		}
+		if n, ok := schemaNumber(schema["exclusiveMaximum"]); ok && number >= n {
+			fail("must be less than %v", n)
+		}
+	}
+}
+
+func validateObject(field string, schema map[string]interface{}, object map[string]interface{}, errs *[]FieldError) {
+	child := func(name string) string {
+		if field == "" {
+			return name
+		}
+		return field + "." + name
+	}
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			name, _ := name.(string)
+			if _, present := object[name]; !present {
+				*errs = append(*errs, FieldError{Field: child(name), Message: "is required"})
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	for name, value := range object {
+		if property, ok := properties[name].(map[string]interface{}); ok {
+			validateSchema(child(name), property, value, errs)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				*errs = append(*errs, FieldError{Field: child(name), Message: "is not allowed"})
+			}
+		case map[string]interface{}:
+			validateSchema(child(name), additional, value, errs)
+		}
+	}
+}
+
+// schemaTypes returns the type names of a type keyword
+func schemaTypes(declared interface{}) ([]string, bool) {
+	switch t := declared.(type) {
+	case string:
+		return []string{t}, true
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, name := range t {
+			s, ok := name.(string)
+			if !ok {
+				return nil, false
+			}
+			types = append(types, s)
+		}
+		return types, true
+	}
+	return nil, false
+}
+
+func matchesType(types []string, value interface{}) bool {
+	for _, name := range types {
+		switch name {
+		case "object":
+			if _, ok := value.(map[string]interface{}); ok {
+				return true
+			}
+		case "array":
+			if _, ok := value.([]interface{}); ok {
+				return true
+			}
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "null":
+			if value == nil {
+				return true
+			}
+		case "number":
+			if _, ok := schemaNumber(value); ok {
+				return true
+			}
+		case "integer":
+			if n, ok := schemaNumber(value); ok && n == math.Trunc(n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func typeDescription(types []string) string {
+	described := make([]string, len(types))
+	for i, name := range types {
+		switch name {
+		case "object", "array", "integer":
+			described[i] = "an " + name
+		case "null":
+			described[i] = "null"
+		default:
+			described[i] = "a " + name
+		}
+	}
+	return strings.Join(described, " or ")
+}
+
+// schemaNumber returns a JSON or YAML number as float64
+func schemaNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if equalValues(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalValues compares values like JSON does, so that the integers of YAML
+// schemas equal the float64 numbers of JSON input
+func equalValues(a, b interface{}) bool {
+	if x, ok := schemaNumber(a); ok {
+		y, ok := schemaNumber(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func formatValues(values []interface{}) string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			formatted[i] = fmt.Sprintf("%q", s)
+		} else {
+			formatted[i] = fmt.Sprintf("%v", value)
+		}
+	}
+	return strings.Join(formatted, ", ")
+}
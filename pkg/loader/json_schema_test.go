@@ -0,0 +1,145 @@
+package loader
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSchemaValidate(t *testing.T) {
+	flowDef, err := ParseFlowDefinition(`
+metadata:
+  name: signup
+  inputs:
+    type: object
+    required: [email, plan]
+    additionalProperties: false
+    properties:
+      email:
+        type: string
+        pattern: "^[^@]+@[^@]+$"
+      plan:
+        enum: [free, pro]
+      seats:
+        type: integer
+        minimum: 1
+        maximum: 50
+      tags:
+        type: array
+        maxItems: 2
+        items:
+          type: string
+          minLength: 2
+      address:
+        type: object
+        required: [country]
+        properties:
+          country:
+            type: string
+nodes:
+  start:
+    type: base
+`)
+	require.NoError(t, err)
+	schema := flowDef.Metadata.Inputs
+	require.NoError(t, schema.Check())
+
+	decode := func(input string) interface{} {
+		var value interface{}
+		require.NoError(t, json.Unmarshal([]byte(input), &value))
+		return value
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []FieldError
+	}{
+		{
+			name:  "valid input",
+			input: `{"email": "a@example.com", "plan": "pro", "seats": 3, "tags": ["vip"], "address": {"country": "NZ"}}`,
+		},
+		{
+			name:  "missing and unknown fields",
+			input: `{"email": "a@example.com", "coupon": "X"}`,
+			expected: []FieldError{
+				{Field: "coupon", Message: "is not allowed"},
+				{Field: "plan", Message: "is required"},
+			},
+		},
+		{
+			name:  "mismatched values",
+			input: `{"email": "nobody", "plan": "gold", "seats": 2.5, "tags": ["a", "bc", "de"], "address": {}}`,
+			expected: []FieldError{
+				{Field: "address.country", Message: "is required"},
+				{Field: "email", Message: "must match pattern ^[^@]+@[^@]+$"},
+				{Field: "plan", Message: `must be one of "free", "pro"`},
+				{Field: "seats", Message: "must be an integer"},
+				{Field: "tags", Message: "must have at most 2 items"},
+				{Field: "tags[0]", Message: "must be at least 2 characters long"},
+			},
+		},
+		{
+			name:     "not an object",
+			input:    `["a@example.com"]`,
+			expected: []FieldError{{Field: "", Message: "must be an object"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, schema.Validate(decode(tt.input)))
+		})
+	}
+}
+
+func TestJSONSchemaMarshalsAsJSON(t *testing.T) {
+	flowDef, err := ParseFlowDefinition(`
+metadata:
+  name: report
+  outputs:
+    type: object
+    properties:
+      total:
+        type: number
+nodes:
+  start:
+    type: base
+`)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(flowDef.Metadata.Outputs)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "object", "properties": {"total": {"type": "number"}}}`, string(data))
+}
+
+func TestJSONSchemaCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema JSONSchema
+		errMsg string
+	}{
+		{name: "valid", schema: JSONSchema{"type": []interface{}{"string", "null"}, "maxLength": 10}},
+		{name: "unknown type", schema: JSONSchema{"type": "int"}, errMsg: `type has unknown type "int"`},
+		{
+			name:   "nested property",
+			schema: JSONSchema{"properties": map[string]interface{}{"age": map[string]interface{}{"minimum": "1"}}},
+			errMsg: "properties.age.minimum must be a number",
+		},
+		{name: "invalid pattern", schema: JSONSchema{"pattern": "("}, errMsg: "pattern is not a valid regular expression"},
+		{name: "negative length", schema: JSONSchema{"minLength": -1}, errMsg: "minLength must be a non-negative integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.Check()
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.errMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/plugins"
+)
+
+// Error classes accepted in the retry_on list of a node
+const (
+	RetryOnAll         = "all"
+	RetryOnTimeout     = "timeout"
+	RetryOnNetwork     = "network"
+	RetryOnRateLimit   = "rate_limit"
+	RetryOnServerError = "server_error"
+)
+
+// retryClassifiers report whether an error belongs to a retry_on class
+var retryClassifiers = map[string]func(error) bool{
+	RetryOnAll:         func(error) bool { return true },
+	RetryOnTimeout:     isTimeoutError,
+	RetryOnNetwork:     isNetworkError,
+	RetryOnRateLimit:   func(err error) bool { return statusCodeOf(err) == 429 },
+	RetryOnServerError: func(err error) bool { return statusCodeOf(err) >= 500 },
+}
+
+// retryDeclared reports whether a node declares any retry settings
+func retryDeclared(def plugins.RetryDefinition) bool {
+	return def.MaxRetries > 0 || def.Wait != "" || def.Backoff != "" ||
+		def.MaxWait != "" || def.Jitter || len(def.RetryOn) > 0
+}
+
+// buildRetryPolicy converts the retry block of a node into a flowlib retry policy
+func buildRetryPolicy(def plugins.RetryDefinition) (flowlib.RetryPolicy, error) {
+	policy := flowlib.RetryPolicy{
+		MaxRetries: def.MaxRetries,
+		Jitter:     def.Jitter,
+	}
+
+	var err error
+	if def.Wait != "" {
+		if policy.Wait, err = time.ParseDuration(def.Wait); err != nil {
+			return policy, fmt.Errorf("invalid wait duration: %w", err)
+		}
+	}
+	if def.MaxWait != "" {
+		if policy.MaxWait, err = time.ParseDuration(def.MaxWait); err != nil {
+			return policy, fmt.Errorf("invalid max_wait duration: %w", err)
+		}
+	}
+
+	switch def.Backoff {
+	case "", flowlib.BackoffFixed:
+		policy.Backoff = flowlib.BackoffFixed
+	case flowlib.BackoffExponential:
+		policy.Backoff = flowlib.BackoffExponential
+	default:
+		return policy, fmt.Errorf("invalid backoff '%s': expected fixed or exponential", def.Backoff)
+	}
+
+	if len(def.RetryOn) > 0 {
+		classifiers := make([]func(error) bool, 0, len(def.RetryOn))
+		for _, class := range def.RetryOn {
+			classifier, ok := retryClassifiers[class]
+			if !ok {
+				return policy, fmt.Errorf("unknown retry_on class '%s'", class)
+			}
+			classifiers = append(classifiers, classifier)
+		}
+		policy.RetryOn = func(err error) bool {
+			for _, matches := range classifiers {
+				if matches(err) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	return policy, nil
+}
+
+// applyRetryPolicy configures a created node with the retry block of its definition
+func applyRetryPolicy(nodeName string, node flowlib.Node, def plugins.RetryDefinition) error {
+	if !retryDeclared(def) {
+		return nil
+	}
+	policy, err := buildRetryPolicy(def)
+	if err != nil {
+		return fmt.Errorf("invalid retry settings for node '%s': %w", nodeName, err)
+	}
+	configurable, ok := node.(flowlib.RetryConfigurable)
+	if !ok {
+		return fmt.Errorf("node '%s' does not support retry settings", nodeName)
+	}
+	configurable.SetRetryPolicy(policy)
+	return nil
+}
+
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// statusCodeOf returns the HTTP status carried by err, such as the status of
+// an LLM provider error, or 0 if there is none
+func statusCodeOf(err error) int {
+	var withStatus interface{ StatusCode() int }
+	if errors.As(err, &withStatus) {
+		return withStatus.StatusCode()
+	}
+	return 0
+}
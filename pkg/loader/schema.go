@@ -20,6 +20,28 @@ const FlowSchema = `
         },
         "version": {
           "type": "string"
+        },
+        "timeout": {
+          "type": "string",
+          "pattern": "^[0-9]+(ns|us|ms|s|m|h)$"
+        },
+        "on_error": {
+          "type": "string",
+          "minLength": 1
+        },
+        "max_node_visits": {
+          "type": "integer",
+          "minimum": 1
+        },
+        "max_visits_per_node": {
+          "type": "integer",
+          "minimum": 1
+        },
+        "inputs": {
+          "type": "object"
+        },
+        "outputs": {
+          "type": "object"
         }
       }
     },
@@ -66,9 +88,50 @@ const FlowSchema = `
               "wait": {
                 "type": "string",
                 "pattern": "^[0-9]+(ns|us|ms|s|m|h)$"
+              },
+              "backoff": {
+                "type": "string",
+                "enum": ["fixed", "exponential"]
+              },
+              "max_wait": {
+                "type": "string",
+                "pattern": "^[0-9]+(ns|us|ms|s|m|h)$"
+              },
+              "jitter": {
+                "type": "boolean"
+              },
+              "retry_on": {
+                "type": "array",
+                "items": {
+                  "type": "string",
+                  "enum": ["all", "timeout", "network", "rate_limit", "server_error"]
+                }
+              }
+            }
+          },
+          "timeout": {
+            "type": "string",
+            "pattern": "^[0-9]+(ns|us|ms|s|m|h)$"
+          },
+          "compensate": {
+            "type": "object",
+            "properties": {
+              "node": {
+                "type": "string",
+                "minLength": 1
+              },
+              "script": {
+                "type": "string",
+                "minLength": 1
               }
             }
           },
+          "dry_run": {
+            "type": "object",
+            "properties": {
+              "output": {}
+            }
+          },
           "hooks": {
             "type": "object",
             "properties": {
@@ -85,6 +148,20 @@ const FlowSchema = `
           }
         }
       }
+    },
+    "vars": {
+      "type": "object"
+    },
+    "environments": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "object",
+        "properties": {
+          "vars": {
+            "type": "object"
+          }
+        }
+      }
     }
   }
 }
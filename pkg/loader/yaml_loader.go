@@ -3,6 +3,8 @@ package loader
 import (
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/tcmartin/flowlib"
 	"github.com/tcmartin/flowrunner/pkg/plugins"
@@ -62,6 +64,9 @@ func (l *DefaultYAMLLoader) Parse(yamlContent string) (*flowlib.Flow, error) {
             merged["node_id"] = nodeName
             merged["node_type"] = nodeDef.Type
             node.SetParams(merged)
+            if err := applyRetryPolicy(nodeName, node, nodeDef.Retry); err != nil {
+                return nil, err
+            }
             nodes[nodeName] = node
 		} else {
             node, err := factory.CreateNode(nodeDef)
@@ -77,6 +82,9 @@ func (l *DefaultYAMLLoader) Parse(yamlContent string) (*flowlib.Flow, error) {
             merged["node_id"] = nodeName
             merged["node_type"] = nodeDef.Type
             node.SetParams(merged)
+            if err := applyRetryPolicy(nodeName, node, nodeDef.Retry); err != nil {
+                return nil, err
+            }
             nodes[nodeName] = node
 		}
 	}
@@ -98,13 +106,37 @@ func (l *DefaultYAMLLoader) Parse(yamlContent string) (*flowlib.Flow, error) {
 		}
 	}
 
+	// Attach compensations as successors under the compensate action
+	for nodeName, nodeDef := range flowDef.Nodes {
+		compensation, err := l.createCompensation(nodeName, nodeDef.Compensate, nodes)
+		if err != nil {
+			return nil, err
+		}
+		if compensation != nil {
+			nodes[nodeName].Next(CompensateAction, compensation)
+		}
+	}
+
+	// Attach the join node of every split node under the join action
+	joins, err := findJoins(flowDef)
+	if err != nil {
+		return nil, err
+	}
+	for splitName, joinName := range joins {
+		nodes[splitName].Next(JoinAction, nodes[joinName])
+	}
+
 	// Find the start node (the one not referenced by any other node)
 	startNode, err := findStartNode(flowDef, nodes)
 	if err != nil {
 		return nil, err
 	}
 
-	return flowlib.NewFlow(startNode), nil
+	flow := flowlib.NewFlow(startNode)
+	if flowDef.Metadata.OnError != "" {
+		flow.SetErrorHandler(nodes[flowDef.Metadata.OnError])
+	}
+	return flow, nil
 }
 
 // Validate checks if a YAML string conforms to the schema
@@ -145,11 +177,106 @@ func (l *DefaultYAMLLoader) Validate(yamlContent string) error {
 		}
 	}
 
+	// Validate compensations
+	for nodeName, nodeDef := range flowDef.Nodes {
+		if _, reserved := nodeDef.Next[CompensateAction]; reserved {
+			return fmt.Errorf("node '%s' uses the reserved action '%s'", nodeName, CompensateAction)
+		}
+		compensate := nodeDef.Compensate
+		if compensate.Node != "" && compensate.Script != "" {
+			return fmt.Errorf("compensate of node '%s' must name either a node or a script", nodeName)
+		}
+		if compensate.Node != "" {
+			if _, exists := flowDef.Nodes[compensate.Node]; !exists {
+				return fmt.Errorf("compensate of node '%s' references non-existent node '%s'", nodeName, compensate.Node)
+			}
+		}
+		if compensate.Script != "" {
+			if _, exists := l.nodeFactories[compensationScriptType]; !exists {
+				return fmt.Errorf("compensate script of node '%s' requires the '%s' node type", nodeName, compensationScriptType)
+			}
+		}
+	}
+
+	// Validate that the branches of every split node meet at one join node
+	if _, err := findJoins(flowDef); err != nil {
+		return err
+	}
+
+	// Validate the flow-level error handler
+	if flowDef.Metadata.OnError != "" {
+		if _, exists := flowDef.Nodes[flowDef.Metadata.OnError]; !exists {
+			return fmt.Errorf("on_error references non-existent node '%s'", flowDef.Metadata.OnError)
+		}
+	}
+
+	// Validate timeouts
+	if flowDef.Metadata.Timeout != "" {
+		if _, err := time.ParseDuration(flowDef.Metadata.Timeout); err != nil {
+			return fmt.Errorf("invalid flow timeout: %w", err)
+		}
+	}
+	for nodeName, nodeDef := range flowDef.Nodes {
+		if nodeDef.Timeout == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(nodeDef.Timeout); err != nil {
+			return fmt.Errorf("invalid timeout for node '%s': %w", nodeName, err)
+		}
+	}
+
+	// Validate retry settings
+	for nodeName, nodeDef := range flowDef.Nodes {
+		if _, err := buildRetryPolicy(nodeDef.Retry); err != nil {
+			return fmt.Errorf("invalid retry settings for node '%s': %w", nodeName, err)
+		}
+	}
+
+	// Validate visit limits
+	if flowDef.Metadata.MaxNodeVisits < 0 {
+		return fmt.Errorf("max_node_visits must not be negative")
+	}
+	if flowDef.Metadata.MaxVisitsPerNode < 0 {
+		return fmt.Errorf("max_visits_per_node must not be negative")
+	}
+
+	// Validate input and output schemas
+	if err := flowDef.Metadata.Inputs.Check(); err != nil {
+		return fmt.Errorf("invalid inputs schema: %w", err)
+	}
+	if err := flowDef.Metadata.Outputs.Check(); err != nil {
+		return fmt.Errorf("invalid outputs schema: %w", err)
+	}
+
+	// Cycles without an exit are legal but only end at the visit limits
+	for _, cycle := range FindUnconditionalCycles(flowDef) {
+		log.Printf("Warning: nodes %s of flow '%s' form a cycle that no routing leaves", strings.Join(cycle, ", "), flowDef.Metadata.Name)
+	}
+
 	return nil
 }
 
+// ParseFlowDefinition decodes a YAML flow definition without building the flow
+// graph, for callers that need the declared settings of a flow
+func ParseFlowDefinition(yamlContent string) (FlowDefinition, error) {
+	var flowDef FlowDefinition
+	if err := yaml.Unmarshal([]byte(yamlContent), &flowDef); err != nil {
+		return FlowDefinition{}, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	return flowDef, nil
+}
+
 func findStartNode(flowDef FlowDefinition, nodes map[string]flowlib.Node) (flowlib.Node, error) {
 	referencedNodes := make(map[string]bool)
+	if flowDef.Metadata.OnError != "" {
+		// The error handler is entered on failure, never at the start
+		referencedNodes[flowDef.Metadata.OnError] = true
+	}
+	for _, nodeDef := range flowDef.Nodes {
+		if nodeDef.Compensate.Node != "" {
+			referencedNodes[nodeDef.Compensate.Node] = true
+		}
+	}
 	for _, nodeDef := range flowDef.Nodes {
 		for _, nextNodeName := range nodeDef.Next {
             if nextNodeName == "END" {
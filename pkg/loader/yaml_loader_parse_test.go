@@ -1,6 +1,8 @@
 package loader_test
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -118,6 +120,142 @@ nodes:
 	assert.Contains(t, processDataNode.Successors(), "failure")
 }
 
+func TestYAMLLoader_Parse_RetryPolicy(t *testing.T) {
+	nodeFactories := map[string]plugins.NodeFactory{
+		"base": &loader.BaseNodeFactory{},
+	}
+	yamlLoader := loader.NewYAMLLoader(nodeFactories, plugins.NewPluginRegistry())
+
+	yamlContent := `
+metadata:
+  name: retry-flow
+nodes:
+  start:
+    type: base
+    retry:
+      max_retries: 3
+      wait: 1ms
+      backoff: exponential
+      max_wait: 3ms
+      retry_on: [timeout]
+`
+
+	flow, err := yamlLoader.Parse(yamlContent)
+	assert.NoError(t, err)
+
+	baseNode, ok := flow.Start().(*flowlib.NodeWithRetry)
+	assert.True(t, ok)
+
+	// Exponential backoff doubles the wait up to max_wait
+	policy := baseNode.RetryPolicy()
+	assert.Equal(t, time.Millisecond, policy.Delay(1))
+	assert.Equal(t, 2*time.Millisecond, policy.Delay(2))
+	assert.Equal(t, 3*time.Millisecond, policy.Delay(3))
+
+	// Only errors of the retry_on classes are retried
+	attempts := 0
+	baseNode.SetExecFn(func(any) (any, error) {
+		attempts++
+		return nil, context.DeadlineExceeded
+	})
+	_, err = baseNode.Run(map[string]interface{}{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	baseNode.SetExecFn(func(any) (any, error) {
+		attempts++
+		return nil, errors.New("invalid input")
+	})
+	_, err = baseNode.Run(map[string]interface{}{})
+	assert.EqualError(t, err, "invalid input")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestYAMLLoader_Parse_OnError(t *testing.T) {
+	nodeFactories := map[string]plugins.NodeFactory{
+		"base": &loader.BaseNodeFactory{},
+	}
+	yamlLoader := loader.NewYAMLLoader(nodeFactories, plugins.NewPluginRegistry())
+
+	yamlContent := `
+metadata:
+  name: on-error-flow
+  on_error: notify
+nodes:
+  start:
+    type: base
+    next:
+      error: handle_failure
+  handle_failure:
+    type: base
+  notify:
+    type: base
+`
+
+	// The on_error node is not mistaken for a second start node
+	flow, err := yamlLoader.Parse(yamlContent)
+	assert.NoError(t, err)
+	assert.Equal(t, "start", flow.Start().Params()["node_id"])
+	assert.Equal(t, "handle_failure", flow.Start().Successors()["error"].Params()["node_id"])
+	assert.Equal(t, "notify", flow.ErrorHandler().Params()["node_id"])
+
+	_, err = yamlLoader.Parse(`
+metadata:
+  name: on-error-flow
+  on_error: missing
+nodes:
+  start:
+    type: base
+`)
+	assert.Error(t, err)
+}
+
+func TestYAMLLoader_Parse_Compensate(t *testing.T) {
+	nodeFactories := map[string]plugins.NodeFactory{
+		"base":      &loader.BaseNodeFactory{},
+		"transform": &loader.BaseNodeFactory{},
+	}
+	yamlLoader := loader.NewYAMLLoader(nodeFactories, plugins.NewPluginRegistry())
+
+	yamlContent := `
+metadata:
+  name: saga-flow
+nodes:
+  insert:
+    type: base
+    compensate:
+      node: delete_row
+    next:
+      default: notify
+  notify:
+    type: base
+    compensate:
+      script: "return {retracted: true}"
+  delete_row:
+    type: base
+`
+
+	// The compensation node is not mistaken for a second start node
+	flow, err := yamlLoader.Parse(yamlContent)
+	assert.NoError(t, err)
+
+	insert := flow.Start()
+	assert.Equal(t, "insert", insert.Params()["node_id"])
+	assert.Equal(t, "delete_row", insert.Successors()[loader.CompensateAction].Params()["node_id"])
+
+	script := insert.Successors()[flowlib.DefaultAction].Successors()[loader.CompensateAction]
+	assert.NotNil(t, script)
+	assert.Equal(t, "notify.compensate", script.Params()["node_id"])
+	assert.Equal(t, "return {retracted: true}", script.Params()["script"])
+
+	// Inline scripts need the transform node type
+	_, err = loader.NewYAMLLoader(map[string]plugins.NodeFactory{
+		"base": &loader.BaseNodeFactory{},
+	}, plugins.NewPluginRegistry()).Parse(yamlContent)
+	assert.Error(t, err)
+}
+
 func TestYAMLLoader_Parse_NoStartNode(t *testing.T) {
 	// Create a map of node factories
 	nodeFactories := map[string]plugins.NodeFactory{
@@ -171,6 +171,126 @@ nodes:
       key: "value"
     next:
       default: "nonexistent"
+`,
+			wantErr: true,
+		},
+		{
+			name: "Valid YAML - Timeouts",
+			yaml: `
+metadata:
+  name: "Test Flow"
+  timeout: "5m"
+nodes:
+  start:
+    type: "test"
+    timeout: "30s"
+`,
+			wantErr: false,
+		},
+		{
+			name: "Invalid YAML - Invalid node timeout",
+			yaml: `
+metadata:
+  name: "Test Flow"
+nodes:
+  start:
+    type: "test"
+    timeout: "soon"
+`,
+			wantErr: true,
+		},
+		{
+			name: "Valid YAML - Retry with backoff",
+			yaml: `
+metadata:
+  name: "Test Flow"
+nodes:
+  start:
+    type: "test"
+    retry:
+      max_retries: 5
+      wait: "1s"
+      backoff: "exponential"
+      max_wait: "30s"
+      jitter: true
+      retry_on: ["timeout", "rate_limit"]
+`,
+			wantErr: false,
+		},
+		{
+			name: "Invalid YAML - Unknown backoff",
+			yaml: `
+metadata:
+  name: "Test Flow"
+nodes:
+  start:
+    type: "test"
+    retry:
+      backoff: "linear"
+`,
+			wantErr: true,
+		},
+		{
+			name: "Invalid YAML - Unknown retry_on class",
+			yaml: `
+metadata:
+  name: "Test Flow"
+nodes:
+  start:
+    type: "test"
+    retry:
+      retry_on: ["sometimes"]
+`,
+			wantErr: true,
+		},
+		{
+			name: "Invalid YAML - Invalid flow timeout",
+			yaml: `
+metadata:
+  name: "Test Flow"
+  timeout: "30"
+nodes:
+  start:
+    type: "test"
+`,
+			wantErr: true,
+		},
+		{
+			name: "Valid YAML - Input and output schemas",
+			yaml: `
+metadata:
+  name: "Test Flow"
+  inputs:
+    type: object
+    required: [email]
+    properties:
+      email:
+        type: string
+        pattern: "^[^@]+@[^@]+$"
+  outputs:
+    type: object
+    properties:
+      sent:
+        type: boolean
+nodes:
+  start:
+    type: "test"
+`,
+			wantErr: false,
+		},
+		{
+			name: "Invalid YAML - Unknown schema type",
+			yaml: `
+metadata:
+  name: "Test Flow"
+  inputs:
+    type: object
+    properties:
+      count:
+        type: int
+nodes:
+  start:
+    type: "test"
 `,
 			wantErr: true,
 		},
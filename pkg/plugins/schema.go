@@ -17,6 +17,15 @@ type NodeDefinition struct {
 	// Retry configuration
 	Retry RetryDefinition `yaml:"retry" json:"retry,omitempty"`
 
+	// Timeout bounds a single run of the node, e.g. "30s"
+	Timeout string `yaml:"timeout" json:"timeout,omitempty"`
+
+	// Compensate undoes the effects of the node when a later step fails the execution
+	Compensate CompensateDefinition `yaml:"compensate" json:"compensate,omitempty"`
+
+	// DryRun declares what the node returns in dry runs instead of running
+	DryRun DryRunDefinition `yaml:"dry_run" json:"dry_run,omitempty"`
+
 	// JavaScript hooks for the node
 	Hooks NodeHooks `yaml:"hooks" json:"hooks,omitempty"`
 }
@@ -31,6 +40,31 @@ type BatchDefinition struct {
 type RetryDefinition struct {
 	MaxRetries int    `yaml:"max_retries" json:"max_retries,omitempty"`
 	Wait       string `yaml:"wait" json:"wait,omitempty"`
+
+	// Backoff is "fixed" (default) or "exponential", which doubles the wait
+	// after every attempt
+	Backoff string `yaml:"backoff" json:"backoff,omitempty"`
+
+	// MaxWait caps the wait between attempts, e.g. "30s"
+	MaxWait string `yaml:"max_wait" json:"max_wait,omitempty"`
+
+	// Jitter randomizes every wait between half and all of it
+	Jitter bool `yaml:"jitter" json:"jitter,omitempty"`
+
+	// RetryOn limits retries to the listed error classes; empty retries all errors
+	RetryOn []string `yaml:"retry_on" json:"retry_on,omitempty"`
+}
+
+// DryRunDefinition declares the stub output a node returns in dry runs
+type DryRunDefinition struct {
+	Output interface{} `yaml:"output" json:"output,omitempty"`
+}
+
+// CompensateDefinition names the node or the inline transform script that
+// compensates a node. Exactly one of them is set.
+type CompensateDefinition struct {
+	Node   string `yaml:"node" json:"node,omitempty"`
+	Script string `yaml:"script" json:"script,omitempty"`
 }
 
 // NodeHooks contains JavaScript code to execute at different stages
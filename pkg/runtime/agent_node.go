@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -52,7 +53,7 @@ func NewAgentNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 	// Create the wrapper
 	wrapper := &NodeWrapper{
 		node: baseNode,
-		exec: func(input interface{}) (interface{}, error) {
+		execWithContext: func(ctx context.Context, input interface{}) (interface{}, error) {
 			// Handle both old format (direct params) and new format (combined input)
 			var nodeParams map[string]interface{}
 			var flowInput map[string]interface{}
@@ -103,7 +104,7 @@ func NewAgentNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 			// The agent node should use the same execution pattern as other nodes
 			// We need to call the exec function directly since we're in a NodeWrapper
 			if wrapper, ok := llmNode.(*NodeWrapper); ok {
-				result, err := wrapper.exec(llmInput)
+				result, err := wrapper.execWithContext(ctx, llmInput)
 				if err != nil {
 					return nil, fmt.Errorf("agent LLM execution failed: %w", err)
 				}
@@ -128,6 +129,7 @@ func NewAgentNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 			return nil, fmt.Errorf("LLM node is not a NodeWrapper")
 		},
 	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
 
 	// Set the parameters
 	wrapper.SetParams(params)
@@ -0,0 +1,229 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/utils"
+)
+
+// Actions of approval nodes
+const (
+	// ApprovedAction follows an approval node once it was approved
+	ApprovedAction = "approved"
+
+	// RejectedAction follows an approval node once it was rejected
+	RejectedAction = "rejected"
+
+	// ExpiredAction follows a waiting node whose wait expired before it was
+	// signaled
+	ExpiredAction = "expired"
+)
+
+// NewApprovalNodeWrapper creates a node that parks its execution until a
+// person approves or rejects it, or the wait expires.
+//
+// Parameters:
+//   - signal: the name of the signal to wait for; defaults to the node ID
+//   - message: what to approve, used in the notifications
+//   - expires_in: how long to wait, such as "48h"; without it the execution
+//     waits until it is signaled or canceled
+//   - notify.email: email.send parameters of an email sent once the node
+//     waits. "{{approve_url}}" and "{{reject_url}}" in the body are replaced
+//     by signed links; without them the links are appended. The body
+//     defaults to the message.
+//   - notify.webhook: url and headers of a webhook that receives the
+//     execution_id, flow_id, node_id, signal, message, approve_url,
+//     reject_url and expires_at of the wait as a JSON POST
+//
+// The links require the public URL of the server to be configured. The node
+// follows the approved, rejected or expired action, and its result holds the
+// decision, the signal, and the payload of the signal if any. Approval nodes
+// cannot run inside loops, split branches or sub-flows.
+func NewApprovalNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
+	// Create the base node
+	baseNode := flowlib.NewNode(1, 0)
+
+	// Create the wrapper
+	wrapper := &NodeWrapper{
+		node: baseNode,
+		execWithContext: func(ctx context.Context, input interface{}) (interface{}, error) {
+			params, _, err := nodeInput(input)
+			if err != nil {
+				return nil, err
+			}
+			scope, err := loopScope(ctx, "approval")
+			if err != nil {
+				return nil, err
+			}
+			if scope.depth > 0 {
+				return nil, fmt.Errorf("approval cannot run inside loops, split branches or sub-flows")
+			}
+
+			wait, err := approvalWait(scope.execution, params)
+			if err != nil {
+				return nil, err
+			}
+			message, _ := params["message"].(string)
+			notify := func(links map[string]string) error {
+				return notifyApproval(ctx, params, wait, message, links)
+			}
+			return nil, scope.runtime.park(scope.execution, wait, notify)
+		},
+		post: func(shared, p, e interface{}) (flowlib.Action, error) {
+			result, _ := e.(map[string]interface{})
+			switch result["decision"] {
+			case RejectedAction:
+				return RejectedAction, nil
+			case ExpiredAction:
+				return ExpiredAction, nil
+			}
+			return ApprovedAction, nil
+		},
+	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
+
+	// Set the parameters
+	wrapper.SetParams(stringKeyedParams(params))
+
+	return wrapper, nil
+}
+
+// approvalWait describes the wait of an approval node in an execution
+func approvalWait(execCtx *executionContext, params map[string]interface{}) (ExecutionWait, error) {
+	nodeID, _ := params["node_id"].(string)
+	signal, _ := params["signal"].(string)
+	if signal == "" {
+		signal = nodeID
+	}
+	if signal == "" {
+		return ExecutionWait{}, fmt.Errorf("signal parameter is required")
+	}
+
+	now := time.Now()
+	wait := ExecutionWait{
+		ExecutionID: execCtx.status.ID,
+		AccountID:   execCtx.accountID,
+		FlowID:      execCtx.flowID,
+		NodeID:      nodeID,
+		Signal:      signal,
+		Action:      ExpiredAction,
+		Result: map[string]interface{}{
+			"decision": ExpiredAction,
+			"signal":   signal,
+		},
+		CreatedAt: now,
+	}
+	if expiresIn, ok := params["expires_in"].(string); ok && expiresIn != "" {
+		duration, err := time.ParseDuration(expiresIn)
+		if err != nil {
+			return ExecutionWait{}, fmt.Errorf("invalid expires_in: %w", err)
+		}
+		wait.Deadline = now.Add(duration)
+	}
+	return wait, nil
+}
+
+// notifyApproval sends the notifications an approval node declares
+func notifyApproval(ctx context.Context, params map[string]interface{}, wait ExecutionWait, message string, links map[string]string) error {
+	notify, _ := params["notify"].(map[string]interface{})
+	if email, ok := notify["email"].(map[string]interface{}); ok {
+		if err := sendApprovalEmail(ctx, email, message, links); err != nil {
+			return fmt.Errorf("failed to send approval email: %w", err)
+		}
+	}
+	if webhook, ok := notify["webhook"].(map[string]interface{}); ok {
+		if err := sendApprovalWebhook(ctx, webhook, wait, message, links); err != nil {
+			return fmt.Errorf("failed to send approval webhook: %w", err)
+		}
+	}
+	return nil
+}
+
+// sendApprovalEmail sends the approval email through an email.send node
+func sendApprovalEmail(ctx context.Context, email map[string]interface{}, message string, links map[string]string) error {
+	params := make(map[string]interface{}, len(email)+1)
+	for key, value := range email {
+		params[key] = value
+	}
+
+	body, _ := params["body"].(string)
+	if body == "" {
+		body = message
+	}
+	if links != nil {
+		if strings.Contains(body, "{{approve_url}}") || strings.Contains(body, "{{reject_url}}") {
+			body = strings.NewReplacer(
+				"{{approve_url}}", links["approve_url"],
+				"{{reject_url}}", links["reject_url"],
+			).Replace(body)
+		} else {
+			body += fmt.Sprintf("\n\nApprove: %s\nReject: %s\n", links["approve_url"], links["reject_url"])
+		}
+	}
+	params["body"] = body
+
+	node, err := NewSMTPNodeWrapper(nil)
+	if err != nil {
+		return err
+	}
+	_, err = node.(*NodeWrapper).execWithContext(ctx, map[string]interface{}{"params": params})
+	return err
+}
+
+// sendApprovalWebhook posts the wait to the webhook
+func sendApprovalWebhook(ctx context.Context, webhook map[string]interface{}, wait ExecutionWait, message string, links map[string]string) error {
+	url, ok := webhook["url"].(string)
+	if !ok || url == "" {
+		return fmt.Errorf("url parameter is required")
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	if declared, ok := webhook["headers"].(map[string]interface{}); ok {
+		for key, value := range declared {
+			headers[key] = fmt.Sprintf("%v", value)
+		}
+	}
+
+	body := map[string]interface{}{
+		"execution_id": wait.ExecutionID,
+		"flow_id":      wait.FlowID,
+		"node_id":      wait.NodeID,
+		"signal":       wait.Signal,
+		"message":      message,
+		"approve_url":  links["approve_url"],
+		"reject_url":   links["reject_url"],
+	}
+	if !wait.Deadline.IsZero() {
+		body["expires_at"] = wait.Deadline.UTC().Format(time.RFC3339)
+	}
+
+	resp, err := utils.NewHTTPClient().DoWithContext(ctx, &utils.HTTPRequest{
+		URL:     url,
+		Method:  "POST",
+		Headers: headers,
+		Body:    body,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// stringKeyedParams converts the nested objects that YAML decodes with
+// interface{} keys, so that nodes can read them as map[string]interface{}
+func stringKeyedParams(params map[string]interface{}) map[string]interface{} {
+	converted := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		if nested, ok := value.(map[interface{}]interface{}); ok {
+			value = convertInterfaceMapToStringMap(nested)
+		}
+		converted[key] = value
+	}
+	return converted
+}
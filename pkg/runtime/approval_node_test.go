@@ -0,0 +1,347 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+// waitTestStore is a checkpointTestStore that supports waits
+type waitTestStore struct {
+	*checkpointTestStore
+	waits map[string]ExecutionWait
+}
+
+func newWaitTestStore() *waitTestStore {
+	return &waitTestStore{
+		checkpointTestStore: newCheckpointTestStore(),
+		waits:               make(map[string]ExecutionWait),
+	}
+}
+
+func (s *waitTestStore) SaveWait(wait ExecutionWait) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.waits[wait.ExecutionID] = wait
+	return nil
+}
+
+func (s *waitTestStore) GetWait(executionID string) (ExecutionWait, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wait, ok := s.waits[executionID]
+	if !ok {
+		return ExecutionWait{}, ErrWaitNotFound
+	}
+	return wait, nil
+}
+
+func (s *waitTestStore) ClaimWait(executionID string) (ExecutionWait, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wait, ok := s.waits[executionID]
+	if !ok {
+		return ExecutionWait{}, ErrWaitNotFound
+	}
+	delete(s.waits, executionID)
+	return wait, nil
+}
+
+func (s *waitTestStore) ListDueWaits(before time.Time) ([]ExecutionWait, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []ExecutionWait
+	for _, wait := range s.waits {
+		if !wait.Deadline.IsZero() && !wait.Deadline.After(before) {
+			due = append(due, wait)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Deadline.Before(due[j].Deadline) })
+	return due, nil
+}
+
+func (s *waitTestStore) ListEventWaits(accountID, event, key string) ([]ExecutionWait, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var waits []ExecutionWait
+	for _, wait := range s.waits {
+		if wait.Event != "" && wait.AccountID == accountID && wait.Event == event && wait.EventKey == key {
+			waits = append(waits, wait)
+		}
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i].CreatedAt.Before(waits[j].CreatedAt) })
+	return waits, nil
+}
+
+// approvalTest runs request -> approval, which continues at the node named
+// after the action it follows
+type approvalTest struct {
+	store    *waitTestStore
+	runtime  *flowRuntime
+	mu       sync.Mutex
+	visited  []string
+	webhooks []map[string]interface{}
+}
+
+func newApprovalTest(t *testing.T, approvalParams map[string]interface{}) *approvalTest {
+	test := &approvalTest{store: newWaitTestStore()}
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		test.mu.Lock()
+		test.webhooks = append(test.webhooks, body)
+		test.mu.Unlock()
+	}))
+	t.Cleanup(webhook.Close)
+
+	newFlow := func() *flowlib.Flow {
+		record := func(nodeID string) flowlib.Node {
+			node := flowlib.NewNode(1, 0)
+			node.SetParams(map[string]interface{}{"node_id": nodeID})
+			node.SetPrepFn(func(shared any) (any, error) {
+				test.mu.Lock()
+				test.visited = append(test.visited, nodeID)
+				test.mu.Unlock()
+				return nil, nil
+			})
+			return node
+		}
+
+		params := map[string]interface{}{
+			"node_id":   "approve",
+			"node_type": "approval",
+			"message":   "Ship the order?",
+			"notify": map[interface{}]interface{}{
+				"webhook": map[interface{}]interface{}{"url": webhook.URL},
+			},
+		}
+		for key, value := range approvalParams {
+			params[key] = value
+		}
+		approval, err := NewApprovalNodeWrapper(params)
+		require.NoError(t, err)
+
+		request := record("request")
+		request.Next(flowlib.DefaultAction, approval)
+		for _, action := range []string{ApprovedAction, RejectedAction, ExpiredAction} {
+			approval.Next(action, record(action))
+		}
+		return flowlib.NewFlow(request)
+	}
+
+	flowDef := &Flow{ID: "approval-flow", YAML: "approval"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "approval-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newFlow(), nil)
+
+	test.runtime = NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, test.store).(*flowRuntime)
+	test.runtime.SetSignalLinks(SignalLinks{BaseURL: "https://flows.example.com/", Key: []byte("signing-key")})
+	return test
+}
+
+// start runs the flow until the approval node parks it
+func (a *approvalTest) start(t *testing.T) string {
+	executionID, err := a.runtime.Execute("test-account", "approval-flow", map[string]interface{}{"order": "42"})
+	require.NoError(t, err)
+	waitForStatus(t, a.store.checkpointTestStore, executionID, "waiting")
+	require.Eventually(t, func() bool {
+		a.runtime.mu.RLock()
+		defer a.runtime.mu.RUnlock()
+		_, active := a.runtime.activeExecutions[executionID]
+		return !active
+	}, 2*time.Second, 10*time.Millisecond)
+	return executionID
+}
+
+func (a *approvalTest) visitedNodes() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.visited...)
+}
+
+func TestApprovalNode_ApprovedBySignedLink(t *testing.T) {
+	test := newApprovalTest(t, map[string]interface{}{"expires_in": "1h"})
+	executionID := test.start(t)
+	assert.Equal(t, []string{"request"}, test.visitedNodes())
+
+	wait, err := test.store.GetWait(executionID)
+	require.NoError(t, err)
+	assert.Equal(t, "approve", wait.Signal)
+	assert.Equal(t, "test-account", wait.AccountID)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), wait.Deadline, time.Minute)
+
+	test.mu.Lock()
+	require.Len(t, test.webhooks, 1)
+	notification := test.webhooks[0]
+	test.mu.Unlock()
+	assert.Equal(t, executionID, notification["execution_id"])
+	assert.Equal(t, "Ship the order?", notification["message"])
+	assert.NotEmpty(t, notification["expires_at"])
+
+	approveURL, err := url.Parse(notification["approve_url"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "flows.example.com", approveURL.Host)
+	assert.Equal(t, "/api/v1/executions/"+executionID+"/signals/approve", approveURL.Path)
+	token := approveURL.Query().Get("token")
+
+	// The approve token cannot reject, and other accounts cannot signal
+	err = test.runtime.Signal(executionID, Signal{Name: "approve", Decision: DecisionReject, Token: token})
+	assert.ErrorIs(t, err, ErrSignalUnauthorized)
+	err = test.runtime.Signal(executionID, Signal{Name: "approve", Decision: DecisionApprove, AccountID: "other-account"})
+	assert.ErrorIs(t, err, ErrSignalUnauthorized)
+	err = test.runtime.Signal(executionID, Signal{Name: "other", Decision: DecisionApprove, Token: token})
+	assert.ErrorIs(t, err, ErrWaitNotFound)
+	err = test.runtime.Signal(executionID, Signal{Name: "approve", Decision: "maybe", AccountID: "test-account"})
+	assert.ErrorIs(t, err, ErrInvalidSignal)
+
+	err = test.runtime.Signal(executionID, Signal{
+		Name:     "approve",
+		Decision: DecisionApprove,
+		Token:    token,
+		Payload:  map[string]interface{}{"comment": "ok"},
+	})
+	require.NoError(t, err)
+	waitForStatus(t, test.store.checkpointTestStore, executionID, "completed")
+	assert.Equal(t, []string{"request", ApprovedAction}, test.visitedNodes())
+
+	// The wait was claimed, so the link works only once
+	err = test.runtime.Signal(executionID, Signal{Name: "approve", Decision: DecisionApprove, Token: token})
+	assert.ErrorIs(t, err, ErrWaitNotFound)
+
+	checkpoints, err := test.store.GetExecutionCheckpoints(executionID)
+	require.NoError(t, err)
+	output := checkpoints[len(checkpoints)-1].Shared[NodeOutputsKey].(map[string]interface{})["approve"].(map[string]interface{})
+	assert.Equal(t, ApprovedAction, output["decision"])
+	assert.Equal(t, map[string]interface{}{"comment": "ok"}, output["payload"])
+
+	traces, err := test.store.GetExecutionTrace(executionID)
+	require.NoError(t, err)
+	var approvalTrace *NodeTrace
+	for i := range traces {
+		if traces[i].NodeID == "approve" {
+			approvalTrace = &traces[i]
+		}
+	}
+	require.NotNil(t, approvalTrace)
+	assert.Equal(t, ApprovedAction, approvalTrace.Action)
+	assert.Equal(t, 2, approvalTrace.Sequence)
+}
+
+func TestApprovalNode_WaitReturnsOnceDecided(t *testing.T) {
+	test := newApprovalTest(t, map[string]interface{}{"expires_in": "1h"})
+	executionID := test.start(t)
+
+	// A parked execution has not finished
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	status, err := test.runtime.Wait(ctx, executionID)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "waiting", status.Status)
+
+	waited := make(chan ExecutionStatus, 1)
+	go func() {
+		status, err := test.runtime.Wait(context.Background(), executionID)
+		assert.NoError(t, err)
+		waited <- status
+	}()
+	require.NoError(t, test.runtime.Signal(executionID, Signal{Name: "approve", Decision: DecisionApprove, AccountID: "test-account"}))
+
+	select {
+	case status := <-waited:
+		assert.Equal(t, "completed", status.Status)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait did not return after the approval")
+	}
+}
+
+func TestApprovalNode_ResumeKeepsStartTime(t *testing.T) {
+	test := newApprovalTest(t, map[string]interface{}{"expires_in": "1h"})
+	executionID := test.start(t)
+
+	parked, err := test.store.GetExecution(executionID)
+	require.NoError(t, err)
+	assert.Empty(t, parked.Metadata[RunningSinceKey])
+
+	signaled := time.Now()
+	require.NoError(t, test.runtime.Signal(executionID, Signal{Name: "approve", Decision: DecisionApprove, AccountID: "test-account"}))
+	waitForStatus(t, test.store.checkpointTestStore, executionID, "completed")
+
+	// The flow timeout restarts, but the execution keeps its start time
+	status, err := test.store.GetExecution(executionID)
+	require.NoError(t, err)
+	assert.True(t, status.StartTime.Equal(parked.StartTime))
+	runningSince, err := time.Parse(time.RFC3339Nano, status.Metadata[RunningSinceKey])
+	require.NoError(t, err)
+	assert.False(t, runningSince.Before(signaled))
+	assert.True(t, timeoutBase(status).Equal(runningSince))
+}
+
+func TestApprovalNode_RejectedByAccount(t *testing.T) {
+	test := newApprovalTest(t, map[string]interface{}{"signal": "ship"})
+	executionID := test.start(t)
+
+	wait, err := test.store.GetWait(executionID)
+	require.NoError(t, err)
+	assert.True(t, wait.Deadline.IsZero())
+
+	err = test.runtime.Signal(executionID, Signal{Name: "ship", Decision: DecisionReject, AccountID: "test-account"})
+	require.NoError(t, err)
+	waitForStatus(t, test.store.checkpointTestStore, executionID, "completed")
+	assert.Equal(t, []string{"request", RejectedAction}, test.visitedNodes())
+}
+
+func TestApprovalNode_Expires(t *testing.T) {
+	test := newApprovalTest(t, map[string]interface{}{"expires_in": "1ms"})
+	executionID := test.start(t)
+
+	test.runtime.expireWaits(test.store, time.Now().Add(time.Second))
+	waitForStatus(t, test.store.checkpointTestStore, executionID, "completed")
+	assert.Equal(t, []string{"request", ExpiredAction}, test.visitedNodes())
+
+	_, err := test.store.GetWait(executionID)
+	assert.ErrorIs(t, err, ErrWaitNotFound)
+}
+
+func TestApprovalNode_CancelWhileWaiting(t *testing.T) {
+	test := newApprovalTest(t, nil)
+	executionID := test.start(t)
+
+	require.NoError(t, test.runtime.Cancel(executionID))
+	status := waitForStatus(t, test.store.checkpointTestStore, executionID, "canceled")
+	assert.Equal(t, "Execution was canceled by user", status.Error)
+
+	err := test.runtime.Signal(executionID, Signal{Name: "approve", Decision: DecisionApprove, AccountID: "test-account"})
+	assert.ErrorIs(t, err, ErrWaitNotFound)
+	assert.Equal(t, []string{"request"}, test.visitedNodes())
+}
+
+func TestSignalToken(t *testing.T) {
+	key := []byte("signing-key")
+	now := time.Now()
+	wait := ExecutionWait{ExecutionID: "exec-1", Signal: "approve", Deadline: now.Add(time.Hour)}
+	token := signalToken(key, wait, DecisionApprove)
+
+	assert.True(t, validSignalToken(key, token, wait, DecisionApprove, now))
+	assert.False(t, validSignalToken(key, token, wait, DecisionReject, now))
+	assert.False(t, validSignalToken([]byte("other-key"), token, wait, DecisionApprove, now))
+	assert.False(t, validSignalToken(key, token, ExecutionWait{ExecutionID: "exec-2", Signal: "approve", Deadline: wait.Deadline}, DecisionApprove, now))
+	assert.False(t, validSignalToken(key, token, wait, DecisionApprove, now.Add(2*time.Hour)))
+	assert.False(t, validSignalToken(key, "garbage", wait, DecisionApprove, now))
+
+	// Waits without a deadline have tokens that do not expire
+	wait.Deadline = time.Time{}
+	token = signalToken(key, wait, DecisionApprove)
+	assert.True(t, validSignalToken(key, token, wait, DecisionApprove, now.Add(24*365*time.Hour)))
+}
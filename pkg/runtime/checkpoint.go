@@ -0,0 +1,228 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+)
+
+// saveCheckpoint persists the shared state and the node about to run, if the
+// execution store supports checkpoints
+func (r *flowRuntime) saveCheckpoint(execCtx *executionContext, step int, next flowlib.Node, shared map[string]interface{}) {
+	store, ok := r.executionStore.(CheckpointStore)
+	if !ok {
+		return
+	}
+
+	snapshot, err := snapshotSharedState(shared)
+	if err != nil {
+		r.logExecution(execCtx.status.ID, "warning", "Skipping checkpoint: shared state is not serializable", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	checkpoint := ExecutionCheckpoint{
+		ExecutionID: execCtx.status.ID,
+		AccountID:   execCtx.accountID,
+		FlowID:      execCtx.flowID,
+		Step:        step,
+		NodeID:      nodeIDOf(next),
+		Shared:      snapshot,
+		CreatedAt:   time.Now(),
+	}
+	if err := store.SaveCheckpoint(checkpoint); err != nil {
+		r.logExecution(execCtx.status.ID, "error", "Failed to save checkpoint", map[string]interface{}{"error": err.Error(), "node_id": checkpoint.NodeID})
+	}
+}
+
+// snapshotSharedState returns a deep copy of the shared state without the
+// runtime-internal keys (prefixed with "_"), which are rebuilt on resume.
+// The compensation record and the node outputs are internal too, but cannot
+// be rebuilt.
+func snapshotSharedState(shared map[string]interface{}) (map[string]interface{}, error) {
+	filtered := make(map[string]interface{}, len(shared))
+	for k, v := range shared {
+		if strings.HasPrefix(k, "_") && k != compensationsKey && k != NodeOutputsKey {
+			continue
+		}
+		filtered[k] = v
+	}
+
+	data, err := json.Marshal(filtered)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// nodeIDOf returns the node ID injected by the loader, or "" if unknown
+func nodeIDOf(node flowlib.Node) string {
+	if node == nil {
+		return ""
+	}
+	if params := node.Params(); params != nil {
+		if id, ok := params["node_id"].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// findNode searches the flow graph reachable from start for the node with the given ID
+func findNode(start flowlib.Node, nodeID string) flowlib.Node {
+	visited := make(map[flowlib.Node]bool)
+	queue := []flowlib.Node{start}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if node == nil || visited[node] {
+			continue
+		}
+		visited[node] = true
+
+		if nodeIDOf(node) == nodeID {
+			return node
+		}
+		for _, successor := range node.Successors() {
+			queue = append(queue, successor)
+		}
+	}
+	return nil
+}
+
+// Starter is implemented by runtimes with background work: resuming the
+// executions interrupted by a previous shutdown and expiring waits
+type Starter interface {
+	// Start begins the background work. Call it once the runtime is
+	// configured, so that resumed executions respect the concurrency limits.
+	// Later calls have no effect.
+	Start()
+}
+
+// resumeInterruptedExecutions restarts executions that were still running when
+// the previous process stopped. Nodes run at least once: the node that was in
+// progress at shutdown runs again from its checkpoint. With an
+// ExecutionClaimer store only the executions whose lease expired are resumed,
+// each by one process; otherwise every process resumes every interrupted
+// execution, so only one process may use the store.
+func (r *flowRuntime) resumeInterruptedExecutions() {
+	store, ok := r.executionStore.(CheckpointStore)
+	if !ok {
+		return
+	}
+
+	checkpoints, err := store.ListInterruptedExecutions()
+	if err != nil {
+		fmt.Printf("Failed to list interrupted executions: %v\n", err)
+		return
+	}
+
+	for _, checkpoint := range checkpoints {
+		r.mu.RLock()
+		_, active := r.activeExecutions[checkpoint.ExecutionID]
+		r.mu.RUnlock()
+		if active {
+			continue
+		}
+		claimed, err := r.claimExecution(checkpoint.ExecutionID)
+		if err != nil {
+			fmt.Printf("Failed to claim execution %s: %v\n", checkpoint.ExecutionID, err)
+			continue
+		}
+		if !claimed {
+			// Another process runs the execution
+			continue
+		}
+
+		if err := r.resumeExecution(checkpoint); err != nil {
+			fmt.Printf("Failed to resume execution %s: %v\n", checkpoint.ExecutionID, err)
+
+			// Mark the execution as failed so it is not retried on every restart
+			status, getErr := r.executionStore.GetExecution(checkpoint.ExecutionID)
+			if getErr != nil {
+				continue
+			}
+			status.Status = "failed"
+			status.Error = fmt.Sprintf("failed to resume execution: %v", err)
+			status.EndTime = time.Now()
+			if saveErr := r.executionStore.SaveExecution(status); saveErr != nil {
+				fmt.Printf("Failed to save execution status: %v\n", saveErr)
+			}
+		}
+	}
+}
+
+// resumeExecution continues an execution from the given checkpoint
+func (r *flowRuntime) resumeExecution(checkpoint ExecutionCheckpoint) error {
+	flow, settings, err := r.loadFlow(checkpoint.AccountID, checkpoint.FlowID, "")
+	if err != nil {
+		return err
+	}
+
+	node := findNode(flow.Start(), checkpoint.NodeID)
+	if node == nil {
+		return fmt.Errorf("checkpoint node %q not found in flow %s", checkpoint.NodeID, checkpoint.FlowID)
+	}
+
+	status, err := r.executionStore.GetExecution(checkpoint.ExecutionID)
+	if err != nil {
+		return fmt.Errorf("failed to get execution: %w", err)
+	}
+	if isFinished(status.Status) {
+		// The execution finished after it was found interrupted
+		r.releaseExecution(status.ID)
+		return nil
+	}
+	if parentID := status.Metadata[ParentExecutionIDKey]; parentID != "" {
+		// The parent resumes at its flow.call node and starts a new child
+		return fmt.Errorf("sub-flow execution is re-run by parent execution %s", parentID)
+	}
+	if status.Results == nil {
+		status.Results = make(map[string]interface{})
+	}
+
+	ctx, execCtx := r.trackExecution(context.Background(), checkpoint.AccountID, settings, status)
+
+	// Resumed executions count against the concurrency limits like new ones
+	r.schedule(execCtx, nil, nil, func() {
+		r.continueExecution(withExecutionScope(ctx, r, execCtx), execCtx, flow, node, checkpoint)
+	})
+
+	return nil
+}
+
+// continueExecution runs the remainder of a resumed execution
+func (r *flowRuntime) continueExecution(ctx context.Context, execCtx *executionContext, flow *flowlib.Flow, node flowlib.Node, checkpoint ExecutionCheckpoint) {
+	defer r.finishExecution(execCtx)
+
+	ctx, cancel := withFlowTimeout(ctx, execCtx)
+	defer cancel()
+
+	r.logExecution(execCtx.status.ID, "info", "Resuming flow execution from checkpoint", map[string]interface{}{
+		"node_id": checkpoint.NodeID,
+		"step":    checkpoint.Step,
+	})
+
+	execCtx.mu.Lock()
+	execCtx.sequence = checkpoint.Step
+	execCtx.mu.Unlock()
+	r.restoreNodeVisits(execCtx)
+	shared := r.newSharedState(execCtx, checkpoint.Shared)
+	action, err := r.runFlowGraph(ctx, execCtx, node, shared, checkpoint.Step)
+
+	var result interface{}
+	if err == nil {
+		result = flowResult(action, shared)
+	} else {
+		r.handleFailure(ctx, execCtx, flow, shared, err)
+	}
+	r.completeExecution(execCtx, result, err)
+}
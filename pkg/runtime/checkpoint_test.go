@@ -0,0 +1,432 @@
+package runtime
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+// checkpointTestStore is an in-memory execution store that supports checkpoints
+type checkpointTestStore struct {
+	mu          sync.Mutex
+	executions  map[string]ExecutionStatus
+	checkpoints map[string][]ExecutionCheckpoint
+	logs        map[string][]ExecutionLog
+	idempotency map[string]string
+	traces      map[string][]NodeTrace
+}
+
+func newCheckpointTestStore() *checkpointTestStore {
+	return &checkpointTestStore{
+		executions:  make(map[string]ExecutionStatus),
+		checkpoints: make(map[string][]ExecutionCheckpoint),
+		logs:        make(map[string][]ExecutionLog),
+		idempotency: make(map[string]string),
+		traces:      make(map[string][]NodeTrace),
+	}
+}
+
+func (s *checkpointTestStore) SaveNodeTrace(trace NodeTrace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.traces[trace.ExecutionID] = append(s.traces[trace.ExecutionID], trace)
+	return nil
+}
+
+// GetExecutionTrace orders the visits by sequence like the real stores
+func (s *checkpointTestStore) GetExecutionTrace(executionID string) ([]NodeTrace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	traces := append([]NodeTrace(nil), s.traces[executionID]...)
+	sort.SliceStable(traces, func(i, j int) bool {
+		return traces[i].Sequence < traces[j].Sequence
+	})
+	return traces, nil
+}
+
+// ClaimIdempotencyKey keeps keys forever, the window is tested by the storage packages
+func (s *checkpointTestStore) ClaimIdempotencyKey(accountID, key, executionID string, since time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if recorded, ok := s.idempotency[accountID+"/"+key]; ok {
+		return recorded, nil
+	}
+	s.idempotency[accountID+"/"+key] = executionID
+	return executionID, nil
+}
+
+func (s *checkpointTestStore) ReleaseIdempotencyKey(accountID, key, executionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.idempotency[accountID+"/"+key] == executionID {
+		delete(s.idempotency, accountID+"/"+key)
+	}
+	return nil
+}
+
+func (s *checkpointTestStore) SaveExecution(execution ExecutionStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.executions[execution.ID] = execution
+	return nil
+}
+
+func (s *checkpointTestStore) GetExecution(executionID string) (ExecutionStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.executions[executionID], nil
+}
+
+func (s *checkpointTestStore) ListExecutions(accountID string) ([]ExecutionStatus, error) {
+	return nil, nil
+}
+
+func (s *checkpointTestStore) SaveExecutionLog(executionID string, log ExecutionLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs[executionID] = append(s.logs[executionID], log)
+	return nil
+}
+
+func (s *checkpointTestStore) GetExecutionLogs(executionID string) ([]ExecutionLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ExecutionLog(nil), s.logs[executionID]...), nil
+}
+
+func (s *checkpointTestStore) SaveCheckpoint(checkpoint ExecutionCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[checkpoint.ExecutionID] = append(s.checkpoints[checkpoint.ExecutionID], checkpoint)
+	return nil
+}
+
+func (s *checkpointTestStore) GetExecutionCheckpoints(executionID string) ([]ExecutionCheckpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ExecutionCheckpoint(nil), s.checkpoints[executionID]...), nil
+}
+
+func (s *checkpointTestStore) ListInterruptedExecutions() ([]ExecutionCheckpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var interrupted []ExecutionCheckpoint
+	for id, execution := range s.executions {
+		if checkpoints := s.checkpoints[id]; (execution.Status == "running" || execution.Status == "queued") && len(checkpoints) > 0 {
+			interrupted = append(interrupted, checkpoints[len(checkpoints)-1])
+		}
+	}
+	return interrupted, nil
+}
+
+func (s *checkpointTestStore) status(executionID string) ExecutionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.executions[executionID]
+}
+
+// newCountingFlow builds first -> second -> third, where every node increments
+// shared["counter"] and records its ID in visited
+func newCountingFlow(visited *[]string, mu *sync.Mutex) *flowlib.Flow {
+	nodes := make([]flowlib.Node, 0, 3)
+	for _, id := range []string{"first", "second", "third"} {
+		nodeID := id
+		node := flowlib.NewNode(1, 0)
+		node.SetParams(map[string]interface{}{"node_id": nodeID})
+		node.SetPrepFn(func(shared any) (any, error) {
+			sharedMap := shared.(map[string]interface{})
+			counter, _ := sharedMap["counter"].(float64)
+			sharedMap["counter"] = counter + 1
+
+			mu.Lock()
+			*visited = append(*visited, nodeID)
+			mu.Unlock()
+			return nil, nil
+		})
+		if len(nodes) > 0 {
+			nodes[len(nodes)-1].Next(flowlib.DefaultAction, node)
+		}
+		nodes = append(nodes, node)
+	}
+	return flowlib.NewFlow(nodes[0])
+}
+
+func waitForStatus(t *testing.T, store *checkpointTestStore, executionID, status string) ExecutionStatus {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if current := store.status(executionID); current.Status == status {
+			return current
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("execution %s did not reach status %q", executionID, status)
+	return ExecutionStatus{}
+}
+
+func TestFlowRuntime_SavesCheckpointBeforeEachNode(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+
+	flowDef := &Flow{ID: "counting-flow", YAML: "counting"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "counting-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newCountingFlow(&visited, &mu), nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store)
+
+	executionID, err := flowRuntime.Execute("test-account", "counting-flow", map[string]interface{}{"counter": float64(0)})
+	assert.NoError(t, err)
+	waitForStatus(t, store, executionID, "completed")
+
+	store.mu.Lock()
+	checkpoints := store.checkpoints[executionID]
+	store.mu.Unlock()
+
+	assert.Len(t, checkpoints, 3)
+	for i, nodeID := range []string{"first", "second", "third"} {
+		assert.Equal(t, i, checkpoints[i].Step)
+		assert.Equal(t, nodeID, checkpoints[i].NodeID)
+		assert.Equal(t, "test-account", checkpoints[i].AccountID)
+		assert.Equal(t, float64(i), checkpoints[i].Shared["counter"])
+		// Runtime-internal keys are rebuilt on resume and must not be persisted
+		assert.NotContains(t, checkpoints[i].Shared, "_execution")
+	}
+}
+
+func TestFlowRuntime_ResumesInterruptedExecution(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+
+	flowDef := &Flow{ID: "counting-flow", YAML: "counting"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "counting-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newCountingFlow(&visited, &mu), nil)
+
+	// Simulate a process that died after the first node completed
+	store := newCheckpointTestStore()
+	store.executions["interrupted"] = ExecutionStatus{
+		ID:        "interrupted",
+		FlowID:    "counting-flow",
+		Status:    "running",
+		StartTime: time.Now(),
+	}
+	store.checkpoints["interrupted"] = []ExecutionCheckpoint{{
+		ExecutionID: "interrupted",
+		AccountID:   "test-account",
+		FlowID:      "counting-flow",
+		Step:        1,
+		NodeID:      "second",
+		Shared:      map[string]interface{}{"counter": float64(1)},
+	}}
+
+	// Constructing the runtime does not resume executions yet
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store)
+	assert.Equal(t, "running", store.status("interrupted").Status)
+	mu.Lock()
+	assert.Empty(t, visited)
+	mu.Unlock()
+
+	flowRuntime.(Starter).Start()
+
+	status := waitForStatus(t, store, "interrupted", "completed")
+	assert.Equal(t, 100.0, status.Progress)
+
+	mu.Lock()
+	assert.Equal(t, []string{"second", "third"}, visited)
+	mu.Unlock()
+
+	store.mu.Lock()
+	checkpoints := store.checkpoints["interrupted"]
+	store.mu.Unlock()
+	last := checkpoints[len(checkpoints)-1]
+	assert.Equal(t, 2, last.Step)
+	assert.Equal(t, "third", last.NodeID)
+	assert.Equal(t, float64(2), last.Shared["counter"])
+}
+
+// leaseTestStore is a checkpointTestStore that records execution leases
+type leaseTestStore struct {
+	*checkpointTestStore
+	leases map[string]executionLease
+}
+
+type executionLease struct {
+	owner string
+	until time.Time
+}
+
+func newLeaseTestStore() *leaseTestStore {
+	return &leaseTestStore{
+		checkpointTestStore: newCheckpointTestStore(),
+		leases:              make(map[string]executionLease),
+	}
+}
+
+func (s *leaseTestStore) ClaimExecution(executionID, owner string, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lease, ok := s.leases[executionID]; ok && lease.owner != owner && lease.until.After(time.Now()) {
+		return false, nil
+	}
+	s.leases[executionID] = executionLease{owner: owner, until: until}
+	return true, nil
+}
+
+func (s *leaseTestStore) ReleaseExecution(executionID, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leases[executionID].owner == owner {
+		delete(s.leases, executionID)
+	}
+	return nil
+}
+
+func TestFlowRuntime_ResumesExecutionsOfExpiredLeasesOnce(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+
+	flowDef := &Flow{ID: "counting-flow", YAML: "counting"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "counting-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newCountingFlow(&visited, &mu), nil)
+
+	// One execution runs in a live process, the other one's process died
+	store := newLeaseTestStore()
+	for executionID, owner := range map[string]executionLease{
+		"owned":    {owner: "live-process", until: time.Now().Add(time.Minute)},
+		"orphaned": {owner: "crashed-process", until: time.Now().Add(-time.Second)},
+	} {
+		store.executions[executionID] = ExecutionStatus{
+			ID:        executionID,
+			FlowID:    "counting-flow",
+			Status:    "running",
+			StartTime: time.Now(),
+		}
+		store.checkpoints[executionID] = []ExecutionCheckpoint{{
+			ExecutionID: executionID,
+			AccountID:   "test-account",
+			FlowID:      "counting-flow",
+			Step:        1,
+			NodeID:      "second",
+			Shared:      map[string]interface{}{"counter": float64(1)},
+		}}
+		store.leases[executionID] = owner
+	}
+
+	// Two processes share the store
+	replicas := []*flowRuntime{
+		NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store).(*flowRuntime),
+		NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store).(*flowRuntime),
+	}
+	var wg sync.WaitGroup
+	for _, replica := range replicas {
+		wg.Add(1)
+		go func(replica *flowRuntime) {
+			defer wg.Done()
+			replica.resumeInterruptedExecutions()
+		}(replica)
+	}
+	wg.Wait()
+
+	waitForStatus(t, store.checkpointTestStore, "orphaned", "completed")
+	mu.Lock()
+	assert.Equal(t, []string{"second", "third"}, visited)
+	mu.Unlock()
+	assert.Equal(t, "running", store.status("owned").Status)
+
+	// Finished executions release their lease
+	assert.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		_, held := store.leases["orphaned"]
+		return !held
+	}, 2*time.Second, 10*time.Millisecond)
+	store.mu.Lock()
+	assert.Equal(t, "live-process", store.leases["owned"].owner)
+	store.mu.Unlock()
+}
+
+func TestFlowRuntime_ResumeFailsForUnknownNode(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+
+	flowDef := &Flow{ID: "counting-flow", YAML: "counting"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "counting-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", mock.Anything).Return(newCountingFlow(&visited, &mu), nil)
+
+	store := newCheckpointTestStore()
+	store.executions["interrupted"] = ExecutionStatus{ID: "interrupted", FlowID: "counting-flow", Status: "running"}
+	store.checkpoints["interrupted"] = []ExecutionCheckpoint{{
+		ExecutionID: "interrupted",
+		AccountID:   "test-account",
+		FlowID:      "counting-flow",
+		Step:        1,
+		NodeID:      "removed-node",
+	}}
+
+	NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store).(Starter).Start()
+
+	status := store.status("interrupted")
+	assert.Equal(t, "failed", status.Status)
+	assert.Contains(t, status.Error, "removed-node")
+	assert.Empty(t, visited)
+}
+
+func TestFlowRuntime_ResumedExecutionsRespectConcurrencyLimits(t *testing.T) {
+	release := make(chan struct{})
+	block := flowlib.NewNode(1, 0)
+	block.SetParams(map[string]interface{}{"node_id": "block"})
+	block.SetPrepFn(func(shared any) (any, error) {
+		<-release
+		return nil, nil
+	})
+
+	flowDef := &Flow{ID: "blocking-flow", YAML: "blocking"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "blocking-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flowlib.NewFlow(block), nil)
+
+	store := newCheckpointTestStore()
+	executionIDs := []string{"interrupted-1", "interrupted-2"}
+	for _, executionID := range executionIDs {
+		store.executions[executionID] = ExecutionStatus{ID: executionID, FlowID: "blocking-flow", Status: "running", StartTime: time.Now()}
+		store.checkpoints[executionID] = []ExecutionCheckpoint{{
+			ExecutionID: executionID,
+			AccountID:   "test-account",
+			FlowID:      "blocking-flow",
+			NodeID:      "block",
+			Shared:      map[string]interface{}{},
+		}}
+	}
+
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store)
+	flowRuntime.(ConcurrencyLimiter).SetConcurrencyLimits(ConcurrencyLimits{MaxConcurrent: 1})
+	flowRuntime.(Starter).Start()
+
+	// Only one resumed execution runs, the other waits for its slot
+	require.Eventually(t, func() bool {
+		statuses := map[string]int{}
+		for _, executionID := range executionIDs {
+			statuses[store.status(executionID).Status]++
+		}
+		return statuses["running"] == 1 && statuses["queued"] == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	close(release)
+	for _, executionID := range executionIDs {
+		waitForStatus(t, store, executionID, "completed")
+	}
+}
@@ -0,0 +1,129 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/loader"
+)
+
+// compensationsKey is the shared state key that records the completed nodes
+// declaring a compensation, oldest first. Keeping the record in the shared
+// state carries it through checkpoints.
+const compensationsKey = "_compensations"
+
+// recordCompletion remembers a completed node that declares a compensation,
+// together with the shared result after it ran
+func recordCompletion(node flowlib.Node, shared map[string]interface{}) {
+	if node.Successors()[loader.CompensateAction] == nil {
+		return
+	}
+	completed, _ := shared[compensationsKey].([]interface{})
+	shared[compensationsKey] = append(completed, map[string]interface{}{
+		"node_id": nodeIDOf(node),
+		"result":  shared["result"],
+	})
+}
+
+// nestedCompletionsKey is the context key under which the running node
+// collects the completions of the nodes nested in it
+type nestedCompletionsKey struct{}
+
+// nestedCompletions collects the completions recorded in the loop bodies and
+// split branches of the running node. They run on shared states of their
+// own, so their completions are handed over to be compensated with the
+// execution.
+type nestedCompletions struct {
+	mu      sync.Mutex
+	entries []interface{}
+}
+
+// withNestedCompletions returns a context in which the running node collects
+// the completions of the nodes nested in it
+func withNestedCompletions(ctx context.Context) (context.Context, *nestedCompletions) {
+	nested := &nestedCompletions{}
+	return context.WithValue(ctx, nestedCompletionsKey{}, nested), nested
+}
+
+// handOverCompletions moves the completions recorded in shared, except the
+// first skip ones, to the node running in ctx
+func handOverCompletions(ctx context.Context, shared map[string]interface{}, skip int) {
+	completed, _ := shared[compensationsKey].([]interface{})
+	if len(completed) <= skip {
+		return
+	}
+	if skip == 0 {
+		delete(shared, compensationsKey)
+	} else {
+		shared[compensationsKey] = completed[:skip]
+	}
+
+	nested, ok := ctx.Value(nestedCompletionsKey{}).(*nestedCompletions)
+	if !ok {
+		return
+	}
+	nested.mu.Lock()
+	nested.entries = append(nested.entries, completed[skip:]...)
+	nested.mu.Unlock()
+}
+
+// record adds the collected completions to the completions in shared
+func (n *nestedCompletions) record(shared map[string]interface{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.entries) == 0 {
+		return
+	}
+	completed, _ := shared[compensationsKey].([]interface{})
+	shared[compensationsKey] = append(completed, n.entries...)
+	n.entries = nil
+}
+
+// compensate runs the compensations of the completed nodes in reverse order.
+// Each compensation receives the ID and result of the node it undoes as its
+// input. A failed compensation is logged and does not stop the others.
+func (r *flowRuntime) compensate(ctx context.Context, execCtx *executionContext, flow *flowlib.Flow, shared map[string]interface{}) {
+	completed, _ := shared[compensationsKey].([]interface{})
+	if len(completed) == 0 {
+		return
+	}
+
+	input, hadInput := shared["input"]
+	defer func() {
+		if hadInput {
+			shared["input"] = input
+		} else {
+			delete(shared, "input")
+		}
+		delete(shared, compensationsKey)
+	}()
+
+	for i := len(completed) - 1; i >= 0; i-- {
+		entry, _ := completed[i].(map[string]interface{})
+		nodeID, _ := entry["node_id"].(string)
+
+		var compensation flowlib.Node
+		if node := findNode(flow.Start(), nodeID); node != nil {
+			compensation = node.Successors()[loader.CompensateAction]
+		}
+		if compensation == nil {
+			r.logExecution(execCtx.status.ID, "error", "Compensation not found", map[string]interface{}{"node_id": nodeID})
+			continue
+		}
+
+		shared["input"] = entry
+		if _, err := r.runNode(ctx, execCtx, compensation, shared); err != nil {
+			r.logExecution(execCtx.status.ID, "error", "Compensation failed", map[string]interface{}{
+				"node_id":      nodeID,
+				"compensation": nodeIDOf(compensation),
+				"error":        err.Error(),
+			})
+			continue
+		}
+		r.logExecution(execCtx.status.ID, "info", "Compensated node", map[string]interface{}{
+			"node_id":      nodeID,
+			"compensation": nodeIDOf(compensation),
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package runtime
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/loader"
+)
+
+func TestFlowRuntime_CompensatesCompletedNodesInReverse(t *testing.T) {
+	var mu sync.Mutex
+	var undone []interface{}
+
+	// step returns a node that sets the shared result, compensated by a node
+	// that records the input it receives
+	step := func(id string) *flowlib.NodeWithRetry {
+		node := flowlib.NewNode(1, 0)
+		node.SetParams(map[string]interface{}{"node_id": id})
+		node.SetPrepFn(func(shared any) (any, error) {
+			shared.(map[string]interface{})["result"] = id + " done"
+			return nil, nil
+		})
+
+		undo := flowlib.NewNode(1, 0)
+		undo.SetParams(map[string]interface{}{"node_id": "undo_" + id})
+		undo.SetPrepFn(func(shared any) (any, error) {
+			mu.Lock()
+			undone = append(undone, shared.(map[string]interface{})["input"])
+			mu.Unlock()
+			return nil, nil
+		})
+		node.Next(loader.CompensateAction, undo)
+		return node
+	}
+
+	insert := step("insert")
+	charge := step("charge")
+	insert.Next(flowlib.DefaultAction, charge)
+	charge.Next(flowlib.DefaultAction, newFailingNode("notify", 1))
+
+	store, executionID := runDeclaredFlow(t, "metadata:\n  name: saga-flow\n", flowlib.NewFlow(insert))
+
+	status := waitForStatus(t, store, executionID, "failed")
+	assert.Equal(t, "boom", status.Error)
+
+	mu.Lock()
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"node_id": "charge", "result": "charge done"},
+		map[string]interface{}{"node_id": "insert", "result": "insert done"},
+	}, undone)
+	mu.Unlock()
+
+	logs, err := store.GetExecutionLogs(executionID)
+	require.NoError(t, err)
+	var compensated []interface{}
+	for _, log := range logs {
+		if log.Message == "Compensated node" {
+			compensated = append(compensated, log.Data["compensation"])
+		}
+	}
+	assert.Equal(t, []interface{}{"undo_charge", "undo_insert"}, compensated)
+}
+
+func TestSnapshotSharedState_KeepsCompensations(t *testing.T) {
+	shared := map[string]interface{}{
+		"order":          "A-1",
+		"_execution":     map[string]interface{}{"logger": func() {}},
+		compensationsKey: []interface{}{map[string]interface{}{"node_id": "insert", "result": "insert done"}},
+	}
+
+	snapshot, err := snapshotSharedState(shared)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"order":          "A-1",
+		compensationsKey: []interface{}{map[string]interface{}{"node_id": "insert", "result": "insert done"}},
+	}, snapshot)
+}
@@ -2,6 +2,7 @@
 package runtime
 
 import (
+    "context"
     "fmt"
     "time"
     "strings"
@@ -14,24 +15,30 @@ import (
 // CoreNodeTypes returns a map of built-in node types
 func CoreNodeTypes() map[string]NodeFactory {
 	return map[string]NodeFactory{
-		"http.request":  NewHTTPRequestNodeWrapper,
-		"store":         NewStoreNodeWrapper,
-		"transform":     NewTransformNodeWrapper,
-		"condition":     NewConditionNodeWrapper,
-		"router":        NewRouterNodeWrapper,        // Enhanced condition node with tool call support
-		"delay":         NewDelayNodeWrapper,
-		"wait":          NewWaitNodeWrapper,
-		"cron":          NewCronNodeWrapper,
-		"llm":           NewLLMNodeWrapper,
-		"email.send":    NewSMTPNodeWrapper,
-		"email.receive": NewIMAPNodeWrapper,
-		"agent":         NewAgentNodeWrapper,
-		"webhook":       NewWebhookNodeWrapper,
-		"dynamodb":      NewDynamoDBNodeWrapper,
-		"postgres":      NewPostgresNodeWrapper,
-		"format":        NewResponseFormatterNodeWrapper, // Response formatting for tool results
-		"split":         NewSplitNodeWrapper,             // Split execution for parallel processing
-		"join":          NewJoinNodeWrapper,              // Join parallel execution results
+		"http.request":   NewHTTPRequestNodeWrapper,
+		"store":          NewStoreNodeWrapper,
+		"transform":      NewTransformNodeWrapper,
+		"condition":      NewConditionNodeWrapper,
+		"router":         NewRouterNodeWrapper, // Enhanced condition node with tool call support
+		"delay":          NewDelayNodeWrapper,
+		"wait":           NewWaitNodeWrapper,
+		"cron":           NewCronNodeWrapper,
+		"llm":            NewLLMNodeWrapper,
+		"email.send":     NewSMTPNodeWrapper,
+		"email.receive":  NewIMAPNodeWrapper,
+		"agent":          NewAgentNodeWrapper,
+		"webhook":        NewWebhookNodeWrapper,
+		"dynamodb":       NewDynamoDBNodeWrapper,
+		"postgres":       NewPostgresNodeWrapper,
+		"format":         NewResponseFormatterNodeWrapper, // Response formatting for tool results
+		"split":          NewSplitNodeWrapper,             // Split execution for parallel processing
+		"join":           NewJoinNodeWrapper,              // Join parallel execution results
+		"flow.call":      NewFlowCallNodeWrapper,          // Call another flow of the same account
+		"foreach":        NewForeachNodeWrapper,           // Run a body once per item of an array
+		"while":          NewWhileNodeWrapper,             // Run a body while a condition holds
+		"approval":       NewApprovalNodeWrapper,          // Wait for a person to approve or reject
+		"wait_for_event": NewWaitForEventNodeWrapper,      // Wait for an event with a correlation key
+		"event.publish":  NewEventPublishNodeWrapper,      // Deliver an event to waiting executions
 	}
 }
 
@@ -182,7 +189,7 @@ func NewSMTPNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 	// Create the wrapper
 	wrapper := &NodeWrapper{
 		node: baseNode,
-		exec: func(input interface{}) (interface{}, error) {
+		execWithContext: func(ctx context.Context, input interface{}) (interface{}, error) {
 			// Handle both old format (direct params) and new format (combined input)
 			var params map[string]interface{}
 
@@ -350,6 +357,11 @@ func NewSMTPNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 				Headers:     headers,
 			}
 
+			// A canceled execution must not send mail after the fact
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
 			// Send the email
 			if err := client.SendEmail(message); err != nil {
 				return nil, fmt.Errorf("failed to send email: %w", err)
@@ -366,6 +378,7 @@ func NewSMTPNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 			}, nil
 		},
 	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
 
 	// Set the parameters
 	wrapper.SetParams(params)
@@ -585,63 +598,3 @@ func NewWebhookNodeWrapper(params map[string]interface{}) (flowlib.Node, error)
 
 	return wrapper, nil
 }
-// NewSplitNodeWrapper creates a new split node wrapper for parallel execution
-func NewSplitNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
-	// Create the base node
-	baseNode := flowlib.NewNode(1, 0)
-
-	// Create the wrapper
-	wrapper := &NodeWrapper{
-		node: baseNode,
-		exec: func(input interface{}) (interface{}, error) {
-			// Split node simply passes through the input to enable parallel execution
-			// The actual parallel execution is handled by the flow runtime
-			return input, nil
-		},
-	}
-
-	// Set the parameters
-	wrapper.SetParams(params)
-
-	return wrapper, nil
-}
-
-// NewJoinNodeWrapper creates a new join node wrapper for collecting parallel execution results
-func NewJoinNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
-	// Create the base node
-	baseNode := flowlib.NewNode(1, 0)
-
-	// Create the wrapper
-	wrapper := &NodeWrapper{
-		node: baseNode,
-		exec: func(input interface{}) (interface{}, error) {
-			// Join node collects results from parallel execution branches
-			// In a real implementation, this would wait for all parallel branches to complete
-			// and merge their results. For now, we'll pass through the input.
-			
-			// If input is a map, we can collect results from different branches
-			if inputMap, ok := input.(map[string]interface{}); ok {
-				// Create a combined result from all branches
-				result := make(map[string]interface{})
-				
-				// Copy all input data to the result
-				for key, value := range inputMap {
-					result[key] = value
-				}
-				
-				// Add a marker that this came from a join operation
-				result["_join_operation"] = true
-				
-				return result, nil
-			}
-			
-			// For non-map inputs, just pass through
-			return input, nil
-		},
-	}
-
-	// Set the parameters
-	wrapper.SetParams(params)
-
-	return wrapper, nil
-}
@@ -0,0 +1,200 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+)
+
+// DebugCommand resumes or stops a paused debug execution
+type DebugCommand string
+
+const (
+	// DebugStep runs the next node and pauses again before the one after it
+	DebugStep DebugCommand = "step"
+
+	// DebugContinue runs the execution until the next breakpoint
+	DebugContinue DebugCommand = "continue"
+
+	// DebugAbort cancels the execution
+	DebugAbort DebugCommand = "abort"
+)
+
+// ExecutionDebugger is implemented by runtimes that can pause debug
+// executions at breakpoints
+type ExecutionDebugger interface {
+	// Debug sends a command to a paused debug execution of the account
+	Debug(accountID, executionID string, command DebugCommand) error
+
+	// GetPause returns where a debug execution is paused, or false if it is
+	// not paused
+	GetPause(executionID string) (DebugPause, bool)
+}
+
+// debugSession tracks the breakpoints and the pause of a debug execution
+type debugSession struct {
+	breakpoints map[string]bool
+
+	// pausing is held while the execution is paused, so that parallel loop
+	// iterations and branches pause one at a time
+	pausing sync.Mutex
+
+	mu sync.Mutex
+	// stepping pauses the execution before the next node, whether or not
+	// it is a breakpoint
+	stepping bool
+	pause    *DebugPause
+	commands chan DebugCommand
+}
+
+// newDebugSession returns a session that pauses before the first node and
+// before the given nodes
+func newDebugSession(breakpoints []string) *debugSession {
+	session := &debugSession{
+		breakpoints: make(map[string]bool, len(breakpoints)),
+		stepping:    true,
+		commands:    make(chan DebugCommand, 1),
+	}
+	for _, nodeID := range breakpoints {
+		session.breakpoints[nodeID] = true
+	}
+	return session
+}
+
+// shouldPause reports whether the execution pauses before the node
+func (s *debugSession) shouldPause(nodeID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stepping || s.breakpoints[nodeID]
+}
+
+// pauseAtBreakpoint pauses a debug execution before node if it is a
+// breakpoint, and waits for a command. It returns the context error if the
+// execution is canceled or aborted while paused.
+func (r *flowRuntime) pauseAtBreakpoint(ctx context.Context, execCtx *executionContext, node flowlib.Node, step int, shared map[string]interface{}) error {
+	session := execCtx.debugger
+	if session == nil {
+		return nil
+	}
+	nodeID := nodeIDOf(node)
+	if !session.shouldPause(nodeID) {
+		return nil
+	}
+	session.pausing.Lock()
+	defer session.pausing.Unlock()
+
+	pause := DebugPause{NodeID: nodeID, Step: step}
+	if declared := node.Params(); declared != nil {
+		pause.Params = redactParams(declared, resolveParams(shared, declared))
+	}
+	snapshot, err := snapshotSharedState(shared)
+	if err != nil {
+		snapshot = map[string]interface{}{"error": fmt.Sprintf("shared state is not serializable: %v", err)}
+	}
+	pause.Shared = snapshot
+
+	session.mu.Lock()
+	session.pause = &pause
+	session.mu.Unlock()
+
+	r.updateProgress(execCtx, func(status *ExecutionStatus) {
+		status.Status = "paused"
+	})
+	r.logExecution(execCtx.status.ID, "info", "Execution paused", map[string]interface{}{"node_id": nodeID})
+	r.notifySubscribers(execCtx.status.ID, ExecutionLog{
+		Timestamp: time.Now(),
+		NodeID:    nodeID,
+		Level:     "paused",
+		Message:   "Execution paused before node",
+		Pause:     &pause,
+	})
+
+	select {
+	case command := <-session.commands:
+		session.mu.Lock()
+		session.stepping = command == DebugStep
+		session.mu.Unlock()
+	case <-ctx.Done():
+		session.mu.Lock()
+		session.pause = nil
+		session.mu.Unlock()
+		return ctx.Err()
+	}
+
+	r.updateProgress(execCtx, func(status *ExecutionStatus) {
+		status.Status = "running"
+	})
+	return nil
+}
+
+// Debug implements ExecutionDebugger
+func (r *flowRuntime) Debug(accountID, executionID string, command DebugCommand) error {
+	r.mu.RLock()
+	execCtx, ok := r.activeExecutions[executionID]
+	r.mu.RUnlock()
+	if !ok || execCtx.accountID != accountID || execCtx.debugger == nil {
+		return fmt.Errorf("debug execution not found: %s", executionID)
+	}
+
+	switch command {
+	case DebugStep, DebugContinue:
+	case DebugAbort:
+		return r.Cancel(executionID)
+	default:
+		return fmt.Errorf("unknown debug command: %s", command)
+	}
+
+	session := execCtx.debugger
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.pause == nil {
+		return fmt.Errorf("execution is not paused: %s", executionID)
+	}
+	// Clearing the pause under the lock lets only one command through
+	session.pause = nil
+	session.commands <- command
+
+	r.logExecution(executionID, "info", "Debug command received", map[string]interface{}{"command": string(command)})
+	return nil
+}
+
+// GetPause implements ExecutionDebugger
+func (r *flowRuntime) GetPause(executionID string) (DebugPause, bool) {
+	r.mu.RLock()
+	execCtx, ok := r.activeExecutions[executionID]
+	r.mu.RUnlock()
+	if !ok || execCtx.debugger == nil {
+		return DebugPause{}, false
+	}
+
+	session := execCtx.debugger
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.pause == nil {
+		return DebugPause{}, false
+	}
+	return *session.pause, true
+}
+
+// resolveParams evaluates the template expressions in the parameters of a
+// node against the shared state, the same way NodeWrapper does before it runs
+func resolveParams(shared map[string]interface{}, params map[string]interface{}) map[string]interface{} {
+	flowContext := flowContextFromShared(shared)
+	if flowContext == nil {
+		return params
+	}
+	for key, value := range shared {
+		if isPublicKey(key) {
+			flowContext.SetSharedData(key, value)
+		}
+	}
+
+	resolved, err := flowContext.ProcessNodeParams(params)
+	if err != nil {
+		return params
+	}
+	return resolved
+}
@@ -0,0 +1,130 @@
+package runtime
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForPause waits until a debug execution is paused before the given node
+func waitForPause(t *testing.T, debugger ExecutionDebugger, executionID, nodeID string) DebugPause {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if pause, ok := debugger.GetPause(executionID); ok && pause.NodeID == nodeID {
+			return pause
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("execution %s did not pause before %q", executionID, nodeID)
+	return DebugPause{}
+}
+
+func newDebugTestRuntime(t *testing.T, visited *[]string, mu *sync.Mutex) (OptionsExecutor, ExecutionDebugger, *checkpointTestStore) {
+	flowDef := &Flow{ID: "counting-flow", YAML: "counting"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "counting-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newCountingFlow(visited, mu), nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store)
+	debugger, ok := flowRuntime.(ExecutionDebugger)
+	require.True(t, ok)
+	return flowRuntime.(OptionsExecutor), debugger, store
+}
+
+func TestFlowRuntime_DebugBreakpoints(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+	flowRuntime, debugger, store := newDebugTestRuntime(t, &visited, &mu)
+
+	executionID, err := flowRuntime.ExecuteWithOptions("test-account", "counting-flow", map[string]interface{}{"counter": float64(0)}, ExecuteOptions{
+		Debug:       true,
+		Breakpoints: []string{"third"},
+	})
+	require.NoError(t, err)
+
+	// Debug executions pause before their first node
+	pause := waitForPause(t, debugger, executionID, "first")
+	assert.Equal(t, 0, pause.Step)
+	assert.Equal(t, float64(0), pause.Shared["counter"])
+	assert.Equal(t, "first", pause.Params["node_id"])
+	assert.Equal(t, "paused", waitForStatus(t, store, executionID, "paused").Status)
+
+	// Continuing runs until the breakpoint
+	require.NoError(t, debugger.Debug("test-account", executionID, DebugContinue))
+	pause = waitForPause(t, debugger, executionID, "third")
+	assert.Equal(t, 2, pause.Step)
+	assert.Equal(t, float64(2), pause.Shared["counter"])
+	assert.NotContains(t, pause.Shared, "_execution")
+
+	mu.Lock()
+	assert.Equal(t, []string{"first", "second"}, visited)
+	mu.Unlock()
+
+	// Commands of other accounts are rejected
+	assert.Error(t, debugger.Debug("other-account", executionID, DebugContinue))
+
+	require.NoError(t, debugger.Debug("test-account", executionID, DebugContinue))
+	waitForStatus(t, store, executionID, "completed")
+
+	_, paused := debugger.GetPause(executionID)
+	assert.False(t, paused)
+}
+
+func TestFlowRuntime_DebugStep(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+	flowRuntime, debugger, store := newDebugTestRuntime(t, &visited, &mu)
+
+	executionID, err := flowRuntime.ExecuteWithOptions("test-account", "counting-flow", nil, ExecuteOptions{Debug: true})
+	require.NoError(t, err)
+
+	for _, nodeID := range []string{"first", "second", "third"} {
+		waitForPause(t, debugger, executionID, nodeID)
+		require.NoError(t, debugger.Debug("test-account", executionID, DebugStep))
+	}
+	waitForStatus(t, store, executionID, "completed")
+
+	mu.Lock()
+	assert.Equal(t, []string{"first", "second", "third"}, visited)
+	mu.Unlock()
+}
+
+func TestFlowRuntime_DebugAbort(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+	flowRuntime, debugger, store := newDebugTestRuntime(t, &visited, &mu)
+
+	executionID, err := flowRuntime.ExecuteWithOptions("test-account", "counting-flow", nil, ExecuteOptions{Debug: true})
+	require.NoError(t, err)
+	waitForPause(t, debugger, executionID, "first")
+
+	require.NoError(t, debugger.Debug("test-account", executionID, DebugAbort))
+	waitForStatus(t, store, executionID, "canceled")
+	assert.Empty(t, visited)
+}
+
+func TestFlowRuntime_DebugRequiresPause(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+	flowRuntime, debugger, store := newDebugTestRuntime(t, &visited, &mu)
+
+	// Executions outside debug mode take no commands
+	executionID, err := flowRuntime.ExecuteWithOptions("test-account", "counting-flow", nil, ExecuteOptions{})
+	require.NoError(t, err)
+	assert.Error(t, debugger.Debug("test-account", executionID, DebugStep))
+	waitForStatus(t, store, executionID, "completed")
+
+	// A debug execution takes one known command per pause
+	debugID, err := flowRuntime.ExecuteWithOptions("test-account", "counting-flow", nil, ExecuteOptions{Debug: true})
+	require.NoError(t, err)
+	waitForPause(t, debugger, debugID, "first")
+	assert.Error(t, debugger.Debug("test-account", debugID, DebugCommand("jump")))
+	require.NoError(t, debugger.Debug("test-account", debugID, DebugContinue))
+	assert.Error(t, debugger.Debug("test-account", debugID, DebugContinue))
+	waitForStatus(t, store, debugID, "completed")
+}
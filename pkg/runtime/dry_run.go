@@ -0,0 +1,117 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+)
+
+// DryRunKey is the ExecutionStatus.Metadata key that marks dry runs. It is
+// set to "true" on executions whose side-effecting nodes are simulated.
+const DryRunKey = "dry_run"
+
+// dryRunKey is the context key of the stub outputs of a dry run
+type dryRunKey struct{}
+
+// isDryRun reports whether an execution status belongs to a dry run
+func isDryRun(status ExecutionStatus) bool {
+	return status.Metadata[DryRunKey] == "true"
+}
+
+// withDryRun returns a context in which nodes with side effects are
+// simulated. outputs are the declared stub outputs, keyed by node ID.
+func withDryRun(ctx context.Context, outputs map[string]interface{}) context.Context {
+	if outputs == nil {
+		outputs = map[string]interface{}{}
+	}
+	return context.WithValue(ctx, dryRunKey{}, outputs)
+}
+
+// inDryRun reports whether ctx belongs to a dry run
+func inDryRun(ctx context.Context) bool {
+	_, ok := ctx.Value(dryRunKey{}).(map[string]interface{})
+	return ok
+}
+
+// dryRunOutput returns the output a node returns instead of running, if ctx
+// belongs to a dry run and the node declares a stub output or has side
+// effects. declared and resolved are the node parameters before and after
+// template expressions were resolved.
+func dryRunOutput(ctx context.Context, declared, resolved map[string]interface{}) (interface{}, bool) {
+	outputs, ok := ctx.Value(dryRunKey{}).(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	nodeID, _ := resolved["node_id"].(string)
+	nodeType, _ := resolved["node_type"].(string)
+	output, declaredOutput := outputs[nodeID]
+	if declaredOutput {
+		// Copied, so that nodes changing their result leave the stub intact
+		output = traceValue(output)
+	} else if hasSideEffects(nodeType, resolved) {
+		output = dryRunPreview(nodeType, redactParams(declared, resolved))
+	} else {
+		return nil, false
+	}
+
+	if scope, ok := executionScopeFrom(ctx); ok {
+		scope.runtime.logExecution(scope.execution.status.ID, "info", "Node simulated in dry run", map[string]interface{}{
+			"node_id": nodeID,
+			"stub":    declaredOutput,
+		})
+	}
+	return output, true
+}
+
+// hasSideEffects reports whether a node of the given type acts on the world
+// outside the execution with the given parameters
+func hasSideEffects(nodeType string, params map[string]interface{}) bool {
+	operation, _ := params["operation"].(string)
+	switch nodeType {
+	case "email.send", "webhook", "approval", "wait_for_event", "event.publish":
+		// Approval and event nodes park or resume executions
+		return true
+	case "http.request":
+		method, _ := params["method"].(string)
+		switch strings.ToUpper(method) {
+		case "", "GET", "HEAD", "OPTIONS":
+			return false
+		}
+		return true
+	case "postgres":
+		// set and delete write to the key-value table like execute does
+		return operation == "execute" || operation == "transaction" || operation == "set" || operation == "delete"
+	case "dynamodb":
+		return operation == "set" || operation == "delete"
+	}
+	return false
+}
+
+// dryRunPreview synthesizes the output of a simulated node. It describes what
+// the node would have done, shaped like a successful result so that routing
+// follows the success path.
+func dryRunPreview(nodeType string, params map[string]interface{}) map[string]interface{} {
+	preview := map[string]interface{}{
+		"dry_run":   true,
+		"node_type": nodeType,
+		"params":    params,
+	}
+	switch nodeType {
+	case "http.request":
+		preview["status_code"] = 200
+		preview["success"] = true
+		preview["headers"] = map[string]interface{}{}
+		preview["body"] = nil
+	case "email.send", "webhook":
+		preview["status"] = "sent"
+	case "approval":
+		preview["decision"] = ApprovedAction
+	case "wait_for_event":
+		preview["received"] = true
+	case "event.publish":
+		preview["delivered"] = []string{}
+	default:
+		preview["success"] = true
+	}
+	return preview
+}
@@ -0,0 +1,137 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+func TestHasSideEffects(t *testing.T) {
+	tests := []struct {
+		nodeType string
+		params   map[string]interface{}
+		expected bool
+	}{
+		{"email.send", nil, true},
+		{"webhook", nil, true},
+		{"approval", nil, true},
+		{"http.request", map[string]interface{}{"method": "POST"}, true},
+		{"http.request", map[string]interface{}{"method": "delete"}, true},
+		{"http.request", map[string]interface{}{"method": "GET"}, false},
+		{"http.request", map[string]interface{}{}, false},
+		{"postgres", map[string]interface{}{"operation": "execute"}, true},
+		{"postgres", map[string]interface{}{"operation": "transaction"}, true},
+		{"postgres", map[string]interface{}{"operation": "query"}, false},
+		{"dynamodb", map[string]interface{}{"operation": "set"}, true},
+		{"dynamodb", map[string]interface{}{"operation": "delete"}, true},
+		{"dynamodb", map[string]interface{}{"operation": "get"}, false},
+		{"transform", nil, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, hasSideEffects(tt.nodeType, tt.params), "%s %v", tt.nodeType, tt.params)
+	}
+}
+
+const dryRunFlowYAML = `
+metadata:
+  name: Dry Run Flow
+  description: Creates an order and notifies about it
+  version: 1.0.0
+nodes:
+  create:
+    type: http.request
+    params:
+      method: POST
+    next:
+      success: notify
+  notify:
+    type: webhook
+    dry_run:
+      output:
+        status: stubbed
+        recipients: [ops]
+    next:
+      default: fetch
+  fetch:
+    type: http.request
+`
+
+func TestFlowRuntime_DryRun(t *testing.T) {
+	var requests []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method)
+		mu.Unlock()
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	newFlow := func() *flowlib.Flow {
+		create, err := NewHTTPRequestNodeWrapper(map[string]interface{}{
+			"node_id": "create", "node_type": "http.request", "method": "POST", "url": server.URL, "body": "order",
+		})
+		require.NoError(t, err)
+		notify, err := NewWebhookNodeWrapper(map[string]interface{}{
+			"node_id": "notify", "node_type": "webhook", "url": server.URL,
+		})
+		require.NoError(t, err)
+		fetch, err := NewHTTPRequestNodeWrapper(map[string]interface{}{
+			"node_id": "fetch", "node_type": "http.request", "method": "GET", "url": server.URL,
+		})
+		require.NoError(t, err)
+		create.Next("success", notify)
+		notify.Next(flowlib.DefaultAction, fetch)
+		return flowlib.NewFlow(create)
+	}
+
+	flowDef := &Flow{ID: "dry-run-flow", YAML: dryRunFlowYAML}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "dry-run-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newFlow(), nil).Once()
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newFlow(), nil).Once()
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store).(OptionsExecutor)
+
+	executionID, err := flowRuntime.ExecuteWithOptions("test-account", "dry-run-flow", nil, ExecuteOptions{DryRun: true})
+	require.NoError(t, err)
+	status := waitForStatus(t, store, executionID, "completed")
+	assert.Equal(t, "true", status.Metadata[DryRunKey])
+
+	// Only the GET request reached the server, routing followed the preview
+	mu.Lock()
+	assert.Equal(t, []string{"GET"}, requests)
+	requests = nil
+	mu.Unlock()
+
+	checkpoints, err := store.GetExecutionCheckpoints(executionID)
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 3)
+
+	preview := checkpoints[1].Shared["result"].(map[string]interface{})
+	assert.Equal(t, true, preview["dry_run"])
+	assert.Equal(t, "http.request", preview["node_type"])
+	assert.Equal(t, "order", preview["params"].(map[string]interface{})["body"])
+
+	stub := checkpoints[2].Shared["result"].(map[string]interface{})
+	assert.Equal(t, "stubbed", stub["status"])
+	assert.Equal(t, []interface{}{"ops"}, stub["recipients"])
+
+	// Without the flag the nodes act
+	executionID, err = flowRuntime.ExecuteWithOptions("test-account", "dry-run-flow", nil, ExecuteOptions{})
+	require.NoError(t, err)
+	status = waitForStatus(t, store, executionID, "completed")
+	assert.Empty(t, status.Metadata[DryRunKey])
+
+	mu.Lock()
+	assert.Equal(t, []string{"POST", "GET"}, requests)
+	mu.Unlock()
+}
@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -129,9 +130,9 @@ func (dm *DynamoDBManager) ensureTableExists() error {
 }
 
 // Get retrieves an item from DynamoDB
-func (dm *DynamoDBManager) Get(key string) (interface{}, error) {
+func (dm *DynamoDBManager) Get(ctx context.Context, key string) (interface{}, error) {
 	// Get item
-	result, err := dm.client.GetItem(&dynamodb.GetItemInput{
+	result, err := dm.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(dm.tableName),
 		Key: map[string]*dynamodb.AttributeValue{
 			"key": {
@@ -174,7 +175,7 @@ func (dm *DynamoDBManager) Get(key string) (interface{}, error) {
 }
 
 // Set stores an item in DynamoDB
-func (dm *DynamoDBManager) Set(key string, value interface{}, ttl time.Duration) error {
+func (dm *DynamoDBManager) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	// Create item
 	item := map[string]interface{}{
 		"key":       key,
@@ -194,7 +195,7 @@ func (dm *DynamoDBManager) Set(key string, value interface{}, ttl time.Duration)
 	}
 
 	// Put item
-	_, err = dm.client.PutItem(&dynamodb.PutItemInput{
+	_, err = dm.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
 		TableName: aws.String(dm.tableName),
 		Item:      av,
 	})
@@ -207,9 +208,9 @@ func (dm *DynamoDBManager) Set(key string, value interface{}, ttl time.Duration)
 }
 
 // Delete removes an item from DynamoDB
-func (dm *DynamoDBManager) Delete(key string) (bool, error) {
+func (dm *DynamoDBManager) Delete(ctx context.Context, key string) (bool, error) {
 	// Delete item
-	result, err := dm.client.DeleteItem(&dynamodb.DeleteItemInput{
+	result, err := dm.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
 		TableName: aws.String(dm.tableName),
 		Key: map[string]*dynamodb.AttributeValue{
 			"key": {
@@ -228,9 +229,9 @@ func (dm *DynamoDBManager) Delete(key string) (bool, error) {
 }
 
 // List returns all keys in DynamoDB
-func (dm *DynamoDBManager) List() ([]string, error) {
+func (dm *DynamoDBManager) List(ctx context.Context) ([]string, error) {
 	// Scan table
-	result, err := dm.client.Scan(&dynamodb.ScanInput{
+	result, err := dm.client.ScanWithContext(ctx, &dynamodb.ScanInput{
 		TableName: aws.String(dm.tableName),
 		ExpressionAttributeNames: map[string]*string{
 			"#k": aws.String("key"),
@@ -254,7 +255,7 @@ func (dm *DynamoDBManager) List() ([]string, error) {
 }
 
 // Query performs a query on DynamoDB
-func (dm *DynamoDBManager) Query(filter map[string]interface{}, sortKey string, limit int) ([]map[string]interface{}, error) {
+func (dm *DynamoDBManager) Query(ctx context.Context, filter map[string]interface{}, sortKey string, limit int) ([]map[string]interface{}, error) {
 	fmt.Printf("DynamoDB Query - Filter: %v, SortKey: %s, Limit: %d\n", filter, sortKey, limit)
 
 	// Create filter expression
@@ -334,7 +335,7 @@ func (dm *DynamoDBManager) Query(filter map[string]interface{}, sortKey string,
 	}
 
 	// Scan table
-	result, err := dm.client.Scan(scanInput)
+	result, err := dm.client.ScanWithContext(ctx, scanInput)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan table: %w", err)
 	}
@@ -364,7 +365,7 @@ func (dm *DynamoDBManager) Query(filter map[string]interface{}, sortKey string,
 }
 
 // BatchWrite performs a batch write operation on DynamoDB
-func (dm *DynamoDBManager) BatchWrite(items []map[string]interface{}) error {
+func (dm *DynamoDBManager) BatchWrite(ctx context.Context, items []map[string]interface{}) error {
 	// Split items into batches of 25 (DynamoDB limit)
 	batchSize := 25
 	for i := 0; i < len(items); i += batchSize {
@@ -392,7 +393,7 @@ func (dm *DynamoDBManager) BatchWrite(items []map[string]interface{}) error {
 		}
 
 		// Perform batch write
-		_, err := dm.client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+		_, err := dm.client.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
 			RequestItems: map[string][]*dynamodb.WriteRequest{
 				dm.tableName: writeRequests,
 			},
@@ -414,7 +415,7 @@ func NewDynamoDBNodeWrapper(params map[string]interface{}) (flowlib.Node, error)
 	// Create the wrapper
 	wrapper := &NodeWrapper{
 		node: baseNode,
-		exec: func(input interface{}) (interface{}, error) {
+		execWithContext: func(ctx context.Context, input interface{}) (interface{}, error) {
 			// Handle both old format (direct params) and new format (combined input)
 			var params map[string]interface{}
 			
@@ -455,7 +456,7 @@ func NewDynamoDBNodeWrapper(params map[string]interface{}) (flowlib.Node, error)
 				}
 
 				// Get item
-				value, err := manager.Get(key)
+				value, err := manager.Get(ctx, key)
 				if err != nil {
 					return nil, err
 				}
@@ -486,7 +487,7 @@ func NewDynamoDBNodeWrapper(params map[string]interface{}) (flowlib.Node, error)
 				}
 
 				// Set item
-				if err := manager.Set(key, value, ttl); err != nil {
+				if err := manager.Set(ctx, key, value, ttl); err != nil {
 					return nil, err
 				}
 
@@ -500,7 +501,7 @@ func NewDynamoDBNodeWrapper(params map[string]interface{}) (flowlib.Node, error)
 				}
 
 				// Delete item
-				exists, err := manager.Delete(key)
+				exists, err := manager.Delete(ctx, key)
 				if err != nil {
 					return nil, err
 				}
@@ -512,7 +513,7 @@ func NewDynamoDBNodeWrapper(params map[string]interface{}) (flowlib.Node, error)
 
 			case "list":
 				// List keys
-				keys, err := manager.List()
+				keys, err := manager.List(ctx)
 				if err != nil {
 					return nil, err
 				}
@@ -541,7 +542,7 @@ func NewDynamoDBNodeWrapper(params map[string]interface{}) (flowlib.Node, error)
 				}
 
 				// Query items
-				results, err := manager.Query(filter, sortField, limit)
+				results, err := manager.Query(ctx, filter, sortField, limit)
 				if err != nil {
 					return nil, err
 				}
@@ -564,7 +565,7 @@ func NewDynamoDBNodeWrapper(params map[string]interface{}) (flowlib.Node, error)
 				}
 
 				// Batch write items
-				if err := manager.BatchWrite(itemMaps); err != nil {
+				if err := manager.BatchWrite(ctx, itemMaps); err != nil {
 					return nil, err
 				}
 
@@ -577,6 +578,7 @@ func NewDynamoDBNodeWrapper(params map[string]interface{}) (flowlib.Node, error)
 			}
 		},
 	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
 
 	// Set the parameters
 	wrapper.SetParams(params)
@@ -0,0 +1,109 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/tcmartin/flowlib"
+)
+
+// ErrorAction is the action followed when a node fails. Nodes without a
+// successor for it fail the execution.
+const ErrorAction = "error"
+
+// ErrorStateKey is the shared state key under which the last node failure is
+// stored for error handlers
+const ErrorStateKey = "error"
+
+// NodeError reports the node whose failure ended an execution
+type NodeError struct {
+	// NodeID is the node that failed
+	NodeID string
+
+	// Attempts is the number of times the node ran before giving up
+	Attempts int
+
+	// Err is the error returned by the node
+	Err error
+}
+
+func (e *NodeError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error returned by the node
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// errorState builds the shared state entry that describes a failure
+func errorState(err error) map[string]interface{} {
+	state := map[string]interface{}{
+		"message": err.Error(),
+	}
+	var nodeErr *NodeError
+	if errors.As(err, &nodeErr) {
+		state["node_id"] = nodeErr.NodeID
+		state["attempts"] = nodeErr.Attempts
+	}
+	return state
+}
+
+// countAttempts returns a context in which the retry attempts of node are
+// counted, in addition to being logged to the execution
+func (r *flowRuntime) countAttempts(ctx context.Context, execCtx *executionContext, node flowlib.Node) (context.Context, func() int) {
+	var attempts atomic.Int64
+	logAttempt := r.retryObserver(execCtx)
+	ctx = flowlib.WithRetryObserver(ctx, func(attempt flowlib.Attempt) {
+		if attempt.Node == node {
+			attempts.Store(int64(attempt.Number))
+		}
+		logAttempt(attempt)
+	})
+	return ctx, func() int {
+		// Nodes that may not retry do not report their single attempt
+		return max(1, int(attempts.Load()))
+	}
+}
+
+// handleFailure runs the compensations of the completed nodes and then the
+// on_error handler of a failed execution. The execution fails with the
+// original error regardless. Canceled and waiting executions run neither.
+func (r *flowRuntime) handleFailure(ctx context.Context, execCtx *executionContext, flow *flowlib.Flow, shared map[string]interface{}, err error) {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrExecutionWaiting) {
+		return
+	}
+	if ctx.Err() != nil {
+		// The execution ran out of time, cleaning up still gets to run
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	shared[ErrorStateKey] = errorState(err)
+	r.compensate(ctx, execCtx, flow, shared)
+	r.runErrorHandler(ctx, execCtx, flow.ErrorHandler(), shared, err)
+}
+
+// runErrorHandler runs the flow-level on_error node and its successors
+func (r *flowRuntime) runErrorHandler(ctx context.Context, execCtx *executionContext, handler flowlib.Node, shared map[string]interface{}, err error) {
+	if handler == nil {
+		return
+	}
+
+	r.logExecution(execCtx.status.ID, "info", "Running on_error handler", map[string]interface{}{
+		"node_id": nodeIDOf(handler),
+		"error":   err.Error(),
+	})
+
+	for curr := handler; curr != nil; {
+		action, handlerErr := r.runNode(ctx, execCtx, curr, shared)
+		if handlerErr != nil {
+			r.logExecution(execCtx.status.ID, "error", "on_error handler failed", map[string]interface{}{
+				"node_id": nodeIDOf(curr),
+				"error":   handlerErr.Error(),
+			})
+			return
+		}
+		curr = nextNode(curr, action)
+	}
+}
@@ -0,0 +1,75 @@
+package runtime
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+// newFailingNode returns a node that fails every attempt with "boom"
+func newFailingNode(id string, attempts int) *flowlib.NodeWithRetry {
+	node := flowlib.NewNode(attempts, 0)
+	node.SetParams(map[string]interface{}{"node_id": id})
+	node.SetExecFn(func(any) (any, error) {
+		return nil, errors.New("boom")
+	})
+	return node
+}
+
+// newRecordingNode returns a node that copies the shared error state into the result
+func newRecordingNode(id string) *flowlib.NodeWithRetry {
+	node := flowlib.NewNode(1, 0)
+	node.SetParams(map[string]interface{}{"node_id": id})
+	node.SetPrepFn(func(shared any) (any, error) {
+		sharedMap := shared.(map[string]interface{})
+		sharedMap["result"] = map[string]interface{}{"handled": sharedMap[ErrorStateKey]}
+		return nil, nil
+	})
+	return node
+}
+
+func TestFlowRuntime_ErrorActionRoutesToHandler(t *testing.T) {
+	risky := newFailingNode("risky", 2)
+	risky.Next(ErrorAction, newRecordingNode("handle_failure"))
+
+	store, executionID := runDeclaredFlow(t, "metadata:\n  name: error-flow\n", flowlib.NewFlow(risky))
+
+	status := waitForStatus(t, store, executionID, "completed")
+	assert.Equal(t, map[string]interface{}{
+		"message":  "boom",
+		"node_id":  "risky",
+		"attempts": 2,
+	}, status.Results["handled"])
+}
+
+func TestFlowRuntime_OnErrorRunsBeforeFailing(t *testing.T) {
+	handler := newRecordingNode("on_failure")
+	flow := flowlib.NewFlow(newFailingNode("risky", 1))
+	flow.SetErrorHandler(handler)
+
+	store, executionID := runDeclaredFlow(t, "metadata:\n  name: error-flow\n", flow)
+
+	status := waitForStatus(t, store, executionID, "failed")
+	assert.Equal(t, "boom", status.Error)
+
+	logs, err := store.GetExecutionLogs(executionID)
+	require.NoError(t, err)
+	var messages []string
+	for _, log := range logs {
+		messages = append(messages, log.Message)
+	}
+	assert.Contains(t, messages, "Running on_error handler")
+	assert.Less(t, indexOf(messages, "Running on_error handler"), indexOf(messages, "Flow execution failed"))
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,155 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+)
+
+// NewWaitForEventNodeWrapper creates a node that parks its execution until
+// an event with a matching correlation key is delivered, through the API or
+// an event.publish node.
+//
+// Parameters:
+//   - event: the name of the event to wait for (required)
+//   - key: the correlation key the event must carry, usually an expression
+//     such as "${shared.order.id}"
+//   - timeout: how long to wait, such as "72h"; without it the execution
+//     waits until the event arrives or it is canceled
+//
+// Once the event arrives, the top-level keys of its payload are merged into
+// the shared state and the node follows the default action. Its result holds
+// the event, the key, received and the payload. If the timeout passes first,
+// the node follows the timeout action, and executions without a successor
+// for it end with the "timeout" status. Event waits cannot run inside loops,
+// split branches or sub-flows.
+func NewWaitForEventNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
+	// Create the base node
+	baseNode := flowlib.NewNode(1, 0)
+
+	// Create the wrapper
+	wrapper := &NodeWrapper{
+		node: baseNode,
+		execWithContext: func(ctx context.Context, input interface{}) (interface{}, error) {
+			params, _, err := nodeInput(input)
+			if err != nil {
+				return nil, err
+			}
+			scope, err := loopScope(ctx, "wait_for_event")
+			if err != nil {
+				return nil, err
+			}
+			if scope.depth > 0 {
+				return nil, fmt.Errorf("wait_for_event cannot run inside loops, split branches or sub-flows")
+			}
+
+			wait, err := eventWait(scope.execution, params)
+			if err != nil {
+				return nil, err
+			}
+			return nil, scope.runtime.park(scope.execution, wait, nil)
+		},
+	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
+
+	// Set the parameters
+	wrapper.SetParams(params)
+
+	return wrapper, nil
+}
+
+// eventWait describes the wait of a wait_for_event node in an execution
+func eventWait(execCtx *executionContext, params map[string]interface{}) (ExecutionWait, error) {
+	nodeID, _ := params["node_id"].(string)
+	event, _ := params["event"].(string)
+	if event == "" {
+		return ExecutionWait{}, fmt.Errorf("event parameter is required")
+	}
+	key := EventKey(params["key"])
+
+	now := time.Now()
+	wait := ExecutionWait{
+		ExecutionID: execCtx.status.ID,
+		AccountID:   execCtx.accountID,
+		FlowID:      execCtx.flowID,
+		NodeID:      nodeID,
+		Event:       event,
+		EventKey:    key,
+		Action:      TimeoutAction,
+		Result: map[string]interface{}{
+			"event":    event,
+			"key":      key,
+			"received": false,
+		},
+		CreatedAt: now,
+	}
+	if timeout, ok := params["timeout"].(string); ok && timeout != "" {
+		duration, err := time.ParseDuration(timeout)
+		if err != nil {
+			return ExecutionWait{}, fmt.Errorf("invalid timeout: %w", err)
+		}
+		wait.Deadline = now.Add(duration)
+	}
+	return wait, nil
+}
+
+// NewEventPublishNodeWrapper creates a node that delivers an event to the
+// executions of the same account that wait for it.
+//
+// Parameters:
+//   - event: the name of the event (required)
+//   - key: the correlation key of the event
+//   - payload: an object merged into the shared state of the resumed
+//     executions
+//
+// The result holds the event, the key and the IDs of the executions that
+// received it under "delivered".
+func NewEventPublishNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
+	// Create the base node
+	baseNode := flowlib.NewNode(1, 0)
+
+	// Create the wrapper
+	wrapper := &NodeWrapper{
+		node: baseNode,
+		execWithContext: func(ctx context.Context, input interface{}) (interface{}, error) {
+			params, _, err := nodeInput(input)
+			if err != nil {
+				return nil, err
+			}
+			scope, err := loopScope(ctx, "event.publish")
+			if err != nil {
+				return nil, err
+			}
+
+			event := Event{
+				Key:       EventKey(params["key"]),
+				AccountID: scope.execution.accountID,
+			}
+			event.Name, _ = params["event"].(string)
+			if payload, ok := params["payload"]; ok && payload != nil {
+				event.Payload, ok = payload.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("payload parameter must be an object, got %T", payload)
+				}
+			}
+
+			delivered, err := scope.runtime.DeliverEvent(event)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{
+				"event":     event.Name,
+				"key":       event.Key,
+				"delivered": delivered,
+			}, nil
+		},
+	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
+
+	// Set the parameters
+	wrapper.SetParams(stringKeyedParams(params))
+
+	return wrapper, nil
+}
@@ -0,0 +1,194 @@
+package runtime
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+// eventTest runs payment -> paid, where payment waits for the
+// payment.completed event of order 42 and follows the timeout action to
+// late if the flow declares it
+type eventTest struct {
+	store   *waitTestStore
+	runtime *flowRuntime
+	mu      sync.Mutex
+	paid    []interface{}
+	visited []string
+}
+
+func newEventTest(t *testing.T, withTimeoutNode bool) *eventTest {
+	test := &eventTest{store: newWaitTestStore()}
+
+	payment, err := NewWaitForEventNodeWrapper(map[string]interface{}{
+		"node_id": "payment",
+		"event":   "payment.completed",
+		"key":     42,
+		"timeout": "1h",
+	})
+	require.NoError(t, err)
+
+	record := func(nodeID string) flowlib.Node {
+		node := flowlib.NewNode(1, 0)
+		node.SetParams(map[string]interface{}{"node_id": nodeID})
+		node.SetPrepFn(func(shared any) (any, error) {
+			test.mu.Lock()
+			defer test.mu.Unlock()
+			test.visited = append(test.visited, nodeID)
+			test.paid = append(test.paid, shared.(map[string]interface{})["paid"])
+			return nil, nil
+		})
+		return node
+	}
+	payment.Next(flowlib.DefaultAction, record("paid"))
+	if withTimeoutNode {
+		payment.Next(TimeoutAction, record("late"))
+	}
+
+	publish, err := NewEventPublishNodeWrapper(map[string]interface{}{
+		"node_id": "publish",
+		"event":   "payment.completed",
+		"key":     "42",
+		"payload": map[interface{}]interface{}{"paid": true},
+	})
+	require.NoError(t, err)
+
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	for id, start := range map[string]flowlib.Node{"payment-flow": payment, "publish-flow": publish} {
+		flowDef := &Flow{ID: id, YAML: id}
+		mockRegistry.On("GetFlow", "test-account", id).Return(flowDef, nil)
+		mockYAMLLoader.On("Parse", flowDef.YAML).Return(flowlib.NewFlow(start), nil)
+	}
+
+	test.runtime = NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, test.store).(*flowRuntime)
+	return test
+}
+
+// start runs the payment flow until it waits for the event
+func (e *eventTest) start(t *testing.T) string {
+	executionID, err := e.runtime.Execute("test-account", "payment-flow", nil)
+	require.NoError(t, err)
+	waitForStatus(t, e.store.checkpointTestStore, executionID, "waiting")
+	require.Eventually(t, func() bool {
+		e.runtime.mu.RLock()
+		defer e.runtime.mu.RUnlock()
+		_, active := e.runtime.activeExecutions[executionID]
+		return !active
+	}, 2*time.Second, 10*time.Millisecond)
+	return executionID
+}
+
+func TestWaitForEventNode_ResumesOnEvent(t *testing.T) {
+	test := newEventTest(t, true)
+	executionID := test.start(t)
+
+	wait, err := test.store.GetWait(executionID)
+	require.NoError(t, err)
+	assert.Equal(t, "payment.completed", wait.Event)
+	assert.Equal(t, "42", wait.EventKey)
+	assert.Empty(t, wait.Signal)
+
+	// Events of other keys, names or accounts do not match
+	for _, event := range []Event{
+		{Name: "payment.completed", Key: "43", AccountID: "test-account"},
+		{Name: "payment.failed", Key: "42", AccountID: "test-account"},
+		{Name: "payment.completed", Key: "42", AccountID: "other-account"},
+	} {
+		delivered, err := test.runtime.DeliverEvent(event)
+		require.NoError(t, err)
+		assert.Empty(t, delivered)
+	}
+	_, err = test.runtime.DeliverEvent(Event{Key: "42", AccountID: "test-account"})
+	assert.ErrorIs(t, err, ErrInvalidEvent)
+
+	delivered, err := test.runtime.DeliverEvent(Event{
+		Name:      "payment.completed",
+		Key:       "42",
+		AccountID: "test-account",
+		Payload:   map[string]interface{}{"paid": true, NodeOutputsKey: "ignored"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{executionID}, delivered)
+	waitForStatus(t, test.store.checkpointTestStore, executionID, "completed")
+
+	test.mu.Lock()
+	assert.Equal(t, []string{"paid"}, test.visited)
+	assert.Equal(t, []interface{}{true}, test.paid)
+	test.mu.Unlock()
+
+	checkpoints, err := test.store.GetExecutionCheckpoints(executionID)
+	require.NoError(t, err)
+	output := checkpoints[len(checkpoints)-1].Shared[NodeOutputsKey].(map[string]interface{})["payment"].(map[string]interface{})
+	assert.Equal(t, true, output["received"])
+	assert.Equal(t, "42", output["key"])
+
+	// The wait was claimed, so the event is delivered only once
+	delivered, err = test.runtime.DeliverEvent(Event{Name: "payment.completed", Key: "42", AccountID: "test-account"})
+	require.NoError(t, err)
+	assert.Empty(t, delivered)
+}
+
+func TestWaitForEventNode_Timeout(t *testing.T) {
+	t.Run("follows timeout action", func(t *testing.T) {
+		test := newEventTest(t, true)
+		executionID := test.start(t)
+
+		test.runtime.expireWaits(test.store, time.Now().Add(2*time.Hour))
+		waitForStatus(t, test.store.checkpointTestStore, executionID, "completed")
+
+		test.mu.Lock()
+		assert.Equal(t, []string{"late"}, test.visited)
+		test.mu.Unlock()
+	})
+
+	t.Run("times out the execution without timeout successor", func(t *testing.T) {
+		test := newEventTest(t, false)
+		executionID := test.start(t)
+
+		test.runtime.expireWaits(test.store, time.Now().Add(2*time.Hour))
+		status := waitForStatus(t, test.store.checkpointTestStore, executionID, "timeout")
+		assert.Equal(t, "node payment timed out after 1h0m0s", status.Error)
+		test.mu.Lock()
+		assert.Empty(t, test.visited)
+		test.mu.Unlock()
+	})
+}
+
+func TestEventPublishNode(t *testing.T) {
+	test := newEventTest(t, true)
+	waitingID := test.start(t)
+
+	publisherID, err := test.runtime.Execute("test-account", "publish-flow", nil)
+	require.NoError(t, err)
+	status := waitForStatus(t, test.store.checkpointTestStore, publisherID, "completed")
+	assert.Equal(t, []string{waitingID}, status.Results["delivered"])
+	waitForStatus(t, test.store.checkpointTestStore, waitingID, "completed")
+
+	test.mu.Lock()
+	assert.Equal(t, []interface{}{true}, test.paid)
+	test.mu.Unlock()
+}
+
+func TestEventKey(t *testing.T) {
+	for _, tc := range []struct {
+		value interface{}
+		key   string
+	}{
+		{nil, ""},
+		{"A-100", "A-100"},
+		{1000000, "1000000"},
+		{int64(-42), "-42"},
+		{float64(1000000), "1000000"},
+		{1e21, "1000000000000000000000"},
+		{12.5, "12.5"},
+		{uint(7), "7"},
+		{true, "true"},
+	} {
+		assert.Equal(t, tc.key, EventKey(tc.value), "key of %#v", tc.value)
+	}
+}
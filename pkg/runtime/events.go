@@ -0,0 +1,140 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+)
+
+// ErrInvalidEvent is returned for events without a name
+var ErrInvalidEvent = errors.New("invalid event")
+
+// Event resumes the executions waiting for it in wait_for_event nodes
+type Event struct {
+	// Name of the event, such as "payment.completed"
+	Name string
+
+	// Key correlates the event with the executions waiting for it, such as
+	// an order ID
+	Key string
+
+	// Payload is merged into the shared state of the resumed executions
+	Payload map[string]interface{}
+
+	// AccountID is the account the event belongs to. Only executions of the
+	// account receive it.
+	AccountID string
+}
+
+// EventKey returns a correlation key as a string. Numbers are written in
+// full, without an exponent or a fraction for whole numbers, so that the key
+// 1000000 matches whether it was decoded from JSON as a float, given as an
+// integer or written as a string.
+func EventKey(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// EventDeliverer is implemented by runtimes that can resume executions
+// waiting for an event
+type EventDeliverer interface {
+	// DeliverEvent resumes the executions of the account that wait for the
+	// event with its key, and returns their IDs. Events that no execution
+	// waits for are dropped.
+	DeliverEvent(event Event) ([]string, error)
+}
+
+// DeliverEvent implements EventDeliverer
+func (r *flowRuntime) DeliverEvent(event Event) ([]string, error) {
+	store, ok := r.executionStore.(WaitStore)
+	if !ok {
+		return nil, fmt.Errorf("execution store does not support waiting executions")
+	}
+	if event.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidEvent)
+	}
+
+	waits, err := store.ListEventWaits(event.AccountID, event.Name, event.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event waits: %w", err)
+	}
+
+	delivered := make([]string, 0, len(waits))
+	for _, listed := range waits {
+		// Only one of a timeout and several deliveries gets to resume the
+		// execution
+		wait, err := store.ClaimWait(listed.ExecutionID)
+		if err != nil {
+			if errors.Is(err, ErrWaitNotFound) {
+				continue
+			}
+			return delivered, fmt.Errorf("failed to claim wait of execution %s: %w", listed.ExecutionID, err)
+		}
+		if wait.Event != event.Name || wait.EventKey != event.Key {
+			// The execution moved on and waits for something else now
+			if err := store.SaveWait(wait); err != nil {
+				return delivered, fmt.Errorf("failed to restore wait of execution %s: %w", wait.ExecutionID, err)
+			}
+			continue
+		}
+
+		r.logExecution(wait.ExecutionID, "info", "Event received", map[string]interface{}{
+			"node_id": wait.NodeID,
+			"event":   wait.Event,
+			"key":     wait.EventKey,
+		})
+		result := map[string]interface{}{
+			"event":       wait.Event,
+			"key":         wait.EventKey,
+			"received":    true,
+			"received_at": time.Now().UTC().Format(time.RFC3339),
+		}
+		if event.Payload != nil {
+			result["payload"] = event.Payload
+		}
+		if err := r.resumeWaiting(wait, flowlib.DefaultAction, result); err != nil {
+			fmt.Printf("Failed to resume execution %s: %v\n", wait.ExecutionID, err)
+			continue
+		}
+		delivered = append(delivered, wait.ExecutionID)
+	}
+
+	return delivered, nil
+}
+
+// mergeEventPayload copies the top-level keys of an event payload into the
+// shared state, except the keys the runtime keeps there
+func mergeEventPayload(shared, payload map[string]interface{}) {
+	for key, value := range payload {
+		if strings.HasPrefix(key, "_") || key == "accountID" {
+			continue
+		}
+		shared[key] = value
+	}
+}
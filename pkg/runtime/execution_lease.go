@@ -0,0 +1,85 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// executionLeaseTimeout is how long a process owns an execution without
+// renewing its lease. The interrupted executions of a process that stopped
+// are resumed by another process once their lease expired.
+const executionLeaseTimeout = 30 * time.Second
+
+// leaseOwner returns the ID under which this process owns executions, and
+// starts renewing the leases of its active executions
+func (r *flowRuntime) leaseOwner(store ExecutionClaimer) string {
+	r.leaseOnce.Do(func() {
+		host, _ := os.Hostname()
+		r.owner = fmt.Sprintf("%s-%s", host, uuid.New().String()[:8])
+		go r.renewExecutionLeases(store)
+	})
+	return r.owner
+}
+
+// claimExecution makes this process the owner of an execution, and reports
+// false if another process owns it. Without an ExecutionClaimer store all
+// executions belong to this process.
+func (r *flowRuntime) claimExecution(executionID string) (bool, error) {
+	store, ok := r.executionStore.(ExecutionClaimer)
+	if !ok || r.queue != nil {
+		// Workers own executions through the leases of the work queue
+		return true, nil
+	}
+	return store.ClaimExecution(executionID, r.leaseOwner(store), time.Now().Add(executionLeaseTimeout))
+}
+
+// releaseExecution ends the lease of this process on an execution that
+// stopped running here
+func (r *flowRuntime) releaseExecution(executionID string) {
+	store, ok := r.executionStore.(ExecutionClaimer)
+	if !ok || r.queue != nil {
+		return
+	}
+	if err := store.ReleaseExecution(executionID, r.leaseOwner(store)); err != nil {
+		fmt.Printf("Failed to release execution %s: %v\n", executionID, err)
+	}
+}
+
+// renewExecutionLeases renews the leases of the executions active in this
+// process, well before they expire
+func (r *flowRuntime) renewExecutionLeases(store ExecutionClaimer) {
+	ticker := time.NewTicker(executionLeaseTimeout / 3)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.mu.RLock()
+		executionIDs := make([]string, 0, len(r.activeExecutions))
+		for executionID := range r.activeExecutions {
+			executionIDs = append(executionIDs, executionID)
+		}
+		r.mu.RUnlock()
+
+		until := time.Now().Add(executionLeaseTimeout)
+		for _, executionID := range executionIDs {
+			claimed, err := store.ClaimExecution(executionID, r.owner, until)
+			if err != nil {
+				fmt.Printf("Failed to renew the lease of execution %s: %v\n", executionID, err)
+			} else if !claimed {
+				r.logExecution(executionID, "warning", "Another process took over the execution", nil)
+			}
+		}
+	}
+}
+
+// resumeExpiredLeases looks for interrupted executions until the process
+// stops, so that the executions of other processes that stopped are resumed
+// once their lease expired
+func (r *flowRuntime) resumeExpiredLeases() {
+	ticker := time.NewTicker(executionLeaseTimeout)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.resumeInterruptedExecutions()
+	}
+}
@@ -0,0 +1,293 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/loader"
+)
+
+// Merge strategies of join nodes
+const (
+	// MergeBranches keeps no branch changes to the shared state other than
+	// the node outputs; the branch results are available in the join output
+	MergeBranches = "branches"
+
+	// MergeShallow copies the top-level keys that a branch changed into the
+	// shared state
+	MergeShallow = "shallow"
+
+	// MergeDeep copies the values that a branch changed into the shared
+	// state, merging nested objects key by key
+	MergeDeep = "deep"
+)
+
+// splitNode runs its branches in parallel, each on its own copy of the shared
+// state, and waits for them as its join node declares
+type splitNode struct {
+	flowlib.Node
+}
+
+// NewSplitNodeWrapper creates a node that runs the successors under its branch
+// actions in parallel.
+//
+// Every action other than default, error and timeout names a branch. A branch
+// runs on a copy of the public shared state until it reaches the join node
+// where the branches meet, or ends. The wait, timeout and merge parameters of
+// that join node decide when the split node finishes; the execution then
+// continues at the join node. Without a join node the split node waits for
+// all branches and continues with its default action.
+func NewSplitNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
+	node := &splitNode{Node: flowlib.NewNode(1, 0)}
+	node.SetParams(params)
+	return node, nil
+}
+
+// Run implements flowlib.Node
+func (s *splitNode) Run(shared interface{}) (flowlib.Action, error) {
+	return s.RunWithContext(context.Background(), shared)
+}
+
+// RunWithContext runs the branches and stores the join output as the result
+func (s *splitNode) RunWithContext(ctx context.Context, shared interface{}) (flowlib.Action, error) {
+	sharedMap, ok := shared.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("expected map[string]interface{} shared state, got %T", shared)
+	}
+	scope, err := loopScope(ctx, "split")
+	if err != nil {
+		return "", err
+	}
+
+	branches := make(map[string]flowlib.Node)
+	for action, successor := range s.Successors() {
+		if loader.IsSplitBranch(string(action)) {
+			branches[string(action)] = successor
+		}
+	}
+	if len(branches) == 0 {
+		return "", fmt.Errorf("split node has no branches")
+	}
+
+	join := s.Successors()[loader.JoinAction]
+	options := joinOptions{wait: len(branches), merge: MergeBranches}
+	if join != nil {
+		if options, err = joinOptionsOf(join.Params(), len(branches)); err != nil {
+			return "", fmt.Errorf("invalid join node %s: %w", nodeIDOf(join), err)
+		}
+	}
+	options.nodeID = nodeIDOf(s)
+
+	output, err := scope.runtime.runBranches(ctx, scope, branches, join, options, sharedMap)
+	if err != nil {
+		return "", err
+	}
+	recordNodeOutput(ctx, output)
+	sharedMap["result"] = output
+	sharedMap["input"] = output
+
+	if join != nil {
+		return loader.JoinAction, nil
+	}
+	return flowlib.DefaultAction, nil
+}
+
+// NewJoinNodeWrapper creates the node where the branches of a split node meet.
+//
+// Parameters:
+//   - wait: "all" (default), "any", or the number of branches to wait for
+//   - timeout: how long to wait, such as "30s"; the split node fails with a
+//     timeout once it is exceeded
+//   - merge: how branch changes to the shared state are kept: "branches"
+//     (default) keeps none, "shallow" copies changed top-level keys and
+//     "deep" merges changed nested values. Branches are applied in the order
+//     of their names, so later names win conflicts. The outputs of the
+//     nodes that ran in completed branches are kept in any case.
+//
+// The join node passes on the output of its split node, which holds the
+// results of the completed branches by branch name under "branches".
+func NewJoinNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
+	if _, err := joinOptionsOf(params, 0); err != nil {
+		return nil, err
+	}
+
+	// Create the base node
+	baseNode := flowlib.NewNode(1, 0)
+
+	// Create the wrapper
+	wrapper := &NodeWrapper{
+		node: baseNode,
+		exec: func(input interface{}) (interface{}, error) {
+			_, flowInput, err := nodeInput(input)
+			if err != nil {
+				return nil, err
+			}
+			return flowInput, nil
+		},
+	}
+
+	// Set the parameters
+	wrapper.SetParams(params)
+
+	return wrapper, nil
+}
+
+// joinOptions describes how a split node waits for its branches
+type joinOptions struct {
+	nodeID  string
+	wait    int
+	timeout time.Duration
+	merge   string
+}
+
+// joinOptionsOf reads the parameters of a join node for a split node with the
+// given number of branches. With branches 0 the wait count is not checked
+// against the branches.
+func joinOptionsOf(params map[string]interface{}, branches int) (joinOptions, error) {
+	options := joinOptions{wait: branches, merge: MergeBranches}
+
+	switch wait := params["wait"].(type) {
+	case nil:
+	case string:
+		switch wait {
+		case "all":
+		case "any":
+			options.wait = 1
+		default:
+			return joinOptions{}, fmt.Errorf("wait must be all, any or a number of branches, got %q", wait)
+		}
+	default:
+		count, err := intParam(params, "wait", 0)
+		if err != nil {
+			return joinOptions{}, err
+		}
+		if branches > 0 && count > branches {
+			return joinOptions{}, fmt.Errorf("wait for %d branches, but the split node has %d", count, branches)
+		}
+		options.wait = count
+	}
+
+	if timeout, ok := params["timeout"].(string); ok && timeout != "" {
+		duration, err := time.ParseDuration(timeout)
+		if err != nil {
+			return joinOptions{}, fmt.Errorf("invalid timeout: %w", err)
+		}
+		options.timeout = duration
+	}
+
+	if merge, ok := params["merge"].(string); ok && merge != "" {
+		switch merge {
+		case MergeBranches, MergeShallow, MergeDeep:
+			options.merge = merge
+		default:
+			return joinOptions{}, fmt.Errorf("merge must be %s, %s or %s, got %q", MergeBranches, MergeShallow, MergeDeep, merge)
+		}
+	}
+	return options, nil
+}
+
+// branchOutcome is how a branch of a split node ended
+type branchOutcome struct {
+	name   string
+	scope  *flowlib.BranchScope
+	result interface{}
+	err    error
+}
+
+// runBranches runs every branch on its own scope of the shared state until
+// options.wait of them completed, its nodes taking the same path as the nodes
+// of the execution. The remaining branches are canceled. The
+// node outputs of the completed branches are kept, their other changes as
+// options.merge declares.
+func (r *flowRuntime) runBranches(ctx context.Context, scope *executionScope, branches map[string]flowlib.Node, join flowlib.Node, options joinOptions, shared map[string]interface{}) (map[string]interface{}, error) {
+	names := make([]string, 0, len(branches))
+	for name := range branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r.logExecution(scope.execution.status.ID, "info", "Running parallel branches", map[string]interface{}{
+		"node_id":  options.nodeID,
+		"branches": names,
+		"wait":     options.wait,
+	})
+
+	branchCtx, cancel := context.WithCancel(withExecutionScope(ctx, r, scope.execution))
+	defer cancel()
+	var timeout <-chan time.Time
+	if options.timeout > 0 {
+		timer := time.NewTimer(options.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	outputs, _ := shared[NodeOutputsKey].(map[string]interface{})
+	// Branches start with the completions recorded so far and hand over theirs
+	recorded, _ := shared[compensationsKey].([]interface{})
+	outcomes := make(chan branchOutcome, len(names))
+	for _, name := range names {
+		branchScope := flowlib.ForkScope(shared)
+		go func(name string, start flowlib.Node) {
+			state := branchScope.State()
+			action, err := r.runNestedGraph(branchCtx, scope.execution, start, join, state)
+			outcomes <- branchOutcome{name: name, scope: branchScope, result: flowResult(action, state), err: err}
+		}(name, branches[name])
+	}
+
+	completed := make(map[string]branchOutcome)
+	failed := make(map[string]interface{})
+	for len(completed) < options.wait {
+		select {
+		case outcome := <-outcomes:
+			// The branch nodes that completed are compensated with the split
+			// node, whether or not their branch failed
+			handOverCompletions(ctx, outcome.scope.State(), len(recorded))
+			if outcome.err == nil {
+				completed[outcome.name] = outcome
+				continue
+			}
+			failed[outcome.name] = outcome.err.Error()
+			r.logExecution(scope.execution.status.ID, "warning", "Branch failed", map[string]interface{}{
+				"node_id": options.nodeID,
+				"branch":  outcome.name,
+				"error":   outcome.err.Error(),
+			})
+			if len(names)-len(failed) < options.wait {
+				return nil, fmt.Errorf("branch %s failed: %w", outcome.name, outcome.err)
+			}
+		case <-timeout:
+			return nil, &TimeoutError{NodeID: options.nodeID, Timeout: options.timeout}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	results := make(map[string]interface{}, len(completed))
+	completedNames := make([]interface{}, 0, len(completed))
+	for _, name := range names {
+		outcome, ok := completed[name]
+		if !ok {
+			continue
+		}
+		results[name] = outcome.result
+		completedNames = append(completedNames, name)
+
+		if options.merge != MergeBranches {
+			deep := options.merge == MergeDeep
+			flowlib.MergeChanges(shared, outcome.scope.Changes(deep), deep)
+		}
+		mergeNodeOutputs(shared, outputs, outcome.scope.State())
+	}
+
+	output := map[string]interface{}{
+		"branches":  results,
+		"completed": completedNames,
+	}
+	if len(failed) > 0 {
+		output["errors"] = failed
+	}
+	return output, nil
+}
@@ -0,0 +1,277 @@
+package runtime
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/loader"
+)
+
+// newBranchNode builds a node that marks its branch in the shared state once
+// release is closed, or fails if fail is set
+func newBranchNode(branch string, release <-chan struct{}, fail bool) flowlib.Node {
+	node := flowlib.NewNode(1, 0)
+	node.SetParams(map[string]interface{}{"node_id": branch})
+	node.SetPrepFn(func(shared any) (any, error) {
+		if release != nil {
+			<-release
+		}
+		if fail {
+			return nil, fmt.Errorf("branch %s is broken", branch)
+		}
+		sharedMap := shared.(map[string]interface{})
+		config := sharedMap["config"].(map[string]interface{})
+		config[branch] = true
+		sharedMap["winner"] = branch
+		sharedMap["result"] = map[string]interface{}{"branch": branch}
+		return nil, nil
+	})
+	return node
+}
+
+// newFanOutFlow builds a flow that splits into branches, joins them with the
+// given join parameters and then hands the shared state to final
+func newFanOutFlow(t *testing.T, branches map[string]flowlib.Node, joinParams map[string]interface{}, final chan<- map[string]interface{}) *flowlib.Flow {
+	split, err := NewSplitNodeWrapper(map[string]interface{}{"node_id": "fan_out"})
+	require.NoError(t, err)
+	joinParams["node_id"] = "gather"
+	join, err := NewJoinNodeWrapper(joinParams)
+	require.NoError(t, err)
+
+	for name, branch := range branches {
+		split.Next(flowlib.Action(name), branch)
+		branch.Next(flowlib.DefaultAction, join)
+	}
+	split.Next(loader.JoinAction, join)
+
+	report := flowlib.NewNode(1, 0)
+	report.SetParams(map[string]interface{}{"node_id": "report"})
+	report.SetPrepFn(func(shared any) (any, error) {
+		final <- publicSharedState(shared.(map[string]interface{}))
+		return nil, nil
+	})
+	join.Next(flowlib.DefaultAction, report)
+	return flowlib.NewFlow(split)
+}
+
+func newFanOutInput() map[string]interface{} {
+	return map[string]interface{}{"config": map[string]interface{}{"retries": float64(3)}}
+}
+
+func TestSplitNode_WaitAll(t *testing.T) {
+	tests := []struct {
+		merge          string
+		expectedConfig interface{}
+		expectedWinner interface{}
+	}{
+		{
+			merge:          MergeBranches,
+			expectedConfig: map[string]interface{}{"retries": float64(3)},
+		},
+		{
+			merge:          MergeShallow,
+			expectedConfig: map[string]interface{}{"retries": float64(3), "users": true},
+			expectedWinner: "users",
+		},
+		{
+			merge:          MergeDeep,
+			expectedConfig: map[string]interface{}{"retries": float64(3), "orders": true, "users": true},
+			expectedWinner: "users",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.merge, func(t *testing.T) {
+			final := make(chan map[string]interface{}, 1)
+			flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+				"fan-out": newFanOutFlow(t, map[string]flowlib.Node{
+					"orders": newBranchNode("orders", nil, false),
+					"users":  newBranchNode("users", nil, false),
+				}, map[string]interface{}{"merge": tt.merge}, final),
+			})
+
+			executionID, err := flowRuntime.Execute("test-account", "fan-out", newFanOutInput())
+			require.NoError(t, err)
+			waitForStatus(t, store, executionID, "completed")
+
+			shared := <-final
+			assert.Equal(t, tt.expectedConfig, shared["config"])
+			assert.Equal(t, tt.expectedWinner, shared["winner"])
+
+			output := shared["result"].(map[string]interface{})
+			assert.Equal(t, []interface{}{"orders", "users"}, output["completed"])
+			branches := output["branches"].(map[string]interface{})
+			assert.Equal(t, "orders", branches["orders"].(map[string]interface{})["branch"])
+			assert.Equal(t, "users", branches["users"].(map[string]interface{})["branch"])
+		})
+	}
+}
+
+func TestSplitNode_WaitAny(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	final := make(chan map[string]interface{}, 1)
+	flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+		"fan-out": newFanOutFlow(t, map[string]flowlib.Node{
+			"fast": newBranchNode("fast", nil, false),
+			"slow": newBranchNode("slow", release, false),
+		}, map[string]interface{}{"wait": "any", "merge": MergeShallow}, final),
+	})
+
+	executionID, err := flowRuntime.Execute("test-account", "fan-out", newFanOutInput())
+	require.NoError(t, err)
+	waitForStatus(t, store, executionID, "completed")
+
+	shared := <-final
+	assert.Equal(t, "fast", shared["winner"])
+	assert.Equal(t, []interface{}{"fast"}, shared["result"].(map[string]interface{})["completed"])
+}
+
+func TestSplitNode_WaitCount(t *testing.T) {
+	final := make(chan map[string]interface{}, 1)
+	flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+		"fan-out": newFanOutFlow(t, map[string]flowlib.Node{
+			"a": newBranchNode("a", nil, false),
+			"b": newBranchNode("b", nil, true),
+			"c": newBranchNode("c", nil, false),
+		}, map[string]interface{}{"wait": 2}, final),
+	})
+
+	executionID, err := flowRuntime.Execute("test-account", "fan-out", newFanOutInput())
+	require.NoError(t, err)
+	waitForStatus(t, store, executionID, "completed")
+
+	output := (<-final)["result"].(map[string]interface{})
+	assert.Len(t, output["branches"], 2)
+	assert.Equal(t, []interface{}{"a", "c"}, output["completed"])
+}
+
+func TestSplitNode_KeepsNodeOutputsBesideUserOutputs(t *testing.T) {
+	newTransform := func(nodeID string) flowlib.Node {
+		node, err := NewTransformNodeWrapper(map[string]interface{}{
+			"node_id": nodeID,
+			"script":  fmt.Sprintf("return {count: %d};", len(nodeID)),
+		})
+		require.NoError(t, err)
+		return node
+	}
+
+	final := make(chan map[string]interface{}, 1)
+	flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+		"fan-out": newFanOutFlow(t, map[string]flowlib.Node{
+			"orders": newTransform("orders"),
+			"users":  newTransform("users"),
+		}, map[string]interface{}{"merge": MergeShallow}, final),
+	})
+
+	input := newFanOutInput()
+	input["outputs"] = map[string]interface{}{"format": "pdf"}
+	executionID, err := flowRuntime.Execute("test-account", "fan-out", input)
+	require.NoError(t, err)
+	waitForStatus(t, store, executionID, "completed")
+
+	// The node outputs do not overwrite a field of the flow named "outputs"
+	shared := <-final
+	assert.Equal(t, map[string]interface{}{"format": "pdf"}, shared["outputs"])
+	outputs := shared[NodeOutputsKey].(map[string]interface{})
+	assert.EqualValues(t, 6, outputs["orders"].(map[string]interface{})["count"])
+	assert.EqualValues(t, 5, outputs["users"].(map[string]interface{})["count"])
+}
+
+func TestSplitNode_Failures(t *testing.T) {
+	t.Run("failed branch", func(t *testing.T) {
+		flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+			"fan-out": newFanOutFlow(t, map[string]flowlib.Node{
+				"a": newBranchNode("a", nil, false),
+				"b": newBranchNode("b", nil, true),
+			}, map[string]interface{}{}, make(chan map[string]interface{}, 1)),
+		})
+
+		executionID, err := flowRuntime.Execute("test-account", "fan-out", newFanOutInput())
+		require.NoError(t, err)
+		status := waitForStatus(t, store, executionID, "failed")
+		assert.Contains(t, status.Error, "branch b failed")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+			"fan-out": newFanOutFlow(t, map[string]flowlib.Node{
+				"a": newBranchNode("a", nil, false),
+				"b": newBranchNode("b", release, false),
+			}, map[string]interface{}{"timeout": "50ms"}, make(chan map[string]interface{}, 1)),
+		})
+
+		started := time.Now()
+		executionID, err := flowRuntime.Execute("test-account", "fan-out", newFanOutInput())
+		require.NoError(t, err)
+		status := waitForStatus(t, store, executionID, "timeout")
+		assert.Contains(t, status.Error, "node fan_out timed out after 50ms")
+		assert.Less(t, time.Since(started), 2*time.Second)
+	})
+
+	t.Run("invalid join parameters", func(t *testing.T) {
+		_, err := NewJoinNodeWrapper(map[string]interface{}{"wait": "most"})
+		assert.Error(t, err)
+		_, err = NewJoinNodeWrapper(map[string]interface{}{"merge": "random"})
+		assert.Error(t, err)
+	})
+}
+
+func TestSplitNode_BranchesTakeExecutionPath(t *testing.T) {
+	var mu sync.Mutex
+	var undone []interface{}
+	orders := newBranchNode("orders", nil, false)
+	undo := flowlib.NewNode(1, 0)
+	undo.SetParams(map[string]interface{}{"node_id": "undo_orders"})
+	undo.SetPrepFn(func(shared any) (any, error) {
+		mu.Lock()
+		undone = append(undone, shared.(map[string]interface{})["input"])
+		mu.Unlock()
+		return nil, nil
+	})
+	orders.Next(loader.CompensateAction, undo)
+
+	users := newFailingNode("users", 1)
+	handler := newRecordingNode("handle_users")
+	users.Next(ErrorAction, handler)
+
+	flow := newFanOutFlow(t, map[string]flowlib.Node{
+		"orders": orders,
+		"users":  users,
+	}, map[string]interface{}{}, make(chan map[string]interface{}, 1))
+	join := flow.Start().Successors()[loader.JoinAction]
+	handler.Next(flowlib.DefaultAction, join)
+	join.Successors()[flowlib.DefaultAction].Next(flowlib.DefaultAction, newFailingNode("notify", 1))
+
+	flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{"fan-out": flow})
+	executionID, err := flowRuntime.Execute("test-account", "fan-out", newFanOutInput())
+	require.NoError(t, err)
+	waitForStatus(t, store, executionID, "failed")
+
+	// The failed branch node followed its error action
+	trace, err := flowRuntime.(ExecutionTracer).GetTrace("test-account", executionID)
+	require.NoError(t, err)
+	actions := make(map[string]string)
+	for _, visit := range trace {
+		actions[visit.NodeID] = visit.Action
+	}
+	assert.Equal(t, ErrorAction, actions["users"])
+	assert.Contains(t, actions, "handle_users")
+	assert.Contains(t, actions, "orders")
+
+	// The completed branch node was compensated once the execution failed
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"node_id": "orders", "result": map[string]interface{}{"branch": "orders"}},
+	}, undone)
+}
@@ -0,0 +1,374 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/scripting"
+)
+
+// ParentExecutionIDKey is the ExecutionStatus.Metadata key that links a
+// sub-flow execution to the execution that started it
+const ParentExecutionIDKey = "parent_execution_id"
+
+// maxFlowCallDepth bounds nested flow.call chains, so a flow that calls
+// itself fails instead of recursing forever
+const maxFlowCallDepth = 16
+
+// executionScopeKey is the context key under which the current execution is stored
+type executionScopeKey struct{}
+
+// executionScope identifies the execution a node runs in. It travels with the
+// execution context so that nodes such as flow.call can reach the runtime.
+type executionScope struct {
+	runtime   *flowRuntime
+	execution *executionContext
+	depth     int
+
+	// settings are the settings of the flow the node belongs to: the flow
+	// of the execution or an inline sub-flow
+	settings flowSettings
+}
+
+// withExecutionScope returns a context that carries the given execution,
+// nested one level below the scope already present in ctx. Node retries in
+// that context are logged to the execution.
+func withExecutionScope(ctx context.Context, r *flowRuntime, execCtx *executionContext) context.Context {
+	settings := execCtx.settings
+	if parent, ok := executionScopeFrom(ctx); ok && parent.execution == execCtx {
+		// Loop bodies and branches in an inline sub-flow belong to the sub-flow
+		settings = parent.settings
+	}
+	return withFlowScope(ctx, r, execCtx, settings)
+}
+
+// withFlowScope is like withExecutionScope, but the nodes in the returned
+// context run with the settings of the given flow, as the nodes of an inline
+// sub-flow do
+func withFlowScope(ctx context.Context, r *flowRuntime, execCtx *executionContext, settings flowSettings) context.Context {
+	depth := 0
+	if parent, ok := executionScopeFrom(ctx); ok {
+		depth = parent.depth + 1
+	}
+	ctx = flowlib.WithRetryObserver(ctx, r.retryObserver(execCtx))
+	return context.WithValue(ctx, executionScopeKey{}, &executionScope{
+		runtime:   r,
+		execution: execCtx,
+		depth:     depth,
+		settings:  settings,
+	})
+}
+
+// executionScopeFrom returns the execution scope stored in ctx, if any
+func executionScopeFrom(ctx context.Context) (*executionScope, bool) {
+	scope, ok := ctx.Value(executionScopeKey{}).(*executionScope)
+	return scope, ok
+}
+
+// nodeSettings returns the settings of the flow whose nodes run in ctx
+func nodeSettings(ctx context.Context, execCtx *executionContext) flowSettings {
+	if scope, ok := executionScopeFrom(ctx); ok && scope.execution == execCtx {
+		return scope.settings
+	}
+	return execCtx.settings
+}
+
+// flowCall describes a sub-flow invocation made by a flow.call node
+type flowCall struct {
+	flowID  string
+	version string
+	mode    string // "inline" or "child"
+	input   map[string]interface{}
+	output  map[string]interface{}
+}
+
+// NewFlowCallNodeWrapper creates a node that runs another flow of the same account.
+//
+// Parameters:
+//   - flow_id: the flow to call (required)
+//   - version: pins a flow version; requires a VersionedFlowRegistry
+//   - mode: "inline" (default) runs the sub-flow inside the current execution,
+//     "child" runs it as a separate execution linked through its metadata
+//   - input: the sub-flow input; defaults to the current node input
+//   - output: maps result keys to expressions evaluated after the sub-flow
+//     completes, with "result" and "shared" referring to the sub-flow's final
+//     result and shared state (e.g. total: "result.order.total")
+func NewFlowCallNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
+	// Create the base node
+	baseNode := flowlib.NewNode(1, 0)
+
+	// Create the wrapper
+	wrapper := &NodeWrapper{
+		node: baseNode,
+		execWithContext: func(ctx context.Context, input interface{}) (interface{}, error) {
+			// Handle both old format (direct params) and new format (combined input)
+			var params map[string]interface{}
+			var flowInput interface{}
+
+			if combinedInput, ok := input.(map[string]interface{}); ok {
+				if nodeParams, hasParams := combinedInput["params"]; hasParams {
+					// New format: combined input with params and input
+					if paramsMap, ok := nodeParams.(map[string]interface{}); ok {
+						params = paramsMap
+					} else {
+						return nil, fmt.Errorf("expected params to be map[string]interface{}")
+					}
+					flowInput = combinedInput["input"]
+				} else {
+					// Old format: direct params (backwards compatibility)
+					params = combinedInput
+				}
+			} else {
+				return nil, fmt.Errorf("expected map[string]interface{}, got %T", input)
+			}
+
+			call, err := flowCallFromParams(params)
+			if err != nil {
+				return nil, err
+			}
+			call.input = callInput(params, flowInput)
+
+			scope, ok := executionScopeFrom(ctx)
+			if !ok {
+				return nil, fmt.Errorf("flow.call can only run inside a flow execution")
+			}
+			if scope.depth >= maxFlowCallDepth {
+				return nil, fmt.Errorf("flow.call nesting exceeds %d levels", maxFlowCallDepth)
+			}
+
+			return scope.runtime.callFlow(ctx, scope.execution, call)
+		},
+	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
+
+	// Set the parameters
+	wrapper.SetParams(params)
+
+	return wrapper, nil
+}
+
+// flowCallFromParams reads the sub-flow, version, mode and output mapping of
+// a flow.call node from its parameters
+func flowCallFromParams(params map[string]interface{}) (flowCall, error) {
+	flowID, ok := params["flow_id"].(string)
+	if !ok || flowID == "" {
+		return flowCall{}, fmt.Errorf("flow_id parameter is required")
+	}
+
+	call := flowCall{flowID: flowID, mode: "inline"}
+	if version, ok := params["version"].(string); ok {
+		call.version = version
+	}
+	if mode, ok := params["mode"].(string); ok && mode != "" {
+		call.mode = mode
+	}
+	if call.mode != "inline" && call.mode != "child" {
+		return flowCall{}, fmt.Errorf("invalid mode %q: expected inline or child", call.mode)
+	}
+
+	if outputMap, ok := params["output"].(map[string]interface{}); ok {
+		call.output = outputMap
+	}
+	return call, nil
+}
+
+// callInput returns the explicit input mapping of a node, falling back to
+// the current node input
+func callInput(params map[string]interface{}, flowInput interface{}) map[string]interface{} {
+	if inputMap, ok := params["input"].(map[string]interface{}); ok {
+		return publicSharedState(inputMap)
+	}
+	if inputMap, ok := flowInput.(map[string]interface{}); ok {
+		return publicSharedState(inputMap)
+	}
+	return make(map[string]interface{})
+}
+
+// callFlow runs a sub-flow on behalf of the caller execution and returns the
+// node result built from the sub-flow's outcome
+func (r *flowRuntime) callFlow(ctx context.Context, caller *executionContext, call flowCall) (interface{}, error) {
+	flow, settings, err := r.loadFlow(caller.accountID, call.flowID, call.version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sub-flow %s: %w", call.flowID, err)
+	}
+	if err := validateInput(settings, call.input); err != nil {
+		return nil, fmt.Errorf("sub-flow %s rejected its input: %w", call.flowID, err)
+	}
+
+	if call.mode == "inline" {
+		r.logExecution(caller.status.ID, "info", "Running sub-flow inline", map[string]interface{}{
+			"flow_id": call.flowID,
+			"version": call.version,
+		})
+		return r.runInlineFlow(ctx, caller, flow, settings, call)
+	}
+
+	metadata := map[string]string{ParentExecutionIDKey: caller.status.ID}
+	if inDryRun(ctx) {
+		metadata[DryRunKey] = "true"
+	}
+	if environment := executionEnvironment(caller); environment != "" {
+		metadata[EnvironmentKey] = environment
+	}
+	childCtx, child, err := r.startExecution(ctx, caller.accountID, settings, newExecutionStatus(call.flowID, "running", metadata))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sub-flow %s: %w", call.flowID, err)
+	}
+	r.logExecution(caller.status.ID, "info", "Started sub-flow execution", map[string]interface{}{
+		"flow_id":      call.flowID,
+		"version":      call.version,
+		"execution_id": child.status.ID,
+	})
+
+	shared, err := r.runChildExecution(childCtx, child, flow, call.input)
+
+	child.mu.RLock()
+	status := child.status
+	child.mu.RUnlock()
+	if err == nil && status.Status != "completed" {
+		err = errors.New(status.Error)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sub-flow %s execution %s failed: %w", call.flowID, status.ID, err)
+	}
+
+	result, err := mapFlowOutputs(call.output, status.Results, shared)
+	if err != nil {
+		return nil, err
+	}
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return result, nil
+	}
+
+	// Copy before adding the child ID, the results map is shared with the child status
+	withID := make(map[string]interface{}, len(resultMap)+1)
+	for k, v := range resultMap {
+		withID[k] = v
+	}
+	if _, exists := withID["execution_id"]; !exists {
+		withID["execution_id"] = status.ID
+	}
+	return withID, nil
+}
+
+// runInlineFlow runs a sub-flow inside the caller execution. Its nodes take
+// the same path as the nodes of the caller, with the timeouts, visit limits
+// and dry run outputs of the sub-flow. Like a child execution, a failed
+// sub-flow runs its own compensations and on_error handler.
+func (r *flowRuntime) runInlineFlow(ctx context.Context, caller *executionContext, flow *flowlib.Flow, settings flowSettings, call flowCall) (interface{}, error) {
+	// Inline sub-flows log into the caller execution but get their own shared state
+	shared := r.newSharedState(caller, call.input)
+	if _, ok := shared[varsKey]; ok {
+		// The sub-flow sees its own vars, in the environment of the caller
+		shared[varsKey] = settings.resolveVars(executionEnvironment(caller))
+	}
+	ctx = flowlib.WithVisitLimits(ctx, r.resolveVisitLimits(settings))
+	if inDryRun(ctx) {
+		// The sub-flow declares its own dry run outputs
+		ctx = withDryRun(ctx, settings.dryRunOutputs)
+	}
+
+	flowCtx, cancel := context.WithCancel(ctx)
+	if settings.timeout > 0 {
+		flowCtx, cancel = context.WithTimeout(ctx, settings.timeout)
+	}
+	defer cancel()
+	flowCtx = withFlowScope(flowCtx, r, caller, settings)
+
+	action, err := r.runNestedGraph(flowCtx, caller, flow.Start(), nil, shared)
+	var timeoutErr *TimeoutError
+	if err != nil && !errors.As(err, &timeoutErr) && flowCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		err = &TimeoutError{Timeout: settings.timeout}
+	}
+	if err != nil {
+		r.handleFailure(flowCtx, caller, flow, shared, err)
+		return nil, fmt.Errorf("sub-flow %s failed: %w", call.flowID, err)
+	}
+
+	result := flowResult(action, shared)
+	if err := validateResults(settings, result); err != nil {
+		return nil, fmt.Errorf("sub-flow %s failed: %w", call.flowID, err)
+	}
+	return mapFlowOutputs(call.output, result, shared)
+}
+
+// runChildExecution runs a sub-flow execution to completion in the calling
+// goroutine and returns its final shared state
+func (r *flowRuntime) runChildExecution(ctx context.Context, execCtx *executionContext, flow *flowlib.Flow, input map[string]interface{}) (shared map[string]interface{}, err error) {
+	defer r.finishExecution(execCtx)
+
+	ctx, cancel := withFlowTimeout(ctx, execCtx)
+	defer cancel()
+
+	r.logExecution(execCtx.status.ID, "info", "Starting flow execution", map[string]interface{}{"flowID": execCtx.flowID, "accountID": execCtx.accountID})
+
+	shared = r.newSharedState(execCtx, input)
+	action, err := r.runFlowGraph(ctx, execCtx, flow.Start(), shared, 0)
+
+	var result interface{}
+	if err == nil {
+		result = flowResult(action, shared)
+	} else {
+		r.handleFailure(ctx, execCtx, flow, shared, err)
+	}
+	if errors.Is(err, context.Canceled) {
+		// Unless the child itself was canceled, the cancellation came from the parent
+		execCtx.mu.RLock()
+		canceled := execCtx.status.Status == "canceled"
+		execCtx.mu.RUnlock()
+		if !canceled {
+			r.updateExecutionStatus(execCtx.status.ID, "canceled", "Parent execution was canceled", nil)
+		}
+	}
+	r.completeExecution(execCtx, result, err)
+	return shared, err
+}
+
+// mapFlowOutputs evaluates the output mapping of a flow.call node against the
+// sub-flow's result and shared state. Without a mapping the result is returned as is.
+func mapFlowOutputs(output map[string]interface{}, result interface{}, shared map[string]interface{}) (interface{}, error) {
+	if len(output) == 0 {
+		return result, nil
+	}
+
+	evaluator := scripting.NewJSExpressionEvaluator()
+	evalContext := map[string]interface{}{
+		"result": result,
+		"shared": publicSharedState(shared),
+	}
+
+	mapped := make(map[string]interface{}, len(output))
+	for key, value := range output {
+		expression, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("output %s must be an expression string", key)
+		}
+		if !strings.HasPrefix(expression, "${") {
+			expression = "${" + expression + "}"
+		}
+
+		evaluated, err := evaluator.Evaluate(expression, evalContext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to map output %s: %w", key, err)
+		}
+		mapped[key] = evaluated
+	}
+	return mapped, nil
+}
+
+// publicSharedState returns a shallow copy of a shared state without the
+// runtime-internal keys. The node outputs are kept.
+func publicSharedState(shared map[string]interface{}) map[string]interface{} {
+	public := make(map[string]interface{}, len(shared))
+	for k, v := range shared {
+		if !isPublicKey(k) {
+			continue
+		}
+		public[k] = v
+	}
+	return public
+}
@@ -0,0 +1,226 @@
+package runtime
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+// versionedTestRegistry serves flows by ID and, when pinned, by ID and version
+type versionedTestRegistry struct {
+	flows map[string]string
+}
+
+func (r *versionedTestRegistry) GetFlow(accountID, flowID string) (*Flow, error) {
+	return r.GetFlowVersion(accountID, flowID, "")
+}
+
+func (r *versionedTestRegistry) GetFlowVersion(accountID, flowID, version string) (*Flow, error) {
+	key := flowID
+	if version != "" {
+		key = flowID + "@" + version
+	}
+	yaml, ok := r.flows[key]
+	if !ok {
+		return nil, fmt.Errorf("flow not found: %s", key)
+	}
+	return &Flow{ID: flowID, YAML: yaml}, nil
+}
+
+// newDoublingFlow builds a single-node flow whose result is twice the "amount" input
+func newDoublingFlow() *flowlib.Flow {
+	node := flowlib.NewNode(1, 0)
+	node.SetParams(map[string]interface{}{"node_id": "double"})
+	node.SetPrepFn(func(shared any) (any, error) {
+		sharedMap := shared.(map[string]interface{})
+		amount, _ := sharedMap["amount"].(float64)
+		sharedMap["result"] = map[string]interface{}{"total": amount * 2}
+		return nil, nil
+	})
+	return flowlib.NewFlow(node)
+}
+
+// newCallerFlow builds a single flow.call node with the given parameters
+func newCallerFlow(t *testing.T, params map[string]interface{}) *flowlib.Flow {
+	node, err := NewFlowCallNodeWrapper(params)
+	require.NoError(t, err)
+	return flowlib.NewFlow(node)
+}
+
+func TestFlowCallNode_Inline(t *testing.T) {
+	registry := &versionedTestRegistry{flows: map[string]string{"parent": "parent", "child": "child"}}
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", "parent").Return(newCallerFlow(t, map[string]interface{}{
+		"node_id": "call",
+		"flow_id": "child",
+		"output":  map[string]interface{}{"doubled": "result.total"},
+	}), nil)
+	mockYAMLLoader.On("Parse", "child").Return(newDoublingFlow(), nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(registry, mockYAMLLoader, store)
+
+	executionID, err := flowRuntime.Execute("test-account", "parent", map[string]interface{}{"amount": float64(21)})
+	require.NoError(t, err)
+
+	status := waitForStatus(t, store, executionID, "completed")
+	assert.Equal(t, float64(42), status.Results["doubled"])
+
+	// Inline calls do not create executions of their own
+	store.mu.Lock()
+	assert.Len(t, store.executions, 1)
+	store.mu.Unlock()
+}
+
+func TestFlowCallNode_InlineNodesFollowTheirSettings(t *testing.T) {
+	childYAML := `
+metadata:
+  name: child
+nodes:
+  slow:
+    type: delay
+    timeout: 50ms
+    next:
+      timeout: fallback
+  fallback:
+    type: base
+`
+	slow := newSlowNode(t)
+	fallback := newRecordingNode("fallback")
+	slow.Next(TimeoutAction, fallback)
+
+	registry := &versionedTestRegistry{flows: map[string]string{"parent": "parent", "child": childYAML}}
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", "parent").Return(newCallerFlow(t, map[string]interface{}{
+		"node_id": "call",
+		"flow_id": "child",
+	}), nil)
+	mockYAMLLoader.On("Parse", childYAML).Return(flowlib.NewFlow(slow), nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(registry, mockYAMLLoader, store)
+
+	executionID, err := flowRuntime.Execute("test-account", "parent", nil)
+	require.NoError(t, err)
+	waitForStatus(t, store, executionID, "completed")
+
+	// The sub-flow nodes are traced in the caller execution, before the call returns
+	trace, err := flowRuntime.(ExecutionTracer).GetTrace("test-account", executionID)
+	require.NoError(t, err)
+	var visited []string
+	for _, visit := range trace {
+		visited = append(visited, visit.NodeID)
+	}
+	assert.Equal(t, []string{"call", "slow", "fallback"}, visited)
+	assert.Equal(t, TimeoutAction, trace[1].Action)
+}
+
+func TestFlowCallNode_InlineFailureRunsSubFlowHandlers(t *testing.T) {
+	child := flowlib.NewFlow(newFailingNode("risky", 1))
+	child.SetErrorHandler(newRecordingNode("on_failure"))
+
+	registry := &versionedTestRegistry{flows: map[string]string{"parent": "parent", "child": "child"}}
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", "parent").Return(newCallerFlow(t, map[string]interface{}{
+		"node_id": "call",
+		"flow_id": "child",
+	}), nil)
+	mockYAMLLoader.On("Parse", "child").Return(child, nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(registry, mockYAMLLoader, store)
+
+	executionID, err := flowRuntime.Execute("test-account", "parent", nil)
+	require.NoError(t, err)
+
+	status := waitForStatus(t, store, executionID, "failed")
+	assert.Equal(t, "sub-flow child failed: boom", status.Error)
+
+	logs, err := store.GetExecutionLogs(executionID)
+	require.NoError(t, err)
+	var handled bool
+	for _, log := range logs {
+		if log.Message == "Running on_error handler" && log.Data["node_id"] == "on_failure" {
+			handled = true
+		}
+	}
+	assert.True(t, handled, "the on_error handler of the sub-flow should run")
+}
+
+func TestFlowCallNode_ChildExecution(t *testing.T) {
+	registry := &versionedTestRegistry{flows: map[string]string{"parent": "parent", "child@2": "child-v2"}}
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", "parent").Return(newCallerFlow(t, map[string]interface{}{
+		"node_id": "call",
+		"flow_id": "child",
+		"version": "2",
+		"mode":    "child",
+		"input":   map[string]interface{}{"amount": float64(5)},
+	}), nil)
+	mockYAMLLoader.On("Parse", "child-v2").Return(newDoublingFlow(), nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(registry, mockYAMLLoader, store)
+
+	executionID, err := flowRuntime.Execute("test-account", "parent", nil)
+	require.NoError(t, err)
+
+	status := waitForStatus(t, store, executionID, "completed")
+	assert.Equal(t, float64(10), status.Results["total"])
+
+	childID, ok := status.Results["execution_id"].(string)
+	require.True(t, ok, "child mode should report the child execution ID")
+	child := store.status(childID)
+	assert.Equal(t, "completed", child.Status)
+	assert.Equal(t, "child", child.FlowID)
+	assert.Equal(t, executionID, child.Metadata[ParentExecutionIDKey])
+}
+
+func TestFlowCallNode_Errors(t *testing.T) {
+	t.Run("missing flow", func(t *testing.T) {
+		registry := &versionedTestRegistry{flows: map[string]string{"parent": "parent"}}
+		mockYAMLLoader := new(MockEnhancedYAMLLoader)
+		mockYAMLLoader.On("Parse", "parent").Return(newCallerFlow(t, map[string]interface{}{
+			"node_id": "call",
+			"flow_id": "missing",
+		}), nil)
+
+		store := newCheckpointTestStore()
+		flowRuntime := NewFlowRuntimeWithStore(registry, mockYAMLLoader, store)
+
+		executionID, err := flowRuntime.Execute("test-account", "parent", nil)
+		require.NoError(t, err)
+
+		status := waitForStatus(t, store, executionID, "failed")
+		assert.Contains(t, status.Error, "missing")
+	})
+
+	t.Run("self recursion", func(t *testing.T) {
+		registry := &versionedTestRegistry{flows: map[string]string{"loop": "loop"}}
+		mockYAMLLoader := new(MockEnhancedYAMLLoader)
+		mockYAMLLoader.On("Parse", "loop").Return(newCallerFlow(t, map[string]interface{}{
+			"node_id": "call",
+			"flow_id": "loop",
+		}), nil)
+
+		store := newCheckpointTestStore()
+		flowRuntime := NewFlowRuntimeWithStore(registry, mockYAMLLoader, store)
+
+		executionID, err := flowRuntime.Execute("test-account", "loop", nil)
+		require.NoError(t, err)
+
+		status := waitForStatus(t, store, executionID, "failed")
+		assert.Contains(t, status.Error, "nesting exceeds")
+	})
+
+	t.Run("outside an execution", func(t *testing.T) {
+		node, err := NewFlowCallNodeWrapper(map[string]interface{}{"flow_id": "child"})
+		require.NoError(t, err)
+
+		_, err = node.Run(map[string]interface{}{})
+		assert.Error(t, err)
+	})
+}
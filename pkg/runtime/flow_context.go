@@ -12,6 +12,7 @@ type FlowContext struct {
 	accountID   string
 	nodeResults map[string]any
 	sharedData  map[string]any
+	vars        map[string]any
 	evaluator   scripting.SecretAwareEvaluator
 }
 
@@ -25,6 +26,7 @@ func NewFlowContext(executionID, flowID, accountID string, secretVault auth.Secr
 		accountID:   accountID,
 		nodeResults: make(map[string]any),
 		sharedData:  make(map[string]any),
+		vars:        make(map[string]any),
 		evaluator:   evaluator,
 	}
 }
@@ -51,6 +53,11 @@ func (fc *FlowContext) GetSharedData(key string) (any, bool) {
 	return value, exists
 }
 
+// SetVars sets the flow vars, resolved for the environment of the execution
+func (fc *FlowContext) SetVars(vars map[string]any) {
+	fc.vars = vars
+}
+
 // EvaluateExpression evaluates an expression with full flow context
 func (fc *FlowContext) EvaluateExpression(expression string) (any, error) {
 	context := fc.createEvaluationContext()
@@ -91,6 +98,9 @@ func (fc *FlowContext) createEvaluationContext() map[string]any {
 	// Add shared context for template expressions that use shared.variable
 	context["shared"] = fc.sharedData
 
+	// Add flow vars for template expressions that use vars.name
+	context["vars"] = fc.vars
+
 	return context
 }
 
@@ -2,11 +2,13 @@ package runtime
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/tcmartin/flowlib"
 	"github.com/tcmartin/flowrunner/pkg/auth"
 	"github.com/tcmartin/flowrunner/pkg/loader"
 )
@@ -20,6 +22,90 @@ type ExecutionStore interface {
 	GetExecutionLogs(executionID string) ([]ExecutionLog, error)
 }
 
+// CheckpointStore is implemented by execution stores that can persist
+// per-node checkpoints, allowing interrupted executions to be resumed
+type CheckpointStore interface {
+	// SaveCheckpoint persists a checkpoint for an execution
+	SaveCheckpoint(checkpoint ExecutionCheckpoint) error
+
+	// ListInterruptedExecutions returns the latest checkpoint of every
+	// execution that is still marked as running or queued
+	ListInterruptedExecutions() ([]ExecutionCheckpoint, error)
+
+	// GetExecutionCheckpoints returns the checkpoints of an execution
+	// ordered by step
+	GetExecutionCheckpoints(executionID string) ([]ExecutionCheckpoint, error)
+}
+
+// WaitStore is implemented by execution stores that can persist the waits of
+// executions parked until a signal or event arrives or a timer fires
+type WaitStore interface {
+	// SaveWait persists the wait of an execution, replacing an earlier one
+	SaveWait(wait ExecutionWait) error
+
+	// GetWait returns the wait of an execution, or ErrWaitNotFound
+	GetWait(executionID string) (ExecutionWait, error)
+
+	// ClaimWait removes the wait of an execution and returns it. Of several
+	// concurrent calls only one gets the wait, the others ErrWaitNotFound.
+	ClaimWait(executionID string) (ExecutionWait, error)
+
+	// ListDueWaits returns the waits whose deadline is not after before,
+	// ordered by deadline
+	ListDueWaits(before time.Time) ([]ExecutionWait, error)
+
+	// ListEventWaits returns the waits of an account for the event with the
+	// correlation key
+	ListEventWaits(accountID, event, key string) ([]ExecutionWait, error)
+}
+
+// ExecutionWaiter is implemented by runtimes that can block until an
+// execution finishes
+type ExecutionWaiter interface {
+	// Wait returns the final status of an execution once it finished. If ctx
+	// ends first, it returns the current status and the context error.
+	Wait(ctx context.Context, executionID string) (ExecutionStatus, error)
+}
+
+// waitPollInterval is how often Wait checks the status of executions running
+// in other processes
+const waitPollInterval = 200 * time.Millisecond
+
+// IdempotencyStore is implemented by execution stores that can remember which
+// execution was started for an idempotency key
+type IdempotencyStore interface {
+	// ClaimIdempotencyKey records executionID under the key of an account,
+	// unless the key was already recorded after since. It returns the
+	// execution ID recorded for the key.
+	ClaimIdempotencyKey(accountID, key, executionID string, since time.Time) (string, error)
+
+	// ReleaseIdempotencyKey forgets the key of an account if it still
+	// records executionID, so that a retry starts a new execution
+	ReleaseIdempotencyKey(accountID, key, executionID string) error
+}
+
+// ExecutionAccountStore is implemented by execution stores that record the
+// account an execution runs for
+type ExecutionAccountStore interface {
+	// GetExecutionAccountID returns the account recorded for an execution
+	GetExecutionAccountID(executionID string) (string, error)
+}
+
+// ExecutionClaimer is implemented by execution stores that can record which
+// process runs an execution, so that of several processes sharing the store
+// only one resumes an interrupted execution
+type ExecutionClaimer interface {
+	// ClaimExecution makes owner the owner of an execution until the given
+	// time, unless another owner holds a lease that has not expired. It
+	// reports whether owner holds the lease now. Owners renew their lease by
+	// claiming it again.
+	ClaimExecution(executionID, owner string, until time.Time) (bool, error)
+
+	// ReleaseExecution ends the lease of owner on an execution, if it holds
+	// one
+	ReleaseExecution(executionID, owner string) error
+}
+
 // flowRuntime is the implementation of the FlowRuntime interface
 type flowRuntime struct {
 	registry       FlowRegistry
@@ -30,6 +116,31 @@ type flowRuntime struct {
 	// In-memory tracking for active executions
 	activeExecutions map[string]*executionContext
 	mu               sync.RWMutex
+
+	// scheduler bounds how many executions run at once
+	scheduler *scheduler
+
+	// queue hands new executions to worker processes instead of running
+	// them in this process
+	queue WorkQueue
+
+	// visitLimits bound the executions of flows that declare no limits
+	visitLimits flowlib.VisitLimits
+
+	// signalLinks sign the links that resume waiting executions
+	signalLinks SignalLinks
+
+	// startOnce guards the background work begun by Start
+	startOnce sync.Once
+
+	// owner identifies this process in the execution leases of an
+	// ExecutionClaimer store; leaseOnce creates it and starts renewing them
+	owner     string
+	leaseOnce sync.Once
+
+	// idempotencyWarning reports once that the execution store ignores
+	// idempotency keys
+	idempotencyWarning sync.Once
 }
 
 // executionContext tracks the context of a running execution
@@ -38,9 +149,40 @@ type executionContext struct {
 	flowID      string
 	status      ExecutionStatus
 	cancel      context.CancelFunc
+	settings    flowSettings
 	logChannel  chan ExecutionLog
 	subscribers []chan ExecutionLog
 	mu          sync.RWMutex
+
+	// saveMu orders the saves of status snapshots taken under mu, so that
+	// an older progress cannot overwrite a final status in the store
+	saveMu sync.Mutex
+
+	// admitted is set once the scheduler gave the execution a slot, which
+	// is released when the execution finishes
+	admitted bool
+
+	// done is closed once the execution goroutine ends
+	done chan struct{}
+
+	// timeoutBase is when the flow timeout started counting: the start of
+	// the execution, or when it last resumed after waiting
+	timeoutBase time.Time
+
+	// completedNodes are the nodes that finished at least once, from which
+	// the progress is estimated
+	completedNodes map[flowlib.Node]bool
+
+	// nodeVisits counts the visits of every node ID for the trace
+	nodeVisits map[string]int
+
+	// sequence is the number of node visits started in the execution,
+	// including the nodes nested in inline sub-flows, loop bodies and split
+	// branches. It numbers the trace and the debug pauses.
+	sequence int
+
+	// debugger is set on debug executions, which pause at breakpoints
+	debugger *debugSession
 }
 
 // NewFlowRuntime creates a new FlowRuntime
@@ -49,17 +191,22 @@ func NewFlowRuntime(registry FlowRegistry, yamlLoader loader.YAMLLoader) FlowRun
 		registry:         registry,
 		yamlLoader:       yamlLoader,
 		activeExecutions: make(map[string]*executionContext),
+		scheduler:        newScheduler(),
 	}
 }
 
-// NewFlowRuntimeWithStore creates a new FlowRuntime with execution store
+// NewFlowRuntimeWithStore creates a new FlowRuntime with execution store.
+// Once started, executions interrupted by a previous shutdown are resumed from their
+// last checkpoint, and waiting executions whose deadline passed follow their expired action.
 func NewFlowRuntimeWithStore(registry FlowRegistry, yamlLoader loader.YAMLLoader, executionStore ExecutionStore) FlowRuntime {
-	return &flowRuntime{
+	r := &flowRuntime{
 		registry:         registry,
 		yamlLoader:       yamlLoader,
 		executionStore:   executionStore,
 		activeExecutions: make(map[string]*executionContext),
+		scheduler:        newScheduler(),
 	}
+	return r
 }
 
 // NewFlowRuntimeWithSecrets creates a new FlowRuntime with secret vault support
@@ -69,98 +216,405 @@ func NewFlowRuntimeWithSecrets(registry FlowRegistry, yamlLoader loader.YAMLLoad
 		yamlLoader:       yamlLoader,
 		secretVault:      secretVault,
 		activeExecutions: make(map[string]*executionContext),
+		scheduler:        newScheduler(),
 	}
 }
 
-// NewFlowRuntimeWithStoreAndSecrets creates a new FlowRuntime with execution store and secret vault
+// NewFlowRuntimeWithStoreAndSecrets creates a new FlowRuntime with execution store and secret vault.
+// Once started, executions interrupted by a previous shutdown are resumed from their
+// last checkpoint, and waiting executions whose deadline passed follow their expired action.
 func NewFlowRuntimeWithStoreAndSecrets(registry FlowRegistry, yamlLoader loader.YAMLLoader, executionStore ExecutionStore, secretVault auth.SecretVault) FlowRuntime {
-	return &flowRuntime{
+	r := &flowRuntime{
 		registry:         registry,
 		yamlLoader:       yamlLoader,
 		executionStore:   executionStore,
 		secretVault:      secretVault,
 		activeExecutions: make(map[string]*executionContext),
+		scheduler:        newScheduler(),
 	}
+	return r
+}
+
+// Start implements Starter
+func (r *flowRuntime) Start() {
+	r.startOnce.Do(func() {
+		if r.queue == nil {
+			// Workers resume the executions of a work queue
+			r.resumeInterruptedExecutions()
+			if _, ok := r.executionStore.(ExecutionClaimer); ok {
+				go r.resumeExpiredLeases()
+			}
+		}
+		r.startWaitExpiry(context.Background())
+	})
 }
 
 func (r *flowRuntime) Execute(accountID string, flowID string, input map[string]interface{}) (string, error) {
-	flowDef, err := r.registry.GetFlow(accountID, flowID)
+	return r.ExecuteWithOptions(accountID, flowID, input, ExecuteOptions{})
+}
+
+// ExecuteWithOptions implements OptionsExecutor
+func (r *flowRuntime) ExecuteWithOptions(accountID string, flowID string, input map[string]interface{}, options ExecuteOptions) (string, error) {
+	flow, settings, err := r.loadFlow(accountID, flowID, "")
+	if err != nil {
+		return "", err
+	}
+	if err := validateInput(settings, input); err != nil {
+		return "", err
+	}
+	if err := settings.checkEnvironment(options.Environment); err != nil {
+		return "", err
+	}
+
+	var metadata map[string]string
+	if options.DryRun || options.Environment != "" {
+		metadata = make(map[string]string)
+	}
+	if options.DryRun {
+		metadata[DryRunKey] = "true"
+	}
+	if options.Environment != "" {
+		metadata[EnvironmentKey] = options.Environment
+	}
+	status := newExecutionStatus(flowID, "running", metadata)
+	if options.IdempotencyKey != "" {
+		executionID, err := r.claimIdempotencyKey(accountID, status.ID, options)
+		if err != nil {
+			return "", err
+		}
+		if executionID != status.ID {
+			// A previous request with the same key started the execution
+			return executionID, nil
+		}
+	}
+
+	if r.queue != nil && !options.Debug {
+		// Workers load the flow again, loading it here rejects broken flows early
+		status.Status = "queued"
+		executionID, err := r.enqueue(accountID, status, input)
+		if err != nil && options.IdempotencyKey != "" {
+			// The execution never ran, a retry with the key may start it
+			r.releaseIdempotencyKey(accountID, status.ID, options)
+		}
+		return executionID, err
+	}
+
+	// Debug executions run in this process, which receives their commands
+	ctx, execCtx, err := r.startExecution(context.Background(), accountID, settings, status)
+	if err != nil {
+		if options.IdempotencyKey != "" {
+			// The key must not record an execution that does not exist
+			r.releaseIdempotencyKey(accountID, status.ID, options)
+		}
+		return "", err
+	}
+	if options.Debug {
+		execCtx.debugger = newDebugSession(options.Breakpoints)
+	}
+
+	// Start execution in goroutine once the concurrency limits allow it
+	r.schedule(execCtx, flow, input, func() {
+		r.executeFlow(ctx, execCtx, flow, input)
+	})
+
+	return execCtx.status.ID, nil
+}
+
+// loadFlow retrieves and parses a flow definition together with its execution
+// settings. An empty version selects the current definition; pinning a version
+// requires a VersionedFlowRegistry.
+func (r *flowRuntime) loadFlow(accountID, flowID, version string) (*flowlib.Flow, flowSettings, error) {
+	var flowDef *Flow
+	var err error
+	if version == "" {
+		flowDef, err = r.registry.GetFlow(accountID, flowID)
+	} else if versioned, ok := r.registry.(VersionedFlowRegistry); ok {
+		flowDef, err = versioned.GetFlowVersion(accountID, flowID, version)
+	} else {
+		return nil, flowSettings{}, fmt.Errorf("flow registry does not support versioned lookups")
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to get flow: %w", err)
+		return nil, flowSettings{}, fmt.Errorf("failed to get flow: %w", err)
 	}
 
 	flow, err := r.yamlLoader.Parse(flowDef.YAML)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse flow YAML: %w", err)
+		return nil, flowSettings{}, fmt.Errorf("failed to parse flow YAML: %w", err)
+	}
+
+	settings, err := parseFlowSettings(flowDef.YAML)
+	if err != nil {
+		return nil, flowSettings{}, fmt.Errorf("failed to parse flow settings: %w", err)
+	}
+	return flow, settings, nil
+}
+
+// startExecution persists the initial status of a new execution and
+// registers it. An execution that cannot be saved is not started. The
+// returned context is derived from parent, so canceling the parent also
+// cancels the execution.
+func (r *flowRuntime) startExecution(parent context.Context, accountID string, settings flowSettings, status ExecutionStatus) (context.Context, *executionContext, error) {
+	if err := r.saveNewExecution(status, accountID); err != nil {
+		return nil, nil, err
+	}
+	ctx, execCtx := r.trackExecution(parent, accountID, settings, status)
+
+	return withExecutionScope(ctx, r, execCtx), execCtx, nil
+}
+
+// newExecutionStatus returns the initial status of a new execution
+func newExecutionStatus(flowID, status string, metadata map[string]string) ExecutionStatus {
+	return ExecutionStatus{
+		ID:        uuid.New().String(),
+		FlowID:    flowID,
+		Status:    status,
+		StartTime: time.Now(),
+		Progress:  0.0,
+		Results:   make(map[string]interface{}),
+		Metadata:  metadata,
+	}
+}
+
+// claimIdempotencyKey records executionID under the idempotency key of the
+// options and returns the execution recorded for the key. Without an
+// IdempotencyStore the key is ignored and executionID returned.
+func (r *flowRuntime) claimIdempotencyKey(accountID, executionID string, options ExecuteOptions) (string, error) {
+	store, ok := r.executionStore.(IdempotencyStore)
+	if !ok {
+		r.idempotencyWarning.Do(func() {
+			fmt.Println("Execution store does not support idempotency keys, ignoring them")
+		})
+		return executionID, nil
+	}
+
+	window := options.IdempotencyWindow
+	if window <= 0 {
+		window = DefaultIdempotencyWindow
+	}
+	recordedID, err := store.ClaimIdempotencyKey(accountID, options.IdempotencyKey, executionID, time.Now().Add(-window))
+	if err != nil {
+		return "", fmt.Errorf("failed to claim idempotency key: %w", err)
 	}
+	return recordedID, nil
+}
 
-	executionID := uuid.New().String()
+// releaseIdempotencyKey forgets the idempotency key of the options if it
+// still records executionID
+func (r *flowRuntime) releaseIdempotencyKey(accountID, executionID string, options ExecuteOptions) {
+	store, ok := r.executionStore.(IdempotencyStore)
+	if !ok {
+		return
+	}
+	if err := store.ReleaseIdempotencyKey(accountID, options.IdempotencyKey, executionID); err != nil {
+		r.logExecution(executionID, "error", "Failed to release idempotency key", map[string]interface{}{"error": err.Error()})
+	}
+}
 
-	// Create execution context
-	ctx, cancel := context.WithCancel(context.Background())
+// trackExecution registers an execution with the given status as active in
+// this process. The returned context is canceled when the execution is,
+// carries its node visit limits and simulates side effects if the execution
+// is a dry run.
+func (r *flowRuntime) trackExecution(parent context.Context, accountID string, settings flowSettings, status ExecutionStatus) (context.Context, *executionContext) {
+	ctx, cancel := context.WithCancel(parent)
 	execCtx := &executionContext{
 		accountID:   accountID,
-		flowID:      flowID,
+		flowID:      status.FlowID,
 		cancel:      cancel,
+		settings:    settings,
 		logChannel:  make(chan ExecutionLog, 100),
 		subscribers: make([]chan ExecutionLog, 0),
-		status: ExecutionStatus{
-			ID:        executionID,
-			FlowID:    flowID,
-			Status:    "running",
-			StartTime: time.Now(),
-			Progress:  0.0,
-			Results:   make(map[string]interface{}),
-		},
+		status:      status,
+		done:        make(chan struct{}),
+		timeoutBase: timeoutBase(status),
+
+		completedNodes: make(map[flowlib.Node]bool),
+		nodeVisits:     make(map[string]int),
+	}
+	ctx = flowlib.WithVisitLimits(ctx, r.resolveVisitLimits(settings))
+	if isDryRun(status) {
+		ctx = withDryRun(ctx, settings.dryRunOutputs)
+	}
+
+	// Other processes sharing the store leave the execution to this one
+	if claimed, err := r.claimExecution(status.ID); err != nil {
+		fmt.Printf("Failed to claim execution %s: %v\n", status.ID, err)
+	} else if !claimed {
+		fmt.Printf("Execution %s is owned by another process\n", status.ID)
 	}
 
 	// Store in active executions
 	r.mu.Lock()
-	r.activeExecutions[executionID] = execCtx
+	r.activeExecutions[status.ID] = execCtx
 	r.mu.Unlock()
 
-	// Save initial execution status to store if available
-	if r.executionStore != nil {
-		if err := r.executionStore.SaveExecution(execCtx.status); err != nil {
-			r.logExecution(executionID, "error", "Failed to save execution status", map[string]interface{}{"error": err.Error()})
+	return ctx, execCtx
+}
+
+// saveNewExecution persists the initial status of an execution together with
+// the account that owns it
+func (r *flowRuntime) saveNewExecution(status ExecutionStatus, accountID string) error {
+	if r.executionStore == nil {
+		return nil
+	}
+	if err := r.executionStore.SaveExecution(status); err != nil {
+		return fmt.Errorf("failed to save execution: %w", err)
+	}
+
+	// If the execution store supports setting account ID (PostgreSQL, DynamoDB, etc.), set it
+	if store, ok := r.executionStore.(interface{ SetExecutionAccountID(string, string) error }); ok {
+		if err := store.SetExecutionAccountID(status.ID, accountID); err != nil {
+			return fmt.Errorf("failed to set execution account ID: %w", err)
 		}
+	}
+	return nil
+}
 
-		// If the execution store supports setting account ID (PostgreSQL, DynamoDB, etc.), set it
-		if store, ok := r.executionStore.(interface{ SetExecutionAccountID(string, string) error }); ok {
-			if err := store.SetExecutionAccountID(executionID, accountID); err != nil {
-				r.logExecution(executionID, "error", "Failed to set execution account ID", map[string]interface{}{"error": err.Error()})
-			}
+func (r *flowRuntime) executeFlow(ctx context.Context, execCtx *executionContext, flow interface{}, input map[string]interface{}) {
+	defer r.finishExecution(execCtx)
+
+	ctx, cancel := withFlowTimeout(ctx, execCtx)
+	defer cancel()
+
+	r.logExecution(execCtx.status.ID, "info", "Starting flow execution", map[string]interface{}{"flowID": execCtx.flowID, "accountID": execCtx.accountID})
+
+	enhancedInput := r.newSharedState(execCtx, input)
+
+	// Execute the flow
+	var result interface{}
+	var err error
+
+	// Check if flow supports context-aware execution
+	if flowWithCtx, ok := flow.(interface {
+		RunWithContext(ctx context.Context, shared interface{}) (interface{}, error)
+	}); ok {
+		result, err = flowWithCtx.RunWithContext(ctx, enhancedInput)
+	} else if flowRunner, ok := flow.(interface {
+		Run(shared interface{}) (interface{}, error)
+	}); ok {
+		result, err = flowRunner.Run(enhancedInput)
+	} else if flowlibFlow, ok := flow.(*flowlib.Flow); ok {
+		// Walk the graph ourselves so every node boundary is checkpointed
+		var action flowlib.Action
+		action, err = r.runFlowGraph(ctx, execCtx, flowlibFlow.Start(), enhancedInput, 0)
+		if err == nil {
+			result = flowResult(action, enhancedInput)
+		} else {
+			r.handleFailure(ctx, execCtx, flowlibFlow, enhancedInput, err)
+		}
+	} else if flowlibFlow, ok := flow.(interface {
+		RunWithContext(ctx context.Context, shared any) (string, error)
+	}); ok {
+		// Handle other context-aware flowlib-style flows
+		var action string
+		action, err = flowlibFlow.RunWithContext(ctx, enhancedInput)
+		if err == nil {
+			result = flowResult(action, enhancedInput)
+		}
+	} else if flowlibFlow, ok := flow.(interface {
+		Run(shared any) (string, error)
+	}); ok {
+		// Handle other flowlib-style flows which return (Action, error)
+		var action string
+		action, err = flowlibFlow.Run(enhancedInput)
+		if err == nil {
+			result = flowResult(action, enhancedInput)
 		}
+	} else {
+		err = fmt.Errorf("flow does not implement expected execution interface")
 	}
 
-	// Start execution in goroutine
-	go r.executeFlow(ctx, execCtx, flow, input)
+	r.completeExecution(execCtx, result, err)
+}
+
+// finishExecution releases the resources of an execution once its goroutine ends.
+// It must be deferred so that panics raised by nodes are recovered.
+func (r *flowRuntime) finishExecution(execCtx *executionContext) {
+	if rec := recover(); rec != nil {
+		r.logExecution(execCtx.status.ID, "error", "Flow execution panicked", map[string]interface{}{"panic": rec})
+		r.updateExecutionStatus(execCtx.status.ID, "failed", fmt.Sprintf("Flow execution panicked: %v", rec), nil)
+	}
 
-	return executionID, nil
+	// Close log channel when execution is done
+	close(execCtx.logChannel)
+
+	// Keep completed executions in-memory if no persistent store is configured,
+	// so that status/logs remain queryable right after completion.
+	// If a persistent execution store is present, we can safely remove it.
+	if r.executionStore != nil {
+		r.mu.Lock()
+		// A signaled execution may already run again under the same ID
+		current := r.activeExecutions[execCtx.status.ID] == execCtx
+		if current {
+			delete(r.activeExecutions, execCtx.status.ID)
+		}
+		r.mu.Unlock()
+		if current {
+			r.releaseExecution(execCtx.status.ID)
+		}
+	}
+
+	// Hand the slot of the execution to the next queued one
+	execCtx.mu.RLock()
+	admitted := execCtx.admitted
+	execCtx.mu.RUnlock()
+	if admitted {
+		r.scheduler.release(execCtx.accountID, execCtx.flowID)
+	}
+
+	// Wake up callers waiting for the execution to finish
+	close(execCtx.done)
 }
 
-func (r *flowRuntime) executeFlow(ctx context.Context, execCtx *executionContext, flow interface{}, input map[string]interface{}) {
-    defer func() {
-		if rec := recover(); rec != nil {
-			r.logExecution(execCtx.status.ID, "error", "Flow execution panicked", map[string]interface{}{"panic": rec})
-			r.updateExecutionStatus(execCtx.status.ID, "failed", fmt.Sprintf("Flow execution panicked: %v", rec), nil)
-		}
-
-		// Close log channel when execution is done
-		close(execCtx.logChannel)
-
-        // Keep completed executions in-memory if no persistent store is configured,
-        // so that status/logs remain queryable right after completion.
-        // If a persistent execution store is present, we can safely remove it.
-        if r.executionStore != nil {
-            r.mu.Lock()
-            delete(r.activeExecutions, execCtx.status.ID)
-            r.mu.Unlock()
-        }
-	}()
+// completeExecution records the final status of an execution
+func (r *flowRuntime) completeExecution(execCtx *executionContext, result interface{}, err error) {
+	if errors.Is(err, ErrExecutionWaiting) {
+		// The execution is parked and resumes once it is signaled
+		return
+	}
+	execCtx.mu.RLock()
+	canceled := execCtx.status.Status == "canceled"
+	execCtx.mu.RUnlock()
+	if canceled || errors.Is(err, context.Canceled) {
+		// Cancel already recorded the final status, which the outcome of
+		// nodes that ignored the cancellation does not replace
+		r.logExecution(execCtx.status.ID, "info", "Flow execution stopped after cancellation", nil)
+		return
+	}
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		r.logExecution(execCtx.status.ID, "error", "Flow execution timed out", map[string]interface{}{"error": err.Error(), "node_id": timeoutErr.NodeID})
+		r.updateExecutionStatus(execCtx.status.ID, "timeout", err.Error(), nil)
+		return
+	}
+	if err != nil {
+		r.logExecution(execCtx.status.ID, "error", "Flow execution failed", map[string]interface{}{"error": err.Error()})
+		r.updateExecutionStatus(execCtx.status.ID, "failed", err.Error(), nil)
+		return
+	}
 
-	r.logExecution(execCtx.status.ID, "info", "Starting flow execution", map[string]interface{}{"flowID": execCtx.flowID, "accountID": execCtx.accountID})
+	// Convert result to map if possible
+	var resultMap map[string]interface{}
+	if result != nil {
+		if rm, ok := result.(map[string]interface{}); ok {
+			resultMap = rm
+		} else {
+			resultMap = map[string]interface{}{"result": result}
+		}
+	}
 
+	if err := validateResults(execCtx.settings, resultMap); err != nil {
+		// The results stay visible next to the error for debugging
+		r.logExecution(execCtx.status.ID, "error", "Flow execution failed", map[string]interface{}{"error": err.Error()})
+		r.updateExecutionStatus(execCtx.status.ID, "failed", err.Error(), resultMap)
+		return
+	}
+
+	r.logExecution(execCtx.status.ID, "info", "Flow execution completed successfully", map[string]interface{}{"result": result})
+	r.updateExecutionStatus(execCtx.status.ID, "completed", "", resultMap)
+}
+
+// newSharedState builds the shared state handed to the nodes of an execution
+func (r *flowRuntime) newSharedState(execCtx *executionContext, input map[string]interface{}) map[string]interface{} {
 	// Create FlowContext for proper expression evaluation
 	var flowContext *FlowContext
 	if r.secretVault != nil {
@@ -176,7 +630,7 @@ func (r *flowRuntime) executeFlow(ctx context.Context, execCtx *executionContext
 			flowContext.SetSharedData(k, v)
 		}
 	}
-	
+
 	// Add execution context for logging and debugging
 	enhancedInput["_execution"] = map[string]interface{}{
 		"execution_id": execCtx.status.ID,
@@ -192,81 +646,164 @@ func (r *flowRuntime) executeFlow(ctx context.Context, execCtx *executionContext
 			"shared_data":  flowContext.sharedData,
 		}
 		enhancedInput["accountID"] = execCtx.accountID
-		enhancedInput["_secret_vault"] = r.secretVault  // Add secret vault for NodeWrapper access
+		enhancedInput["_secret_vault"] = r.secretVault // Add secret vault for NodeWrapper access
 	}
 
-	// Execute the flow
-	var result interface{}
-	var err error
+	// Templates read the flow vars with or without a secret vault
+	enhancedInput[varsKey] = execCtx.settings.resolveVars(executionEnvironment(execCtx))
 
-	// Check if flow supports context-aware execution
-	if flowWithCtx, ok := flow.(interface {
-		RunWithContext(ctx context.Context, shared interface{}) (interface{}, error)
-	}); ok {
-		result, err = flowWithCtx.RunWithContext(ctx, enhancedInput)
-	} else if flowRunner, ok := flow.(interface {
-		Run(shared interface{}) (interface{}, error)
-	}); ok {
-		result, err = flowRunner.Run(enhancedInput)
-    } else if flowlibFlow, ok := flow.(interface {
-		Run(shared any) (string, error)
-	}); ok {
-		// Handle flowlib.Flow which returns (Action, error)
-        var action string
-        action, err = flowlibFlow.Run(enhancedInput)
-        if err == nil {
-            // Prefer rich result from shared context if available
-            if sharedResult, ok := enhancedInput["result"]; ok {
-                // Ensure it's a map; if not, wrap
-                if rm, ok := sharedResult.(map[string]interface{}); ok {
-                    // Attach action metadata as well
-                    rmCopy := make(map[string]interface{}, len(rm)+1)
-                    for k, v := range rm {
-                        rmCopy[k] = v
-                    }
-                    rmCopy["action"] = action
-                    result = rmCopy
-                } else {
-                    result = map[string]interface{}{
-                        "action": action,
-                        "result": sharedResult,
-                    }
-                }
-            } else {
-                result = map[string]interface{}{"action": action}
-            }
-        }
-	} else {
-		err = fmt.Errorf("flow does not implement expected execution interface")
+	return enhancedInput
+}
+
+// runFlowGraph runs the flow node by node starting at start, following the
+// same successor rules as flowlib.Flow. A checkpoint is saved before every
+// node; step is the number of nodes that already ran before start. Like
+// flowlib.Flow, it fails once the execution exceeds the visit limits in ctx.
+func (r *flowRuntime) runFlowGraph(ctx context.Context, execCtx *executionContext, start flowlib.Node, shared map[string]interface{}, step int) (flowlib.Action, error) {
+	visits := flowlib.NewVisitCounter(flowlib.VisitLimitsFrom(ctx), step)
+	var last flowlib.Action
+	curr := start
+	for curr != nil {
+		// Stop between nodes once the execution is canceled or out of time
+		if err := ctx.Err(); err != nil {
+			if err == context.DeadlineExceeded {
+				return last, &TimeoutError{Timeout: execCtx.settings.timeout}
+			}
+			return last, err
+		}
+		if err := visits.Visit(curr); err != nil {
+			return last, err
+		}
+		r.saveCheckpoint(execCtx, step, curr, shared)
+		r.startNode(execCtx, curr)
+		var err error
+		if last, err = r.visitNode(ctx, execCtx, curr, shared); err != nil {
+			return last, err
+		}
+		step++
+		next := nextNode(curr, last)
+		r.completeNode(execCtx, curr, next)
+		curr = next
 	}
+	return last, nil
+}
+
+// runNestedGraph runs the nodes nested in a node of an execution, such as an
+// inline sub-flow, a loop body or a split branch, from start until they end
+// or reach stop. They take the same path as the nodes of the execution but
+// are not checkpointed, so a resumed execution runs the node they are nested
+// in again. Like flowlib.Flow, it fails once the nodes exceed the visit
+// limits in ctx.
+func (r *flowRuntime) runNestedGraph(ctx context.Context, execCtx *executionContext, start, stop flowlib.Node, shared map[string]interface{}) (flowlib.Action, error) {
+	visits := flowlib.NewVisitCounter(flowlib.VisitLimitsFrom(ctx), 0)
+	var last flowlib.Action
+	for curr := start; curr != nil && curr != stop; curr = nextNode(curr, last) {
+		if err := ctx.Err(); err != nil {
+			return last, err
+		}
+		if err := visits.Visit(curr); err != nil {
+			return last, err
+		}
+		r.startNode(execCtx, curr)
+		var err error
+		if last, err = r.visitNode(ctx, execCtx, curr, shared); err != nil {
+			return last, err
+		}
+	}
+	return last, nil
+}
 
+// visitNode runs a node of an execution and returns the action to follow. It
+// pauses at breakpoints, bounds the node by its timeout, follows the timeout
+// and error actions of the node, traces the visit and records the completion
+// of the node and of the nodes nested in it for compensation.
+func (r *flowRuntime) visitNode(ctx context.Context, execCtx *executionContext, node flowlib.Node, shared map[string]interface{}) (flowlib.Action, error) {
+	sequence := nextSequence(execCtx)
+	if err := r.pauseAtBreakpoint(ctx, execCtx, node, sequence-1, shared); err != nil {
+		return "", err
+	}
+
+	nodeCtx, attempts := r.countAttempts(ctx, execCtx, node)
+	nodeCtx, recorder := withNodeTrace(nodeCtx)
+	nodeCtx, nested := withNestedCompletions(nodeCtx)
+	started := time.Now()
+	action, err := r.runNode(nodeCtx, execCtx, node, shared)
+	if errors.Is(err, ErrExecutionWaiting) {
+		// The node parked the execution, which continues after the node
+		// once it is signaled
+		return action, err
+	}
+	// Nested nodes that completed are compensated even if the node failed
+	nested.record(shared)
+	nodeErr := err
+	completed := err == nil
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) && timeoutErr.NodeID != "" && node.Successors()[TimeoutAction] != nil {
+		r.logExecution(execCtx.status.ID, "warning", "Node timed out, following timeout action", map[string]interface{}{"node_id": timeoutErr.NodeID, "timeout": timeoutErr.Timeout.String()})
+		action, err = TimeoutAction, nil
+	}
+	if err != nil && ctx.Err() == nil {
+		err = &NodeError{NodeID: nodeIDOf(node), Attempts: attempts(), Err: err}
+		if node.Successors()[ErrorAction] != nil {
+			// Hand the failure to the error handler instead of failing the execution
+			shared[ErrorStateKey] = errorState(err)
+			r.logExecution(execCtx.status.ID, "warning", "Node failed, following error action", shared[ErrorStateKey].(map[string]interface{}))
+			action, err = ErrorAction, nil
+		}
+	}
+	r.traceNode(execCtx, node, sequence, recorder, started, action, attempts(), nodeErr)
 	if err != nil {
-		r.logExecution(execCtx.status.ID, "error", "Flow execution failed", map[string]interface{}{"error": err.Error()})
-		r.updateExecutionStatus(execCtx.status.ID, "failed", err.Error(), nil)
-		return
+		return action, err
+	}
+	if completed {
+		recordCompletion(node, shared)
 	}
+	return action, nil
+}
 
-	// Convert result to map if possible
-	var resultMap map[string]interface{}
-	if result != nil {
-		if rm, ok := result.(map[string]interface{}); ok {
-			resultMap = rm
-		} else {
-			resultMap = map[string]interface{}{"result": result}
+// nextNode returns the successor of curr for the given action
+func nextNode(curr flowlib.Node, action flowlib.Action) flowlib.Node {
+	if action == "" {
+		action = flowlib.DefaultAction
+	}
+	next := curr.Successors()[action]
+	if next == nil && len(curr.Successors()) > 0 {
+		fmt.Printf("Flow ends: action '%s' not found (%v)\n", action, curr.Successors())
+	}
+	return next
+}
+
+// flowResult builds the execution result from the final action and shared state
+func flowResult(action flowlib.Action, shared map[string]interface{}) map[string]interface{} {
+	// Prefer rich result from shared context if available
+	sharedResult, ok := shared["result"]
+	if !ok {
+		return map[string]interface{}{"action": action}
+	}
+
+	// Ensure it's a map; if not, wrap
+	rm, ok := sharedResult.(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{
+			"action": action,
+			"result": sharedResult,
 		}
 	}
 
-	r.logExecution(execCtx.status.ID, "info", "Flow execution completed successfully", map[string]interface{}{"result": result})
-	r.updateExecutionStatus(execCtx.status.ID, "completed", "", resultMap)
+	// Attach action metadata as well
+	rmCopy := make(map[string]interface{}, len(rm)+1)
+	for k, v := range rm {
+		rmCopy[k] = v
+	}
+	rmCopy["action"] = action
+	return rmCopy
 }
 
 func (r *flowRuntime) GetStatus(executionID string) (ExecutionStatus, error) {
 	// First check active executions
 	r.mu.RLock()
 	if execCtx, ok := r.activeExecutions[executionID]; ok {
-		execCtx.mu.RLock()
-		status := execCtx.status
-		execCtx.mu.RUnlock()
+		status := r.activeStatus(execCtx)
 		r.mu.RUnlock()
 		return status, nil
 	}
@@ -280,6 +817,36 @@ func (r *flowRuntime) GetStatus(executionID string) (ExecutionStatus, error) {
 	return ExecutionStatus{}, fmt.Errorf("execution not found: %s", executionID)
 }
 
+// Wait implements ExecutionWaiter
+func (r *flowRuntime) Wait(ctx context.Context, executionID string) (ExecutionStatus, error) {
+	for {
+		r.mu.RLock()
+		execCtx, active := r.activeExecutions[executionID]
+		r.mu.RUnlock()
+
+		if active {
+			select {
+			case <-execCtx.done:
+			case <-ctx.Done():
+				status, _ := r.GetStatus(executionID)
+				return status, ctx.Err()
+			}
+		}
+
+		// Parked executions, such as those waiting for an approval, and
+		// executions running in other processes are polled until they finish
+		status, err := r.GetStatus(executionID)
+		if err != nil || isFinished(status.Status) {
+			return status, err
+		}
+		select {
+		case <-time.After(waitPollInterval):
+		case <-ctx.Done():
+			return status, ctx.Err()
+		}
+	}
+}
+
 func (r *flowRuntime) GetLogs(executionID string) ([]ExecutionLog, error) {
 	// If execution store is available, get logs from there
 	if r.executionStore != nil {
@@ -326,15 +893,31 @@ func (r *flowRuntime) Cancel(executionID string) error {
 	r.mu.RUnlock()
 
 	if !ok {
+		if canceled, err := r.cancelWaiting(executionID); canceled || err != nil {
+			return err
+		}
+		if r.queue != nil {
+			return r.cancelDistributed(executionID)
+		}
 		return fmt.Errorf("execution not found or not active: %s", executionID)
 	}
 
-	// Cancel the execution context
-	execCtx.cancel()
+	// A queued execution never started, so nothing else will finish it
+	if r.scheduler.remove(executionID) {
+		r.updateExecutionStatus(executionID, "canceled", "Execution was canceled by user", nil)
+		execCtx.cancel()
+		r.logExecution(executionID, "info", "Queued execution canceled by user", nil)
+		r.finishExecution(execCtx)
+		return nil
+	}
 
-	// Update status
+	// Update status first so the execution goroutine, which stops as soon as
+	// the context is canceled, cannot report the interruption as a failure
 	r.updateExecutionStatus(executionID, "canceled", "Execution was canceled by user", nil)
 
+	// Cancel the execution context
+	execCtx.cancel()
+
 	r.logExecution(executionID, "info", "Execution canceled by user", nil)
 
 	return nil
@@ -364,7 +947,11 @@ func (r *flowRuntime) logExecution(executionID, level, message string, data map[
 		}
 	}
 
-	// Send to active subscribers
+	r.notifySubscribers(executionID, log)
+}
+
+// notifySubscribers sends a log entry to the subscribers of an active execution
+func (r *flowRuntime) notifySubscribers(executionID string, log ExecutionLog) {
 	r.mu.RLock()
 	if execCtx, ok := r.activeExecutions[executionID]; ok {
 		execCtx.mu.RLock()
@@ -389,7 +976,15 @@ func (r *flowRuntime) updateExecutionStatus(executionID, status, errorMsg string
 		return
 	}
 
+	execCtx.saveMu.Lock()
+	defer execCtx.saveMu.Unlock()
+
 	execCtx.mu.Lock()
+	if execCtx.status.Status == "canceled" {
+		// Cancellation is final, even if the flow finished meanwhile
+		execCtx.mu.Unlock()
+		return
+	}
 	execCtx.status.Status = status
 	if errorMsg != "" {
 		execCtx.status.Error = errorMsg
@@ -397,13 +992,19 @@ func (r *flowRuntime) updateExecutionStatus(executionID, status, errorMsg string
 	if results != nil {
 		execCtx.status.Results = results
 	}
-	if status == "completed" || status == "failed" || status == "canceled" {
+	if isFinished(status) {
 		execCtx.status.EndTime = time.Now()
 		execCtx.status.Progress = 100.0
+		execCtx.status.QueuePosition = 0
+	}
+	if status == "completed" {
+		execCtx.status.CurrentNode = ""
 	}
 	status_copy := execCtx.status
 	execCtx.mu.Unlock()
 
+	r.publishStatus(status_copy)
+
 	// Save to execution store if available
 	if r.executionStore != nil {
 		if err := r.executionStore.SaveExecution(status_copy); err != nil {
@@ -420,9 +1021,7 @@ func (r *flowRuntime) ListExecutions(accountID string) ([]ExecutionStatus, error
 	r.mu.RLock()
 	for _, execCtx := range r.activeExecutions {
 		if execCtx.accountID == accountID {
-			execCtx.mu.RLock()
-			executions = append(executions, execCtx.status)
-			execCtx.mu.RUnlock()
+			executions = append(executions, r.activeStatus(execCtx))
 		}
 	}
 	r.mu.RUnlock()
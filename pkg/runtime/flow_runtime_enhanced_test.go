@@ -1,11 +1,15 @@
 package runtime
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/tcmartin/flowlib"
 )
 
@@ -130,6 +134,7 @@ func TestEnhancedFlowRuntime_Execute(t *testing.T) {
 
 	// Create a mock node
 	mockNode := new(MockEnhancedNode)
+	mockNode.On("Params").Return(map[string]interface{}{"node_id": "start"})
 	mockNode.On("Run", mock.Anything).Return(flowlib.DefaultAction, nil)
 	mockNode.On("Successors").Return(map[flowlib.Action]flowlib.Node{})
 
@@ -179,6 +184,7 @@ func TestEnhancedFlowRuntime_GetStatus(t *testing.T) {
 	}
 
 	mockNode := new(MockEnhancedNode)
+	mockNode.On("Params").Return(map[string]interface{}{"node_id": "start"})
 	mockNode.On("Run", mock.Anything).Return(flowlib.DefaultAction, nil)
 	mockNode.On("Successors").Return(map[flowlib.Action]flowlib.Node{})
 
@@ -298,6 +304,7 @@ func TestEnhancedFlowRuntime_Cancel(t *testing.T) {
 
 	// Create a mock node that runs longer
 	mockNode := new(MockEnhancedNode)
+	mockNode.On("Params").Return(map[string]interface{}{"node_id": "start"})
 	mockNode.On("Run", mock.Anything).After(500*time.Millisecond).Return(flowlib.DefaultAction, nil)
 	mockNode.On("Successors").Return(map[flowlib.Action]flowlib.Node{})
 
@@ -342,6 +349,92 @@ func TestEnhancedFlowRuntime_Cancel(t *testing.T) {
 	mockYAMLLoader.AssertExpectations(t)
 }
 
+func TestEnhancedFlowRuntime_CancelStopsRunningNodes(t *testing.T) {
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+
+	flowDef := &Flow{ID: "slow-flow", YAML: "slow"}
+
+	// A long delay followed by a node that must never run
+	delayNode, err := NewDelayNodeWrapper(map[string]interface{}{"node_id": "delay", "duration": "10s"})
+	assert.NoError(t, err)
+	var sent bool
+	sendNode := flowlib.NewNode(1, 0)
+	sendNode.SetExecFn(func(prepResult any) (any, error) {
+		sent = true
+		return nil, nil
+	})
+	delayNode.Next(flowlib.DefaultAction, sendNode)
+
+	mockRegistry.On("GetFlow", "test-account", "slow-flow").Return(flowDef, nil)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flowlib.NewFlow(delayNode), nil)
+
+	store := newCheckpointTestStore()
+	rt := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store).(*flowRuntime)
+
+	executionID, err := rt.Execute("test-account", "slow-flow", nil)
+	assert.NoError(t, err)
+
+	// Give execution a moment to enter the delay
+	time.Sleep(50 * time.Millisecond)
+
+	started := time.Now()
+	assert.NoError(t, rt.Cancel(executionID))
+
+	// Wait for the execution goroutine to finish
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		rt.mu.RLock()
+		_, active := rt.activeExecutions[executionID]
+		rt.mu.RUnlock()
+		if !active {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Less(t, time.Since(started), 2*time.Second, "delay should be interrupted by cancel")
+	assert.Equal(t, "canceled", store.status(executionID).Status)
+	assert.False(t, sent, "nodes after a canceled one must not run")
+}
+
+func TestEnhancedFlowRuntime_CancelIsFinal(t *testing.T) {
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+
+	flowDef := &Flow{ID: "stubborn-flow", YAML: "stubborn"}
+
+	// The last node ignores the cancellation and completes the flow
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	stubbornNode := flowlib.NewNode(1, 0)
+	stubbornNode.SetExecFn(func(prepResult any) (any, error) {
+		close(entered)
+		<-release
+		return nil, nil
+	})
+
+	mockRegistry.On("GetFlow", "test-account", "stubborn-flow").Return(flowDef, nil)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flowlib.NewFlow(stubbornNode), nil)
+
+	store := newCheckpointTestStore()
+	rt := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store).(*flowRuntime)
+
+	executionID, err := rt.Execute("test-account", "stubborn-flow", nil)
+	require.NoError(t, err)
+	<-entered
+	require.NoError(t, rt.Cancel(executionID))
+	close(release)
+
+	require.Eventually(t, func() bool {
+		rt.mu.RLock()
+		defer rt.mu.RUnlock()
+		_, active := rt.activeExecutions[executionID]
+		return !active
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "canceled", store.status(executionID).Status)
+}
+
 func TestEnhancedFlowRuntime_SubscribeToLogs(t *testing.T) {
 	mockRegistry := new(MockEnhancedFlowRegistry)
 	mockYAMLLoader := new(MockEnhancedYAMLLoader)
@@ -461,6 +554,7 @@ func TestEnhancedFlowRuntime_WithoutExecutionStore(t *testing.T) {
 	}
 
 	mockNode := new(MockEnhancedNode)
+	mockNode.On("Params").Return(map[string]interface{}{"node_id": "start"})
 	mockNode.On("Run", mock.Anything).Return(flowlib.DefaultAction, nil)
 	mockNode.On("Successors").Return(map[flowlib.Action]flowlib.Node{})
 
@@ -494,3 +588,129 @@ func TestEnhancedFlowRuntime_WithoutExecutionStore(t *testing.T) {
 	mockYAMLLoader.AssertExpectations(t)
 	mockNode.AssertExpectations(t)
 }
+
+func TestFlowRuntime_IdempotencyKey(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+
+	flowDef := &Flow{ID: "counting-flow", YAML: "counting"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "counting-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newCountingFlow(&visited, &mu), nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store).(OptionsExecutor)
+
+	options := ExecuteOptions{IdempotencyKey: "webhook-delivery-1"}
+	first, err := flowRuntime.ExecuteWithOptions("test-account", "counting-flow", nil, options)
+	require.NoError(t, err)
+	waitForStatus(t, store, first, "completed")
+
+	// A retried request returns the original execution without running the flow again
+	retried, err := flowRuntime.ExecuteWithOptions("test-account", "counting-flow", nil, options)
+	require.NoError(t, err)
+	assert.Equal(t, first, retried)
+
+	other, err := flowRuntime.ExecuteWithOptions("test-account", "counting-flow", nil, ExecuteOptions{IdempotencyKey: "webhook-delivery-2"})
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other)
+	waitForStatus(t, store, other, "completed")
+
+	mu.Lock()
+	assert.Len(t, visited, 6)
+	mu.Unlock()
+
+	// Without an idempotency store, keys are ignored
+	withoutStore := NewFlowRuntime(mockRegistry, mockYAMLLoader).(OptionsExecutor)
+	first, err = withoutStore.ExecuteWithOptions("test-account", "counting-flow", nil, options)
+	require.NoError(t, err)
+	retried, err = withoutStore.ExecuteWithOptions("test-account", "counting-flow", nil, options)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, retried)
+}
+
+// unavailableExecutionStore fails to save the first executions
+type unavailableExecutionStore struct {
+	*checkpointTestStore
+	failures int
+}
+
+func (s *unavailableExecutionStore) SaveExecution(execution ExecutionStatus) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("store unavailable")
+	}
+	return s.checkpointTestStore.SaveExecution(execution)
+}
+
+func TestFlowRuntime_FailedSaveReleasesIdempotencyKey(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+
+	flowDef := &Flow{ID: "counting-flow", YAML: "counting"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "counting-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newCountingFlow(&visited, &mu), nil)
+
+	store := &unavailableExecutionStore{checkpointTestStore: newCheckpointTestStore(), failures: 1}
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store).(OptionsExecutor)
+
+	options := ExecuteOptions{IdempotencyKey: "webhook-delivery-1"}
+	_, err := flowRuntime.ExecuteWithOptions("test-account", "counting-flow", nil, options)
+	require.ErrorContains(t, err, "store unavailable")
+
+	// The retry starts a new execution instead of returning the unsaved one
+	executionID, err := flowRuntime.ExecuteWithOptions("test-account", "counting-flow", nil, options)
+	require.NoError(t, err)
+	waitForStatus(t, store.checkpointTestStore, executionID, "completed")
+
+	retried, err := flowRuntime.ExecuteWithOptions("test-account", "counting-flow", nil, options)
+	require.NoError(t, err)
+	assert.Equal(t, executionID, retried)
+
+	mu.Lock()
+	assert.Len(t, visited, 3)
+	mu.Unlock()
+}
+
+func TestFlowRuntime_Wait(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+
+	flowDef := &Flow{ID: "counting-flow", YAML: "counting"}
+	slowDef := &Flow{ID: "slow-flow", YAML: "slow"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "counting-flow").Return(flowDef, nil)
+	mockRegistry.On("GetFlow", "test-account", "slow-flow").Return(slowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newCountingFlow(&visited, &mu), nil)
+	mockYAMLLoader.On("Parse", slowDef.YAML).Return(flowlib.NewFlow(newSlowNode(t)), nil)
+
+	for name, flowRuntime := range map[string]FlowRuntime{
+		"with store":    NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, newCheckpointTestStore()),
+		"without store": NewFlowRuntime(mockRegistry, mockYAMLLoader),
+	} {
+		t.Run(name, func(t *testing.T) {
+			waiter := flowRuntime.(ExecutionWaiter)
+
+			executionID, err := flowRuntime.Execute("test-account", "counting-flow", map[string]interface{}{"result": "done"})
+			require.NoError(t, err)
+			status, err := waiter.Wait(context.Background(), executionID)
+			require.NoError(t, err)
+			assert.Equal(t, "completed", status.Status)
+			assert.Equal(t, "done", status.Results["result"])
+
+			// A wait that ends first reports the execution as still running
+			executionID, err = flowRuntime.Execute("test-account", "slow-flow", nil)
+			require.NoError(t, err)
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			status, err = waiter.Wait(ctx, executionID)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Equal(t, "running", status.Status)
+			require.NoError(t, flowRuntime.Cancel(executionID))
+		})
+	}
+}
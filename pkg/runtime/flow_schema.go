@@ -0,0 +1,73 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tcmartin/flowrunner/pkg/loader"
+)
+
+// SchemaError is returned for execution input or results that do not match
+// the schemas a flow declares
+type SchemaError struct {
+	// Schema is "inputs" or "outputs"
+	Schema string
+
+	// Fields lists the mismatches, ordered by field
+	Fields []loader.FieldError
+}
+
+func (e *SchemaError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Error()
+	}
+	return fmt.Sprintf("flow %s schema not matched: %s", e.Schema, strings.Join(messages, "; "))
+}
+
+// validateInput checks execution input against the inputs schema of a flow
+func validateInput(settings flowSettings, input map[string]interface{}) error {
+	if settings.inputs == nil {
+		return nil
+	}
+	if input == nil {
+		input = map[string]interface{}{}
+	}
+	if fields := settings.inputs.Validate(jsonValue(input)); len(fields) > 0 {
+		return &SchemaError{Schema: "inputs", Fields: fields}
+	}
+	return nil
+}
+
+// validateResults checks execution results against the outputs schema of a
+// flow. The action key the runtime adds to results is not validated.
+func validateResults(settings flowSettings, results map[string]interface{}) error {
+	if settings.outputs == nil {
+		return nil
+	}
+	declared := make(map[string]interface{}, len(results))
+	for key, value := range results {
+		if key != "action" {
+			declared[key] = value
+		}
+	}
+	if fields := settings.outputs.Validate(jsonValue(declared)); len(fields) > 0 {
+		return &SchemaError{Schema: "outputs", Fields: fields}
+	}
+	return nil
+}
+
+// jsonValue returns value as clients see it after JSON encoding, so that
+// typed slices and maps validate like the arrays and objects they encode to
+func jsonValue(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return value
+	}
+	return decoded
+}
@@ -0,0 +1,95 @@
+package runtime
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/loader"
+)
+
+const schemaFlowYAML = `
+metadata:
+  name: invoice
+  inputs:
+    type: object
+    required: [customer, amount]
+    properties:
+      customer:
+        type: string
+      amount:
+        type: number
+        exclusiveMinimum: 0
+  outputs:
+    type: object
+    required: [total]
+    properties:
+      total:
+        type: number
+nodes:
+  bill:
+    type: base
+`
+
+// newSchemaRuntime runs a flow whose bill node returns the amount as total,
+// or the override input if there is one
+func newSchemaRuntime(t *testing.T) (FlowRuntime, *checkpointTestStore) {
+	bill := flowlib.NewNode(1, 0)
+	bill.SetParams(map[string]interface{}{"node_id": "bill"})
+	bill.SetPrepFn(func(shared any) (any, error) {
+		state := shared.(map[string]interface{})
+		total := state["amount"]
+		if override, ok := state["override"]; ok {
+			total = override
+		}
+		state["result"] = map[string]interface{}{"total": total}
+		return nil, nil
+	})
+
+	flowDef := &Flow{ID: "invoice", YAML: schemaFlowYAML}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "invoice").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flowlib.NewFlow(bill), nil)
+
+	store := newCheckpointTestStore()
+	return NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store), store
+}
+
+func TestFlowRuntime_RejectsInputNotMatchingSchema(t *testing.T) {
+	flowRuntime, store := newSchemaRuntime(t)
+
+	executionID, err := flowRuntime.Execute("test-account", "invoice", map[string]interface{}{"amount": -5})
+	require.Error(t, err)
+	assert.Empty(t, executionID)
+
+	var schemaErr *SchemaError
+	require.True(t, errors.As(err, &schemaErr))
+	assert.Equal(t, "inputs", schemaErr.Schema)
+	assert.Equal(t, []loader.FieldError{
+		{Field: "amount", Message: "must be greater than 0"},
+		{Field: "customer", Message: "is required"},
+	}, schemaErr.Fields)
+	assert.Equal(t, "flow inputs schema not matched: amount must be greater than 0; customer is required", err.Error())
+
+	store.mu.Lock()
+	assert.Empty(t, store.executions)
+	store.mu.Unlock()
+}
+
+func TestFlowRuntime_ValidatesResultsAgainstSchema(t *testing.T) {
+	flowRuntime, store := newSchemaRuntime(t)
+
+	executionID, err := flowRuntime.Execute("test-account", "invoice", map[string]interface{}{"customer": "acme", "amount": 12.5})
+	require.NoError(t, err)
+	status := waitForStatus(t, store, executionID, "completed")
+	assert.Equal(t, 12.5, status.Results["total"])
+
+	executionID, err = flowRuntime.Execute("test-account", "invoice", map[string]interface{}{"customer": "acme", "amount": 12.5, "override": "twelve"})
+	require.NoError(t, err)
+	status = waitForStatus(t, store, executionID, "failed")
+	assert.Equal(t, "flow outputs schema not matched: total must be a number", status.Error)
+	assert.Equal(t, "twelve", status.Results["total"])
+}
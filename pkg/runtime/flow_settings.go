@@ -0,0 +1,127 @@
+package runtime
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/loader"
+)
+
+// flowSettings holds the execution settings declared in a flow definition
+type flowSettings struct {
+	// timeout bounds a whole execution; zero means no limit
+	timeout time.Duration
+
+	// nodeTimeouts bounds single node runs, keyed by node ID
+	nodeTimeouts map[string]time.Duration
+
+	// dryRunOutputs are the stub outputs nodes return in dry runs, keyed by
+	// node ID
+	dryRunOutputs map[string]interface{}
+
+	// visitLimits bound the node visits of an execution; zero fields use
+	// the limits of the runtime
+	visitLimits flowlib.VisitLimits
+
+	// inputs and outputs are the schemas of the input and the results of
+	// an execution; nil schemas accept anything
+	inputs  loader.JSONSchema
+	outputs loader.JSONSchema
+
+	// vars are the flow vars, and environments their overlays keyed by
+	// environment name
+	vars         map[string]interface{}
+	environments map[string]map[string]interface{}
+}
+
+// parseFlowSettings reads the execution settings declared in a flow definition.
+// Definitions that do not follow the YAML schema declare no settings.
+func parseFlowSettings(yamlContent string) (flowSettings, error) {
+	var settings flowSettings
+
+	flowDef, err := loader.ParseFlowDefinition(yamlContent)
+	if err != nil {
+		return settings, nil
+	}
+
+	if flowDef.Metadata.Timeout != "" {
+		settings.timeout, err = time.ParseDuration(flowDef.Metadata.Timeout)
+		if err != nil {
+			return settings, fmt.Errorf("invalid flow timeout: %w", err)
+		}
+	}
+
+	settings.visitLimits = flowlib.VisitLimits{
+		MaxVisits:        flowDef.Metadata.MaxNodeVisits,
+		MaxVisitsPerNode: flowDef.Metadata.MaxVisitsPerNode,
+	}
+	settings.inputs = flowDef.Metadata.Inputs
+	settings.outputs = flowDef.Metadata.Outputs
+
+	settings.vars, _ = normalizeYAMLValue(flowDef.Vars).(map[string]interface{})
+	for name, environment := range flowDef.Environments {
+		if settings.environments == nil {
+			settings.environments = make(map[string]map[string]interface{})
+		}
+		overlay, _ := normalizeYAMLValue(environment.Vars).(map[string]interface{})
+		settings.environments[name] = overlay
+	}
+
+	for nodeID, nodeDef := range flowDef.Nodes {
+		if nodeDef.DryRun.Output != nil {
+			if settings.dryRunOutputs == nil {
+				settings.dryRunOutputs = make(map[string]interface{})
+			}
+			settings.dryRunOutputs[nodeID] = normalizeYAMLValue(nodeDef.DryRun.Output)
+		}
+
+		if nodeDef.Timeout == "" {
+			continue
+		}
+		timeout, err := time.ParseDuration(nodeDef.Timeout)
+		if err != nil {
+			return settings, fmt.Errorf("invalid timeout for node %s: %w", nodeID, err)
+		}
+		if settings.nodeTimeouts == nil {
+			settings.nodeTimeouts = make(map[string]time.Duration)
+		}
+		settings.nodeTimeouts[nodeID] = timeout
+	}
+
+	return settings, nil
+}
+
+// nodeTimeout returns the declared timeout of a node, or zero if it has none
+func (s flowSettings) nodeTimeout(node flowlib.Node) time.Duration {
+	if len(s.nodeTimeouts) == 0 {
+		return 0
+	}
+	return s.nodeTimeouts[nodeIDOf(node)]
+}
+
+// normalizeYAMLValue converts the maps decoded from YAML, which have
+// interface{} keys, into maps with string keys
+func normalizeYAMLValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[fmt.Sprintf("%v", key)] = normalizeYAMLValue(item)
+		}
+		return normalized
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[key] = normalizeYAMLValue(item)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeYAMLValue(item)
+		}
+		return normalized
+	}
+	return value
+}
@@ -0,0 +1,68 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+)
+
+// EnvironmentKey is the ExecutionStatus.Metadata key that records the
+// environment an execution runs in, whose overlay applies to the flow vars
+const EnvironmentKey = "environment"
+
+// varsKey is the shared state key under which the runtime keeps the resolved
+// flow vars for template evaluation
+const varsKey = "_vars"
+
+// ErrUnknownEnvironment is returned for runs in an environment that the flow
+// does not declare
+var ErrUnknownEnvironment = errors.New("unknown environment")
+
+// environmentOf returns the environment an execution runs in, or "" for the
+// flow vars without overlay
+func environmentOf(status ExecutionStatus) string {
+	return status.Metadata[EnvironmentKey]
+}
+
+// executionEnvironment returns the environment of a running execution, whose
+// status may change meanwhile
+func executionEnvironment(execCtx *executionContext) string {
+	execCtx.mu.RLock()
+	defer execCtx.mu.RUnlock()
+	return environmentOf(execCtx.status)
+}
+
+// checkEnvironment rejects environments that a flow with overlays does not
+// declare. Flows without overlays run with their vars in every environment.
+func (s flowSettings) checkEnvironment(environment string) error {
+	if environment == "" || len(s.environments) == 0 {
+		return nil
+	}
+	if _, ok := s.environments[environment]; !ok {
+		return fmt.Errorf("%w: flow declares no environment %q", ErrUnknownEnvironment, environment)
+	}
+	return nil
+}
+
+// resolveVars returns the flow vars with the overlay of environment applied
+func (s flowSettings) resolveVars(environment string) map[string]interface{} {
+	vars := mergeVars(map[string]interface{}{}, s.vars)
+	if overlay, ok := s.environments[environment]; ok {
+		vars = mergeVars(vars, overlay)
+	}
+	return vars
+}
+
+// mergeVars copies overlay into vars. Objects in both are merged, other
+// values of overlay replace those of vars.
+func mergeVars(vars, overlay map[string]interface{}) map[string]interface{} {
+	for name, value := range overlay {
+		overlayObject, isObject := value.(map[string]interface{})
+		if !isObject {
+			vars[name] = value
+			continue
+		}
+		base, _ := vars[name].(map[string]interface{})
+		vars[name] = mergeVars(mergeVars(map[string]interface{}{}, base), overlayObject)
+	}
+	return vars
+}
@@ -0,0 +1,130 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+const varsFlowYAML = `
+metadata:
+  name: enrich
+vars:
+  api_url: https://api.dev.example.com
+  model: gpt-4o-mini
+  retry:
+    attempts: 2
+    backoff: 1s
+environments:
+  staging:
+    vars:
+      api_url: https://api.staging.example.com
+  prod:
+    vars:
+      api_url: https://api.example.com
+      model: gpt-4o
+      retry:
+        attempts: 5
+nodes:
+  fetch:
+    type: base
+`
+
+func TestFlowSettings_ResolveVars(t *testing.T) {
+	settings, err := parseFlowSettings(varsFlowYAML)
+	require.NoError(t, err)
+
+	tests := []struct {
+		environment string
+		expected    map[string]interface{}
+	}{
+		{
+			environment: "",
+			expected: map[string]interface{}{
+				"api_url": "https://api.dev.example.com",
+				"model":   "gpt-4o-mini",
+				"retry":   map[string]interface{}{"attempts": 2, "backoff": "1s"},
+			},
+		},
+		{
+			environment: "staging",
+			expected: map[string]interface{}{
+				"api_url": "https://api.staging.example.com",
+				"model":   "gpt-4o-mini",
+				"retry":   map[string]interface{}{"attempts": 2, "backoff": "1s"},
+			},
+		},
+		{
+			environment: "prod",
+			expected: map[string]interface{}{
+				"api_url": "https://api.example.com",
+				"model":   "gpt-4o",
+				"retry":   map[string]interface{}{"attempts": 5, "backoff": "1s"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("environment "+tt.environment, func(t *testing.T) {
+			assert.NoError(t, settings.checkEnvironment(tt.environment))
+			assert.Equal(t, tt.expected, settings.resolveVars(tt.environment))
+		})
+	}
+
+	// Overlays never change the declared vars
+	assert.Equal(t, 2, settings.vars["retry"].(map[string]interface{})["attempts"])
+
+	assert.ErrorIs(t, settings.checkEnvironment("qa"), ErrUnknownEnvironment)
+
+	// Flows without overlays run with their vars in any environment
+	plain, err := parseFlowSettings("metadata:\n  name: plain\nvars:\n  region: eu-west-1\nnodes:\n  start:\n    type: base\n")
+	require.NoError(t, err)
+	assert.NoError(t, plain.checkEnvironment("qa"))
+	assert.Equal(t, map[string]interface{}{"region": "eu-west-1"}, plain.resolveVars("qa"))
+}
+
+func TestFlowContext_Vars(t *testing.T) {
+	flowContext := NewFlowContext("exec-1", "enrich", "test-account", nil)
+	flowContext.SetSharedData("path", "/users")
+	flowContext.SetVars(map[string]interface{}{"api_url": "https://api.example.com"})
+
+	resolved, err := flowContext.ProcessNodeParams(map[string]interface{}{
+		"url": "${vars.api_url + shared.path}",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.example.com/users", resolved["url"])
+}
+
+func TestFlowRuntime_VarsWithoutSecretVault(t *testing.T) {
+	var resolved map[string]interface{}
+	fetch := &NodeWrapper{
+		node: flowlib.NewNode(1, 0),
+		exec: func(input interface{}) (interface{}, error) {
+			resolved = input.(map[string]interface{})["params"].(map[string]interface{})
+			return nil, nil
+		},
+	}
+	fetch.SetParams(map[string]interface{}{
+		"node_id": "fetch",
+		"url":     "${vars.api_url}",
+		"model":   "${vars.model}",
+	})
+
+	flowDef := &Flow{ID: "enrich", YAML: varsFlowYAML}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "enrich").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flowlib.NewFlow(fetch), nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store).(OptionsExecutor)
+
+	executionID, err := flowRuntime.ExecuteWithOptions("test-account", "enrich", nil, ExecuteOptions{Environment: "prod"})
+	require.NoError(t, err)
+	waitForStatus(t, store, executionID, "completed")
+
+	assert.Equal(t, "https://api.example.com", resolved["url"])
+	assert.Equal(t, "gpt-4o", resolved["model"])
+}
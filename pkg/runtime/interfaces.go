@@ -26,11 +26,58 @@ type FlowRuntime interface {
 	ListExecutions(accountID string) ([]ExecutionStatus, error)
 }
 
+// OptionsExecutor is implemented by runtimes that can start executions with
+// ExecuteOptions
+type OptionsExecutor interface {
+	// ExecuteWithOptions runs a flow with the given input and execution options
+	ExecuteWithOptions(accountID string, flowID string, input map[string]interface{}, options ExecuteOptions) (string, error)
+}
+
+// DefaultIdempotencyWindow is how long an idempotency key is remembered when
+// ExecuteOptions does not say otherwise
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// ExecuteOptions controls how an execution is started
+type ExecuteOptions struct {
+	// IdempotencyKey identifies a request within an account. Repeating a key
+	// within IdempotencyWindow returns the execution started by the first
+	// request instead of starting a new one.
+	IdempotencyKey string
+
+	// IdempotencyWindow is how long an idempotency key is remembered.
+	// Defaults to DefaultIdempotencyWindow.
+	IdempotencyWindow time.Duration
+
+	// Debug runs the execution in debug mode: it pauses before its first
+	// node and before every breakpoint, and waits for debug commands
+	Debug bool
+
+	// Breakpoints are the IDs of the nodes a debug execution pauses before
+	Breakpoints []string
+
+	// DryRun simulates the nodes with side effects: they return their
+	// declared dry run output or a preview of what they would have done
+	DryRun bool
+
+	// Environment selects the overlay applied to the flow vars, such as
+	// "prod". Empty runs with the flow vars as declared.
+	Environment string
+}
+
 // FlowRegistry is an interface for retrieving flow definitions
 type FlowRegistry interface {
 	GetFlow(accountID, flowID string) (*Flow, error)
 }
 
+// VersionedFlowRegistry is implemented by flow registries that can return a
+// specific version of a flow definition
+type VersionedFlowRegistry interface {
+	FlowRegistry
+
+	// GetFlowVersion retrieves the given version of a flow definition
+	GetFlowVersion(accountID, flowID, version string) (*Flow, error)
+}
+
 // Flow represents a flow definition
 type Flow struct {
 	ID   string
@@ -46,7 +93,7 @@ type ExecutionStatus struct {
 	FlowID string `json:"flow_id"`
 
 	// Status of the execution
-	Status string `json:"status"` // "running", "completed", "failed", "canceled"
+	Status string `json:"status"` // "queued", "running", "paused", "waiting", "completed", "failed", "canceled", "timeout"
 
 	// StartTime is when the execution started
 	StartTime time.Time `json:"start_time"`
@@ -63,6 +110,10 @@ type ExecutionStatus struct {
 	// Progress of the execution (0-100%)
 	Progress float64 `json:"progress"`
 
+	// QueuePosition is the 1-based position of a queued execution in the
+	// execution queue
+	QueuePosition int `json:"queue_position,omitempty"`
+
 	// CurrentNode is the ID of the currently executing node
 	CurrentNode string `json:"current_node,omitempty"`
 
@@ -79,11 +130,141 @@ type ExecutionLog struct {
 	NodeID string `json:"node_id,omitempty"`
 
 	// Level of the log entry
-	Level string `json:"level"` // "info", "warning", "error", "debug"
+	Level string `json:"level"` // "info", "warning", "error", "debug", "status", "paused"
 
 	// Message is the log message
 	Message string `json:"message"`
 
 	// Data is additional context for the log entry
 	Data map[string]interface{} `json:"data,omitempty"`
+
+	// Status is the new status of the execution on entries with the "status"
+	// level. These entries are only sent to subscribers and never stored.
+	Status *ExecutionStatus `json:"status,omitempty"`
+
+	// Pause describes where a debug execution paused on entries with the
+	// "paused" level. These entries are only sent to subscribers and never
+	// stored.
+	Pause *DebugPause `json:"pause,omitempty"`
+}
+
+// DebugPause describes a debug execution paused before a node
+type DebugPause struct {
+	// NodeID is the ID of the node that runs once the execution resumes
+	NodeID string `json:"node_id"`
+
+	// Step is the number of nodes that ran before the pause
+	Step int `json:"step"`
+
+	// Params are the node parameters after template expressions were
+	// resolved, with secrets redacted
+	Params map[string]interface{} `json:"params,omitempty"`
+
+	// Shared is a copy of the shared state without runtime-internal keys
+	Shared map[string]interface{} `json:"shared"`
+}
+
+// NodeTrace records one visit of a node during an execution
+type NodeTrace struct {
+	// ExecutionID is the ID of the traced execution
+	ExecutionID string `json:"execution_id"`
+
+	// Sequence is the 1-based position of the visit in the execution
+	Sequence int `json:"sequence"`
+
+	// NodeID is the ID of the visited node
+	NodeID string `json:"node_id"`
+
+	// NodeType is the type of the visited node
+	NodeType string `json:"node_type,omitempty"`
+
+	// Visit counts the visits of the node, 1 for its first visit
+	Visit int `json:"visit"`
+
+	// StartTime is when the node started
+	StartTime time.Time `json:"start_time"`
+
+	// EndTime is when the node finished
+	EndTime time.Time `json:"end_time"`
+
+	// Action is the action the execution followed after the node
+	Action string `json:"action,omitempty"`
+
+	// Retries is the number of times the node was retried
+	Retries int `json:"retries"`
+
+	// Params are the node parameters after template expressions were
+	// resolved, with secrets redacted
+	Params map[string]interface{} `json:"params,omitempty"`
+
+	// Output is the result of the node
+	Output interface{} `json:"output,omitempty"`
+
+	// Error is the error the node failed with
+	Error string `json:"error,omitempty"`
+}
+
+// ExecutionCheckpoint captures the state of an execution between two nodes
+type ExecutionCheckpoint struct {
+	// ExecutionID is the ID of the checkpointed execution
+	ExecutionID string `json:"execution_id"`
+
+	// AccountID is the account that owns the execution
+	AccountID string `json:"account_id"`
+
+	// FlowID is the ID of the flow being executed
+	FlowID string `json:"flow_id"`
+
+	// Step is the number of nodes that completed before this checkpoint
+	Step int `json:"step"`
+
+	// NodeID is the ID of the node that runs next
+	NodeID string `json:"node_id"`
+
+	// Shared is a snapshot of the shared state without runtime-internal keys
+	Shared map[string]interface{} `json:"shared"`
+
+	// CreatedAt is when the checkpoint was taken
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExecutionWait records an execution parked by a node until it is signaled,
+// an event arrives or its deadline passes. Timers are waits that only end at
+// their deadline.
+type ExecutionWait struct {
+	// ExecutionID is the ID of the waiting execution
+	ExecutionID string `json:"execution_id"`
+
+	// AccountID is the account that owns the execution
+	AccountID string `json:"account_id"`
+
+	// FlowID is the ID of the flow being executed
+	FlowID string `json:"flow_id"`
+
+	// NodeID is the ID of the node the execution waits in
+	NodeID string `json:"node_id"`
+
+	// Signal is the name of the signal that resumes the execution. Timers,
+	// which only resume at their deadline, have none.
+	Signal string `json:"signal,omitempty"`
+
+	// Event is the name of the event that resumes the execution, if any
+	Event string `json:"event,omitempty"`
+
+	// EventKey is the correlation key the event must carry, such as an
+	// order ID
+	EventKey string `json:"event_key,omitempty"`
+
+	// Deadline is when the wait expires; zero waits until the execution is
+	// signaled or canceled
+	Deadline time.Time `json:"deadline,omitempty"`
+
+	// Action is the action the node follows once the deadline passes
+	Action string `json:"action,omitempty"`
+
+	// Result is the result of the node once the deadline passes
+	Result map[string]interface{} `json:"result,omitempty"`
+
+	// CreatedAt is when the execution was parked
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -52,7 +52,7 @@ func NewLLMNodeWrapper(params map[string]any) (flowlib.Node, error) {
 	// Create the wrapper
 	wrapper := &NodeWrapper{
 		node: baseNode,
-		exec: func(input any) (any, error) {
+		execWithContext: func(ctx context.Context, input any) (any, error) {
 			// Extract parameters and flow input from combined context
 			combinedInput, ok := input.(map[string]interface{})
 			if !ok {
@@ -451,10 +451,14 @@ func NewLLMNodeWrapper(params map[string]any) (flowlib.Node, error) {
 
 			// Extract additional options
 			options := make(map[string]any)
-			if optsParam, ok := params["options"].(map[string]any); ok {
-				for k, v := range optsParam {
-					options[k] = v
-				}
+			var optsParam map[string]any
+			if opts, ok := params["options"].(map[string]any); ok {
+				optsParam = opts
+			} else if optsInterface, ok := params["options"].(map[interface{}]interface{}); ok {
+				optsParam = convertInterfaceMapToStringMap(optsInterface)
+			}
+			for k, v := range optsParam {
+				options[k] = v
 			}
 
 			// Extract response format (for structured output)
@@ -494,8 +498,7 @@ func NewLLMNodeWrapper(params map[string]any) (flowlib.Node, error) {
 				"provider":     providerStr,
 			})
 
-			// Execute request
-			ctx := context.Background()
+			// Execute request; canceling the execution aborts the API call
 			resp, err := client.Complete(ctx, request)
 			if err != nil {
 				logToExecution("error", "LLM request failed", map[string]interface{}{
@@ -515,6 +518,9 @@ func NewLLMNodeWrapper(params map[string]any) (flowlib.Node, error) {
 					"error": resp.Error.Message,
 					"model": model,
 				})
+				if resp.Error.Status > 0 {
+					return nil, &utils.APIError{Provider: "LLM", Status: resp.Error.Status, Message: resp.Error.Message}
+				}
 				return nil, fmt.Errorf("LLM API error: %s", resp.Error.Message)
 			}
 
@@ -593,6 +599,7 @@ func NewLLMNodeWrapper(params map[string]any) (flowlib.Node, error) {
 			return result, nil
 		},
 	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
 
 	// Set the parameters
 	wrapper.SetParams(params)
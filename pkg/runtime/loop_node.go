@@ -0,0 +1,374 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/scripting"
+)
+
+// BodyAction is the successor action under which foreach and while nodes find
+// the first node of their body. The loop node runs the body itself; after the
+// loop the execution follows the action the loop node returns.
+const BodyAction = "body"
+
+// DefaultForeachMaxItems bounds the items of foreach nodes without max_items
+const DefaultForeachMaxItems = 1000
+
+// loopBody is the sub-graph or the sub-flow a loop node runs per iteration
+type loopBody struct {
+	start flowlib.Node
+	call  *flowCall
+}
+
+// loopBodyOf returns the body of a loop node: the sub-flow named by its
+// flow_id parameter, or else the nodes under its body action
+func loopBodyOf(node flowlib.Node, params map[string]interface{}) (loopBody, error) {
+	if _, ok := params["flow_id"]; ok {
+		call, err := flowCallFromParams(params)
+		if err != nil {
+			return loopBody{}, err
+		}
+		return loopBody{call: &call}, nil
+	}
+
+	start := node.Successors()[BodyAction]
+	if start == nil {
+		return loopBody{}, fmt.Errorf("flow_id parameter or %q successor is required", BodyAction)
+	}
+	return loopBody{start: start}, nil
+}
+
+// run runs the body once and returns its result. A sub-graph runs on shared
+// and may change it; its nodes take the same path as the nodes of the
+// execution. A sub-flow gets the public part of shared as input.
+func (b loopBody) run(ctx context.Context, scope *executionScope, shared map[string]interface{}) (interface{}, error) {
+	if b.call != nil {
+		call := *b.call
+		call.input = publicSharedState(shared)
+		return scope.runtime.callFlow(ctx, scope.execution, call)
+	}
+
+	action, err := scope.runtime.runNestedGraph(withExecutionScope(ctx, scope.runtime, scope.execution), scope.execution, b.start, nil, shared)
+	// The body nodes that completed are compensated with the loop node
+	handOverCompletions(ctx, shared, 0)
+	if err != nil {
+		return nil, err
+	}
+	return flowResult(action, shared), nil
+}
+
+// loopScope returns the execution a loop node runs in
+func loopScope(ctx context.Context, nodeType string) (*executionScope, error) {
+	scope, ok := executionScopeFrom(ctx)
+	if !ok {
+		return nil, fmt.Errorf("%s can only run inside a flow execution", nodeType)
+	}
+	if scope.depth >= maxFlowCallDepth {
+		return nil, fmt.Errorf("%s nesting exceeds %d levels", nodeType, maxFlowCallDepth)
+	}
+	return scope, nil
+}
+
+// NewForeachNodeWrapper creates a node that runs its body once per item of an array.
+//
+// Parameters:
+//   - items: the array to iterate over, usually an expression such as
+//     "${shared.orders}" (required)
+//   - flow_id, version, mode, output: run a sub-flow per item, like flow.call;
+//     without flow_id the nodes under the "body" action run per item
+//   - input: the state every iteration starts from; defaults to the current
+//     node input. Each iteration adds "item" and "index" to it.
+//   - max_parallel: how many items run at the same time (default 1)
+//   - max_items: fails the node if there are more items (default 1000)
+//   - continue_on_error: records failed items instead of failing the node
+//
+// The result holds the body results in item order under "results".
+func NewForeachNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
+	// Create the base node
+	baseNode := flowlib.NewNode(1, 0)
+
+	// Create the wrapper
+	wrapper := &NodeWrapper{node: baseNode}
+	wrapper.execWithContext = func(ctx context.Context, input interface{}) (interface{}, error) {
+		params, flowInput, err := nodeInput(input)
+		if err != nil {
+			return nil, err
+		}
+
+		items, ok := params["items"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("items parameter must resolve to an array, got %T", params["items"])
+		}
+		maxItems, err := intParam(params, "max_items", DefaultForeachMaxItems)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) > maxItems {
+			return nil, fmt.Errorf("foreach has %d items, more than max_items %d", len(items), maxItems)
+		}
+		maxParallel, err := intParam(params, "max_parallel", 1)
+		if err != nil {
+			return nil, err
+		}
+		continueOnError, _ := params["continue_on_error"].(bool)
+
+		body, err := loopBodyOf(wrapper, params)
+		if err != nil {
+			return nil, err
+		}
+		scope, err := loopScope(ctx, "foreach")
+		if err != nil {
+			return nil, err
+		}
+
+		return scope.runtime.runForeach(ctx, scope, body, foreachOptions{
+			base:            callInput(params, flowInput),
+			items:           items,
+			maxParallel:     maxParallel,
+			continueOnError: continueOnError,
+		})
+	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
+
+	// Set the parameters
+	wrapper.SetParams(params)
+
+	return wrapper, nil
+}
+
+// foreachOptions describes a run of a foreach node
+type foreachOptions struct {
+	base            map[string]interface{}
+	items           []interface{}
+	maxParallel     int
+	continueOnError bool
+}
+
+// runForeach runs the body once per item, at most maxParallel at a time. Every
+// iteration gets its own shared state.
+func (r *flowRuntime) runForeach(ctx context.Context, scope *executionScope, body loopBody, options foreachOptions) (interface{}, error) {
+	r.logExecution(scope.execution.status.ID, "info", "Running foreach", map[string]interface{}{
+		"items":        len(options.items),
+		"max_parallel": options.maxParallel,
+	})
+
+	// The first failure stops the remaining items unless they continue on error
+	itemsCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]interface{}, len(options.items))
+	itemErrors := make([]error, len(options.items))
+	var failOnce sync.Once
+	var failure error
+
+	slots := make(chan struct{}, options.maxParallel)
+	var wg sync.WaitGroup
+items:
+	for index, item := range options.items {
+		select {
+		case slots <- struct{}{}:
+		case <-itemsCtx.Done():
+			break items
+		}
+
+		// Parallel iterations must not share the objects they may change
+		input := flowlib.CopyValue(options.base).(map[string]interface{})
+		input["item"] = item
+		input["index"] = index
+
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			results[index], itemErrors[index] = body.run(itemsCtx, scope, r.newSharedState(scope.execution, input))
+			if itemErrors[index] != nil && !options.continueOnError {
+				failOnce.Do(func() {
+					failure = fmt.Errorf("foreach item %d failed: %w", index, itemErrors[index])
+					cancel()
+				})
+			}
+		}(index)
+	}
+	wg.Wait()
+
+	if failure != nil {
+		return nil, failure
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	output := map[string]interface{}{
+		"results": results,
+		"count":   len(options.items),
+	}
+	if options.continueOnError {
+		failed := make([]interface{}, 0)
+		for index, err := range itemErrors {
+			if err != nil {
+				failed = append(failed, map[string]interface{}{
+					"index": index,
+					"item":  options.items[index],
+					"error": err.Error(),
+				})
+			}
+		}
+		output["errors"] = failed
+	}
+	return output, nil
+}
+
+// NewWhileNodeWrapper creates a node that runs its body as long as a condition holds.
+//
+// Parameters:
+//   - condition: an expression evaluated before every iteration, with "shared"
+//     the loop state, "index" the iteration number and "result" the result of
+//     the previous iteration (required)
+//   - max_iterations: fails the node if the condition still holds after this
+//     many iterations (required)
+//   - flow_id, version, mode, output: run a sub-flow per iteration, like
+//     flow.call; without flow_id the nodes under the "body" action run
+//   - input: the initial loop state; defaults to the current node input
+//
+// The nodes under the "body" action share the loop state across iterations.
+// The result holds the results of the iterations under "results" and the
+// final loop state under "state".
+func NewWhileNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
+	// Create the base node
+	baseNode := flowlib.NewNode(1, 0)
+
+	// Create the wrapper
+	wrapper := &NodeWrapper{node: baseNode}
+	wrapper.execWithContext = func(ctx context.Context, input interface{}) (interface{}, error) {
+		params, flowInput, err := nodeInput(input)
+		if err != nil {
+			return nil, err
+		}
+
+		condition, ok := params["condition"].(string)
+		if !ok || condition == "" {
+			return nil, fmt.Errorf("condition parameter is required")
+		}
+		if _, ok := params["max_iterations"]; !ok {
+			return nil, fmt.Errorf("max_iterations parameter is required")
+		}
+		maxIterations, err := intParam(params, "max_iterations", 0)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := loopBodyOf(wrapper, params)
+		if err != nil {
+			return nil, err
+		}
+		scope, err := loopScope(ctx, "while")
+		if err != nil {
+			return nil, err
+		}
+
+		shared := scope.runtime.newSharedState(scope.execution, callInput(params, flowInput))
+		return scope.runtime.runWhile(ctx, scope, body, condition, maxIterations, shared)
+	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
+
+	// Set the parameters
+	wrapper.SetParams(params)
+
+	return wrapper, nil
+}
+
+// runWhile runs the body on shared until the condition is false
+func (r *flowRuntime) runWhile(ctx context.Context, scope *executionScope, body loopBody, condition string, maxIterations int, shared map[string]interface{}) (interface{}, error) {
+	if !strings.HasPrefix(condition, "${") {
+		condition = "${" + condition + "}"
+	}
+	evaluator := scripting.NewJSExpressionEvaluator()
+
+	results := make([]interface{}, 0)
+	var result interface{}
+	for index := 0; ; index++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		shared["index"] = index
+		value, err := evaluator.Evaluate(condition, map[string]interface{}{
+			"shared": publicSharedState(shared),
+			"index":  index,
+			"result": result,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate while condition: %w", err)
+		}
+		holds, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("while condition must be a boolean, got %T", value)
+		}
+		if !holds {
+			break
+		}
+		if index >= maxIterations {
+			return nil, fmt.Errorf("while condition still holds after max_iterations %d", maxIterations)
+		}
+
+		result, err = body.run(ctx, scope, shared)
+		if err != nil {
+			return nil, fmt.Errorf("while iteration %d failed: %w", index, err)
+		}
+		results = append(results, result)
+	}
+
+	return map[string]interface{}{
+		"results":    results,
+		"iterations": len(results),
+		"state":      publicSharedState(shared),
+	}, nil
+}
+
+// nodeInput splits the combined input of a node into its parameters and the
+// current node input
+func nodeInput(input interface{}) (map[string]interface{}, interface{}, error) {
+	combinedInput, ok := input.(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("expected map[string]interface{}, got %T", input)
+	}
+	nodeParams, hasParams := combinedInput["params"]
+	if !hasParams {
+		// Old format: direct params (backwards compatibility)
+		return combinedInput, nil, nil
+	}
+	params, ok := nodeParams.(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("expected params to be map[string]interface{}")
+	}
+	return params, combinedInput["input"], nil
+}
+
+// intParam returns a positive integer parameter, or def if it is not set
+func intParam(params map[string]interface{}, key string, def int) (int, error) {
+	var value int
+	switch v := params[key].(type) {
+	case nil:
+		return def, nil
+	case int:
+		value = v
+	case int64:
+		value = int(v)
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("%s must be an integer, got %v", key, v)
+		}
+		value = int(v)
+	default:
+		return 0, fmt.Errorf("%s must be an integer, got %T", key, v)
+	}
+	if value < 1 {
+		return 0, fmt.Errorf("%s must be at least 1, got %d", key, value)
+	}
+	return value, nil
+}
@@ -0,0 +1,311 @@
+package runtime
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/loader"
+)
+
+// newLoopTestRuntime returns a runtime that serves the given flows by ID
+func newLoopTestRuntime(flows map[string]*flowlib.Flow) (FlowRuntime, *checkpointTestStore) {
+	registry := &versionedTestRegistry{flows: make(map[string]string)}
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	for flowID, flow := range flows {
+		registry.flows[flowID] = flowID
+		mockYAMLLoader.On("Parse", flowID).Return(flow, nil)
+	}
+	store := newCheckpointTestStore()
+	return NewFlowRuntimeWithStore(registry, mockYAMLLoader, store), store
+}
+
+// newLoopFlow builds a flow of a single loop node whose body is body
+func newLoopFlow(t *testing.T, factory NodeFactory, params map[string]interface{}, body flowlib.Node) *flowlib.Flow {
+	node, err := factory(params)
+	require.NoError(t, err)
+	if body != nil {
+		node.Next(BodyAction, body)
+	}
+	return flowlib.NewFlow(node)
+}
+
+// newDoublingNode builds a node whose result is twice the "item" in the shared state
+func newDoublingNode(run func(item float64) error) flowlib.Node {
+	node := flowlib.NewNode(1, 0)
+	node.SetParams(map[string]interface{}{"node_id": "double"})
+	node.SetPrepFn(func(shared any) (any, error) {
+		sharedMap := shared.(map[string]interface{})
+		item, _ := sharedMap["item"].(float64)
+		if run != nil {
+			if err := run(item); err != nil {
+				return nil, err
+			}
+		}
+		sharedMap["result"] = map[string]interface{}{"value": item * 2, "index": sharedMap["index"]}
+		return nil, nil
+	})
+	return node
+}
+
+func TestForeachNode_Body(t *testing.T) {
+	var running, maxRunning int32
+	body := newDoublingNode(func(float64) error {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			seen := atomic.LoadInt32(&maxRunning)
+			if current <= seen || atomic.CompareAndSwapInt32(&maxRunning, seen, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+
+	flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+		"loop": newLoopFlow(t, NewForeachNodeWrapper, map[string]interface{}{
+			"node_id":      "each",
+			"items":        []interface{}{float64(1), float64(2), float64(3), float64(4)},
+			"max_parallel": 2,
+		}, body),
+	})
+
+	executionID, err := flowRuntime.Execute("test-account", "loop", nil)
+	require.NoError(t, err)
+	status := waitForStatus(t, store, executionID, "completed")
+
+	assert.Equal(t, 4, status.Results["count"])
+	results := status.Results["results"].([]interface{})
+	require.Len(t, results, 4)
+	for i, result := range results {
+		assert.Equal(t, float64(2*(i+1)), result.(map[string]interface{})["value"])
+		assert.Equal(t, i, result.(map[string]interface{})["index"])
+	}
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(2))
+}
+
+func TestForeachNode_SubFlow(t *testing.T) {
+	flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+		"loop": newLoopFlow(t, NewForeachNodeWrapper, map[string]interface{}{
+			"node_id": "each",
+			"items":   []interface{}{float64(5), float64(6)},
+			"flow_id": "double",
+			"output":  map[string]interface{}{"doubled": "result.value"},
+		}, nil),
+		"double": flowlib.NewFlow(newDoublingNode(nil)),
+	})
+
+	executionID, err := flowRuntime.Execute("test-account", "loop", nil)
+	require.NoError(t, err)
+	status := waitForStatus(t, store, executionID, "completed")
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"doubled": float64(10)},
+		map[string]interface{}{"doubled": float64(12)},
+	}, status.Results["results"])
+}
+
+func TestForeachNode_Errors(t *testing.T) {
+	failOnThree := func(item float64) error {
+		if item == 3 {
+			return fmt.Errorf("item %v is broken", item)
+		}
+		return nil
+	}
+	items := []interface{}{float64(1), float64(2), float64(3)}
+
+	t.Run("failure fails the node", func(t *testing.T) {
+		flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+			"loop": newLoopFlow(t, NewForeachNodeWrapper, map[string]interface{}{
+				"node_id": "each",
+				"items":   items,
+			}, newDoublingNode(failOnThree)),
+		})
+
+		executionID, err := flowRuntime.Execute("test-account", "loop", nil)
+		require.NoError(t, err)
+		status := waitForStatus(t, store, executionID, "failed")
+		assert.Contains(t, status.Error, "foreach item 2 failed")
+	})
+
+	t.Run("continue on error", func(t *testing.T) {
+		flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+			"loop": newLoopFlow(t, NewForeachNodeWrapper, map[string]interface{}{
+				"node_id":           "each",
+				"items":             items,
+				"continue_on_error": true,
+			}, newDoublingNode(failOnThree)),
+		})
+
+		executionID, err := flowRuntime.Execute("test-account", "loop", nil)
+		require.NoError(t, err)
+		status := waitForStatus(t, store, executionID, "completed")
+
+		results := status.Results["results"].([]interface{})
+		assert.Equal(t, float64(4), results[1].(map[string]interface{})["value"])
+		assert.Nil(t, results[2])
+		failed := status.Results["errors"].([]interface{})
+		require.Len(t, failed, 1)
+		assert.Equal(t, 2, failed[0].(map[string]interface{})["index"])
+		assert.Contains(t, failed[0].(map[string]interface{})["error"], "is broken")
+	})
+
+	t.Run("too many items", func(t *testing.T) {
+		flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+			"loop": newLoopFlow(t, NewForeachNodeWrapper, map[string]interface{}{
+				"node_id":   "each",
+				"items":     items,
+				"max_items": 2,
+			}, newDoublingNode(nil)),
+		})
+
+		executionID, err := flowRuntime.Execute("test-account", "loop", nil)
+		require.NoError(t, err)
+		status := waitForStatus(t, store, executionID, "failed")
+		assert.Contains(t, status.Error, "more than max_items 2")
+	})
+
+	t.Run("items must be an array", func(t *testing.T) {
+		flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+			"loop": newLoopFlow(t, NewForeachNodeWrapper, map[string]interface{}{
+				"node_id": "each",
+				"items":   "not an array",
+			}, newDoublingNode(nil)),
+		})
+
+		executionID, err := flowRuntime.Execute("test-account", "loop", nil)
+		require.NoError(t, err)
+		waitForStatus(t, store, executionID, "failed")
+	})
+}
+
+func TestForeachNode_BodyTakesExecutionPath(t *testing.T) {
+	body := newDoublingNode(func(item float64) error {
+		if item == 3 {
+			return fmt.Errorf("item %v is broken", item)
+		}
+		return nil
+	})
+	body.Next(ErrorAction, newRecordingNode("handle"))
+
+	var mu sync.Mutex
+	var undone []interface{}
+	undo := flowlib.NewNode(1, 0)
+	undo.SetParams(map[string]interface{}{"node_id": "undo_double"})
+	undo.SetPrepFn(func(shared any) (any, error) {
+		mu.Lock()
+		undone = append(undone, shared.(map[string]interface{})["input"])
+		mu.Unlock()
+		return nil, nil
+	})
+	body.Next(loader.CompensateAction, undo)
+
+	flow := newLoopFlow(t, NewForeachNodeWrapper, map[string]interface{}{
+		"node_id": "each",
+		"items":   []interface{}{float64(1), float64(2), float64(3)},
+	}, body)
+	flow.Start().Next(flowlib.DefaultAction, newFailingNode("notify", 1))
+
+	flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{"loop": flow})
+	executionID, err := flowRuntime.Execute("test-account", "loop", nil)
+	require.NoError(t, err)
+	waitForStatus(t, store, executionID, "failed")
+
+	// The failed item followed the error action of the body node
+	trace, err := flowRuntime.(ExecutionTracer).GetTrace("test-account", executionID)
+	require.NoError(t, err)
+	var visited []string
+	for _, visit := range trace {
+		visited = append(visited, visit.NodeID)
+	}
+	assert.Equal(t, []string{"each", "double", "double", "double", "handle", "notify"}, visited)
+	assert.Equal(t, ErrorAction, trace[3].Action)
+
+	// The completed body nodes were compensated once the execution failed
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"node_id": "double", "result": map[string]interface{}{"value": float64(4), "index": 1}},
+		map[string]interface{}{"node_id": "double", "result": map[string]interface{}{"value": float64(2), "index": 0}},
+	}, undone)
+}
+
+// newIncrementingNode builds a node that increments "count" in the shared state
+func newIncrementingNode(mu *sync.Mutex, runs *int) flowlib.Node {
+	node := flowlib.NewNode(1, 0)
+	node.SetParams(map[string]interface{}{"node_id": "increment"})
+	node.SetPrepFn(func(shared any) (any, error) {
+		sharedMap := shared.(map[string]interface{})
+		count, _ := sharedMap["count"].(float64)
+		sharedMap["count"] = count + 1
+		sharedMap["result"] = map[string]interface{}{"count": count + 1}
+
+		mu.Lock()
+		*runs++
+		mu.Unlock()
+		return nil, nil
+	})
+	return node
+}
+
+func TestWhileNode(t *testing.T) {
+	var mu sync.Mutex
+	runs := 0
+	flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+		"loop": newLoopFlow(t, NewWhileNodeWrapper, map[string]interface{}{
+			"node_id":        "repeat",
+			"condition":      "shared.count < 3",
+			"max_iterations": 10,
+			"input":          map[string]interface{}{"count": float64(0)},
+		}, newIncrementingNode(&mu, &runs)),
+	})
+
+	executionID, err := flowRuntime.Execute("test-account", "loop", nil)
+	require.NoError(t, err)
+	status := waitForStatus(t, store, executionID, "completed")
+
+	assert.Equal(t, 3, status.Results["iterations"])
+	assert.Equal(t, float64(3), status.Results["state"].(map[string]interface{})["count"])
+	mu.Lock()
+	assert.Equal(t, 3, runs)
+	mu.Unlock()
+}
+
+func TestWhileNode_MaxIterations(t *testing.T) {
+	var mu sync.Mutex
+	runs := 0
+	flowRuntime, store := newLoopTestRuntime(map[string]*flowlib.Flow{
+		"loop": newLoopFlow(t, NewWhileNodeWrapper, map[string]interface{}{
+			"node_id":        "repeat",
+			"condition":      "true",
+			"max_iterations": 5,
+		}, newIncrementingNode(&mu, &runs)),
+		"unbounded": newLoopFlow(t, NewWhileNodeWrapper, map[string]interface{}{
+			"node_id":   "repeat",
+			"condition": "true",
+		}, newIncrementingNode(&mu, &runs)),
+	})
+
+	executionID, err := flowRuntime.Execute("test-account", "loop", nil)
+	require.NoError(t, err)
+	status := waitForStatus(t, store, executionID, "failed")
+	assert.Contains(t, status.Error, "max_iterations 5")
+	mu.Lock()
+	assert.Equal(t, 5, runs)
+	mu.Unlock()
+
+	// Loops without a bound are rejected before their body runs
+	executionID, err = flowRuntime.Execute("test-account", "unbounded", nil)
+	require.NoError(t, err)
+	status = waitForStatus(t, store, executionID, "failed")
+	assert.Contains(t, status.Error, "max_iterations parameter is required")
+	mu.Lock()
+	assert.Equal(t, 5, runs)
+	mu.Unlock()
+}
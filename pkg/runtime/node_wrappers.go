@@ -1,8 +1,12 @@
 package runtime
 
 import (
+    "context"
     "encoding/json"
+    "errors"
     "fmt"
+    "maps"
+    "net/http"
     "strings"
     "time"
 
@@ -16,7 +20,32 @@ import (
 type NodeWrapper struct {
 	node flowlib.Node
 	exec func(input interface{}) (interface{}, error)
-	post func(shared, p, e interface{}) (flowlib.Action, error)
+	// execWithContext is used instead of exec by nodes whose work (network or
+	// database calls, sleeps) should be aborted when the execution is canceled
+	execWithContext func(ctx context.Context, input interface{}) (interface{}, error)
+	post            func(shared, p, e interface{}) (flowlib.Action, error)
+	// retry is the retry policy declared for the node, if any. Without one the
+	// exec function runs once.
+	retry *flowlib.RetryPolicy
+}
+
+// backgroundExec adapts a context-aware exec function for callers that have no context
+func backgroundExec(fn func(ctx context.Context, input interface{}) (interface{}, error)) func(input interface{}) (interface{}, error) {
+	return func(input interface{}) (interface{}, error) {
+		return fn(context.Background(), input)
+	}
+}
+
+// sleepWithContext pauses for d, returning ctx.Err() if ctx is canceled first
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // SetParams sets the parameters for the node
@@ -39,39 +68,45 @@ func (w *NodeWrapper) Successors() map[flowlib.Action]flowlib.Node {
 	return w.node.Successors()
 }
 
-// Run executes the node
-func (w *NodeWrapper) Run(shared interface{}) (flowlib.Action, error) {
-	// Create a custom implementation that calls our exec function
-	if w.exec != nil {
-		// Get the parameters
-		params := w.Params()
+// SetRetryPolicy sets the retry policy applied to the exec function
+func (w *NodeWrapper) SetRetryPolicy(p flowlib.RetryPolicy) {
+	w.retry = &p
+	if rc, ok := w.node.(flowlib.RetryConfigurable); ok {
+		rc.SetRetryPolicy(p)
+	}
+}
 
-		// Extract FlowContext for template expression evaluation if available
-		var flowContext *FlowContext
-		if sharedMap, ok := shared.(map[string]interface{}); ok {
-			if flowContextData, hasFlowContext := sharedMap["_flow_context"]; hasFlowContext {
-				if fcMap, ok := flowContextData.(map[string]interface{}); ok {
-					// Try to reconstruct FlowContext from the data
-					if executionID, ok := sharedMap["_execution"].(map[string]interface{})["execution_id"].(string); ok {
-						if flowID, ok := sharedMap["_execution"].(map[string]interface{})["flow_id"].(string); ok {
-							if accountID, ok := sharedMap["accountID"].(string); ok {
-								// We need access to the secret vault to recreate FlowContext
-								// For now, we'll try to find it in the shared context
-								if secretVault, ok := sharedMap["_secret_vault"]; ok {
-									if vault, ok := secretVault.(auth.SecretVault); ok {
-										flowContext = NewFlowContext(executionID, flowID, accountID, vault)
-										// Import existing data
-										if nodeResults, ok := fcMap["node_results"].(map[string]any); ok {
-											for k, v := range nodeResults {
-												flowContext.SetNodeResult(k, v)
-											}
+// flowContextFromShared rebuilds the FlowContext of an execution from the
+// data the runtime stores in the shared state, or returns nil if the
+// execution has neither a secret vault nor flow vars
+func flowContextFromShared(shared interface{}) *FlowContext {
+	var flowContext *FlowContext
+	if sharedMap, ok := shared.(map[string]interface{}); ok {
+		if flowContextData, hasFlowContext := sharedMap["_flow_context"]; hasFlowContext {
+			if fcMap, ok := flowContextData.(map[string]interface{}); ok {
+				// Try to reconstruct FlowContext from the data
+				if executionID, ok := sharedMap["_execution"].(map[string]interface{})["execution_id"].(string); ok {
+					if flowID, ok := sharedMap["_execution"].(map[string]interface{})["flow_id"].(string); ok {
+						if accountID, ok := sharedMap["accountID"].(string); ok {
+							// We need access to the secret vault to recreate FlowContext
+							// For now, we'll try to find it in the shared context
+							if secretVault, ok := sharedMap["_secret_vault"]; ok {
+								if vault, ok := secretVault.(auth.SecretVault); ok {
+									flowContext = NewFlowContext(executionID, flowID, accountID, vault)
+									// Import existing data
+									if nodeResults, ok := fcMap["node_results"].(map[string]any); ok {
+										for k, v := range nodeResults {
+											flowContext.SetNodeResult(k, v)
 										}
-										if sharedData, ok := fcMap["shared_data"].(map[string]any); ok {
-											for k, v := range sharedData {
-												flowContext.SetSharedData(k, v)
-											}
+									}
+									if sharedData, ok := fcMap["shared_data"].(map[string]any); ok {
+										for k, v := range sharedData {
+											flowContext.SetSharedData(k, v)
 										}
 									}
+									if vars, ok := sharedMap[varsKey].(map[string]any); ok {
+										flowContext.SetVars(vars)
+									}
 								}
 							}
 						}
@@ -79,21 +114,53 @@ func (w *NodeWrapper) Run(shared interface{}) (flowlib.Action, error) {
 				}
 			}
 		}
+		if flowContext == nil {
+			flowContext = varsFlowContext(sharedMap)
+		}
+	}
+	return flowContext
+}
+
+// varsFlowContext returns a FlowContext without secrets for executions of
+// flows that declare vars, or nil for flows without vars
+func varsFlowContext(sharedMap map[string]interface{}) *FlowContext {
+	vars, _ := sharedMap[varsKey].(map[string]interface{})
+	execution, _ := sharedMap["_execution"].(map[string]interface{})
+	if len(vars) == 0 || execution == nil {
+		return nil
+	}
+
+	executionID, _ := execution["execution_id"].(string)
+	flowID, _ := execution["flow_id"].(string)
+	accountID, _ := execution["account_id"].(string)
+	flowContext := NewFlowContext(executionID, flowID, accountID, nil)
+	flowContext.SetVars(vars)
+	return flowContext
+}
+
+// Run executes the node
+func (w *NodeWrapper) Run(shared interface{}) (flowlib.Action, error) {
+	return w.RunWithContext(context.Background(), shared)
+}
+
+// RunWithContext executes the node, passing ctx to its exec function
+func (w *NodeWrapper) RunWithContext(ctx context.Context, shared interface{}) (flowlib.Action, error) {
+	// Create a custom implementation that calls our exec function
+	if w.exec != nil || w.execWithContext != nil {
+		// Get the parameters
+		params := w.Params()
+
+		// Extract FlowContext for template expression evaluation if available
+		flowContext := flowContextFromShared(shared)
 
 		// Process node parameters through template engine if FlowContext is available
 		processedParams := params
 		if flowContext != nil {
 			// Update the flow context with current shared data for template evaluation
 			if sharedMap, ok := shared.(map[string]interface{}); ok {
-				// Log the complete shared state in readable JSON format (thread-safe)
-				sharedCopy := make(map[string]interface{})
-				for k, v := range sharedMap {
-					// Skip potentially problematic keys that might be modified concurrently
-					if k != "_split_results" && k != "mapper_results" {
-						sharedCopy[k] = v
-					}
-				}
-				sharedJSON, _ := json.MarshalIndent(sharedCopy, "", "  ")
+				// Log the complete shared state in readable JSON format. Parallel
+				// branches run on their own scope, so no other node writes to it.
+				sharedJSON, _ := json.MarshalIndent(sharedMap, "", "  ")
 				fmt.Printf("\n🔄 [NodeWrapper] PRE-EXECUTION SHARED STATE:\n%s\n", string(sharedJSON))
 
 				keys := make([]string, 0, len(sharedMap))
@@ -114,7 +181,7 @@ func (w *NodeWrapper) Run(shared interface{}) (flowlib.Action, error) {
 
 				for key, value := range sharedMap {
 					// Skip internal flow context keys
-					if !strings.HasPrefix(key, "_") && key != "accountID" {
+					if isPublicKey(key) {
 						flowContext.SetSharedData(key, value)
 					}
 				}
@@ -139,6 +206,8 @@ func (w *NodeWrapper) Run(shared interface{}) (flowlib.Action, error) {
 			}
 		}
 
+		recordNodeParams(ctx, params, processedParams)
+
 		// For direct node usage, shared is typically an empty map or only contains result storage
 		// For flow execution, shared contains meaningful input data
         var combinedInput map[string]interface{}
@@ -164,11 +233,39 @@ func (w *NodeWrapper) Run(shared interface{}) (flowlib.Action, error) {
             }
         }
 
-        // Execute the function
-        result, err := w.exec(combinedInput)
+        // Execute the function unless the execution was canceled meanwhile
+        if err := ctx.Err(); err != nil {
+            return "", err
+        }
+        execute := func() (interface{}, error) {
+            if w.execWithContext != nil {
+                return w.execWithContext(ctx, combinedInput)
+            }
+            return w.exec(combinedInput)
+        }
+        if output, simulated := dryRunOutput(ctx, params, processedParams); simulated {
+            // Dry runs keep templates and routing but skip the side effect
+            execute = func() (interface{}, error) {
+                return output, nil
+            }
+        }
+        var result interface{}
+        var err error
+        if w.retry != nil {
+            result, err = w.retry.Do(ctx, w, execute)
+        } else {
+            result, err = execute()
+        }
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) {
+			// The response is retried like an error, but is the result of
+			// the node once the retries are used up
+			result, err = statusErr.response, nil
+		}
 		if err != nil {
 			return "", err
 		}
+		recordNodeOutput(ctx, result)
 
 		// Store the result in the shared context if it's a map
         if sharedMap, ok := shared.(map[string]interface{}); ok {
@@ -195,6 +292,12 @@ func (w *NodeWrapper) Run(shared interface{}) (flowlib.Action, error) {
 			// Also store in the generic "result" key for backward compatibility
 			sharedMap["result"] = result
 
+			// Keep the result under the node ID, where results of parallel
+			// branches do not overwrite each other
+			if nodeID, ok := processedParams["node_id"].(string); ok && nodeID != "" {
+				setNodeOutput(sharedMap, nodeID, result)
+			}
+
             // SPECIAL HANDLING FOR MAPPER RESULTS
 			// Check if this result looks like a mapper result and add it to the SplitNode collector
 			if resultMap, ok := result.(map[string]interface{}); ok {
@@ -234,15 +337,8 @@ func (w *NodeWrapper) Run(shared interface{}) (flowlib.Action, error) {
 			resultJSON, _ := json.MarshalIndent(result, "", "  ")
 			fmt.Printf("📊 [NodeWrapper] STORED RESULT:\n%s\n", string(resultJSON))
 
-			// Log the updated shared state after storing the result (thread-safe)
-			sharedCopy := make(map[string]interface{})
-			for k, v := range sharedMap {
-				// Skip potentially problematic keys that might be modified concurrently
-				if k != "_split_results" && k != "mapper_results" {
-					sharedCopy[k] = v
-				}
-			}
-			sharedJSON, _ := json.MarshalIndent(sharedCopy, "", "  ")
+			// Log the updated shared state after storing the result
+			sharedJSON, _ := json.MarshalIndent(sharedMap, "", "  ")
 			fmt.Printf("\n🔄 [NodeWrapper] POST-EXECUTION SHARED STATE:\n%s\n", string(sharedJSON))
 		}
 
@@ -256,7 +352,23 @@ func (w *NodeWrapper) Run(shared interface{}) (flowlib.Action, error) {
 	}
 
 	// Fall back to the wrapped node's Run method
-	return w.node.Run(shared)
+	return flowlib.RunNode(ctx, w.node, shared)
+}
+
+// httpStatusError reports a response whose status is worth retrying, so that
+// the rate_limit and server_error retry classes match it
+type httpStatusError struct {
+	statusCode int
+	response   map[string]interface{}
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP request failed with status %d", e.statusCode)
+}
+
+// StatusCode returns the HTTP status of the response
+func (e *httpStatusError) StatusCode() int {
+	return e.statusCode
 }
 
 // NewHTTPRequestNodeWrapper creates a new HTTP request node wrapper
@@ -270,7 +382,7 @@ func NewHTTPRequestNodeWrapper(params map[string]interface{}) (flowlib.Node, err
 	// Create the wrapper
 	wrapper := &NodeWrapper{
 		node: baseNode,
-		exec: func(input interface{}) (interface{}, error) {
+		execWithContext: func(ctx context.Context, input interface{}) (interface{}, error) {
 			// Handle both old format (direct params) and new format (combined input)
 			var params map[string]interface{}
 
@@ -443,7 +555,7 @@ func NewHTTPRequestNodeWrapper(params map[string]interface{}) (flowlib.Node, err
 			}
 
 			// Execute request
-			resp, err := httpClient.Do(httpRequest)
+			resp, err := httpClient.DoWithContext(ctx, httpRequest)
 			if err != nil {
 				return nil, err
 			}
@@ -465,6 +577,10 @@ func NewHTTPRequestNodeWrapper(params map[string]interface{}) (flowlib.Node, err
 				}
 			}
 
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+				return nil, &httpStatusError{statusCode: resp.StatusCode, response: result}
+			}
+
 			return result, nil
 		},
 		post: func(shared, p, e interface{}) (flowlib.Action, error) {
@@ -489,6 +605,7 @@ func NewHTTPRequestNodeWrapper(params map[string]interface{}) (flowlib.Node, err
 			return flowlib.DefaultAction, nil
 		},
 	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
 
 	// Set the parameters
 	wrapper.SetParams(params)
@@ -500,11 +617,12 @@ func NewHTTPRequestNodeWrapper(params map[string]interface{}) (flowlib.Node, err
 func NewDelayNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 	// Create the base node
 	baseNode := flowlib.NewNode(1, 0)
+	declared := params
 
 	// Create the wrapper
 	wrapper := &NodeWrapper{
 		node: baseNode,
-		exec: func(input interface{}) (interface{}, error) {
+		execWithContext: func(ctx context.Context, input interface{}) (interface{}, error) {
 			// Handle both old format (direct params) and new format (combined input)
 			var params map[string]interface{}
 
@@ -535,12 +653,12 @@ func NewDelayNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 				return nil, fmt.Errorf("invalid duration: %w", err)
 			}
 
-			// Wait
-			time.Sleep(duration)
-
-			return input, nil
+			// Wait, returning early if the execution is canceled
+			result := delayResult(input)
+			return waitUntil(ctx, params, time.Now().Add(duration), result, persistedDelayResult(declared, result))
 		},
 	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
 
 	// Set the parameters
 	wrapper.SetParams(params)
@@ -548,6 +666,43 @@ func NewDelayNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 	return wrapper, nil
 }
 
+// delayResult returns the params and input a delay node passes on. The input
+// is copied, as without a prepared input it is the shared state itself, which
+// the result is then stored in.
+func delayResult(input interface{}) map[string]interface{} {
+	combinedInput, _ := input.(map[string]interface{})
+	result := make(map[string]interface{}, len(combinedInput))
+	for key, value := range combinedInput {
+		if inputMap, ok := value.(map[string]interface{}); ok && key == "input" {
+			value = maps.Clone(inputMap)
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// persistedDelayResult returns the copy of a delay node result that a parked
+// execution persists. It keeps the shape of the result, but its params are
+// redacted like in traces and its input leaves out the runtime-internal
+// keys, which hold secrets and are rebuilt on resume.
+func persistedDelayResult(declared, result map[string]interface{}) map[string]interface{} {
+	persisted := make(map[string]interface{}, len(result))
+	for key, value := range result {
+		switch key {
+		case "params":
+			if params, ok := value.(map[string]interface{}); ok {
+				value = redactParams(declared, params)
+			}
+		case "input":
+			if input, ok := value.(map[string]interface{}); ok {
+				value = publicSharedState(input)
+			}
+		}
+		persisted[key] = value
+	}
+	return persisted
+}
+
 // NewConditionNodeWrapper creates a new condition node wrapper
 func NewConditionNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 	// Create the base node
@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -120,12 +121,12 @@ func (pm *PostgresManager) ensureTableExists() error {
 }
 
 // Get retrieves an item from PostgreSQL
-func (pm *PostgresManager) Get(key string) (interface{}, error) {
+func (pm *PostgresManager) Get(ctx context.Context, key string) (interface{}, error) {
 	// Get item
 	var valueStr string
 	var ttl sql.NullTime
 
-	err := pm.db.QueryRow(fmt.Sprintf(`
+	err := pm.db.QueryRowContext(ctx, fmt.Sprintf(`
 		SELECT value, ttl FROM %s WHERE key = $1 AND (ttl IS NULL OR ttl > NOW())
 	`, pm.tableName), key).Scan(&valueStr, &ttl)
 
@@ -146,7 +147,7 @@ func (pm *PostgresManager) Get(key string) (interface{}, error) {
 }
 
 // Set stores an item in PostgreSQL
-func (pm *PostgresManager) Set(key string, value interface{}, ttl time.Duration) error {
+func (pm *PostgresManager) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	// Marshal value
 	valueBytes, err := json.Marshal(value)
 	if err != nil {
@@ -162,7 +163,7 @@ func (pm *PostgresManager) Set(key string, value interface{}, ttl time.Duration)
 	}
 
 	// Upsert item
-	_, err = pm.db.Exec(fmt.Sprintf(`
+	_, err = pm.db.ExecContext(ctx, fmt.Sprintf(`
 		INSERT INTO %s (key, value, ttl)
 		VALUES ($1, $2, $3)
 		ON CONFLICT (key) DO UPDATE
@@ -177,9 +178,9 @@ func (pm *PostgresManager) Set(key string, value interface{}, ttl time.Duration)
 }
 
 // Delete removes an item from PostgreSQL
-func (pm *PostgresManager) Delete(key string) (bool, error) {
+func (pm *PostgresManager) Delete(ctx context.Context, key string) (bool, error) {
 	// Delete item
-	result, err := pm.db.Exec(fmt.Sprintf(`
+	result, err := pm.db.ExecContext(ctx, fmt.Sprintf(`
 		DELETE FROM %s WHERE key = $1
 	`, pm.tableName), key)
 
@@ -197,9 +198,9 @@ func (pm *PostgresManager) Delete(key string) (bool, error) {
 }
 
 // List returns all keys in PostgreSQL
-func (pm *PostgresManager) List() ([]string, error) {
+func (pm *PostgresManager) List(ctx context.Context) ([]string, error) {
 	// List keys
-	rows, err := pm.db.Query(fmt.Sprintf(`
+	rows, err := pm.db.QueryContext(ctx, fmt.Sprintf(`
 		SELECT key FROM %s WHERE ttl IS NULL OR ttl > NOW()
 	`, pm.tableName))
 
@@ -226,7 +227,7 @@ func (pm *PostgresManager) List() ([]string, error) {
 }
 
 // Query performs a query on PostgreSQL
-func (pm *PostgresManager) Query(filter map[string]interface{}, sortField string, limit int) ([]map[string]interface{}, error) {
+func (pm *PostgresManager) Query(ctx context.Context, filter map[string]interface{}, sortField string, limit int) ([]map[string]interface{}, error) {
 	// Build query
 	query := fmt.Sprintf(`
 		SELECT key, value FROM %s WHERE (ttl IS NULL OR ttl > NOW())
@@ -297,7 +298,7 @@ func (pm *PostgresManager) Query(filter map[string]interface{}, sortField string
 	}
 
 	// Execute query
-	rows, err := pm.db.Query(query, args...)
+	rows, err := pm.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -332,13 +333,13 @@ func (pm *PostgresManager) Query(filter map[string]interface{}, sortField string
 }
 
 // ExecuteSQL executes a SQL query
-func (pm *PostgresManager) ExecuteSQL(query string, args []interface{}) (interface{}, error) {
+func (pm *PostgresManager) ExecuteSQL(ctx context.Context, query string, args []interface{}) (interface{}, error) {
 	// Check if query is a SELECT
 	isSelect := strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "SELECT")
 
 	if isSelect {
 		// Execute SELECT query
-		rows, err := pm.db.Query(query, args...)
+		rows, err := pm.db.QueryContext(ctx, query, args...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute query: %w", err)
 		}
@@ -398,7 +399,7 @@ func (pm *PostgresManager) ExecuteSQL(query string, args []interface{}) (interfa
 		return results, nil
 	} else {
 		// Execute non-SELECT query
-		result, err := pm.db.Exec(query, args...)
+		result, err := pm.db.ExecContext(ctx, query, args...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute query: %w", err)
 		}
@@ -416,8 +417,8 @@ func (pm *PostgresManager) ExecuteSQL(query string, args []interface{}) (interfa
 }
 
 // BeginTransaction begins a transaction
-func (pm *PostgresManager) BeginTransaction() (*sql.Tx, error) {
-	return pm.db.Begin()
+func (pm *PostgresManager) BeginTransaction(ctx context.Context) (*sql.Tx, error) {
+	return pm.db.BeginTx(ctx, nil)
 }
 
 // NewPostgresNodeWrapper creates a new PostgreSQL node wrapper
@@ -439,7 +440,7 @@ func NewPostgresNodeWrapper(initParams map[string]interface{}) (flowlib.Node, er
 	// Create the wrapper
 	wrapper := &NodeWrapper{
 		node: baseNode,
-		exec: func(input interface{}) (interface{}, error) {
+		execWithContext: func(ctx context.Context, input interface{}) (interface{}, error) {
 			// Handle both old format (direct params) and new format (combined input)
 			var params map[string]interface{}
 			
@@ -503,7 +504,7 @@ func NewPostgresNodeWrapper(initParams map[string]interface{}) (flowlib.Node, er
 				}
 
 				// Get item
-				value, err := manager.Get(key)
+				value, err := manager.Get(ctx, key)
 				if err != nil {
 					return nil, err
 				}
@@ -534,7 +535,7 @@ func NewPostgresNodeWrapper(initParams map[string]interface{}) (flowlib.Node, er
 				}
 
 				// Set item
-				if err := manager.Set(key, value, ttl); err != nil {
+				if err := manager.Set(ctx, key, value, ttl); err != nil {
 					return nil, err
 				}
 
@@ -548,7 +549,7 @@ func NewPostgresNodeWrapper(initParams map[string]interface{}) (flowlib.Node, er
 				}
 
 				// Delete item
-				exists, err := manager.Delete(key)
+				exists, err := manager.Delete(ctx, key)
 				if err != nil {
 					return nil, err
 				}
@@ -560,7 +561,7 @@ func NewPostgresNodeWrapper(initParams map[string]interface{}) (flowlib.Node, er
 
 			case "list":
 				// List keys
-				keys, err := manager.List()
+				keys, err := manager.List(ctx)
 				if err != nil {
 					return nil, err
 				}
@@ -589,7 +590,7 @@ func NewPostgresNodeWrapper(initParams map[string]interface{}) (flowlib.Node, er
 				}
 
 				// Query items
-				results, err := manager.Query(filter, sortField, limit)
+				results, err := manager.Query(ctx, filter, sortField, limit)
 				if err != nil {
 					return nil, err
 				}
@@ -610,7 +611,7 @@ func NewPostgresNodeWrapper(initParams map[string]interface{}) (flowlib.Node, er
 				}
 
 				// Execute query
-				result, err := manager.ExecuteSQL(query, args)
+				result, err := manager.ExecuteSQL(ctx, query, args)
 				if err != nil {
 					return nil, err
 				}
@@ -625,7 +626,7 @@ func NewPostgresNodeWrapper(initParams map[string]interface{}) (flowlib.Node, er
 				}
 
 				// Begin transaction
-				tx, err := manager.BeginTransaction()
+				tx, err := manager.BeginTransaction(ctx)
 				if err != nil {
 					return nil, fmt.Errorf("failed to begin transaction: %w", err)
 				}
@@ -664,7 +665,7 @@ func NewPostgresNodeWrapper(initParams map[string]interface{}) (flowlib.Node, er
 
 					if isSelect {
 						// Execute SELECT query
-						rows, err := tx.Query(query, args...)
+						rows, err := tx.QueryContext(ctx, query, args...)
 						if err != nil {
 							return nil, fmt.Errorf("failed to execute query: %w", err)
 						}
@@ -727,7 +728,7 @@ func NewPostgresNodeWrapper(initParams map[string]interface{}) (flowlib.Node, er
 						results[i] = stmtResults
 					} else {
 						// Execute non-SELECT query
-						result, err := tx.Exec(query, args...)
+						result, err := tx.ExecContext(ctx, query, args...)
 						if err != nil {
 							return nil, fmt.Errorf("failed to execute query: %w", err)
 						}
@@ -758,6 +759,7 @@ func NewPostgresNodeWrapper(initParams map[string]interface{}) (flowlib.Node, er
 			}
 		},
 	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
 
 	// Set the parameters
 	wrapper.SetParams(initParams)
@@ -0,0 +1,115 @@
+package runtime
+
+import (
+	"math"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/loader"
+)
+
+// maxRunningProgress caps the estimated progress of an execution until it
+// actually finishes
+const maxRunningProgress = 99.0
+
+// startNode records the node an execution is about to run, unless
+// completeNode already did
+func (r *flowRuntime) startNode(execCtx *executionContext, node flowlib.Node) {
+	nodeID := nodeIDOf(node)
+	execCtx.mu.RLock()
+	current := execCtx.status.CurrentNode
+	execCtx.mu.RUnlock()
+	if current == nodeID {
+		return
+	}
+	r.updateProgress(execCtx, func(status *ExecutionStatus) {
+		status.CurrentNode = nodeID
+	})
+}
+
+// completeNode records a finished node and estimates the progress from the
+// nodes still reachable from next, the node that runs after it. next becomes
+// the current node in the same update.
+func (r *flowRuntime) completeNode(execCtx *executionContext, node, next flowlib.Node) {
+	r.updateProgress(execCtx, func(status *ExecutionStatus) {
+		execCtx.completedNodes[node] = true
+		progress := estimateProgress(execCtx.completedNodes, next)
+		// Loops and resumed executions must not move the progress backwards
+		status.Progress = math.Max(status.Progress, progress)
+		if next != nil {
+			status.CurrentNode = nodeIDOf(next)
+		}
+	})
+}
+
+// updateProgress applies a change to the status of a running execution,
+// persists it and pushes it to the subscribers of the execution
+func (r *flowRuntime) updateProgress(execCtx *executionContext, update func(status *ExecutionStatus)) {
+	// saveMu keeps the snapshot and its save together, so that a final
+	// status saved meanwhile is not overwritten, without holding mu while
+	// the store is written
+	execCtx.saveMu.Lock()
+	defer execCtx.saveMu.Unlock()
+
+	execCtx.mu.Lock()
+	if isFinished(execCtx.status.Status) {
+		execCtx.mu.Unlock()
+		return
+	}
+	update(&execCtx.status)
+	status := execCtx.status
+	execCtx.mu.Unlock()
+
+	r.saveStatus(status)
+	r.publishStatus(status)
+}
+
+// publishStatus sends a status change to the subscribers of an execution
+func (r *flowRuntime) publishStatus(status ExecutionStatus) {
+	r.notifySubscribers(status.ID, ExecutionLog{
+		Timestamp: time.Now(),
+		NodeID:    status.CurrentNode,
+		Level:     "status",
+		Message:   "Execution status changed",
+		Status:    &status,
+	})
+}
+
+// estimateProgress returns the share of the completed nodes among those
+// nodes plus the nodes that may still run after next. Branches that will not
+// be taken count as remaining, so the estimate is low rather than high.
+func estimateProgress(completed map[flowlib.Node]bool, next flowlib.Node) float64 {
+	remaining := 0
+	visited := make(map[flowlib.Node]bool)
+	queue := []flowlib.Node{next}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if node == nil || visited[node] {
+			continue
+		}
+		visited[node] = true
+
+		if !completed[node] {
+			remaining++
+		}
+		for action, successor := range node.Successors() {
+			// Compensations only run once the execution failed, and loop
+			// bodies and split branches run inside their loop or split node
+			if action == loader.CompensateAction || action == BodyAction {
+				continue
+			}
+			if _, isSplit := node.(*splitNode); isSplit && loader.IsSplitBranch(string(action)) {
+				continue
+			}
+			queue = append(queue, successor)
+		}
+	}
+
+	total := len(completed) + remaining
+	if total == 0 {
+		return 0
+	}
+	progress := float64(len(completed)) * 100 / float64(total)
+	return math.Min(math.Round(progress*10)/10, maxRunningProgress)
+}
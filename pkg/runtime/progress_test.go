@@ -0,0 +1,103 @@
+package runtime
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+func TestEstimateProgress(t *testing.T) {
+	first := flowlib.NewNode(1, 0)
+	left := flowlib.NewNode(1, 0)
+	right := flowlib.NewNode(1, 0)
+	last := flowlib.NewNode(1, 0)
+	first.Next("left", left)
+	first.Next("right", right)
+	left.Next(flowlib.DefaultAction, last)
+	right.Next(flowlib.DefaultAction, last)
+
+	assert.Equal(t, 0.0, estimateProgress(map[flowlib.Node]bool{}, first))
+
+	// The branch that is not taken no longer counts once the other one ran
+	completed := map[flowlib.Node]bool{first: true}
+	assert.Equal(t, 33.3, estimateProgress(completed, left))
+	completed[left] = true
+	assert.Equal(t, 66.7, estimateProgress(completed, last))
+
+	// Finished graphs stay below 100% until the execution completes
+	completed[last] = true
+	assert.Equal(t, maxRunningProgress, estimateProgress(completed, nil))
+}
+
+func TestFlowRuntime_PublishesProgress(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+
+	// The gate holds the execution until the test subscribed
+	release := make(chan struct{})
+	gate := flowlib.NewNode(1, 0)
+	gate.SetParams(map[string]interface{}{"node_id": "gate"})
+	gate.SetPrepFn(func(shared any) (any, error) {
+		<-release
+		return nil, nil
+	})
+	counting := newCountingFlow(&visited, &mu)
+	gate.Next(flowlib.DefaultAction, counting.Start())
+
+	flowDef := &Flow{ID: "counting-flow", YAML: "counting"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "counting-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flowlib.NewFlow(gate), nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store)
+
+	executionID, err := flowRuntime.Execute("test-account", "counting-flow", nil)
+	require.NoError(t, err)
+	logs, err := flowRuntime.SubscribeToLogs(executionID)
+	require.NoError(t, err)
+	close(release)
+
+	var updates []ExecutionStatus
+	timeout := time.After(2 * time.Second)
+	for done := false; !done; {
+		select {
+		case log, ok := <-logs:
+			if !ok {
+				done = true
+				break
+			}
+			if log.Status != nil {
+				assert.Equal(t, "status", log.Level)
+				updates = append(updates, *log.Status)
+			}
+		case <-timeout:
+			t.Fatal("execution did not finish")
+		}
+	}
+
+	var started []string
+	previous := 0.0
+	for _, update := range updates {
+		if update.Status == "running" && (len(started) == 0 || started[len(started)-1] != update.CurrentNode) {
+			started = append(started, update.CurrentNode)
+		}
+		assert.GreaterOrEqual(t, update.Progress, previous, "progress must not move backwards")
+		previous = update.Progress
+	}
+	assert.Equal(t, []string{"gate", "first", "second", "third"}, started)
+
+	final := updates[len(updates)-1]
+	assert.Equal(t, "completed", final.Status)
+	assert.Equal(t, 100.0, final.Progress)
+	assert.Empty(t, final.CurrentNode)
+
+	// The store sees the same progress as the subscribers
+	stored := waitForStatus(t, store, executionID, "completed")
+	assert.Equal(t, 100.0, stored.Progress)
+}
@@ -0,0 +1,191 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisWorkQueueOptions configures a RedisWorkQueue
+type RedisWorkQueueOptions struct {
+	// Stream is the Redis stream holding the queued executions.
+	// Defaults to "flowrunner:executions".
+	Stream string
+
+	// Group is the consumer group shared by the workers.
+	// Defaults to "flowrunner-workers".
+	Group string
+
+	// LeaseTimeout is how long a lease lasts without heartbeats.
+	// Defaults to 30 seconds.
+	LeaseTimeout time.Duration
+
+	// Block is how long Claim waits for a new execution. Defaults to 2 seconds.
+	Block time.Duration
+}
+
+// RedisWorkQueue is a WorkQueue backed by a Redis stream. Leases are the
+// pending entries of a consumer group: heartbeats reset their idle time, and
+// entries idle for longer than the lease timeout are claimed by other workers.
+type RedisWorkQueue struct {
+	client  redis.UniversalClient
+	options RedisWorkQueueOptions
+}
+
+// renewLeaseScript resets the idle time of a pending entry, provided the
+// worker renewing it still owns it
+var renewLeaseScript = redis.NewScript(`
+local pending = redis.call('XPENDING', KEYS[1], ARGV[1], ARGV[3], ARGV[3], 1)
+if #pending == 0 or pending[1][2] ~= ARGV[2] then
+	return 0
+end
+redis.call('XCLAIM', KEYS[1], ARGV[1], ARGV[2], 0, ARGV[3], 'JUSTID')
+return 1
+`)
+
+// NewRedisWorkQueue creates a work queue on the given Redis client, creating
+// the stream and consumer group if needed
+func NewRedisWorkQueue(ctx context.Context, client redis.UniversalClient, options RedisWorkQueueOptions) (*RedisWorkQueue, error) {
+	if options.Stream == "" {
+		options.Stream = "flowrunner:executions"
+	}
+	if options.Group == "" {
+		options.Group = "flowrunner-workers"
+	}
+	if options.LeaseTimeout <= 0 {
+		options.LeaseTimeout = 30 * time.Second
+	}
+	if options.Block <= 0 {
+		options.Block = 2 * time.Second
+	}
+
+	err := client.XGroupCreateMkStream(ctx, options.Stream, options.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	return &RedisWorkQueue{client: client, options: options}, nil
+}
+
+// Enqueue implements WorkQueue
+func (q *RedisWorkQueue) Enqueue(ctx context.Context, item WorkItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal work item: %w", err)
+	}
+	err = q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.options.Stream,
+		Values: map[string]interface{}{"item": string(data)},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to add work item: %w", err)
+	}
+	return nil
+}
+
+// Claim implements WorkQueue
+func (q *RedisWorkQueue) Claim(ctx context.Context, workerID string) (*Lease, error) {
+	lease, err := q.claimExpired(ctx, workerID)
+	if lease != nil || err != nil {
+		return lease, err
+	}
+
+	streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    q.options.Group,
+		Consumer: workerID,
+		Streams:  []string{q.options.Stream, ">"},
+		Count:    1,
+		Block:    q.options.Block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read work queue: %w", err)
+	}
+	for _, stream := range streams {
+		for _, message := range stream.Messages {
+			return q.newLease(ctx, workerID, message, false)
+		}
+	}
+	return nil, nil
+}
+
+// claimExpired takes over the oldest entry whose lease expired
+func (q *RedisWorkQueue) claimExpired(ctx context.Context, workerID string) (*Lease, error) {
+	pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: q.options.Stream,
+		Group:  q.options.Group,
+		Idle:   q.options.LeaseTimeout,
+		Start:  "-",
+		End:    "+",
+		Count:  1,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired leases: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	// The idle time is checked again, another worker may have been faster
+	messages, err := q.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   q.options.Stream,
+		Group:    q.options.Group,
+		Consumer: workerID,
+		MinIdle:  q.options.LeaseTimeout,
+		Messages: []string{pending[0].ID},
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim expired lease: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	return q.newLease(ctx, workerID, messages[0], true)
+}
+
+// newLease decodes a claimed stream entry. Entries that cannot be decoded are
+// removed, as no worker could run them.
+func (q *RedisWorkQueue) newLease(ctx context.Context, workerID string, message redis.XMessage, redelivered bool) (*Lease, error) {
+	lease := &Lease{ID: message.ID, WorkerID: workerID, Redelivered: redelivered}
+
+	data, _ := message.Values["item"].(string)
+	if err := json.Unmarshal([]byte(data), &lease.Item); err != nil {
+		if completeErr := q.Complete(ctx, lease); completeErr != nil {
+			return nil, completeErr
+		}
+		return nil, fmt.Errorf("failed to unmarshal work item %s: %w", message.ID, err)
+	}
+	return lease, nil
+}
+
+// Heartbeat implements WorkQueue
+func (q *RedisWorkQueue) Heartbeat(ctx context.Context, lease *Lease) error {
+	renewed, err := renewLeaseScript.Run(ctx, q.client, []string{q.options.Stream}, q.options.Group, lease.WorkerID, lease.ID).Int()
+	if err != nil {
+		return fmt.Errorf("failed to renew lease: %w", err)
+	}
+	if renewed == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Complete implements WorkQueue
+func (q *RedisWorkQueue) Complete(ctx context.Context, lease *Lease) error {
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, q.options.Stream, q.options.Group, lease.ID)
+		pipe.XDel(ctx, q.options.Stream, lease.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete work item: %w", err)
+	}
+	return nil
+}
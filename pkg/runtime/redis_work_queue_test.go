@@ -0,0 +1,83 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWorkQueue(t *testing.T, leaseTimeout time.Duration) *RedisWorkQueue {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	queue, err := NewRedisWorkQueue(context.Background(), client, RedisWorkQueueOptions{
+		LeaseTimeout: leaseTimeout,
+		Block:        10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	return queue
+}
+
+func TestRedisWorkQueue_ClaimAndComplete(t *testing.T) {
+	ctx := context.Background()
+	queue := newTestWorkQueue(t, time.Minute)
+
+	lease, err := queue.Claim(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.Nil(t, lease)
+
+	item := WorkItem{ExecutionID: "exec-1", AccountID: "acct", FlowID: "flow", Input: map[string]interface{}{"x": "y"}}
+	require.NoError(t, queue.Enqueue(ctx, item))
+
+	lease, err = queue.Claim(ctx, "worker-1")
+	require.NoError(t, err)
+	require.NotNil(t, lease)
+	assert.Equal(t, item, lease.Item)
+	assert.Equal(t, "worker-1", lease.WorkerID)
+	assert.False(t, lease.Redelivered)
+
+	// A leased execution is not handed to other workers
+	other, err := queue.Claim(ctx, "worker-2")
+	require.NoError(t, err)
+	assert.Nil(t, other)
+
+	require.NoError(t, queue.Heartbeat(ctx, lease))
+	require.NoError(t, queue.Complete(ctx, lease))
+	assert.ErrorIs(t, queue.Heartbeat(ctx, lease), ErrLeaseLost)
+}
+
+func TestRedisWorkQueue_RedeliversExpiredLeases(t *testing.T) {
+	ctx := context.Background()
+	queue := newTestWorkQueue(t, 100*time.Millisecond)
+
+	require.NoError(t, queue.Enqueue(ctx, WorkItem{ExecutionID: "exec-1"}))
+	lease, err := queue.Claim(ctx, "worker-1")
+	require.NoError(t, err)
+	require.NotNil(t, lease)
+
+	// Heartbeats keep the lease
+	for i := 0; i < 3; i++ {
+		time.Sleep(60 * time.Millisecond)
+		require.NoError(t, queue.Heartbeat(ctx, lease))
+	}
+	other, err := queue.Claim(ctx, "worker-2")
+	require.NoError(t, err)
+	assert.Nil(t, other)
+
+	// Without them the execution moves to another worker
+	time.Sleep(150 * time.Millisecond)
+	other, err = queue.Claim(ctx, "worker-2")
+	require.NoError(t, err)
+	require.NotNil(t, other)
+	assert.Equal(t, "exec-1", other.Item.ExecutionID)
+	assert.True(t, other.Redelivered)
+
+	assert.ErrorIs(t, queue.Heartbeat(ctx, lease), ErrLeaseLost)
+	assert.NoError(t, queue.Heartbeat(ctx, other))
+}
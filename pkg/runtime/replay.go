@@ -0,0 +1,167 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ReplayOfExecutionIDKey is the ExecutionStatus.Metadata key that links a
+// replayed execution to the execution it replays
+const ReplayOfExecutionIDKey = "replay_of_execution_id"
+
+// ReplayFromNodeKey is the ExecutionStatus.Metadata key that records the node
+// a replayed execution started at
+const ReplayFromNodeKey = "replay_from_node"
+
+// ErrCheckpointNotFound is returned when an execution has no checkpoint to
+// replay from
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// ExecutionReplayer is implemented by runtimes that can run an execution
+// again from one of its nodes
+type ExecutionReplayer interface {
+	// Replay starts a new execution of the current flow definition at
+	// options.FromNode, with the shared state the original execution had
+	// before that node. It returns the ID of the new execution.
+	Replay(accountID, executionID string, options ReplayOptions) (string, error)
+}
+
+// ReplayOptions controls how an execution is replayed
+type ReplayOptions struct {
+	// FromNode is the ID of the node the replay starts at
+	FromNode string
+
+	// State is a JSON merge patch (RFC 7386) applied to the recorded shared
+	// state: its values replace the recorded ones, objects are merged and
+	// null values remove keys
+	State map[string]interface{}
+}
+
+// Replay implements ExecutionReplayer
+func (r *flowRuntime) Replay(accountID, executionID string, options ReplayOptions) (string, error) {
+	store, ok := r.executionStore.(CheckpointStore)
+	if !ok {
+		return "", fmt.Errorf("execution store does not support checkpoints")
+	}
+
+	checkpoints, err := store.GetExecutionCheckpoints(executionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get checkpoints: %w", err)
+	}
+	if len(checkpoints) == 0 || checkpoints[0].AccountID != accountID {
+		return "", fmt.Errorf("%w: execution %s has no checkpoints", ErrCheckpointNotFound, executionID)
+	}
+
+	// A node that ran several times is replayed from its last visit
+	var original *ExecutionCheckpoint
+	for i := range checkpoints {
+		if checkpoints[i].NodeID == options.FromNode {
+			original = &checkpoints[i]
+		}
+	}
+	if original == nil {
+		return "", fmt.Errorf("%w: node %q did not run in execution %s", ErrCheckpointNotFound, options.FromNode, executionID)
+	}
+
+	// The flow is loaded again, so that fixes made since the original run apply
+	flow, settings, err := r.loadFlow(accountID, original.FlowID, "")
+	if err != nil {
+		return "", err
+	}
+	node := findNode(flow.Start(), options.FromNode)
+	if node == nil {
+		return "", fmt.Errorf("node %q not found in flow %s", options.FromNode, original.FlowID)
+	}
+
+	shared, err := replayState(original.Shared, options.State)
+	if err != nil {
+		return "", err
+	}
+
+	metadata := map[string]string{
+		ReplayOfExecutionIDKey: executionID,
+		ReplayFromNodeKey:      options.FromNode,
+	}
+	if originalStatus, err := r.executionStore.GetExecution(executionID); err == nil {
+		// The replay runs in the environment of the original execution
+		if environment := environmentOf(originalStatus); environment != "" {
+			metadata[EnvironmentKey] = environment
+		}
+	}
+	status := newExecutionStatus(original.FlowID, "running", metadata)
+
+	// The replay starts from its own first checkpoint, which also lets it be
+	// resumed after a restart or picked up by a worker
+	checkpoint := ExecutionCheckpoint{
+		ExecutionID: status.ID,
+		AccountID:   accountID,
+		FlowID:      original.FlowID,
+		Step:        0,
+		NodeID:      options.FromNode,
+		Shared:      shared,
+		CreatedAt:   time.Now(),
+	}
+	if err := store.SaveCheckpoint(checkpoint); err != nil {
+		return "", fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	if r.queue != nil {
+		status.Status = "queued"
+		return r.enqueue(accountID, status, nil)
+	}
+
+	ctx, execCtx, err := r.startExecution(context.Background(), accountID, settings, status)
+	if err != nil {
+		return "", err
+	}
+	r.logExecution(status.ID, "info", "Replaying execution", map[string]interface{}{
+		"replay_of": executionID,
+		"node_id":   options.FromNode,
+	})
+
+	r.schedule(execCtx, nil, nil, func() {
+		r.continueExecution(ctx, execCtx, flow, node, checkpoint)
+	})
+
+	return status.ID, nil
+}
+
+// replayState returns a copy of a recorded shared state with the patch applied.
+// Compensations recorded by the original execution are dropped, since the
+// replay did not run those nodes.
+func replayState(recorded, patch map[string]interface{}) (map[string]interface{}, error) {
+	shared, err := snapshotSharedState(recorded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy recorded state: %w", err)
+	}
+	delete(shared, compensationsKey)
+
+	patch, err = snapshotSharedState(patch)
+	if err != nil {
+		return nil, fmt.Errorf("state patch is not serializable: %w", err)
+	}
+	return mergePatch(shared, patch), nil
+}
+
+// mergePatch applies a JSON merge patch to target and returns the result
+func mergePatch(target, patch map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(target)+len(patch))
+	for key, value := range target {
+		merged[key] = value
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		if patchMap, ok := value.(map[string]interface{}); ok {
+			targetMap, _ := merged[key].(map[string]interface{})
+			merged[key] = mergePatch(targetMap, patchMap)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,119 @@
+package runtime
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	target := map[string]interface{}{
+		"keep":    "value",
+		"replace": "old",
+		"remove":  "value",
+		"nested":  map[string]interface{}{"a": 1.0, "b": 2.0},
+	}
+	patch := map[string]interface{}{
+		"replace": "new",
+		"remove":  nil,
+		"nested":  map[string]interface{}{"b": nil, "c": 3.0},
+		"added":   []interface{}{"x"},
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"keep":    "value",
+		"replace": "new",
+		"nested":  map[string]interface{}{"a": 1.0, "c": 3.0},
+		"added":   []interface{}{"x"},
+	}, mergePatch(target, patch))
+
+	// The target is left untouched
+	assert.Equal(t, "old", target["replace"])
+	assert.Equal(t, 2.0, target["nested"].(map[string]interface{})["b"])
+}
+
+func TestFlowRuntime_Replay(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+
+	flowDef := &Flow{ID: "counting-flow", YAML: "counting"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "counting-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newCountingFlow(&visited, &mu), nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store)
+	replayer, ok := flowRuntime.(ExecutionReplayer)
+	require.True(t, ok)
+
+	originalID, err := flowRuntime.Execute("test-account", "counting-flow", map[string]interface{}{"counter": float64(0), "topic": "go"})
+	require.NoError(t, err)
+	waitForStatus(t, store, originalID, "completed")
+
+	mu.Lock()
+	visited = nil
+	mu.Unlock()
+
+	replayID, err := replayer.Replay("test-account", originalID, ReplayOptions{
+		FromNode: "second",
+		State:    map[string]interface{}{"counter": float64(10)},
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, originalID, replayID)
+
+	status := waitForStatus(t, store, replayID, "completed")
+	assert.Equal(t, originalID, status.Metadata[ReplayOfExecutionIDKey])
+	assert.Equal(t, "second", status.Metadata[ReplayFromNodeKey])
+
+	// Only the nodes from the replayed one run again
+	mu.Lock()
+	assert.Equal(t, []string{"second", "third"}, visited)
+	mu.Unlock()
+
+	checkpoints, err := store.GetExecutionCheckpoints(replayID)
+	require.NoError(t, err)
+	require.NotEmpty(t, checkpoints)
+	assert.Equal(t, "second", checkpoints[0].NodeID)
+	assert.Equal(t, float64(10), checkpoints[0].Shared["counter"])
+	assert.Equal(t, "go", checkpoints[0].Shared["topic"])
+	last := checkpoints[len(checkpoints)-1]
+	assert.Equal(t, "third", last.NodeID)
+	assert.Equal(t, float64(11), last.Shared["counter"])
+
+	// The recorded state of the original execution is not changed
+	original, err := store.GetExecutionCheckpoints(originalID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), original[1].Shared["counter"])
+}
+
+func TestFlowRuntime_ReplayRequiresCheckpoint(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+
+	flowDef := &Flow{ID: "counting-flow", YAML: "counting"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "counting-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newCountingFlow(&visited, &mu), nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store)
+	replayer := flowRuntime.(ExecutionReplayer)
+
+	originalID, err := flowRuntime.Execute("test-account", "counting-flow", nil)
+	require.NoError(t, err)
+	waitForStatus(t, store, originalID, "completed")
+
+	_, err = replayer.Replay("test-account", originalID, ReplayOptions{FromNode: "unknown"})
+	assert.ErrorIs(t, err, ErrCheckpointNotFound)
+
+	_, err = replayer.Replay("test-account", "non-existent", ReplayOptions{FromNode: "second"})
+	assert.ErrorIs(t, err, ErrCheckpointNotFound)
+
+	// Executions of other accounts cannot be replayed
+	_, err = replayer.Replay("other-account", originalID, ReplayOptions{FromNode: "second"})
+	assert.ErrorIs(t, err, ErrCheckpointNotFound)
+}
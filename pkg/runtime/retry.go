@@ -0,0 +1,29 @@
+package runtime
+
+import (
+	"github.com/tcmartin/flowlib"
+)
+
+// retryObserver returns a flowlib retry observer that records every attempt
+// of a retrying node in the execution log
+func (r *flowRuntime) retryObserver(execCtx *executionContext) flowlib.RetryObserver {
+	return func(attempt flowlib.Attempt) {
+		data := map[string]interface{}{
+			"node_id":      nodeIDOf(attempt.Node),
+			"attempt":      attempt.Number,
+			"max_attempts": attempt.MaxAttempts,
+		}
+
+		switch {
+		case attempt.Err == nil:
+			r.logExecution(execCtx.status.ID, "info", "Node attempt succeeded", data)
+		case attempt.WillRetry:
+			data["error"] = attempt.Err.Error()
+			data["retry_in"] = attempt.RetryIn.String()
+			r.logExecution(execCtx.status.ID, "warning", "Node attempt failed, retrying", data)
+		default:
+			data["error"] = attempt.Err.Error()
+			r.logExecution(execCtx.status.ID, "error", "Node attempt failed, giving up", data)
+		}
+	}
+}
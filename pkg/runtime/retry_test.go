@@ -0,0 +1,130 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/loader"
+	"github.com/tcmartin/flowrunner/pkg/plugins"
+)
+
+func TestFlowRuntime_RetryAttemptsAreLogged(t *testing.T) {
+	attempts := 0
+	flaky := &NodeWrapper{
+		node: flowlib.NewNode(1, 0),
+		execWithContext: func(ctx context.Context, input interface{}) (interface{}, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, errors.New("connection reset")
+			}
+			return map[string]interface{}{"attempts": attempts}, nil
+		},
+	}
+	flaky.exec = backgroundExec(flaky.execWithContext)
+	flaky.SetParams(map[string]interface{}{"node_id": "flaky"})
+	flaky.SetRetryPolicy(flowlib.RetryPolicy{
+		MaxRetries: 3,
+		Wait:       time.Millisecond,
+		Backoff:    flowlib.BackoffExponential,
+	})
+
+	flowDef := &Flow{ID: "retry-flow", YAML: "metadata:\n  name: retry-flow\n"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "retry-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flowlib.NewFlow(flaky), nil)
+
+	store := newCheckpointTestStore()
+	rt := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store)
+
+	executionID, err := rt.Execute("test-account", "retry-flow", nil)
+	require.NoError(t, err)
+	waitForStatus(t, store, executionID, "completed")
+	assert.Equal(t, 3, attempts)
+
+	logs, err := rt.GetLogs(executionID)
+	require.NoError(t, err)
+
+	var attemptLogs []ExecutionLog
+	for _, log := range logs {
+		if _, ok := log.Data["attempt"]; ok {
+			attemptLogs = append(attemptLogs, log)
+		}
+	}
+	require.Len(t, attemptLogs, 3)
+	assert.Equal(t, "flaky", attemptLogs[0].NodeID)
+	assert.Equal(t, "connection reset", attemptLogs[0].Data["error"])
+	assert.Equal(t, "1ms", attemptLogs[0].Data["retry_in"])
+	assert.Equal(t, "2ms", attemptLogs[1].Data["retry_in"])
+	assert.Equal(t, "Node attempt succeeded", attemptLogs[2].Message)
+}
+
+// coreNodeFactory adapts a core node type to the node factories of the loader
+type coreNodeFactory struct {
+	factory NodeFactory
+}
+
+func (f coreNodeFactory) CreateNode(nodeDef plugins.NodeDefinition) (flowlib.Node, error) {
+	return f.factory(nodeDef.Params)
+}
+
+func TestHTTPRequestNode_RetriesErrorResponses(t *testing.T) {
+	tests := []struct {
+		name           string
+		failures       int32
+		expectedCalls  int32
+		expectedStatus int
+		expectedAction flowlib.Action
+	}{
+		{name: "recovers", failures: 2, expectedCalls: 3, expectedStatus: http.StatusOK, expectedAction: "success"},
+		{name: "retries used up", failures: 5, expectedCalls: 3, expectedStatus: http.StatusServiceUnavailable, expectedAction: "server_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte(`{"ok": true}`))
+			}))
+			defer server.Close()
+
+			yamlLoader := loader.NewYAMLLoader(map[string]plugins.NodeFactory{
+				"http.request": coreNodeFactory{NewHTTPRequestNodeWrapper},
+			}, plugins.NewPluginRegistry())
+			flow, err := yamlLoader.Parse(fmt.Sprintf(`
+metadata:
+  name: retry-flow
+nodes:
+  fetch:
+    type: http.request
+    params:
+      url: %s
+    retry:
+      max_retries: 3
+      wait: 1ms
+      retry_on: [server_error]
+`, server.URL))
+			require.NoError(t, err)
+
+			shared := map[string]interface{}{}
+			action, err := flow.Start().Run(shared)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedAction, action)
+			assert.Equal(t, tt.expectedCalls, atomic.LoadInt32(&calls))
+			assert.Equal(t, tt.expectedStatus, shared["result"].(map[string]interface{})["status_code"])
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package runtime
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/tcmartin/flowlib"
+)
+
+// ConcurrencyLimits bounds how many executions run at the same time. Zero
+// means no limit. Executions beyond the limits wait in a FIFO queue with the
+// "queued" status.
+type ConcurrencyLimits struct {
+	// MaxConcurrent bounds the executions running in the runtime
+	MaxConcurrent int
+
+	// MaxConcurrentPerAccount bounds the executions running for one account
+	MaxConcurrentPerAccount int
+
+	// MaxConcurrentPerFlow bounds the executions running for one flow
+	MaxConcurrentPerFlow int
+}
+
+// ConcurrencyLimiter is implemented by runtimes whose executions can be bounded
+type ConcurrencyLimiter interface {
+	// SetConcurrencyLimits replaces the limits; queued executions that fit
+	// the new limits start immediately
+	SetConcurrencyLimits(limits ConcurrencyLimits)
+}
+
+// queuedExecution is an execution waiting for a free slot
+type queuedExecution struct {
+	id        string
+	accountID string
+	flowID    string
+	start     func()
+}
+
+// scheduler admits executions in FIFO order within the concurrency limits.
+// An execution whose account or flow is at its limit does not hold back the
+// executions of other accounts and flows queued behind it.
+type scheduler struct {
+	mu         sync.Mutex
+	limits     ConcurrencyLimits
+	running    int
+	perAccount map[string]int
+	perFlow    map[string]int
+	queue      []*queuedExecution
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		perAccount: make(map[string]int),
+		perFlow:    make(map[string]int),
+	}
+}
+
+// setLimits replaces the limits and starts the queued executions they allow
+func (s *scheduler) setLimits(limits ConcurrencyLimits) {
+	s.mu.Lock()
+	s.limits = limits
+	admitted := s.admitLocked()
+	s.mu.Unlock()
+
+	startAll(admitted)
+}
+
+// submit starts the execution once the limits allow it. It returns the
+// 1-based queue position of the execution, or 0 if it started right away.
+func (s *scheduler) submit(id, accountID, flowID string, start func()) int {
+	s.mu.Lock()
+	s.queue = append(s.queue, &queuedExecution{id: id, accountID: accountID, flowID: flowID, start: start})
+	admitted := s.admitLocked()
+	position := s.positionLocked(id)
+	s.mu.Unlock()
+
+	startAll(admitted)
+	return position
+}
+
+// release frees the slot of a finished execution and starts the queued
+// executions that fit into it
+func (s *scheduler) release(accountID, flowID string) {
+	s.mu.Lock()
+	s.running--
+	s.perAccount[accountID]--
+	if s.perAccount[accountID] <= 0 {
+		delete(s.perAccount, accountID)
+	}
+	key := flowKey(accountID, flowID)
+	s.perFlow[key]--
+	if s.perFlow[key] <= 0 {
+		delete(s.perFlow, key)
+	}
+	admitted := s.admitLocked()
+	s.mu.Unlock()
+
+	startAll(admitted)
+}
+
+// remove drops a queued execution and reports whether it was queued
+func (s *scheduler) remove(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, queued := range s.queue {
+		if queued.id == id {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// position returns the 1-based queue position of an execution, or 0 if it is
+// not queued
+func (s *scheduler) position(id string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.positionLocked(id)
+}
+
+func (s *scheduler) positionLocked(id string) int {
+	for i, queued := range s.queue {
+		if queued.id == id {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// admitLocked takes the queued executions that fit the limits off the queue,
+// oldest first, and counts them as running
+func (s *scheduler) admitLocked() []*queuedExecution {
+	var admitted []*queuedExecution
+	remaining := s.queue[:0]
+	for _, queued := range s.queue {
+		if !s.fitsLocked(queued) {
+			remaining = append(remaining, queued)
+			continue
+		}
+		s.running++
+		s.perAccount[queued.accountID]++
+		s.perFlow[flowKey(queued.accountID, queued.flowID)]++
+		admitted = append(admitted, queued)
+	}
+	for i := len(remaining); i < len(s.queue); i++ {
+		s.queue[i] = nil
+	}
+	s.queue = remaining
+	return admitted
+}
+
+func (s *scheduler) fitsLocked(queued *queuedExecution) bool {
+	if s.limits.MaxConcurrent > 0 && s.running >= s.limits.MaxConcurrent {
+		return false
+	}
+	if s.limits.MaxConcurrentPerAccount > 0 && s.perAccount[queued.accountID] >= s.limits.MaxConcurrentPerAccount {
+		return false
+	}
+	if s.limits.MaxConcurrentPerFlow > 0 && s.perFlow[flowKey(queued.accountID, queued.flowID)] >= s.limits.MaxConcurrentPerFlow {
+		return false
+	}
+	return true
+}
+
+func startAll(admitted []*queuedExecution) {
+	for _, queued := range admitted {
+		queued.start()
+	}
+}
+
+// flowKey identifies a flow across accounts
+func flowKey(accountID, flowID string) string {
+	return accountID + "/" + flowID
+}
+
+// SetConcurrencyLimits implements ConcurrencyLimiter
+func (r *flowRuntime) SetConcurrencyLimits(limits ConcurrencyLimits) {
+	r.scheduler.setLimits(limits)
+}
+
+// schedule runs an execution in its own goroutine once the concurrency limits
+// allow it. Until then the execution is "queued", and a checkpoint at the
+// start node lets it be resumed if the process stops while it waits.
+func (r *flowRuntime) schedule(execCtx *executionContext, flow *flowlib.Flow, input map[string]interface{}, run func()) {
+	executionID := execCtx.status.ID
+
+	execCtx.mu.Lock()
+	execCtx.status.Status = "queued"
+	execCtx.mu.Unlock()
+
+	position := r.scheduler.submit(executionID, execCtx.accountID, execCtx.flowID, func() {
+		// Status changes are saved under the lock, so that a late "queued"
+		// cannot overwrite "running" in the store
+		execCtx.mu.Lock()
+		execCtx.admitted = true
+		announced := execCtx.status.QueuePosition > 0
+		execCtx.status.Status = "running"
+		execCtx.status.QueuePosition = 0
+		if announced {
+			execCtx.timeoutBase = restartFlowTimeout(&execCtx.status)
+			r.saveStatus(execCtx.status)
+		}
+		execCtx.mu.Unlock()
+
+		if announced {
+			r.logExecution(executionID, "info", "Execution left the queue", nil)
+		}
+		go run()
+	})
+	if position == 0 {
+		return
+	}
+
+	if flow != nil {
+		r.saveCheckpoint(execCtx, 0, flow.Start(), input)
+	}
+
+	execCtx.mu.Lock()
+	if execCtx.admitted {
+		// A slot freed up in the meantime
+		execCtx.mu.Unlock()
+		return
+	}
+	execCtx.status.QueuePosition = position
+	r.saveStatus(execCtx.status)
+	execCtx.mu.Unlock()
+
+	r.logExecution(executionID, "info", "Execution queued", map[string]interface{}{"queue_position": position})
+}
+
+// saveStatus persists an execution status if an execution store is configured
+func (r *flowRuntime) saveStatus(status ExecutionStatus) {
+	if r.executionStore == nil {
+		return
+	}
+	if err := r.executionStore.SaveExecution(status); err != nil {
+		fmt.Printf("Failed to save execution status: %v\n", err)
+	}
+}
+
+// activeStatus returns the status of an active execution with its current
+// queue position
+func (r *flowRuntime) activeStatus(execCtx *executionContext) ExecutionStatus {
+	execCtx.mu.RLock()
+	status := execCtx.status
+	execCtx.mu.RUnlock()
+
+	if status.Status == "queued" {
+		status.QueuePosition = r.scheduler.position(status.ID)
+	}
+	return status
+}
@@ -0,0 +1,90 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+func TestScheduler_Limits(t *testing.T) {
+	s := newScheduler()
+	s.setLimits(ConcurrencyLimits{MaxConcurrent: 3, MaxConcurrentPerAccount: 2, MaxConcurrentPerFlow: 1})
+
+	var started []string
+	submit := func(id, accountID, flowID string) int {
+		return s.submit(id, accountID, flowID, func() { started = append(started, id) })
+	}
+
+	assert.Equal(t, 0, submit("a1", "noisy", "llm"))
+	assert.Equal(t, 1, submit("a2", "noisy", "llm"))    // flow limit
+	assert.Equal(t, 0, submit("a3", "noisy", "report")) // other flow of the same account
+	assert.Equal(t, 2, submit("a4", "noisy", "other"))  // account limit
+	assert.Equal(t, 0, submit("b1", "quiet", "llm"))    // other account is not held back
+	assert.Equal(t, 3, submit("b2", "quiet", "report")) // global limit
+	assert.Equal(t, []string{"a1", "a3", "b1"}, started)
+
+	// Queued executions start in order, skipping those still at their limits
+	s.release("noisy", "report")
+	assert.Equal(t, []string{"a1", "a3", "b1", "a4"}, started)
+	assert.Equal(t, 1, s.position("a2"))
+	assert.Equal(t, 2, s.position("b2"))
+
+	assert.True(t, s.remove("b2"))
+	assert.False(t, s.remove("b2"))
+
+	s.release("noisy", "llm")
+	assert.Equal(t, []string{"a1", "a3", "b1", "a4", "a2"}, started)
+	assert.Equal(t, 0, s.position("a2"))
+}
+
+func TestFlowRuntime_QueuesExecutionsBeyondLimits(t *testing.T) {
+	flowDef := &Flow{ID: "slow-flow", YAML: "metadata:\n  name: slow-flow\n"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "slow-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flowlib.NewFlow(newSlowNode(t)), nil)
+
+	store := newCheckpointTestStore()
+	rt := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store)
+	rt.(ConcurrencyLimiter).SetConcurrencyLimits(ConcurrencyLimits{MaxConcurrentPerAccount: 1})
+
+	first, err := rt.Execute("test-account", "slow-flow", nil)
+	require.NoError(t, err)
+	second, err := rt.Execute("test-account", "slow-flow", nil)
+	require.NoError(t, err)
+	third, err := rt.Execute("test-account", "slow-flow", nil)
+	require.NoError(t, err)
+
+	status, err := rt.GetStatus(second)
+	require.NoError(t, err)
+	assert.Equal(t, "queued", status.Status)
+	assert.Equal(t, 1, status.QueuePosition)
+
+	status, err = rt.GetStatus(third)
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.QueuePosition)
+	queuedAt := status.StartTime
+
+	// Canceling a queued execution does not start it
+	require.NoError(t, rt.Cancel(second))
+	waitForStatus(t, store, second, "canceled")
+
+	status, err = rt.GetStatus(third)
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.QueuePosition)
+
+	// Finishing the running execution starts the next one
+	require.NoError(t, rt.Cancel(first))
+	waitForStatus(t, store, third, "running")
+
+	// The flow timeout counts from the end of the wait, not the start time
+	status, err = store.GetExecution(third)
+	require.NoError(t, err)
+	assert.True(t, status.StartTime.Equal(queuedAt))
+	assert.NotEmpty(t, status.Metadata[RunningSinceKey])
+
+	require.NoError(t, rt.Cancel(third))
+	waitForStatus(t, store, third, "canceled")
+}
@@ -0,0 +1,60 @@
+package runtime
+
+import (
+	"reflect"
+	"strings"
+)
+
+// NodeOutputsKey is the shared state key under which the latest result of
+// every node is kept by node ID, such as "${shared._outputs.fetch_orders}".
+// Unlike "result", the outputs of nodes in parallel branches never overwrite
+// each other and are kept when the branches are joined. The key is reserved
+// like the other runtime keys, so flows keep their own "outputs" field.
+const NodeOutputsKey = "_outputs"
+
+// isPublicKey reports whether a shared state key is visible to expressions
+// and results. The runtime keeps its own keys, prefixed with "_", hidden,
+// except the node outputs.
+func isPublicKey(key string) bool {
+	if key == NodeOutputsKey {
+		return true
+	}
+	return !strings.HasPrefix(key, "_") && key != "accountID"
+}
+
+// setNodeOutput stores the result of a node under its ID. The outputs are
+// copied on write, so states that share the previous outputs, such as the
+// snapshot a split node compares its branches with, never see the change.
+func setNodeOutput(shared map[string]interface{}, nodeID string, result interface{}) {
+	previous, _ := shared[NodeOutputsKey].(map[string]interface{})
+	outputs := make(map[string]interface{}, len(previous)+1)
+	for id, output := range previous {
+		outputs[id] = output
+	}
+	outputs[nodeID] = result
+	shared[NodeOutputsKey] = outputs
+}
+
+// mergeNodeOutputs copies the outputs of the nodes that ran in a branch into
+// shared. before are the outputs when the branch was forked.
+func mergeNodeOutputs(shared, before, branch map[string]interface{}) {
+	outputs, _ := branch[NodeOutputsKey].(map[string]interface{})
+	for nodeID, output := range outputs {
+		if previous, existed := before[nodeID]; existed && reflect.DeepEqual(previous, output) {
+			continue
+		}
+		setNodeOutput(shared, nodeID, output)
+	}
+}
+
+// replaceState makes shared hold the same values as state
+func replaceState(shared, state map[string]interface{}) {
+	for key := range shared {
+		if _, ok := state[key]; !ok {
+			delete(shared, key)
+		}
+	}
+	for key, value := range state {
+		shared[key] = value
+	}
+}
@@ -0,0 +1,533 @@
+package runtime
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+)
+
+// ErrExecutionWaiting is returned by nodes that parked their execution. The
+// execution stops without a final status and continues once it is signaled
+// or its wait expires.
+var ErrExecutionWaiting = errors.New("execution is waiting")
+
+// ErrWaitNotFound is returned when an execution does not wait for a signal,
+// for example because it was already signaled
+var ErrWaitNotFound = errors.New("execution is not waiting")
+
+// ErrSignalUnauthorized is returned for signals that neither come from the
+// account of the execution nor carry a valid signed token
+var ErrSignalUnauthorized = errors.New("signal is not authorized")
+
+// ErrInvalidSignal is returned for signals with an unknown decision
+var ErrInvalidSignal = errors.New("invalid signal")
+
+// Decisions of signals sent to approval nodes
+const (
+	// DecisionApprove approves the waiting execution
+	DecisionApprove = "approve"
+
+	// DecisionReject rejects the waiting execution
+	DecisionReject = "reject"
+)
+
+// waitExpiryInterval is how often the runtime looks for expired waits
+const waitExpiryInterval = time.Second
+
+// Signal resumes an execution waiting in an approval node
+type Signal struct {
+	// Name of the signal, which must match the signal the node waits for
+	Name string
+
+	// Decision is DecisionApprove or DecisionReject
+	Decision string
+
+	// Payload is passed on to the flow in the result of the node
+	Payload map[string]interface{}
+
+	// AccountID is the account sending the signal. It must own the execution
+	// unless the signal carries a token.
+	AccountID string
+
+	// Token is the token of a signed link
+	Token string
+}
+
+// ExecutionSignaler is implemented by runtimes that can resume executions
+// waiting for a signal
+type ExecutionSignaler interface {
+	// Signal resumes a waiting execution. It returns ErrWaitNotFound if the
+	// execution does not wait for the signal, and ErrSignalUnauthorized if
+	// the sender may not signal it.
+	Signal(executionID string, signal Signal) error
+}
+
+// SignalLinks configures the signed links that let people signal a waiting
+// execution without an account, such as the approve and reject links in the
+// notifications of approval nodes
+type SignalLinks struct {
+	// BaseURL is the public URL of the API server, such as
+	// "https://flows.example.com"
+	BaseURL string
+
+	// Key signs the links
+	Key []byte
+}
+
+// SignalLinker is implemented by runtimes that put signed links in the
+// notifications of waiting executions
+type SignalLinker interface {
+	// SetSignalLinks sets where the links point to and how they are signed
+	SetSignalLinks(links SignalLinks)
+}
+
+// SetSignalLinks implements SignalLinker
+func (r *flowRuntime) SetSignalLinks(links SignalLinks) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.signalLinks = links
+}
+
+// park suspends an execution in the node of wait until it is signaled or the
+// wait expires. notify, if not nil, receives the signed links of the wait,
+// which are nil unless signal links are configured. park returns
+// ErrExecutionWaiting once the execution is parked.
+func (r *flowRuntime) park(execCtx *executionContext, wait ExecutionWait, notify func(links map[string]string) error) error {
+	store, ok := r.executionStore.(WaitStore)
+	if !ok {
+		return fmt.Errorf("execution store does not support waiting executions")
+	}
+
+	// The status is saved before the wait, so that a signal arriving right
+	// away cannot be overwritten by it
+	r.updateProgress(execCtx, func(status *ExecutionStatus) {
+		status.Status = "waiting"
+	})
+	if err := store.SaveWait(wait); err != nil {
+		r.updateProgress(execCtx, func(status *ExecutionStatus) {
+			status.Status = "running"
+		})
+		return fmt.Errorf("failed to save wait: %w", err)
+	}
+
+	if notify == nil {
+		// Timers cannot be signaled
+	} else if err := notify(r.signalURLs(wait)); err != nil {
+		if _, claimErr := store.ClaimWait(wait.ExecutionID); claimErr != nil {
+			// A signal was faster and resumes the execution
+			return ErrExecutionWaiting
+		}
+		r.updateProgress(execCtx, func(status *ExecutionStatus) {
+			status.Status = "running"
+		})
+		return err
+	}
+
+	data := map[string]interface{}{
+		"node_id": wait.NodeID,
+	}
+	message := "Execution waiting for timer"
+	if wait.Signal != "" {
+		data["signal"] = wait.Signal
+		message = "Execution waiting for signal"
+	}
+	if !wait.Deadline.IsZero() {
+		data["deadline"] = wait.Deadline.Format(time.RFC3339)
+	}
+	r.logExecution(wait.ExecutionID, "info", message, data)
+	return ErrExecutionWaiting
+}
+
+// signalURLs returns the signed approve and reject links of a wait, or nil if
+// signal links are not configured
+func (r *flowRuntime) signalURLs(wait ExecutionWait) map[string]string {
+	r.mu.RLock()
+	links := r.signalLinks
+	r.mu.RUnlock()
+	if links.BaseURL == "" || len(links.Key) == 0 {
+		return nil
+	}
+
+	base := fmt.Sprintf("%s/api/v1/executions/%s/signals/%s",
+		strings.TrimRight(links.BaseURL, "/"), url.PathEscape(wait.ExecutionID), url.PathEscape(wait.Signal))
+	link := func(decision string) string {
+		query := url.Values{
+			"decision": {decision},
+			"token":    {signalToken(links.Key, wait, decision)},
+		}
+		return base + "?" + query.Encode()
+	}
+	return map[string]string{
+		"approve_url": link(DecisionApprove),
+		"reject_url":  link(DecisionReject),
+	}
+}
+
+// signalToken signs a decision on a wait. The token holds the deadline of the
+// wait as Unix time, 0 for none, followed by the signature.
+func signalToken(key []byte, wait ExecutionWait, decision string) string {
+	var expires int64
+	if !wait.Deadline.IsZero() {
+		expires = wait.Deadline.Unix()
+	}
+	return strconv.FormatInt(expires, 10) + "." + signTokenPayload(key, wait.ExecutionID, wait.Signal, decision, expires)
+}
+
+// signTokenPayload returns the signature of a signal token
+func signTokenPayload(key []byte, executionID, signal, decision string, expires int64) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d", executionID, signal, decision, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validSignalToken reports whether token signs the decision on the wait and
+// has not expired at now
+func validSignalToken(key []byte, token string, wait ExecutionWait, decision string, now time.Time) bool {
+	expiresPart, signature, found := strings.Cut(token, ".")
+	if !found {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiresPart, 10, 64)
+	if err != nil {
+		return false
+	}
+	if expires != 0 && now.Unix() > expires {
+		return false
+	}
+	expected := signTokenPayload(key, wait.ExecutionID, wait.Signal, decision, expires)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// Signal implements ExecutionSignaler
+func (r *flowRuntime) Signal(executionID string, signal Signal) error {
+	store, ok := r.executionStore.(WaitStore)
+	if !ok {
+		return fmt.Errorf("execution store does not support waiting executions")
+	}
+
+	wait, err := store.GetWait(executionID)
+	if err != nil {
+		return err
+	}
+	if wait.Signal == "" || wait.Signal != signal.Name {
+		return fmt.Errorf("%w for signal %s", ErrWaitNotFound, signal.Name)
+	}
+
+	var action flowlib.Action
+	switch signal.Decision {
+	case DecisionApprove:
+		action = ApprovedAction
+	case DecisionReject:
+		action = RejectedAction
+	default:
+		return fmt.Errorf("%w: decision must be %s or %s, got %q", ErrInvalidSignal, DecisionApprove, DecisionReject, signal.Decision)
+	}
+
+	if signal.Token != "" {
+		r.mu.RLock()
+		key := r.signalLinks.Key
+		r.mu.RUnlock()
+		if len(key) == 0 || !validSignalToken(key, signal.Token, wait, signal.Decision, time.Now()) {
+			return ErrSignalUnauthorized
+		}
+	} else if signal.AccountID == "" || signal.AccountID != wait.AccountID {
+		return ErrSignalUnauthorized
+	}
+
+	// Only one of several signals gets to resume the execution
+	wait, err = store.ClaimWait(executionID)
+	if err != nil {
+		return err
+	}
+
+	r.logExecution(executionID, "info", "Signal received", map[string]interface{}{
+		"node_id":  wait.NodeID,
+		"signal":   wait.Signal,
+		"decision": signal.Decision,
+	})
+	result := map[string]interface{}{
+		"decision":   string(action),
+		"signal":     wait.Signal,
+		"decided_at": time.Now().UTC().Format(time.RFC3339),
+	}
+	if signal.Payload != nil {
+		result["payload"] = signal.Payload
+	}
+	return r.resumeWaiting(wait, action, result)
+}
+
+// startWaitExpiry resumes waiting executions once their deadline passes, if
+// the execution store supports waits, until ctx is canceled. As the waits are
+// persisted, timers set before a restart or by another process fire as well.
+func (r *flowRuntime) startWaitExpiry(ctx context.Context) {
+	store, ok := r.executionStore.(WaitStore)
+	if !ok {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(waitExpiryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.expireWaits(store, time.Now())
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// expireWaits resumes the executions whose wait expired before now with the
+// action and result the wait declares for its deadline. Waits claimed by
+// another process in the meantime are left to it.
+func (r *flowRuntime) expireWaits(store WaitStore, now time.Time) {
+	waits, err := store.ListDueWaits(now)
+	if err != nil {
+		fmt.Printf("Failed to list expired waits: %v\n", err)
+		return
+	}
+
+	for _, due := range waits {
+		wait, err := store.ClaimWait(due.ExecutionID)
+		if err != nil {
+			if !errors.Is(err, ErrWaitNotFound) {
+				fmt.Printf("Failed to claim wait of execution %s: %v\n", due.ExecutionID, err)
+			}
+			continue
+		}
+
+		r.logExecution(wait.ExecutionID, "info", "Wait expired", map[string]interface{}{
+			"node_id": wait.NodeID,
+			"action":  wait.Action,
+		})
+		if err := r.resumeWaiting(wait, wait.Action, wait.Result); err != nil {
+			fmt.Printf("Failed to resume execution %s: %v\n", wait.ExecutionID, err)
+		}
+	}
+}
+
+// resumeWaiting continues a parked execution after its waiting node, which
+// completes with result and the given action. The wait must have been
+// claimed. Executions that cannot be resumed fail.
+func (r *flowRuntime) resumeWaiting(wait ExecutionWait, action flowlib.Action, result map[string]interface{}) error {
+	// The goroutine that parked the execution may still be finishing
+	r.mu.RLock()
+	execCtx, active := r.activeExecutions[wait.ExecutionID]
+	r.mu.RUnlock()
+	if active {
+		<-execCtx.done
+	}
+
+	status, err := r.executionStore.GetExecution(wait.ExecutionID)
+	if err != nil {
+		return fmt.Errorf("failed to get execution: %w", err)
+	}
+	if isFinished(status.Status) {
+		// Canceled before it was parked
+		r.logExecution(wait.ExecutionID, "info", "Finished execution not resumed", map[string]interface{}{"status": status.Status})
+		return nil
+	}
+	if status.Status != "waiting" {
+		return fmt.Errorf("execution %s is %s, not waiting", wait.ExecutionID, status.Status)
+	}
+
+	if err := r.continueWaiting(wait, status, action, result); err != nil {
+		status.Status = "failed"
+		status.Error = fmt.Sprintf("failed to resume execution: %v", err)
+		status.EndTime = time.Now()
+		r.saveStatus(status)
+		r.logExecution(wait.ExecutionID, "error", "Failed to resume waiting execution", map[string]interface{}{"error": err.Error(), "node_id": wait.NodeID})
+		return err
+	}
+	return nil
+}
+
+// continueWaiting completes the waiting node of an execution and runs the
+// rest of the execution from a checkpoint at the node after it. The payload
+// of an event is merged into the shared state first.
+func (r *flowRuntime) continueWaiting(wait ExecutionWait, status ExecutionStatus, action flowlib.Action, result map[string]interface{}) error {
+	store, ok := r.executionStore.(CheckpointStore)
+	if !ok {
+		return fmt.Errorf("execution store does not support checkpoints")
+	}
+	checkpoints, err := store.GetExecutionCheckpoints(wait.ExecutionID)
+	if err != nil {
+		return fmt.Errorf("failed to get checkpoints: %w", err)
+	}
+	if len(checkpoints) == 0 || checkpoints[len(checkpoints)-1].NodeID != wait.NodeID {
+		return fmt.Errorf("no checkpoint at waiting node %s", wait.NodeID)
+	}
+	checkpoint := checkpoints[len(checkpoints)-1]
+
+	flow, settings, err := r.loadFlow(wait.AccountID, wait.FlowID, "")
+	if err != nil {
+		return err
+	}
+	node := findNode(flow.Start(), wait.NodeID)
+	if node == nil {
+		return fmt.Errorf("waiting node %q not found in flow %s", wait.NodeID, wait.FlowID)
+	}
+
+	// Complete the node like NodeWrapper does
+	shared := checkpoint.Shared
+	if shared == nil {
+		shared = make(map[string]interface{})
+	}
+	if payload, ok := result["payload"].(map[string]interface{}); ok && wait.Event != "" {
+		mergeEventPayload(shared, payload)
+	}
+	shared["result"] = result
+	shared["input"] = result
+	setNodeOutput(shared, wait.NodeID, result)
+	recordCompletion(node, shared)
+	r.traceWait(wait, node, action, result)
+
+	next := nextNode(node, action)
+	if next == nil && action == TimeoutAction {
+		// Like nodes that exceed their timeout, waits that time out without
+		// a successor for it end the execution with the timeout status
+		timeoutErr := &TimeoutError{NodeID: wait.NodeID, Timeout: wait.Deadline.Sub(wait.CreatedAt)}
+		status.Status = "timeout"
+		status.Error = timeoutErr.Error()
+		status.EndTime = time.Now()
+		status.Progress = 100.0
+		r.saveStatus(status)
+		r.logExecution(wait.ExecutionID, "error", "Flow execution timed out", map[string]interface{}{"error": timeoutErr.Error()})
+		return nil
+	}
+	if next == nil {
+		status.Status = "completed"
+		status.Results = flowResult(action, shared)
+		status.EndTime = time.Now()
+		status.Progress = 100.0
+		status.CurrentNode = ""
+		if err := validateResults(settings, status.Results); err != nil {
+			status.Status = "failed"
+			status.Error = err.Error()
+			r.saveStatus(status)
+			r.logExecution(wait.ExecutionID, "error", "Flow execution failed", map[string]interface{}{"error": err.Error()})
+			return nil
+		}
+		r.saveStatus(status)
+		r.logExecution(wait.ExecutionID, "info", "Flow execution completed successfully", map[string]interface{}{"result": status.Results})
+		return nil
+	}
+
+	resumed := ExecutionCheckpoint{
+		ExecutionID: wait.ExecutionID,
+		AccountID:   wait.AccountID,
+		FlowID:      wait.FlowID,
+		Step:        checkpoint.Step + 1,
+		NodeID:      nodeIDOf(next),
+		Shared:      shared,
+		CreatedAt:   time.Now(),
+	}
+	if err := store.SaveCheckpoint(resumed); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	restartFlowTimeout(&status)
+	if r.queue != nil {
+		status.Status = "queued"
+		r.saveStatus(status)
+		item := WorkItem{
+			ExecutionID: wait.ExecutionID,
+			AccountID:   wait.AccountID,
+			FlowID:      wait.FlowID,
+		}
+		if err := r.queue.Enqueue(context.Background(), item); err != nil {
+			return fmt.Errorf("failed to enqueue execution: %w", err)
+		}
+		return nil
+	}
+	// Claimed before it is running, so that no other process resumes it
+	claimed, err := r.claimExecution(wait.ExecutionID)
+	if err != nil {
+		return fmt.Errorf("failed to claim execution: %w", err)
+	}
+	if !claimed {
+		return fmt.Errorf("execution %s is owned by another process", wait.ExecutionID)
+	}
+	status.Status = "running"
+	r.saveStatus(status)
+	return r.resumeExecution(resumed)
+}
+
+// traceWait records the visit of a waiting node once it completed, if the
+// execution store supports traces. No node ran since the execution parked,
+// so the visit follows the last one traced.
+func (r *flowRuntime) traceWait(wait ExecutionWait, node flowlib.Node, action flowlib.Action, result map[string]interface{}) {
+	store, ok := r.executionStore.(TraceStore)
+	if !ok {
+		return
+	}
+	traces, err := store.GetExecutionTrace(wait.ExecutionID)
+	if err != nil {
+		r.logExecution(wait.ExecutionID, "warning", "Failed to load node trace", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	visit, sequence := 1, 1
+	for _, trace := range traces {
+		if trace.NodeID == wait.NodeID {
+			visit = max(visit, trace.Visit+1)
+		}
+		sequence = max(sequence, trace.Sequence+1)
+	}
+
+	params := node.Params()
+	trace := NodeTrace{
+		ExecutionID: wait.ExecutionID,
+		Sequence:    sequence,
+		NodeID:      wait.NodeID,
+		Visit:       visit,
+		StartTime:   wait.CreatedAt,
+		EndTime:     time.Now(),
+		Action:      action,
+		Params:      redactParams(params, params),
+		Output:      traceValue(result),
+	}
+	if nodeType, ok := params["node_type"].(string); ok {
+		trace.NodeType = nodeType
+	}
+	if err := store.SaveNodeTrace(trace); err != nil {
+		r.logExecution(wait.ExecutionID, "error", "Failed to save node trace", map[string]interface{}{"error": err.Error(), "node_id": wait.NodeID})
+	}
+}
+
+// cancelWaiting cancels an execution parked by a node. It reports false if
+// the execution does not wait.
+func (r *flowRuntime) cancelWaiting(executionID string) (bool, error) {
+	store, ok := r.executionStore.(WaitStore)
+	if !ok {
+		return false, nil
+	}
+	if _, err := store.ClaimWait(executionID); err != nil {
+		if errors.Is(err, ErrWaitNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to claim wait: %w", err)
+	}
+
+	status, err := r.executionStore.GetExecution(executionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get execution: %w", err)
+	}
+	status.Status = "canceled"
+	status.Error = "Execution was canceled by user"
+	status.EndTime = time.Now()
+	status.Progress = 100.0
+	r.saveStatus(status)
+
+	r.logExecution(executionID, "info", "Waiting execution canceled by user", nil)
+	return true, nil
+}
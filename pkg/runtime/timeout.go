@@ -0,0 +1,133 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+)
+
+// TimeoutAction is the action followed when a node exceeds its timeout. Nodes
+// without a successor for it fail the execution with the "timeout" status.
+const TimeoutAction = "timeout"
+
+// TimeoutError reports that a node or a whole execution ran longer than its
+// declared timeout
+type TimeoutError struct {
+	// NodeID is the node that timed out, or "" if the execution did
+	NodeID string
+
+	// Timeout is the limit that was exceeded
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	if e.NodeID != "" {
+		return fmt.Sprintf("node %s timed out after %s", e.NodeID, e.Timeout)
+	}
+	if e.Timeout > 0 {
+		return fmt.Sprintf("execution timed out after %s", e.Timeout)
+	}
+	return "execution timed out"
+}
+
+// Unwrap allows errors.Is(err, context.DeadlineExceeded)
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// RunningSinceKey is the ExecutionStatus.Metadata key that records when an
+// execution last resumed after waiting, as an RFC 3339 time. The flow timeout
+// counts from then instead of from the start of the execution.
+const RunningSinceKey = "running_since"
+
+// withFlowTimeout bounds ctx by the flow timeout, measured from the timeout
+// base of the execution so that executions resumed after a restart keep
+// their deadline
+func withFlowTimeout(ctx context.Context, execCtx *executionContext) (context.Context, context.CancelFunc) {
+	// Debug executions wait for commands for as long as it takes
+	if execCtx.settings.timeout <= 0 || execCtx.debugger != nil {
+		return context.WithCancel(ctx)
+	}
+	execCtx.mu.RLock()
+	base := execCtx.timeoutBase
+	execCtx.mu.RUnlock()
+	return context.WithDeadline(ctx, base.Add(execCtx.settings.timeout))
+}
+
+// timeoutBase returns when the flow timeout of an execution started counting
+func timeoutBase(status ExecutionStatus) time.Time {
+	if since, err := time.Parse(time.RFC3339Nano, status.Metadata[RunningSinceKey]); err == nil {
+		return since
+	}
+	return status.StartTime
+}
+
+// restartFlowTimeout records in the status that the flow timeout of an
+// execution counts from now, and returns now. Time spent waiting for a
+// signal, an event, a timer or a free slot does not count.
+func restartFlowTimeout(status *ExecutionStatus) time.Time {
+	now := time.Now()
+	// Snapshots of the status share the old metadata
+	metadata := make(map[string]string, len(status.Metadata)+1)
+	for k, v := range status.Metadata {
+		metadata[k] = v
+	}
+	metadata[RunningSinceKey] = now.Format(time.RFC3339Nano)
+	status.Metadata = metadata
+	return now
+}
+
+// runNode runs a single node of an execution, enforcing the node timeout and
+// the execution deadline
+func (r *flowRuntime) runNode(ctx context.Context, execCtx *executionContext, node flowlib.Node, shared map[string]interface{}) (flowlib.Action, error) {
+	timeout := nodeSettings(ctx, execCtx).nodeTimeout(node)
+	if _, hasDeadline := ctx.Deadline(); timeout <= 0 && !hasDeadline {
+		return flowlib.RunNode(ctx, node, shared)
+	}
+
+	nodeCtx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		nodeCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	type outcome struct {
+		action flowlib.Action
+		err    error
+	}
+
+	// Run the node in its own goroutine, so a node that ignores its context
+	// (such as a blocking IMAP fetch) cannot hold the execution past the
+	// deadline. It runs on its own scope of the shared state, which is only
+	// taken over once the node returned, so an abandoned node cannot change
+	// the state the execution goes on with.
+	state := flowlib.ForkScope(shared).State()
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				done <- outcome{err: fmt.Errorf("node panicked: %v", rec)}
+			}
+		}()
+		action, err := flowlib.RunNode(nodeCtx, node, state)
+		done <- outcome{action: action, err: err}
+	}()
+
+	var result outcome
+	select {
+	case result = <-done:
+		replaceState(shared, state)
+	case <-nodeCtx.Done():
+		result = outcome{err: nodeCtx.Err()}
+	}
+
+	if result.err != nil && nodeCtx.Err() == context.DeadlineExceeded {
+		if ctx.Err() == nil {
+			return "", &TimeoutError{NodeID: nodeIDOf(node), Timeout: timeout}
+		}
+		return "", &TimeoutError{Timeout: execCtx.settings.timeout}
+	}
+	return result.action, result.err
+}
@@ -0,0 +1,100 @@
+package runtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+// runDeclaredFlow executes a hand-built flow whose settings come from flowYAML
+func runDeclaredFlow(t *testing.T, flowYAML string, flow *flowlib.Flow) (*checkpointTestStore, string) {
+	flowDef := &Flow{ID: "timeout-flow", YAML: flowYAML}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "timeout-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flow, nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store)
+
+	executionID, err := flowRuntime.Execute("test-account", "timeout-flow", nil)
+	require.NoError(t, err)
+	return store, executionID
+}
+
+func newSlowNode(t *testing.T) flowlib.Node {
+	node, err := NewDelayNodeWrapper(map[string]interface{}{"node_id": "slow", "duration": "10s"})
+	require.NoError(t, err)
+	return node
+}
+
+func TestFlowRuntime_NodeTimeout(t *testing.T) {
+	flowYAML := `
+metadata:
+  name: timeout-flow
+nodes:
+  slow:
+    type: delay
+    timeout: 50ms
+`
+	store, executionID := runDeclaredFlow(t, flowYAML, flowlib.NewFlow(newSlowNode(t)))
+
+	status := waitForStatus(t, store, executionID, "timeout")
+	assert.Contains(t, status.Error, "node slow timed out after 50ms")
+}
+
+func TestFlowRuntime_NodeTimeoutFollowsTimeoutAction(t *testing.T) {
+	flowYAML := `
+metadata:
+  name: timeout-flow
+nodes:
+  slow:
+    type: delay
+    timeout: 50ms
+    next:
+      timeout: fallback
+  fallback:
+    type: base
+`
+	slow := newSlowNode(t)
+	fallback := flowlib.NewNode(1, 0)
+	fallback.SetParams(map[string]interface{}{"node_id": "fallback"})
+	fallback.SetPrepFn(func(shared any) (any, error) {
+		shared.(map[string]interface{})["result"] = "fallback"
+		return nil, nil
+	})
+	slow.Next(TimeoutAction, fallback)
+
+	store, executionID := runDeclaredFlow(t, flowYAML, flowlib.NewFlow(slow))
+
+	status := waitForStatus(t, store, executionID, "completed")
+	assert.Equal(t, "fallback", status.Results["result"])
+}
+
+func TestFlowRuntime_FlowTimeout(t *testing.T) {
+	flowYAML := `
+metadata:
+  name: timeout-flow
+  timeout: 100ms
+nodes:
+  stuck:
+    type: base
+`
+	// A node that ignores its context must not hold the execution open
+	stuck := flowlib.NewNode(1, 0)
+	stuck.SetParams(map[string]interface{}{"node_id": "stuck"})
+	stuck.SetExecFn(func(prepResult any) (any, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	})
+
+	started := time.Now()
+	store, executionID := runDeclaredFlow(t, flowYAML, flowlib.NewFlow(stuck))
+
+	status := waitForStatus(t, store, executionID, "timeout")
+	assert.Less(t, time.Since(started), time.Second)
+	assert.Equal(t, "execution timed out after 100ms", status.Error)
+}
@@ -0,0 +1,278 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+)
+
+// TraceStore is implemented by execution stores that can persist the
+// per-node trace of executions
+type TraceStore interface {
+	// SaveNodeTrace persists a node visit, replacing a visit with the same
+	// execution ID and sequence
+	SaveNodeTrace(trace NodeTrace) error
+
+	// GetExecutionTrace returns the node visits of an execution ordered by
+	// sequence
+	GetExecutionTrace(executionID string) ([]NodeTrace, error)
+}
+
+// ExecutionTracer is implemented by runtimes that record a trace of the nodes
+// visited by every execution
+type ExecutionTracer interface {
+	// GetTrace returns the node visits of an execution of the account in
+	// order, or ErrTraceNotFound if the account has no such execution
+	GetTrace(accountID, executionID string) ([]NodeTrace, error)
+}
+
+// ErrTraceNotFound is returned for traces of executions that do not exist or
+// belong to another account
+var ErrTraceNotFound = errors.New("trace not found")
+
+// redactedValue replaces secrets in traced node parameters
+const redactedValue = "[REDACTED]"
+
+// sensitiveParamSuffixes mark parameters whose values are redacted from
+// traces even when they are written in the flow instead of read from secrets
+var sensitiveParamSuffixes = []string{"password", "secret", "token", "api_key", "apikey", "authorization", "private_key", "access_key"}
+
+// nodeTraceKey is the context key of the recorder of the running node
+type nodeTraceKey struct{}
+
+// nodeTraceRecorder collects what a node reports about its own run
+type nodeTraceRecorder struct {
+	mu        sync.Mutex
+	params    map[string]interface{}
+	output    interface{}
+	hasOutput bool
+}
+
+// withNodeTrace returns a context in which the running node can report its
+// resolved parameters and output
+func withNodeTrace(ctx context.Context) (context.Context, *nodeTraceRecorder) {
+	recorder := &nodeTraceRecorder{}
+	return context.WithValue(ctx, nodeTraceKey{}, recorder), recorder
+}
+
+// recordNodeParams reports the parameters of the running node after template
+// expressions were resolved. The declared parameters tell which values were
+// resolved from secrets.
+func recordNodeParams(ctx context.Context, declared, resolved map[string]interface{}) {
+	recorder, ok := ctx.Value(nodeTraceKey{}).(*nodeTraceRecorder)
+	if !ok {
+		return
+	}
+	params := redactParams(declared, resolved)
+
+	recorder.mu.Lock()
+	recorder.params = params
+	recorder.mu.Unlock()
+}
+
+// recordNodeOutput reports the result of the running node
+func recordNodeOutput(ctx context.Context, output interface{}) {
+	recorder, ok := ctx.Value(nodeTraceKey{}).(*nodeTraceRecorder)
+	if !ok {
+		return
+	}
+
+	recorder.mu.Lock()
+	recorder.output = output
+	recorder.hasOutput = true
+	recorder.mu.Unlock()
+}
+
+// nextSequence numbers a node visit that starts in the execution
+func nextSequence(execCtx *executionContext) int {
+	execCtx.mu.Lock()
+	defer execCtx.mu.Unlock()
+	execCtx.sequence++
+	return execCtx.sequence
+}
+
+// traceNode persists the visit of a node, if the execution store supports
+// traces. sequence is the number of the visit from nextSequence.
+func (r *flowRuntime) traceNode(execCtx *executionContext, node flowlib.Node, sequence int, recorder *nodeTraceRecorder, started time.Time, action flowlib.Action, attempts int, err error) {
+	store, ok := r.executionStore.(TraceStore)
+	if !ok {
+		return
+	}
+
+	nodeID := nodeIDOf(node)
+	execCtx.mu.Lock()
+	execCtx.nodeVisits[nodeID]++
+	visit := execCtx.nodeVisits[nodeID]
+	execCtx.mu.Unlock()
+
+	trace := NodeTrace{
+		ExecutionID: execCtx.status.ID,
+		Sequence:    sequence,
+		NodeID:      nodeID,
+		Visit:       visit,
+		StartTime:   started,
+		EndTime:     time.Now(),
+		Action:      action,
+		Retries:     max(0, attempts-1),
+	}
+	params := node.Params()
+	if nodeType, ok := params["node_type"].(string); ok {
+		trace.NodeType = nodeType
+	}
+	if err != nil {
+		trace.Error = err.Error()
+	}
+
+	recorder.mu.Lock()
+	trace.Params = recorder.params
+	if recorder.hasOutput {
+		trace.Output = traceValue(recorder.output)
+	}
+	recorder.mu.Unlock()
+	if trace.Params == nil && params != nil {
+		// Nodes that do not resolve templates report their declared parameters
+		trace.Params = redactParams(params, params)
+	}
+
+	if err := store.SaveNodeTrace(trace); err != nil {
+		r.logExecution(execCtx.status.ID, "error", "Failed to save node trace", map[string]interface{}{"error": err.Error(), "node_id": nodeID})
+	}
+}
+
+// restoreNodeVisits counts the visits recorded before an execution was
+// resumed, so that the visits and the sequence of the resumed execution
+// continue from there
+func (r *flowRuntime) restoreNodeVisits(execCtx *executionContext) {
+	store, ok := r.executionStore.(TraceStore)
+	if !ok {
+		return
+	}
+	traces, err := store.GetExecutionTrace(execCtx.status.ID)
+	if err != nil {
+		r.logExecution(execCtx.status.ID, "warning", "Failed to load node trace", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	execCtx.mu.Lock()
+	defer execCtx.mu.Unlock()
+	for _, trace := range traces {
+		execCtx.nodeVisits[trace.NodeID] = max(execCtx.nodeVisits[trace.NodeID], trace.Visit)
+		execCtx.sequence = max(execCtx.sequence, trace.Sequence)
+	}
+}
+
+// GetTrace implements ExecutionTracer
+func (r *flowRuntime) GetTrace(accountID, executionID string) ([]NodeTrace, error) {
+	if r.executionStore == nil {
+		return []NodeTrace{}, nil
+	}
+	store, ok := r.executionStore.(TraceStore)
+	if !ok {
+		return nil, fmt.Errorf("execution store does not support traces")
+	}
+	if r.executionAccount(executionID) != accountID {
+		return nil, fmt.Errorf("%w: execution %s", ErrTraceNotFound, executionID)
+	}
+	return store.GetExecutionTrace(executionID)
+}
+
+// executionAccount returns the account an execution runs for, known while it
+// is active and from its execution record afterwards, or "" if it is unknown.
+// Stores that do not record accounts fall back to the checkpoints.
+func (r *flowRuntime) executionAccount(executionID string) string {
+	r.mu.RLock()
+	execCtx, active := r.activeExecutions[executionID]
+	r.mu.RUnlock()
+	if active {
+		return execCtx.accountID
+	}
+
+	if accounts, ok := r.executionStore.(ExecutionAccountStore); ok {
+		accountID, err := accounts.GetExecutionAccountID(executionID)
+		if err != nil {
+			return ""
+		}
+		return accountID
+	}
+
+	store, ok := r.executionStore.(CheckpointStore)
+	if !ok {
+		return ""
+	}
+	checkpoints, err := store.GetExecutionCheckpoints(executionID)
+	if err != nil || len(checkpoints) == 0 {
+		return ""
+	}
+	return checkpoints[0].AccountID
+}
+
+// traceValue returns a JSON-compatible copy of a node output, or its string
+// form if it cannot be serialized
+func traceValue(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	var copied interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return copied
+}
+
+// redactParams returns a copy of the resolved parameters in which values
+// resolved from secrets, and values of sensitive parameters, are redacted
+func redactParams(declared, resolved map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(resolved))
+	for key, value := range resolved {
+		redacted[key] = redactValue(key, declared[key], value)
+	}
+	return redacted
+}
+
+func redactValue(key string, declared, resolved interface{}) interface{} {
+	if isSensitiveParam(key) || referencesSecrets(declared) {
+		return redactedValue
+	}
+
+	switch value := resolved.(type) {
+	case map[string]interface{}:
+		declaredMap, _ := declared.(map[string]interface{})
+		return redactParams(declaredMap, value)
+	case []interface{}:
+		declaredList, _ := declared.([]interface{})
+		redacted := make([]interface{}, len(value))
+		for i, item := range value {
+			var declaredItem interface{}
+			if i < len(declaredList) {
+				declaredItem = declaredList[i]
+			}
+			redacted[i] = redactValue("", declaredItem, item)
+		}
+		return redacted
+	}
+	return resolved
+}
+
+// referencesSecrets reports whether a declared parameter is a template that
+// reads a secret
+func referencesSecrets(declared interface{}) bool {
+	template, ok := declared.(string)
+	return ok && strings.Contains(template, "${") && (strings.Contains(template, "secrets.") || strings.Contains(template, "secrets["))
+}
+
+func isSensitiveParam(key string) bool {
+	key = strings.ToLower(strings.ReplaceAll(key, "-", "_"))
+	for _, suffix := range sensitiveParamSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package runtime
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+func TestRedactParams(t *testing.T) {
+	declared := map[string]interface{}{
+		"url":      "https://api.example.com/${input.path}",
+		"password": "literal",
+		"headers": map[string]interface{}{
+			"Authorization": "Bearer ${secrets.API_KEY}",
+			"X-Request":     "${secrets['REQUEST_ID']}",
+			"Accept":        "application/json",
+		},
+		"max_tokens": 100,
+	}
+	resolved := map[string]interface{}{
+		"url":      "https://api.example.com/users",
+		"password": "literal",
+		"headers": map[string]interface{}{
+			"Authorization": "Bearer s3cr3t",
+			"X-Request":     "r-1",
+			"Accept":        "application/json",
+		},
+		"max_tokens": 100,
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"url":      "https://api.example.com/users",
+		"password": redactedValue,
+		"headers": map[string]interface{}{
+			"Authorization": redactedValue,
+			"X-Request":     redactedValue,
+			"Accept":        "application/json",
+		},
+		"max_tokens": 100,
+	}, redactParams(declared, resolved))
+}
+
+func TestFlowRuntime_RecordsNodeTrace(t *testing.T) {
+	// The loop node runs twice before the flow ends
+	runs := 0
+	loop := &NodeWrapper{
+		node: flowlib.NewNode(1, 0),
+		exec: func(input interface{}) (interface{}, error) {
+			runs++
+			return map[string]interface{}{"count": runs}, nil
+		},
+		post: func(shared, p, e interface{}) (flowlib.Action, error) {
+			if e.(map[string]interface{})["count"].(int) < 2 {
+				return "again", nil
+			}
+			return flowlib.DefaultAction, nil
+		},
+	}
+	loop.SetParams(map[string]interface{}{
+		"node_id":   "loop",
+		"node_type": "counter",
+		"url":       "https://example.com",
+		"api_key":   "k3y",
+	})
+	loop.Next("again", loop)
+
+	flowDef := &Flow{ID: "loop-flow", YAML: "loop"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "loop-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flowlib.NewFlow(loop), nil)
+
+	store := newCheckpointTestStore()
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store)
+
+	executionID, err := flowRuntime.Execute("test-account", "loop-flow", nil)
+	require.NoError(t, err)
+	waitForStatus(t, store, executionID, "completed")
+
+	tracer := flowRuntime.(ExecutionTracer)
+	trace, err := tracer.GetTrace("test-account", executionID)
+	require.NoError(t, err)
+	require.Len(t, trace, 2)
+
+	// Other accounts cannot read the trace
+	_, err = tracer.GetTrace("other-account", executionID)
+	assert.ErrorIs(t, err, ErrTraceNotFound)
+
+	for i, visit := range trace {
+		assert.Equal(t, executionID, visit.ExecutionID)
+		assert.Equal(t, i+1, visit.Sequence)
+		assert.Equal(t, i+1, visit.Visit)
+		assert.Equal(t, "loop", visit.NodeID)
+		assert.Equal(t, "counter", visit.NodeType)
+		assert.Equal(t, 0, visit.Retries)
+		assert.False(t, visit.EndTime.Before(visit.StartTime))
+		assert.Equal(t, "https://example.com", visit.Params["url"])
+		assert.Equal(t, redactedValue, visit.Params["api_key"])
+		assert.Equal(t, map[string]interface{}{"count": float64(i + 1)}, visit.Output)
+	}
+	assert.Equal(t, "again", trace[0].Action)
+	assert.Equal(t, flowlib.DefaultAction, trace[1].Action)
+	assert.False(t, trace[1].StartTime.Before(trace[0].EndTime))
+}
+
+// accountTestStore records the account of executions but keeps no
+// checkpoints, like a store whose checkpoints were pruned
+type accountTestStore struct {
+	*checkpointTestStore
+	accountsMu sync.Mutex
+	accounts   map[string]string
+}
+
+func (s *accountTestStore) SetExecutionAccountID(executionID, accountID string) error {
+	s.accountsMu.Lock()
+	defer s.accountsMu.Unlock()
+	s.accounts[executionID] = accountID
+	return nil
+}
+
+func (s *accountTestStore) GetExecutionAccountID(executionID string) (string, error) {
+	s.accountsMu.Lock()
+	defer s.accountsMu.Unlock()
+	return s.accounts[executionID], nil
+}
+
+func (s *accountTestStore) GetExecutionCheckpoints(executionID string) ([]ExecutionCheckpoint, error) {
+	return nil, nil
+}
+
+func TestFlowRuntime_TraceOwnershipFromExecutionRecord(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+
+	flowDef := &Flow{ID: "counting-flow", YAML: "counting"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "counting-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newCountingFlow(&visited, &mu), nil)
+
+	store := &accountTestStore{checkpointTestStore: newCheckpointTestStore(), accounts: make(map[string]string)}
+	flowRuntime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store).(*flowRuntime)
+
+	executionID, err := flowRuntime.Execute("test-account", "counting-flow", nil)
+	require.NoError(t, err)
+	waitForStatus(t, store.checkpointTestStore, executionID, "completed")
+	require.Eventually(t, func() bool {
+		flowRuntime.mu.RLock()
+		defer flowRuntime.mu.RUnlock()
+		_, active := flowRuntime.activeExecutions[executionID]
+		return !active
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// The finished execution is owned by the account of its record
+	trace, err := flowRuntime.GetTrace("test-account", executionID)
+	require.NoError(t, err)
+	assert.Len(t, trace, 3)
+
+	_, err = flowRuntime.GetTrace("other-account", executionID)
+	assert.ErrorIs(t, err, ErrTraceNotFound)
+}
@@ -0,0 +1,52 @@
+package runtime
+
+import (
+	"github.com/tcmartin/flowlib"
+)
+
+const (
+	// DefaultMaxNodeVisits bounds the node visits of an execution when
+	// neither the flow nor the runtime sets a limit
+	DefaultMaxNodeVisits = 10000
+
+	// DefaultMaxVisitsPerNode bounds the visits of any single node of an
+	// execution when neither the flow nor the runtime sets a limit
+	DefaultMaxVisitsPerNode = 1000
+)
+
+// VisitLimiter is implemented by runtimes whose executions can be bounded
+// in node visits, so that flows stuck in a cycle fail instead of running
+// forever
+type VisitLimiter interface {
+	// SetVisitLimits replaces the limits of the executions of flows that
+	// declare none. Zero fields fall back to DefaultMaxNodeVisits and
+	// DefaultMaxVisitsPerNode.
+	SetVisitLimits(limits flowlib.VisitLimits)
+}
+
+// SetVisitLimits implements VisitLimiter
+func (r *flowRuntime) SetVisitLimits(limits flowlib.VisitLimits) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.visitLimits = limits
+}
+
+// resolveVisitLimits returns the visit limits of an execution: the limits
+// declared by the flow, else those of the runtime, else the defaults
+func (r *flowRuntime) resolveVisitLimits(settings flowSettings) flowlib.VisitLimits {
+	r.mu.RLock()
+	limits := r.visitLimits
+	r.mu.RUnlock()
+
+	if settings.visitLimits.MaxVisits > 0 {
+		limits.MaxVisits = settings.visitLimits.MaxVisits
+	} else if limits.MaxVisits <= 0 {
+		limits.MaxVisits = DefaultMaxNodeVisits
+	}
+	if settings.visitLimits.MaxVisitsPerNode > 0 {
+		limits.MaxVisitsPerNode = settings.visitLimits.MaxVisitsPerNode
+	} else if limits.MaxVisitsPerNode <= 0 {
+		limits.MaxVisitsPerNode = DefaultMaxVisitsPerNode
+	}
+	return limits
+}
@@ -0,0 +1,110 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+// newCyclingFlow builds a flow whose "poll" and "check" nodes route to each
+// other forever
+func newCyclingFlow(runs *int32) *flowlib.Flow {
+	newNode := func(nodeID string) flowlib.Node {
+		node := flowlib.NewNode(1, 0)
+		node.SetParams(map[string]interface{}{"node_id": nodeID})
+		node.SetPrepFn(func(shared any) (any, error) {
+			atomic.AddInt32(runs, 1)
+			return nil, nil
+		})
+		return node
+	}
+	poll := newNode("poll")
+	check := newNode("check")
+	poll.Next(flowlib.DefaultAction, check)
+	check.Next(flowlib.DefaultAction, poll)
+	return flowlib.NewFlow(poll)
+}
+
+func newVisitLimitRuntime(yaml string, runs *int32) (FlowRuntime, *checkpointTestStore) {
+	flowDef := &Flow{ID: "cycling-flow", YAML: yaml}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "cycling-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newCyclingFlow(runs), nil)
+
+	store := newCheckpointTestStore()
+	return NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store), store
+}
+
+func TestFlowRuntime_MaxVisitsPerNode(t *testing.T) {
+	var runs int32
+	flowRuntime, store := newVisitLimitRuntime(`
+metadata:
+  name: Cycling Flow
+  max_visits_per_node: 3
+nodes:
+  poll:
+    type: base
+    next:
+      default: check
+  check:
+    type: base
+    next:
+      default: poll
+`, &runs)
+
+	executionID, err := flowRuntime.Execute("test-account", "cycling-flow", nil)
+	require.NoError(t, err)
+	status := waitForStatus(t, store, executionID, "failed")
+
+	assert.Contains(t, status.Error, `node "poll" would be visited 4 times, more than the limit of 3 visits per node`)
+	assert.Equal(t, int32(6), atomic.LoadInt32(&runs))
+}
+
+func TestFlowRuntime_MaxNodeVisits(t *testing.T) {
+	var runs int32
+	flowRuntime, store := newVisitLimitRuntime("cycling", &runs)
+
+	// Without limits in the flow, the limits of the runtime apply
+	limiter, ok := flowRuntime.(VisitLimiter)
+	require.True(t, ok)
+	limiter.SetVisitLimits(flowlib.VisitLimits{MaxVisits: 5})
+
+	executionID, err := flowRuntime.Execute("test-account", "cycling-flow", nil)
+	require.NoError(t, err)
+	status := waitForStatus(t, store, executionID, "failed")
+
+	assert.Contains(t, status.Error, `exceeded the limit of 5 node visits at node "check"`)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&runs))
+}
+
+func TestFlowRuntime_DefaultVisitLimits(t *testing.T) {
+	var runs int32
+	flowRuntime, store := newVisitLimitRuntime("cycling", &runs)
+
+	executionID, err := flowRuntime.Execute("test-account", "cycling-flow", nil)
+	require.NoError(t, err)
+	status := waitForStatus(t, store, executionID, "failed")
+
+	assert.Contains(t, status.Error, "limit of 1000 visits per node")
+	assert.Equal(t, int32(2*DefaultMaxVisitsPerNode), atomic.LoadInt32(&runs))
+}
+
+func TestFlow_VisitLimits(t *testing.T) {
+	var runs int32
+	flow := newCyclingFlow(&runs)
+
+	ctx := flowlib.WithVisitLimits(context.Background(), flowlib.VisitLimits{MaxVisitsPerNode: 2})
+	_, err := flow.RunWithContext(ctx, map[string]interface{}{})
+
+	var visitErr *flowlib.VisitLimitError
+	require.True(t, errors.As(err, &visitErr))
+	assert.Equal(t, "poll", visitErr.NodeID)
+	assert.True(t, visitErr.PerNode)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&runs))
+}
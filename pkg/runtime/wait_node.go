@@ -1,12 +1,48 @@
 package runtime
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/tcmartin/flowlib"
 )
 
+// durableWaitThreshold is the length from which wait and delay nodes park
+// their execution as a persisted timer instead of sleeping
+const durableWaitThreshold = time.Minute
+
+// waitUntil completes a wait or delay node with result once until passes.
+// Waits longer than durableWaitThreshold park the execution as a timer if the
+// execution store supports waits, so that they hold no goroutine and survive
+// restarts; the timer stores persisted, the copy of result the node completes
+// with on resume. Nodes inside loops, split branches and sub-flows always
+// sleep, as their execution cannot be parked.
+func waitUntil(ctx context.Context, params map[string]interface{}, until time.Time, result, persisted map[string]interface{}) (interface{}, error) {
+	if scope, ok := executionScopeFrom(ctx); ok && scope.depth == 0 && time.Until(until) > durableWaitThreshold {
+		if _, durable := scope.runtime.executionStore.(WaitStore); durable {
+			nodeID, _ := params["node_id"].(string)
+			execCtx := scope.execution
+			timer := ExecutionWait{
+				ExecutionID: execCtx.status.ID,
+				AccountID:   execCtx.accountID,
+				FlowID:      execCtx.flowID,
+				NodeID:      nodeID,
+				Deadline:    until,
+				Action:      flowlib.DefaultAction,
+				Result:      persisted,
+				CreatedAt:   time.Now(),
+			}
+			return nil, scope.runtime.park(execCtx, timer, nil)
+		}
+	}
+
+	if err := sleepWithContext(ctx, time.Until(until)); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // NewWaitNodeWrapper creates a new wait node wrapper
 // This node is more advanced than the delay node and can handle different types of wait conditions
 func NewWaitNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
@@ -16,7 +52,7 @@ func NewWaitNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 	// Create the wrapper
 	wrapper := &NodeWrapper{
 		node: baseNode,
-		exec: func(input interface{}) (interface{}, error) {
+		execWithContext: func(ctx context.Context, input interface{}) (interface{}, error) {
 			// Handle both old format (direct params) and new format (combined input)
 			var params map[string]interface{}
 			
@@ -53,12 +89,11 @@ func NewWaitNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 				}
 
 				// Wait
-				time.Sleep(duration)
-
-				return map[string]interface{}{
+				result := map[string]interface{}{
 					"waited_for": durationStr,
 					"type":       "duration",
-				}, nil
+				}
+				return waitUntil(ctx, params, time.Now().Add(duration), result, result)
 
 			case "until_time":
 				// Get time parameter
@@ -73,17 +108,12 @@ func NewWaitNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 					return nil, fmt.Errorf("invalid time format, expected RFC3339 (e.g., 2006-01-02T15:04:05Z): %w", err)
 				}
 
-				// Calculate wait duration
-				now := time.Now()
-				if targetTime.After(now) {
-					waitDuration := targetTime.Sub(now)
-					time.Sleep(waitDuration)
-				}
-
-				return map[string]interface{}{
+				// Wait, unless the time already passed
+				result := map[string]interface{}{
 					"waited_until": timeStr,
 					"type":         "until_time",
-				}, nil
+				}
+				return waitUntil(ctx, params, targetTime, result, result)
 
 			case "condition":
 				// This would use the JavaScript engine to evaluate a condition
@@ -115,7 +145,9 @@ func NewWaitNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 				for i := 0; i < maxAttempts; i++ {
 					// In a real implementation, we would evaluate the condition here
 					// For now, we'll just sleep for the interval
-					time.Sleep(interval)
+					if err := sleepWithContext(ctx, interval); err != nil {
+						return nil, err
+					}
 				}
 
 				return map[string]interface{}{
@@ -130,6 +162,7 @@ func NewWaitNodeWrapper(params map[string]interface{}) (flowlib.Node, error) {
 			}
 		},
 	}
+	wrapper.exec = backgroundExec(wrapper.execWithContext)
 
 	// Set the parameters
 	wrapper.SetParams(params)
@@ -1,10 +1,14 @@
 package runtime
 
 import (
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
 )
 
 func TestWaitNode(t *testing.T) {
@@ -92,3 +96,131 @@ func TestWaitNode(t *testing.T) {
 		assert.Equal(t, true, resultMap["completed"])
 	})
 }
+
+func TestWaitNode_ParksLongWaitsAsTimers(t *testing.T) {
+	store := newWaitTestStore()
+	var visited []string
+	var mu sync.Mutex
+
+	newFlow := func() *flowlib.Flow {
+		pause, err := NewWaitNodeWrapper(map[string]interface{}{
+			"node_id":  "pause",
+			"type":     "duration",
+			"duration": "72h",
+		})
+		require.NoError(t, err)
+
+		after := flowlib.NewNode(1, 0)
+		after.SetPrepFn(func(shared any) (any, error) {
+			mu.Lock()
+			visited = append(visited, "after")
+			mu.Unlock()
+			return nil, nil
+		})
+		pause.Next(flowlib.DefaultAction, after)
+		return flowlib.NewFlow(pause)
+	}
+
+	flowDef := &Flow{ID: "timer-flow", YAML: "timer"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "timer-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(newFlow(), nil)
+	runtime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store).(*flowRuntime)
+
+	executionID, err := runtime.Execute("test-account", "timer-flow", nil)
+	require.NoError(t, err)
+	waitForStatus(t, store.checkpointTestStore, executionID, "waiting")
+
+	timer, err := store.GetWait(executionID)
+	require.NoError(t, err)
+	assert.Equal(t, "pause", timer.NodeID)
+	assert.Empty(t, timer.Signal)
+	assert.Equal(t, flowlib.DefaultAction, timer.Action)
+	assert.WithinDuration(t, time.Now().Add(72*time.Hour), timer.Deadline, time.Minute)
+
+	// Timers cannot be signaled, and do not fire early
+	err = runtime.Signal(executionID, Signal{Name: "pause", Decision: DecisionApprove, AccountID: "test-account"})
+	assert.ErrorIs(t, err, ErrWaitNotFound)
+	runtime.expireWaits(store, time.Now())
+	assert.Equal(t, "waiting", store.status(executionID).Status)
+
+	// The timer fires even though the goroutine that parked it is gone
+	require.Eventually(t, func() bool {
+		runtime.mu.RLock()
+		defer runtime.mu.RUnlock()
+		_, active := runtime.activeExecutions[executionID]
+		return !active
+	}, 2*time.Second, 10*time.Millisecond)
+	runtime.expireWaits(store, time.Now().Add(73*time.Hour))
+	waitForStatus(t, store.checkpointTestStore, executionID, "completed")
+
+	mu.Lock()
+	assert.Equal(t, []string{"after"}, visited)
+	mu.Unlock()
+
+	checkpoints, err := store.GetExecutionCheckpoints(executionID)
+	require.NoError(t, err)
+	output := checkpoints[len(checkpoints)-1].Shared[NodeOutputsKey].(map[string]interface{})["pause"]
+	assert.Equal(t, map[string]interface{}{"waited_for": "72h", "type": "duration"}, output)
+}
+
+func TestDelayNode_ParksWithoutSecretsOrInternals(t *testing.T) {
+	store := newWaitTestStore()
+
+	delay, err := NewDelayNodeWrapper(map[string]interface{}{
+		"node_id":  "delay",
+		"duration": "72h",
+		"api_key":  "k3y",
+	})
+	require.NoError(t, err)
+
+	flowDef := &Flow{ID: "delay-flow", YAML: "delay"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "delay-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flowlib.NewFlow(delay), nil)
+	runtime := NewFlowRuntimeWithStore(mockRegistry, mockYAMLLoader, store)
+
+	executionID, err := runtime.Execute("test-account", "delay-flow", map[string]interface{}{"order": "42"})
+	require.NoError(t, err)
+	waitForStatus(t, store.checkpointTestStore, executionID, "waiting")
+
+	// The timer keeps the shape of the result without its secrets
+	timer, err := store.GetWait(executionID)
+	require.NoError(t, err)
+	params, ok := timer.Result["params"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "72h", params["duration"])
+	assert.Equal(t, redactedValue, params["api_key"])
+	input, ok := timer.Result["input"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "42", input["order"])
+	for key := range input {
+		assert.False(t, strings.HasPrefix(key, "_"), "internal key %s persisted", key)
+	}
+	assert.NotContains(t, input, "accountID")
+}
+
+func TestDelayNode_ReturnsParamsAndInput(t *testing.T) {
+	delay, err := NewDelayNodeWrapper(map[string]interface{}{"node_id": "delay", "duration": "1ms"})
+	require.NoError(t, err)
+
+	shared := map[string]interface{}{"input": map[string]interface{}{"order": "42"}}
+	_, err = delay.Run(shared)
+	require.NoError(t, err)
+
+	result := shared["result"].(map[string]interface{})
+	assert.Equal(t, "1ms", result["params"].(map[string]interface{})["duration"])
+	assert.Equal(t, map[string]interface{}{"order": "42"}, result["input"])
+
+	// Without a prepared input the node gets the shared state, which its
+	// result must not refer back to
+	shared = map[string]interface{}{"order": "42"}
+	_, err = delay.Run(shared)
+	require.NoError(t, err)
+
+	input := shared["result"].(map[string]interface{})["input"].(map[string]interface{})
+	assert.Equal(t, "42", input["order"])
+	assert.NotContains(t, input, "result")
+}
@@ -0,0 +1,168 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/auth"
+	"github.com/tcmartin/flowrunner/pkg/loader"
+)
+
+// ErrLeaseLost is returned when a worker no longer holds the lease on an
+// execution, because it expired and another worker claimed the execution
+var ErrLeaseLost = errors.New("execution lease lost")
+
+// WorkItem is an execution waiting in the work queue
+type WorkItem struct {
+	// ExecutionID is the ID of the queued execution
+	ExecutionID string `json:"execution_id"`
+
+	// AccountID is the account that owns the execution
+	AccountID string `json:"account_id"`
+
+	// FlowID is the ID of the flow to execute
+	FlowID string `json:"flow_id"`
+
+	// Input is the input of the execution
+	Input map[string]interface{} `json:"input,omitempty"`
+}
+
+// Lease is a worker's claim on a queued execution. It expires unless the
+// worker renews it with heartbeats, after which another worker can claim the
+// execution.
+type Lease struct {
+	// ID identifies the lease in the queue
+	ID string
+
+	// WorkerID is the worker holding the lease
+	WorkerID string
+
+	// Item is the claimed execution
+	Item WorkItem
+
+	// Redelivered is set when the lease of a previous worker expired
+	Redelivered bool
+}
+
+// WorkQueue hands executions from API processes to worker processes
+type WorkQueue interface {
+	// Enqueue adds an execution to the queue
+	Enqueue(ctx context.Context, item WorkItem) error
+
+	// Claim leases the next execution to a worker, preferring executions
+	// whose previous lease expired. It returns nil if no execution became
+	// available within the queue's blocking time.
+	Claim(ctx context.Context, workerID string) (*Lease, error)
+
+	// Heartbeat renews a lease, or returns ErrLeaseLost if it expired
+	Heartbeat(ctx context.Context, lease *Lease) error
+
+	// Complete removes a finished execution from the queue
+	Complete(ctx context.Context, lease *Lease) error
+}
+
+// NewFlowRuntimeWithQueue creates a FlowRuntime that hands executions to
+// workers through a work queue instead of running them itself. Status, logs
+// and cancellation go through the execution store, which must be shared with
+// the workers. secretVault may be nil.
+func NewFlowRuntimeWithQueue(registry FlowRegistry, yamlLoader loader.YAMLLoader, executionStore ExecutionStore, secretVault auth.SecretVault, queue WorkQueue) FlowRuntime {
+	r := &flowRuntime{
+		registry:         registry,
+		yamlLoader:       yamlLoader,
+		executionStore:   executionStore,
+		secretVault:      secretVault,
+		activeExecutions: make(map[string]*executionContext),
+		scheduler:        newScheduler(),
+		queue:            queue,
+	}
+	return r
+}
+
+// enqueue records a new queued execution and adds it to the work queue
+func (r *flowRuntime) enqueue(accountID string, status ExecutionStatus, input map[string]interface{}) (string, error) {
+	if err := r.saveNewExecution(status, accountID); err != nil {
+		return "", err
+	}
+
+	item := WorkItem{
+		ExecutionID: status.ID,
+		AccountID:   accountID,
+		FlowID:      status.FlowID,
+		Input:       input,
+	}
+	if err := r.queue.Enqueue(context.Background(), item); err != nil {
+		status.Status = "failed"
+		status.Error = fmt.Sprintf("failed to enqueue execution: %v", err)
+		status.EndTime = time.Now()
+		r.saveStatus(status)
+		return "", fmt.Errorf("failed to enqueue execution: %w", err)
+	}
+	return status.ID, nil
+}
+
+// cancelDistributed cancels an execution queued for or running on a worker.
+// Workers skip canceled executions when they claim them, and stop running
+// ones at their next heartbeat.
+func (r *flowRuntime) cancelDistributed(executionID string) error {
+	if r.executionStore == nil {
+		return fmt.Errorf("execution not found or not active: %s", executionID)
+	}
+	status, err := r.executionStore.GetExecution(executionID)
+	if err != nil || (status.Status != "queued" && status.Status != "running") {
+		return fmt.Errorf("execution not found or not active: %s", executionID)
+	}
+
+	status.Status = "canceled"
+	status.Error = "Execution was canceled by user"
+	status.EndTime = time.Now()
+	status.Progress = 100.0
+	status.QueuePosition = 0
+	r.saveStatus(status)
+
+	r.logExecution(executionID, "info", "Execution canceled by user", nil)
+	return nil
+}
+
+// latestCheckpoint returns the last checkpoint of an execution, or nil if
+// there is none
+func (r *flowRuntime) latestCheckpoint(executionID string) *ExecutionCheckpoint {
+	store, ok := r.executionStore.(CheckpointStore)
+	if !ok {
+		return nil
+	}
+	checkpoints, err := store.GetExecutionCheckpoints(executionID)
+	if err != nil {
+		fmt.Printf("Failed to get checkpoints of execution %s: %v\n", executionID, err)
+		return nil
+	}
+	if len(checkpoints) == 0 {
+		return nil
+	}
+	return &checkpoints[len(checkpoints)-1]
+}
+
+// isFinished reports whether an execution status is final
+func isFinished(status string) bool {
+	switch status {
+	case "completed", "failed", "canceled", "timeout":
+		return true
+	}
+	return false
+}
+
+// resumePoint returns the node a redelivered execution continues from and the
+// checkpoint taken before it, or nil if the execution starts over
+func (r *flowRuntime) resumePoint(flow *flowlib.Flow, executionID string) (flowlib.Node, *ExecutionCheckpoint) {
+	checkpoint := r.latestCheckpoint(executionID)
+	if checkpoint == nil {
+		return nil, nil
+	}
+	node := findNode(flow.Start(), checkpoint.NodeID)
+	if node == nil {
+		return nil, nil
+	}
+	return node, checkpoint
+}
@@ -0,0 +1,242 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tcmartin/flowlib"
+	"github.com/tcmartin/flowrunner/pkg/auth"
+	"github.com/tcmartin/flowrunner/pkg/loader"
+)
+
+// WorkerOptions configures a Worker
+type WorkerOptions struct {
+	// ID identifies the worker in the queue. Defaults to the host name
+	// followed by a random suffix.
+	ID string
+
+	// Concurrency is the number of executions the worker runs at once.
+	// Defaults to 1.
+	Concurrency int
+
+	// HeartbeatInterval is how often the leases of running executions are
+	// renewed. It must be well below the lease timeout of the queue.
+	// Defaults to 10 seconds.
+	HeartbeatInterval time.Duration
+
+	// VisitLimits bound the node visits of executions of flows that declare
+	// no limits. Defaults to DefaultMaxNodeVisits and DefaultMaxVisitsPerNode.
+	VisitLimits flowlib.VisitLimits
+
+	// SignalLinks configures the signed links in the notifications of
+	// executions that wait for a signal, such as approvals
+	SignalLinks SignalLinks
+}
+
+// Worker claims executions from a work queue and runs them. Executions of a
+// worker that stops or crashes are claimed by another worker once their lease
+// expires, and continue from their last checkpoint.
+type Worker struct {
+	runtime *flowRuntime
+	queue   WorkQueue
+	options WorkerOptions
+}
+
+// NewWorker creates a worker running the executions queued by a runtime
+// created with NewFlowRuntimeWithQueue. The execution store must be shared
+// with that runtime. secretVault may be nil.
+func NewWorker(registry FlowRegistry, yamlLoader loader.YAMLLoader, executionStore ExecutionStore, secretVault auth.SecretVault, queue WorkQueue, options WorkerOptions) *Worker {
+	if options.ID == "" {
+		host, _ := os.Hostname()
+		options.ID = fmt.Sprintf("%s-%s", host, uuid.New().String()[:8])
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.HeartbeatInterval <= 0 {
+		options.HeartbeatInterval = 10 * time.Second
+	}
+
+	return &Worker{
+		runtime: &flowRuntime{
+			registry:         registry,
+			yamlLoader:       yamlLoader,
+			executionStore:   executionStore,
+			secretVault:      secretVault,
+			activeExecutions: make(map[string]*executionContext),
+			scheduler:        newScheduler(),
+			visitLimits:      options.VisitLimits,
+			signalLinks:      options.SignalLinks,
+			// Executions resumed after waiting go back to the queue
+			queue: queue,
+		},
+		queue:   queue,
+		options: options,
+	}
+}
+
+// ID returns the ID of the worker
+func (w *Worker) ID() string {
+	return w.options.ID
+}
+
+// Run claims and runs executions until ctx is canceled. Executions still
+// running at that point are stopped without releasing their lease, so that
+// another worker picks them up. While it runs, the worker also resumes the
+// executions whose wait expired.
+func (w *Worker) Run(ctx context.Context) error {
+	w.runtime.startWaitExpiry(ctx)
+
+	slots := make(chan struct{}, w.options.Concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+
+		lease, err := w.queue.Claim(ctx, w.options.ID)
+		if err != nil || lease == nil {
+			<-slots
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				fmt.Printf("Worker %s failed to claim an execution: %v\n", w.options.ID, err)
+				select {
+				case <-time.After(time.Second):
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			w.process(ctx, lease)
+		}()
+	}
+}
+
+// process runs a claimed execution and completes its lease
+func (w *Worker) process(ctx context.Context, lease *Lease) {
+	r := w.runtime
+	item := lease.Item
+
+	status, err := r.executionStore.GetExecution(item.ExecutionID)
+	if err != nil {
+		fmt.Printf("Worker %s dropped execution %s: %v\n", w.options.ID, item.ExecutionID, err)
+		w.complete(lease)
+		return
+	}
+	if isFinished(status.Status) {
+		// Canceled while it was queued, or finished before a worker crashed
+		w.complete(lease)
+		return
+	}
+
+	flow, settings, err := r.loadFlow(item.AccountID, item.FlowID, "")
+	if err != nil {
+		status.Status = "failed"
+		status.Error = err.Error()
+		status.EndTime = time.Now()
+		r.saveStatus(status)
+		w.complete(lease)
+		return
+	}
+
+	node, checkpoint := r.resumePoint(flow, item.ExecutionID)
+	if status.Status == "queued" {
+		restartFlowTimeout(&status)
+	}
+	if status.Results == nil {
+		status.Results = make(map[string]interface{})
+	}
+	status.Status = "running"
+	status.QueuePosition = 0
+
+	execCtxParent, execCtx := r.trackExecution(ctx, item.AccountID, settings, status)
+	r.saveStatus(status)
+	r.logExecution(status.ID, "info", "Execution claimed by worker", map[string]interface{}{
+		"worker_id":   w.options.ID,
+		"redelivered": lease.Redelivered,
+	})
+
+	done := make(chan struct{})
+	var lost atomic.Bool
+	go w.heartbeat(lease, execCtx, done, &lost)
+
+	scoped := withExecutionScope(execCtxParent, r, execCtx)
+	if node != nil {
+		r.continueExecution(scoped, execCtx, flow, node, *checkpoint)
+	} else {
+		r.executeFlow(scoped, execCtx, flow, item.Input)
+	}
+	close(done)
+
+	if lost.Load() || ctx.Err() != nil {
+		// Another worker runs the execution now, or will once the lease expires
+		return
+	}
+	w.complete(lease)
+}
+
+// heartbeat renews the lease of a running execution until done is closed. The
+// execution is stopped if the lease is lost or the execution was canceled
+// through another process.
+func (w *Worker) heartbeat(lease *Lease, execCtx *executionContext, done <-chan struct{}, lost *atomic.Bool) {
+	r := w.runtime
+	executionID := lease.Item.ExecutionID
+
+	ticker := time.NewTicker(w.options.HeartbeatInterval)
+	defer ticker.Stop()
+	canceled := false
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		if err := w.queue.Heartbeat(context.Background(), lease); err != nil {
+			if errors.Is(err, ErrLeaseLost) {
+				lost.Store(true)
+				r.logExecution(executionID, "warning", "Worker lost the execution lease", map[string]interface{}{"worker_id": w.options.ID})
+				execCtx.cancel()
+				return
+			}
+			fmt.Printf("Worker %s failed to renew the lease of execution %s: %v\n", w.options.ID, executionID, err)
+			continue
+		}
+
+		if canceled {
+			continue
+		}
+		status, err := r.executionStore.GetExecution(executionID)
+		if err == nil && status.Status == "canceled" {
+			// Keep the canceled status when the execution stops
+			canceled = true
+			r.updateExecutionStatus(executionID, "canceled", status.Error, nil)
+			execCtx.cancel()
+		}
+	}
+}
+
+// complete removes a processed execution from the queue
+func (w *Worker) complete(lease *Lease) {
+	if err := w.queue.Complete(context.Background(), lease); err != nil {
+		fmt.Printf("Worker %s failed to complete execution %s: %v\n", w.options.ID, lease.Item.ExecutionID, err)
+	}
+}
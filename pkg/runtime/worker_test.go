@@ -0,0 +1,221 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmartin/flowlib"
+)
+
+// startTestWorker runs a worker on flow until the test ends
+func startTestWorker(t *testing.T, flow *flowlib.Flow, store ExecutionStore, queue WorkQueue) {
+	flowDef := &Flow{ID: "worker-flow", YAML: "worker"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "worker-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flow, nil)
+
+	worker := NewWorker(mockRegistry, mockYAMLLoader, store, nil, queue, WorkerOptions{
+		ID:                "worker-1",
+		HeartbeatInterval: 20 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, worker.Run(ctx))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// newQueueingRuntime returns a runtime that hands worker-flow executions to queue
+func newQueueingRuntime(flow *flowlib.Flow, store ExecutionStore, queue WorkQueue) FlowRuntime {
+	flowDef := &Flow{ID: "worker-flow", YAML: "worker"}
+	mockRegistry := new(MockEnhancedFlowRegistry)
+	mockRegistry.On("GetFlow", "test-account", "worker-flow").Return(flowDef, nil)
+	mockYAMLLoader := new(MockEnhancedYAMLLoader)
+	mockYAMLLoader.On("Parse", flowDef.YAML).Return(flow, nil)
+
+	return NewFlowRuntimeWithQueue(mockRegistry, mockYAMLLoader, store, nil, queue)
+}
+
+// unavailableWorkQueue fails the first enqueues
+type unavailableWorkQueue struct {
+	WorkQueue
+	failures int
+}
+
+func (q *unavailableWorkQueue) Enqueue(ctx context.Context, item WorkItem) error {
+	if q.failures > 0 {
+		q.failures--
+		return errors.New("queue unavailable")
+	}
+	return q.WorkQueue.Enqueue(ctx, item)
+}
+
+func TestWorker_RunsQueuedExecutions(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+	flow := newCountingFlow(&visited, &mu)
+
+	store := newCheckpointTestStore()
+	queue := newTestWorkQueue(t, time.Minute)
+	api := newQueueingRuntime(flow, store, queue)
+
+	executionID, err := api.Execute("test-account", "worker-flow", map[string]interface{}{"counter": 10})
+	require.NoError(t, err)
+
+	status, err := api.GetStatus(executionID)
+	require.NoError(t, err)
+	assert.Equal(t, "queued", status.Status)
+
+	startTestWorker(t, flow, store, queue)
+
+	waitForStatus(t, store, executionID, "completed")
+	mu.Lock()
+	assert.Equal(t, []string{"first", "second", "third"}, visited)
+	mu.Unlock()
+
+	// The completed execution left the queue
+	lease, err := queue.Claim(context.Background(), "worker-2")
+	require.NoError(t, err)
+	assert.Nil(t, lease)
+}
+
+func TestWorker_ResumesExecutionsOfCrashedWorkers(t *testing.T) {
+	var visited []string
+	var mu sync.Mutex
+	flow := newCountingFlow(&visited, &mu)
+
+	store := newCheckpointTestStore()
+	queue := newTestWorkQueue(t, 50*time.Millisecond)
+	api := newQueueingRuntime(flow, store, queue)
+
+	executionID, err := api.Execute("test-account", "worker-flow", nil)
+	require.NoError(t, err)
+
+	// A worker claims the execution, completes the first node and crashes
+	lease, err := queue.Claim(context.Background(), "crashed-worker")
+	require.NoError(t, err)
+	require.NotNil(t, lease)
+	store.mu.Lock()
+	status := store.executions[executionID]
+	status.Status = "running"
+	store.executions[executionID] = status
+	store.checkpoints[executionID] = []ExecutionCheckpoint{{
+		ExecutionID: executionID,
+		AccountID:   "test-account",
+		FlowID:      "worker-flow",
+		Step:        1,
+		NodeID:      "second",
+		Shared:      map[string]interface{}{"counter": float64(1)},
+	}}
+	store.mu.Unlock()
+
+	startTestWorker(t, flow, store, queue)
+
+	waitForStatus(t, store, executionID, "completed")
+	mu.Lock()
+	assert.Equal(t, []string{"second", "third"}, visited)
+	mu.Unlock()
+}
+
+func TestWorker_ResumesExpiredWaits(t *testing.T) {
+	pause, err := NewWaitNodeWrapper(map[string]interface{}{
+		"node_id":  "pause",
+		"type":     "duration",
+		"duration": "72h",
+	})
+	require.NoError(t, err)
+	var resumed atomic.Bool
+	after := flowlib.NewNode(1, 0)
+	after.SetPrepFn(func(shared any) (any, error) {
+		resumed.Store(true)
+		return nil, nil
+	})
+	pause.Next(flowlib.DefaultAction, after)
+	flow := flowlib.NewFlow(pause)
+
+	store := newWaitTestStore()
+	queue := newTestWorkQueue(t, time.Minute)
+	api := newQueueingRuntime(flow, store, queue)
+
+	executionID, err := api.Execute("test-account", "worker-flow", nil)
+	require.NoError(t, err)
+	startTestWorker(t, flow, store, queue)
+	waitForStatus(t, store.checkpointTestStore, executionID, "waiting")
+
+	// The timer is due; no API process runs, so the worker resumes it
+	store.mu.Lock()
+	timer := store.waits[executionID]
+	timer.Deadline = time.Now().Add(-time.Second)
+	store.waits[executionID] = timer
+	store.mu.Unlock()
+
+	waitForStatus(t, store.checkpointTestStore, executionID, "completed")
+	assert.True(t, resumed.Load())
+}
+
+func TestWorker_StopsExecutionsCanceledThroughAPI(t *testing.T) {
+	store := newCheckpointTestStore()
+	queue := newTestWorkQueue(t, time.Minute)
+	flow := flowlib.NewFlow(newSlowNode(t))
+	api := newQueueingRuntime(flow, store, queue)
+
+	// Canceled while queued: the worker never runs it
+	queuedID, err := api.Execute("test-account", "worker-flow", nil)
+	require.NoError(t, err)
+	require.NoError(t, api.Cancel(queuedID))
+	assert.Equal(t, "canceled", store.status(queuedID).Status)
+
+	runningID, err := api.Execute("test-account", "worker-flow", nil)
+	require.NoError(t, err)
+
+	startTestWorker(t, flow, store, queue)
+	waitForStatus(t, store, runningID, "running")
+
+	// Canceled while running: the worker stops it at its next heartbeat
+	require.NoError(t, api.Cancel(runningID))
+	waitForStatus(t, store, runningID, "canceled")
+
+	assert.Eventually(t, func() bool {
+		logs, _ := store.GetExecutionLogs(runningID)
+		for _, log := range logs {
+			if log.Message == "Flow execution stopped after cancellation" {
+				return true
+			}
+		}
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "canceled", store.status(runningID).Status)
+	assert.Error(t, api.Cancel(runningID))
+}
+
+func TestFlowRuntime_FailedEnqueueReleasesIdempotencyKey(t *testing.T) {
+	store := newCheckpointTestStore()
+	queue := &unavailableWorkQueue{WorkQueue: newTestWorkQueue(t, time.Minute), failures: 1}
+	api := newQueueingRuntime(flowlib.NewFlow(newSlowNode(t)), store, queue).(OptionsExecutor)
+
+	options := ExecuteOptions{IdempotencyKey: "webhook-delivery-1"}
+	_, err := api.ExecuteWithOptions("test-account", "worker-flow", nil, options)
+	require.ErrorContains(t, err, "queue unavailable")
+
+	// The retry starts a new execution instead of returning the failed one
+	executionID, err := api.ExecuteWithOptions("test-account", "worker-flow", nil, options)
+	require.NoError(t, err)
+	assert.Equal(t, "queued", store.status(executionID).Status)
+
+	retried, err := api.ExecuteWithOptions("test-account", "worker-flow", nil, options)
+	require.NoError(t, err)
+	assert.Equal(t, executionID, retried)
+}
@@ -2,7 +2,9 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"time"
 
@@ -865,10 +867,32 @@ func (s *DynamoDBSecretStore) DeleteSecret(accountID, key string) error {
 
 // DynamoDBExecutionStore implements the ExecutionStore interface using DynamoDB
 type DynamoDBExecutionStore struct {
-	client        dynamodbiface.DynamoDBAPI
-	tablePrefix   string
-	execTableName string
-	logsTableName string
+	client               dynamodbiface.DynamoDBAPI
+	tablePrefix          string
+	execTableName        string
+	logsTableName        string
+	checkpointsTableName string
+	idempotencyTableName string
+	tracesTableName      string
+	waitsTableName       string
+	leasesTableName      string
+
+	// checkpointRetention is how many checkpoints are kept per execution
+	checkpointRetention int
+}
+
+// DefaultCheckpointRetention is how many of the latest checkpoints of an
+// execution the DynamoDB execution store keeps
+const DefaultCheckpointRetention = 100
+
+// maxDynamoDBItemSize is the largest item DynamoDB accepts
+const maxDynamoDBItemSize = 400 * 1024
+
+// SetCheckpointRetention sets how many of the latest checkpoints are kept per
+// execution. Older checkpoints are deleted as new ones are saved, so replays
+// can only start from nodes among the last checkpoints. Zero keeps them all.
+func (s *DynamoDBExecutionStore) SetCheckpointRetention(checkpoints int) {
+	s.checkpointRetention = checkpoints
 }
 
 // SetExecutionAccountID sets the account ID for an execution in its metadata
@@ -899,10 +923,16 @@ func (s *DynamoDBExecutionStore) SetExecutionAccountID(executionID, accountID st
 // NewDynamoDBExecutionStore creates a new DynamoDB execution store
 func NewDynamoDBExecutionStore(client dynamodbiface.DynamoDBAPI, tablePrefix string) *DynamoDBExecutionStore {
 	return &DynamoDBExecutionStore{
-		client:        client,
-		tablePrefix:   tablePrefix,
-		execTableName: tablePrefix + "executions",
-		logsTableName: tablePrefix + "execution_logs",
+		client:               client,
+		tablePrefix:          tablePrefix,
+		execTableName:        tablePrefix + "executions",
+		logsTableName:        tablePrefix + "execution_logs",
+		checkpointsTableName: tablePrefix + "execution_checkpoints",
+		idempotencyTableName: tablePrefix + "execution_idempotency_keys",
+		tracesTableName:      tablePrefix + "execution_traces",
+		waitsTableName:       tablePrefix + "execution_waits",
+		leasesTableName:      tablePrefix + "execution_leases",
+		checkpointRetention:  DefaultCheckpointRetention,
 	}
 }
 
@@ -918,6 +948,31 @@ func (s *DynamoDBExecutionStore) Initialize() error {
 		return err
 	}
 
+	// Initialize execution checkpoints table
+	if err := s.initializeExecutionCheckpointsTable(); err != nil {
+		return err
+	}
+
+	// Initialize idempotency keys table
+	if err := s.initializeIdempotencyKeysTable(); err != nil {
+		return err
+	}
+
+	// Initialize execution traces table
+	if err := s.initializeExecutionTracesTable(); err != nil {
+		return err
+	}
+
+	// Initialize execution waits table
+	if err := s.initializeExecutionWaitsTable(); err != nil {
+		return err
+	}
+
+	// Initialize execution leases table
+	if err := s.initializeExecutionLeasesTable(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -1057,14 +1112,288 @@ func (s *DynamoDBExecutionStore) initializeExecutionLogsTable() error {
 	return fmt.Errorf("failed to check if execution logs table exists: %w", err)
 }
 
+// initializeExecutionCheckpointsTable creates the execution checkpoints table if it doesn't exist
+func (s *DynamoDBExecutionStore) initializeExecutionCheckpointsTable() error {
+	// Check if table exists
+	_, err := s.client.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(s.checkpointsTableName),
+	})
+
+	if err == nil {
+		// Table exists
+		return nil
+	}
+
+	// Check if error is "table not found"
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		// Create table
+		_, err = s.client.CreateTable(&dynamodb.CreateTableInput{
+			TableName: aws.String(s.checkpointsTableName),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{
+				{
+					AttributeName: aws.String("ExecutionID"),
+					AttributeType: aws.String("S"),
+				},
+				{
+					AttributeName: aws.String("Step"),
+					AttributeType: aws.String("N"),
+				},
+			},
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{
+					AttributeName: aws.String("ExecutionID"),
+					KeyType:       aws.String("HASH"),
+				},
+				{
+					AttributeName: aws.String("Step"),
+					KeyType:       aws.String("RANGE"),
+				},
+			},
+			BillingMode: aws.String("PAY_PER_REQUEST"),
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to create execution checkpoints table: %w", err)
+		}
+
+		// Wait for table to be created
+		err = s.client.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+			TableName: aws.String(s.checkpointsTableName),
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to wait for execution checkpoints table creation: %w", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("failed to check if execution checkpoints table exists: %w", err)
+}
+
+// initializeIdempotencyKeysTable creates the idempotency keys table if it doesn't exist
+func (s *DynamoDBExecutionStore) initializeIdempotencyKeysTable() error {
+	// Check if table exists
+	_, err := s.client.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(s.idempotencyTableName),
+	})
+
+	if err == nil {
+		// Table exists
+		return nil
+	}
+
+	// Check if error is "table not found"
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		// Create table
+		_, err = s.client.CreateTable(&dynamodb.CreateTableInput{
+			TableName: aws.String(s.idempotencyTableName),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{
+				{
+					AttributeName: aws.String("Key"),
+					AttributeType: aws.String("S"),
+				},
+			},
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{
+					AttributeName: aws.String("Key"),
+					KeyType:       aws.String("HASH"),
+				},
+			},
+			BillingMode: aws.String("PAY_PER_REQUEST"),
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to create idempotency keys table: %w", err)
+		}
+
+		// Wait for table to be created
+		err = s.client.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+			TableName: aws.String(s.idempotencyTableName),
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to wait for idempotency keys table creation: %w", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("failed to check if idempotency keys table exists: %w", err)
+}
+
+// initializeExecutionLeasesTable creates the execution leases table if it doesn't exist
+func (s *DynamoDBExecutionStore) initializeExecutionLeasesTable() error {
+	// Check if table exists
+	_, err := s.client.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(s.leasesTableName),
+	})
+
+	if err == nil {
+		// Table exists
+		return nil
+	}
+
+	// Check if error is "table not found"
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		// Create table
+		_, err = s.client.CreateTable(&dynamodb.CreateTableInput{
+			TableName: aws.String(s.leasesTableName),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{
+				{
+					AttributeName: aws.String("ExecutionID"),
+					AttributeType: aws.String("S"),
+				},
+			},
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{
+					AttributeName: aws.String("ExecutionID"),
+					KeyType:       aws.String("HASH"),
+				},
+			},
+			BillingMode: aws.String("PAY_PER_REQUEST"),
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to create execution leases table: %w", err)
+		}
+
+		// Wait for table to be created
+		err = s.client.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+			TableName: aws.String(s.leasesTableName),
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to wait for execution leases table creation: %w", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("failed to check if execution leases table exists: %w", err)
+}
+
+// initializeExecutionWaitsTable creates the execution waits table if it doesn't exist
+func (s *DynamoDBExecutionStore) initializeExecutionWaitsTable() error {
+	// Check if table exists
+	_, err := s.client.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(s.waitsTableName),
+	})
+
+	if err == nil {
+		// Table exists
+		return nil
+	}
+
+	// Check if error is "table not found"
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		// Create table
+		_, err = s.client.CreateTable(&dynamodb.CreateTableInput{
+			TableName: aws.String(s.waitsTableName),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{
+				{
+					AttributeName: aws.String("ExecutionID"),
+					AttributeType: aws.String("S"),
+				},
+			},
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{
+					AttributeName: aws.String("ExecutionID"),
+					KeyType:       aws.String("HASH"),
+				},
+			},
+			BillingMode: aws.String("PAY_PER_REQUEST"),
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to create execution waits table: %w", err)
+		}
+
+		// Wait for table to be created
+		err = s.client.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+			TableName: aws.String(s.waitsTableName),
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to wait for execution waits table creation: %w", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("failed to check if execution waits table exists: %w", err)
+}
+
+// initializeExecutionTracesTable creates the execution traces table if it doesn't exist
+func (s *DynamoDBExecutionStore) initializeExecutionTracesTable() error {
+	// Check if table exists
+	_, err := s.client.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(s.tracesTableName),
+	})
+
+	if err == nil {
+		// Table exists
+		return nil
+	}
+
+	// Check if error is "table not found"
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		// Create table
+		_, err = s.client.CreateTable(&dynamodb.CreateTableInput{
+			TableName: aws.String(s.tracesTableName),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{
+				{
+					AttributeName: aws.String("ExecutionID"),
+					AttributeType: aws.String("S"),
+				},
+				{
+					AttributeName: aws.String("Sequence"),
+					AttributeType: aws.String("N"),
+				},
+			},
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{
+					AttributeName: aws.String("ExecutionID"),
+					KeyType:       aws.String("HASH"),
+				},
+				{
+					AttributeName: aws.String("Sequence"),
+					KeyType:       aws.String("RANGE"),
+				},
+			},
+			BillingMode: aws.String("PAY_PER_REQUEST"),
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to create execution traces table: %w", err)
+		}
+
+		// Wait for table to be created
+		err = s.client.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+			TableName: aws.String(s.tracesTableName),
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to wait for execution traces table creation: %w", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("failed to check if execution traces table exists: %w", err)
+}
+
 // SaveExecution persists execution data
 func (s *DynamoDBExecutionStore) SaveExecution(execution runtime.ExecutionStatus) error {
-	// Get account ID from metadata if available
+	// Get account ID from metadata if available, otherwise keep the one
+	// recorded for the execution
 	accountID := "default-account"
-	if execution.Metadata != nil {
-		if id, ok := execution.Metadata["account_id"]; ok && id != "" {
-			accountID = id
-		}
+	if id := execution.Metadata["account_id"]; id != "" {
+		accountID = id
+	} else if id, err := s.GetExecutionAccountID(execution.ID); err == nil {
+		accountID = id
+	} else if !errors.Is(err, ErrExecutionNotFound) {
+		return err
 	}
 
 	// Convert time fields to Unix timestamps for DynamoDB
@@ -1127,9 +1456,8 @@ func (s *DynamoDBExecutionStore) SaveExecution(execution runtime.ExecutionStatus
 	return nil
 }
 
-// GetExecution retrieves execution data
-func (s *DynamoDBExecutionStore) GetExecution(executionID string) (runtime.ExecutionStatus, error) {
-	// Get execution
+// GetExecutionAccountID returns the account recorded for an execution
+func (s *DynamoDBExecutionStore) GetExecutionAccountID(executionID string) (string, error) {
 	result, err := s.client.GetItem(&dynamodb.GetItemInput{
 		TableName: aws.String(s.execTableName),
 		Key: map[string]*dynamodb.AttributeValue{
@@ -1137,16 +1465,40 @@ func (s *DynamoDBExecutionStore) GetExecution(executionID string) (runtime.Execu
 				S: aws.String(executionID),
 			},
 		},
+		ProjectionExpression: aws.String("AccountID"),
 	})
-
 	if err != nil {
-		return runtime.ExecutionStatus{}, fmt.Errorf("failed to get execution: %w", err)
+		return "", fmt.Errorf("failed to get execution account ID: %w", err)
 	}
-
 	if result.Item == nil {
-		return runtime.ExecutionStatus{}, ErrExecutionNotFound
+		return "", ErrExecutionNotFound
 	}
-
+	if v, ok := result.Item["AccountID"]; ok && v.S != nil {
+		return *v.S, nil
+	}
+	return "", nil
+}
+
+// GetExecution retrieves execution data
+func (s *DynamoDBExecutionStore) GetExecution(executionID string) (runtime.ExecutionStatus, error) {
+	// Get execution
+	result, err := s.client.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(s.execTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ID": {
+				S: aws.String(executionID),
+			},
+		},
+	})
+
+	if err != nil {
+		return runtime.ExecutionStatus{}, fmt.Errorf("failed to get execution: %w", err)
+	}
+
+	if result.Item == nil {
+		return runtime.ExecutionStatus{}, ErrExecutionNotFound
+	}
+
 	// Create execution status manually from the DynamoDB item
 	execution := runtime.ExecutionStatus{
 		ID: executionID,
@@ -1359,6 +1711,717 @@ func (s *DynamoDBExecutionStore) GetExecutionLogs(executionID string) ([]runtime
 	return logs, nil
 }
 
+// dynamoDBCheckpointItem is the stored form of an execution checkpoint
+type dynamoDBCheckpointItem struct {
+	ExecutionID string `json:"ExecutionID"`
+	Step        int    `json:"Step"`
+	AccountID   string `json:"AccountID"`
+	FlowID      string `json:"FlowID"`
+	NodeID      string `json:"NodeID"`
+	Shared      string `json:"Shared"`
+	CreatedAt   int64  `json:"CreatedAt"`
+}
+
+// SaveCheckpoint persists a checkpoint for an execution and deletes the
+// checkpoint that falls out of the retention
+func (s *DynamoDBExecutionStore) SaveCheckpoint(checkpoint runtime.ExecutionCheckpoint) error {
+	// Store the shared state as JSON to keep arbitrary nesting intact
+	sharedJSON, err := json.Marshal(checkpoint.Shared)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint state: %w", err)
+	}
+
+	av, err := dynamodbattribute.MarshalMap(dynamoDBCheckpointItem{
+		ExecutionID: checkpoint.ExecutionID,
+		Step:        checkpoint.Step,
+		AccountID:   checkpoint.AccountID,
+		FlowID:      checkpoint.FlowID,
+		NodeID:      checkpoint.NodeID,
+		Shared:      string(sharedJSON),
+		CreatedAt:   checkpoint.CreatedAt.UnixNano(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	// DynamoDB rejects larger items with a generic validation error
+	if size := itemSize(av); size > maxDynamoDBItemSize {
+		return fmt.Errorf("checkpoint of %d bytes exceeds the DynamoDB item limit of %d bytes; keep large values out of the shared state", size, maxDynamoDBItemSize)
+	}
+
+	_, err = s.client.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.checkpointsTableName),
+		Item:      av,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	// Steps are consecutive, so one checkpoint falls out per step
+	if s.checkpointRetention > 0 && checkpoint.Step >= s.checkpointRetention {
+		_, err = s.client.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String(s.checkpointsTableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"ExecutionID": {S: aws.String(checkpoint.ExecutionID)},
+				"Step":        {N: aws.String(strconv.Itoa(checkpoint.Step - s.checkpointRetention))},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete old checkpoint: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// itemSize estimates the size DynamoDB counts for an item: the lengths of the
+// attribute names and values
+func itemSize(item map[string]*dynamodb.AttributeValue) int {
+	size := 0
+	for name, value := range item {
+		size += len(name) + len(aws.StringValue(value.S)) + len(aws.StringValue(value.N)) + len(value.B)
+	}
+	return size
+}
+
+// ListInterruptedExecutions returns the latest checkpoint of every running or queued execution
+func (s *DynamoDBExecutionStore) ListInterruptedExecutions() ([]runtime.ExecutionCheckpoint, error) {
+	// This only runs on startup, so a scan of the executions table is acceptable
+	checkpoints := make([]runtime.ExecutionCheckpoint, 0)
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		result, err := s.client.Scan(&dynamodb.ScanInput{
+			TableName:         aws.String(s.execTableName),
+			ExclusiveStartKey: startKey,
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan executions: %w", err)
+		}
+
+		for _, item := range result.Items {
+			if v, ok := item["Status"]; !ok || v.S == nil || (*v.S != "running" && *v.S != "queued") {
+				continue
+			}
+			if v, ok := item["ID"]; ok && v.S != nil {
+				checkpoint, found, err := s.getLatestCheckpoint(*v.S)
+				if err != nil {
+					return nil, err
+				}
+				if found {
+					checkpoints = append(checkpoints, checkpoint)
+				}
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return checkpoints, nil
+		}
+		startKey = result.LastEvaluatedKey
+	}
+}
+
+// getLatestCheckpoint returns the checkpoint with the highest step for an execution
+func (s *DynamoDBExecutionStore) getLatestCheckpoint(executionID string) (runtime.ExecutionCheckpoint, bool, error) {
+	keyCond := expression.Key("ExecutionID").Equal(expression.Value(executionID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return runtime.ExecutionCheckpoint{}, false, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := s.client.Query(&dynamodb.QueryInput{
+		TableName:                 aws.String(s.checkpointsTableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(false), // Sort by Step descending
+		Limit:                     aws.Int64(1),
+	})
+
+	if err != nil {
+		return runtime.ExecutionCheckpoint{}, false, fmt.Errorf("failed to query checkpoints: %w", err)
+	}
+	if len(result.Items) == 0 {
+		return runtime.ExecutionCheckpoint{}, false, nil
+	}
+
+	checkpoint, err := checkpointFromItem(result.Items[0])
+	if err != nil {
+		return runtime.ExecutionCheckpoint{}, false, err
+	}
+	return checkpoint, true, nil
+}
+
+// GetExecutionCheckpoints retrieves the checkpoints of an execution ordered by step
+func (s *DynamoDBExecutionStore) GetExecutionCheckpoints(executionID string) ([]runtime.ExecutionCheckpoint, error) {
+	keyCond := expression.Key("ExecutionID").Equal(expression.Value(executionID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	checkpoints := make([]runtime.ExecutionCheckpoint, 0)
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		// A query page holds at most 1 MB, a few checkpoints of large states
+		result, err := s.client.Query(&dynamodb.QueryInput{
+			TableName:                 aws.String(s.checkpointsTableName),
+			KeyConditionExpression:    expr.KeyCondition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			ScanIndexForward:          aws.Bool(true), // Sort by Step ascending
+			ExclusiveStartKey:         startKey,
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query checkpoints: %w", err)
+		}
+
+		for _, item := range result.Items {
+			checkpoint, err := checkpointFromItem(item)
+			if err != nil {
+				return nil, err
+			}
+			checkpoints = append(checkpoints, checkpoint)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return checkpoints, nil
+		}
+		startKey = result.LastEvaluatedKey
+	}
+}
+
+// checkpointFromItem converts a stored checkpoint back into a checkpoint
+func checkpointFromItem(item map[string]*dynamodb.AttributeValue) (runtime.ExecutionCheckpoint, error) {
+	var checkpointItem dynamoDBCheckpointItem
+	if err := dynamodbattribute.UnmarshalMap(item, &checkpointItem); err != nil {
+		return runtime.ExecutionCheckpoint{}, fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+	}
+
+	checkpoint := runtime.ExecutionCheckpoint{
+		ExecutionID: checkpointItem.ExecutionID,
+		Step:        checkpointItem.Step,
+		AccountID:   checkpointItem.AccountID,
+		FlowID:      checkpointItem.FlowID,
+		NodeID:      checkpointItem.NodeID,
+		CreatedAt:   time.Unix(0, checkpointItem.CreatedAt),
+	}
+	if checkpointItem.Shared != "" {
+		if err := json.Unmarshal([]byte(checkpointItem.Shared), &checkpoint.Shared); err != nil {
+			return runtime.ExecutionCheckpoint{}, fmt.Errorf("failed to unmarshal checkpoint state: %w", err)
+		}
+	}
+	return checkpoint, nil
+}
+
+// dynamoDBTraceItem is the stored form of a node visit
+type dynamoDBTraceItem struct {
+	ExecutionID string `json:"ExecutionID"`
+	Sequence    int    `json:"Sequence"`
+	NodeID      string `json:"NodeID"`
+	NodeType    string `json:"NodeType,omitempty"`
+	Visit       int    `json:"Visit"`
+	StartTime   int64  `json:"StartTime"`
+	EndTime     int64  `json:"EndTime"`
+	Action      string `json:"Action,omitempty"`
+	Retries     int    `json:"Retries"`
+	Params      string `json:"Params,omitempty"`
+	Output      string `json:"Output,omitempty"`
+	Error       string `json:"Error,omitempty"`
+}
+
+// SaveNodeTrace persists a node visit of an execution
+func (s *DynamoDBExecutionStore) SaveNodeTrace(trace runtime.NodeTrace) error {
+	item := dynamoDBTraceItem{
+		ExecutionID: trace.ExecutionID,
+		Sequence:    trace.Sequence,
+		NodeID:      trace.NodeID,
+		NodeType:    trace.NodeType,
+		Visit:       trace.Visit,
+		StartTime:   trace.StartTime.UnixNano(),
+		EndTime:     trace.EndTime.UnixNano(),
+		Action:      trace.Action,
+		Retries:     trace.Retries,
+		Error:       trace.Error,
+	}
+
+	// Params and output are stored as JSON to keep arbitrary nesting intact
+	if trace.Params != nil {
+		paramsJSON, err := json.Marshal(trace.Params)
+		if err != nil {
+			return fmt.Errorf("failed to marshal trace params: %w", err)
+		}
+		item.Params = string(paramsJSON)
+	}
+	if trace.Output != nil {
+		outputJSON, err := json.Marshal(trace.Output)
+		if err != nil {
+			return fmt.Errorf("failed to marshal trace output: %w", err)
+		}
+		item.Output = string(outputJSON)
+	}
+
+	av, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal node trace: %w", err)
+	}
+
+	_, err = s.client.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.tracesTableName),
+		Item:      av,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to save node trace: %w", err)
+	}
+
+	return nil
+}
+
+// GetExecutionTrace retrieves the node visits of an execution ordered by sequence
+func (s *DynamoDBExecutionStore) GetExecutionTrace(executionID string) ([]runtime.NodeTrace, error) {
+	keyCond := expression.Key("ExecutionID").Equal(expression.Value(executionID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := s.client.Query(&dynamodb.QueryInput{
+		TableName:                 aws.String(s.tracesTableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(true), // Sort by Sequence ascending
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query node traces: %w", err)
+	}
+
+	traces := make([]runtime.NodeTrace, 0, len(result.Items))
+	for _, item := range result.Items {
+		var traceItem dynamoDBTraceItem
+		if err := dynamodbattribute.UnmarshalMap(item, &traceItem); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal node trace: %w", err)
+		}
+
+		trace := runtime.NodeTrace{
+			ExecutionID: traceItem.ExecutionID,
+			Sequence:    traceItem.Sequence,
+			NodeID:      traceItem.NodeID,
+			NodeType:    traceItem.NodeType,
+			Visit:       traceItem.Visit,
+			StartTime:   time.Unix(0, traceItem.StartTime),
+			EndTime:     time.Unix(0, traceItem.EndTime),
+			Action:      traceItem.Action,
+			Retries:     traceItem.Retries,
+			Error:       traceItem.Error,
+		}
+		if traceItem.Params != "" {
+			if err := json.Unmarshal([]byte(traceItem.Params), &trace.Params); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal trace params: %w", err)
+			}
+		}
+		if traceItem.Output != "" {
+			if err := json.Unmarshal([]byte(traceItem.Output), &trace.Output); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal trace output: %w", err)
+			}
+		}
+
+		traces = append(traces, trace)
+	}
+
+	sort.Slice(traces, func(i, j int) bool {
+		return traces[i].Sequence < traces[j].Sequence
+	})
+
+	return traces, nil
+}
+
+// dynamoDBIdempotencyItem is the stored form of an idempotency key
+type dynamoDBIdempotencyItem struct {
+	Key         string `json:"Key"`
+	ExecutionID string `json:"ExecutionID"`
+	CreatedAt   int64  `json:"CreatedAt"`
+}
+
+// ClaimIdempotencyKey records executionID under the key of an account unless
+// the key was recorded after since, and returns the recorded execution ID
+func (s *DynamoDBExecutionStore) ClaimIdempotencyKey(accountID, key, executionID string, since time.Time) (string, error) {
+	itemKey := map[string]*dynamodb.AttributeValue{
+		"Key": {S: aws.String(accountID + "#" + key)},
+	}
+
+	recorded, err := s.getIdempotencyItem(itemKey)
+	if err != nil {
+		return "", err
+	}
+	if recorded != nil && recorded.CreatedAt > since.UnixNano() {
+		return recorded.ExecutionID, nil
+	}
+
+	av, err := dynamodbattribute.MarshalMap(dynamoDBIdempotencyItem{
+		Key:         accountID + "#" + key,
+		ExecutionID: executionID,
+		CreatedAt:   time.Now().UnixNano(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal idempotency key: %w", err)
+	}
+
+	// Only one of several concurrent requests with the same key gets to write
+	_, err = s.client.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(s.idempotencyTableName),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR CreatedAt <= :since"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key": aws.String("Key"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":since": {N: aws.String(strconv.FormatInt(since.UnixNano(), 10))},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		recorded, err = s.getIdempotencyItem(itemKey)
+		if err != nil {
+			return "", err
+		}
+		if recorded == nil {
+			return "", fmt.Errorf("idempotency key %s disappeared while claiming it", key)
+		}
+		return recorded.ExecutionID, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save idempotency key: %w", err)
+	}
+
+	return executionID, nil
+}
+
+// ReleaseIdempotencyKey removes the key of an account if it still records
+// executionID
+func (s *DynamoDBExecutionStore) ReleaseIdempotencyKey(accountID, key, executionID string) error {
+	_, err := s.client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(s.idempotencyTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Key": {S: aws.String(accountID + "#" + key)},
+		},
+		ConditionExpression: aws.String("ExecutionID = :executionID"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":executionID": {S: aws.String(executionID)},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		// Another execution claimed the key since
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// dynamoDBLeaseItem is the stored form of an execution lease
+type dynamoDBLeaseItem struct {
+	ExecutionID string `json:"ExecutionID"`
+	Owner       string `json:"Owner"`
+	LeaseUntil  int64  `json:"LeaseUntil"`
+}
+
+// ClaimExecution makes owner the owner of an execution until the given time,
+// unless another owner holds a lease that has not expired
+func (s *DynamoDBExecutionStore) ClaimExecution(executionID, owner string, until time.Time) (bool, error) {
+	now := time.Now()
+	result, err := s.client.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(s.leasesTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ExecutionID": {S: aws.String(executionID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get execution lease: %w", err)
+	}
+	if result.Item != nil {
+		var lease dynamoDBLeaseItem
+		if err := dynamodbattribute.UnmarshalMap(result.Item, &lease); err != nil {
+			return false, fmt.Errorf("failed to unmarshal execution lease: %w", err)
+		}
+		if lease.Owner != owner && lease.LeaseUntil > now.UnixNano() {
+			return false, nil
+		}
+	}
+
+	av, err := dynamodbattribute.MarshalMap(dynamoDBLeaseItem{
+		ExecutionID: executionID,
+		Owner:       owner,
+		LeaseUntil:  until.UnixNano(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal execution lease: %w", err)
+	}
+
+	// Only one of several concurrent claims gets to write
+	_, err = s.client.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(s.leasesTableName),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(ExecutionID) OR #owner = :owner OR LeaseUntil < :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("Owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(owner)},
+			":now":   {N: aws.String(strconv.FormatInt(now.UnixNano(), 10))},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save execution lease: %w", err)
+	}
+
+	return true, nil
+}
+
+// ReleaseExecution ends the lease of owner on an execution
+func (s *DynamoDBExecutionStore) ReleaseExecution(executionID, owner string) error {
+	_, err := s.client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(s.leasesTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ExecutionID": {S: aws.String(executionID)},
+		},
+		ConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("Owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(owner)},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		// Another owner took over the execution, or the lease is gone
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to release execution: %w", err)
+	}
+
+	return nil
+}
+
+// getIdempotencyItem returns the stored idempotency key, or nil if there is none
+func (s *DynamoDBExecutionStore) getIdempotencyItem(key map[string]*dynamodb.AttributeValue) (*dynamoDBIdempotencyItem, error) {
+	result, err := s.client.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(s.idempotencyTableName),
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var item dynamoDBIdempotencyItem
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal idempotency key: %w", err)
+	}
+	return &item, nil
+}
+
+// dynamoDBWaitItem is the stored form of an execution wait
+type dynamoDBWaitItem struct {
+	ExecutionID string `json:"ExecutionID"`
+	AccountID   string `json:"AccountID"`
+	FlowID      string `json:"FlowID"`
+	NodeID      string `json:"NodeID"`
+	Signal      string `json:"Signal,omitempty"`
+	Event       string `json:"Event,omitempty"`
+	EventKey    string `json:"EventKey,omitempty"`
+	Deadline    int64  `json:"Deadline"` // 0 for waits without a deadline
+	Action      string `json:"Action,omitempty"`
+	Result      string `json:"Result,omitempty"` // JSON
+	CreatedAt   int64  `json:"CreatedAt"`
+}
+
+// wait returns the execution wait of a stored item
+func (item dynamoDBWaitItem) wait() (runtime.ExecutionWait, error) {
+	wait := runtime.ExecutionWait{
+		ExecutionID: item.ExecutionID,
+		AccountID:   item.AccountID,
+		FlowID:      item.FlowID,
+		NodeID:      item.NodeID,
+		Signal:      item.Signal,
+		Event:       item.Event,
+		EventKey:    item.EventKey,
+		Action:      item.Action,
+		CreatedAt:   time.Unix(0, item.CreatedAt),
+	}
+	if item.Deadline != 0 {
+		wait.Deadline = time.Unix(0, item.Deadline)
+	}
+	if item.Result != "" {
+		if err := json.Unmarshal([]byte(item.Result), &wait.Result); err != nil {
+			return runtime.ExecutionWait{}, fmt.Errorf("failed to unmarshal wait result: %w", err)
+		}
+	}
+	return wait, nil
+}
+
+// SaveWait records that an execution waits for a signal or timer
+func (s *DynamoDBExecutionStore) SaveWait(wait runtime.ExecutionWait) error {
+	item := dynamoDBWaitItem{
+		ExecutionID: wait.ExecutionID,
+		AccountID:   wait.AccountID,
+		FlowID:      wait.FlowID,
+		NodeID:      wait.NodeID,
+		Signal:      wait.Signal,
+		Event:       wait.Event,
+		EventKey:    wait.EventKey,
+		Action:      wait.Action,
+		CreatedAt:   wait.CreatedAt.UnixNano(),
+	}
+	if !wait.Deadline.IsZero() {
+		item.Deadline = wait.Deadline.UnixNano()
+	}
+	if wait.Result != nil {
+		resultJSON, err := json.Marshal(wait.Result)
+		if err != nil {
+			return fmt.Errorf("failed to marshal wait result: %w", err)
+		}
+		item.Result = string(resultJSON)
+	}
+	av, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal wait: %w", err)
+	}
+
+	_, err = s.client.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.waitsTableName),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save wait: %w", err)
+	}
+
+	return nil
+}
+
+// GetWait retrieves the wait of an execution
+func (s *DynamoDBExecutionStore) GetWait(executionID string) (runtime.ExecutionWait, error) {
+	result, err := s.client.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(s.waitsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ExecutionID": {S: aws.String(executionID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return runtime.ExecutionWait{}, fmt.Errorf("failed to get wait: %w", err)
+	}
+	if result.Item == nil {
+		return runtime.ExecutionWait{}, runtime.ErrWaitNotFound
+	}
+
+	var item dynamoDBWaitItem
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return runtime.ExecutionWait{}, fmt.Errorf("failed to unmarshal wait: %w", err)
+	}
+	return item.wait()
+}
+
+// ClaimWait removes and returns the wait of an execution
+func (s *DynamoDBExecutionStore) ClaimWait(executionID string) (runtime.ExecutionWait, error) {
+	// Only the request that actually deletes the item gets its old attributes
+	result, err := s.client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(s.waitsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ExecutionID": {S: aws.String(executionID)},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+	})
+	if err != nil {
+		return runtime.ExecutionWait{}, fmt.Errorf("failed to claim wait: %w", err)
+	}
+	if len(result.Attributes) == 0 {
+		return runtime.ExecutionWait{}, runtime.ErrWaitNotFound
+	}
+
+	var item dynamoDBWaitItem
+	if err := dynamodbattribute.UnmarshalMap(result.Attributes, &item); err != nil {
+		return runtime.ExecutionWait{}, fmt.Errorf("failed to unmarshal wait: %w", err)
+	}
+	return item.wait()
+}
+
+// ListDueWaits returns the waits whose deadline is not after before, ordered by deadline
+func (s *DynamoDBExecutionStore) ListDueWaits(before time.Time) ([]runtime.ExecutionWait, error) {
+	// Only executions that are waiting right now are in the table, so it stays small
+	result, err := s.client.Scan(&dynamodb.ScanInput{
+		TableName: aws.String(s.waitsTableName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan waits: %w", err)
+	}
+
+	waits := make([]runtime.ExecutionWait, 0)
+	for _, av := range result.Items {
+		var item dynamoDBWaitItem
+		if err := dynamodbattribute.UnmarshalMap(av, &item); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal wait: %w", err)
+		}
+		if item.Deadline == 0 || item.Deadline > before.UnixNano() {
+			continue
+		}
+		wait, err := item.wait()
+		if err != nil {
+			return nil, err
+		}
+		waits = append(waits, wait)
+	}
+	sort.Slice(waits, func(i, j int) bool {
+		return waits[i].Deadline.Before(waits[j].Deadline)
+	})
+
+	return waits, nil
+}
+
+// ListEventWaits returns the waits of an account for the event with the correlation key
+func (s *DynamoDBExecutionStore) ListEventWaits(accountID, event, key string) ([]runtime.ExecutionWait, error) {
+	result, err := s.client.Scan(&dynamodb.ScanInput{
+		TableName: aws.String(s.waitsTableName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan waits: %w", err)
+	}
+
+	waits := make([]runtime.ExecutionWait, 0)
+	for _, av := range result.Items {
+		var item dynamoDBWaitItem
+		if err := dynamodbattribute.UnmarshalMap(av, &item); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal wait: %w", err)
+		}
+		if item.Event == "" || item.AccountID != accountID || item.Event != event || item.EventKey != key {
+			continue
+		}
+		wait, err := item.wait()
+		if err != nil {
+			return nil, err
+		}
+		waits = append(waits, wait)
+	}
+	sort.Slice(waits, func(i, j int) bool {
+		return waits[i].CreatedAt.Before(waits[j].CreatedAt)
+	})
+
+	return waits, nil
+}
+
 // DynamoDBAccountStore implements the AccountStore interface using DynamoDB
 type DynamoDBAccountStore struct {
 	client      dynamodbiface.DynamoDBAPI
@@ -1,12 +1,15 @@
 package storage
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
+	"github.com/tcmartin/flowrunner/pkg/runtime"
 )
 
 func init() {
@@ -57,6 +60,9 @@ func cleanupTables(t *testing.T, provider *DynamoDBProvider) {
 		provider.secretStore.tableName,
 		provider.executionStore.execTableName,
 		provider.executionStore.logsTableName,
+		provider.executionStore.checkpointsTableName,
+		provider.executionStore.idempotencyTableName,
+		provider.executionStore.tracesTableName,
 		provider.accountStore.tableName,
 	}
 
@@ -162,3 +168,266 @@ func TestDynamoDBFlowStore(t *testing.T) {
 // Integration tests for other DynamoDB stores would follow a similar pattern
 // but are omitted for brevity. In a real project, you would have comprehensive
 // tests for each store type.
+
+// TestDynamoDBExecutionCheckpoints tests checkpoint persistence in the DynamoDB execution store
+func TestDynamoDBExecutionCheckpoints(t *testing.T) {
+	// Get test client (mock by default, real with -real-dynamodb flag)
+	client, err := GetTestDynamoDBClient()
+	if err != nil {
+		t.Fatalf("Failed to get test DynamoDB client: %v", err)
+	}
+
+	store := NewDynamoDBExecutionStore(client, "test_checkpoints_")
+	err = store.Initialize()
+	assert.NoError(t, err)
+
+	running := runtime.ExecutionStatus{ID: "exec-running", FlowID: "test-flow", Status: "running", StartTime: time.Now()}
+	completed := runtime.ExecutionStatus{ID: "exec-completed", FlowID: "test-flow", Status: "completed", StartTime: time.Now()}
+	assert.NoError(t, store.SaveExecution(running))
+	assert.NoError(t, store.SaveExecution(completed))
+
+	for step, nodeID := range []string{"first", "second"} {
+		for _, executionID := range []string{running.ID, completed.ID} {
+			err := store.SaveCheckpoint(runtime.ExecutionCheckpoint{
+				ExecutionID: executionID,
+				AccountID:   "test-account",
+				FlowID:      "test-flow",
+				Step:        step,
+				NodeID:      nodeID,
+				Shared:      map[string]interface{}{"counter": float64(step)},
+				CreatedAt:   time.Now(),
+			})
+			assert.NoError(t, err)
+		}
+	}
+
+	checkpoints, err := store.ListInterruptedExecutions()
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 1)
+	assert.Equal(t, running.ID, checkpoints[0].ExecutionID)
+	assert.Equal(t, "test-account", checkpoints[0].AccountID)
+	assert.Equal(t, 1, checkpoints[0].Step)
+	assert.Equal(t, "second", checkpoints[0].NodeID)
+	assert.Equal(t, float64(1), checkpoints[0].Shared["counter"])
+
+	checkpoints, err = store.GetExecutionCheckpoints(completed.ID)
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+	assert.Equal(t, "first", checkpoints[0].NodeID)
+	assert.Equal(t, "second", checkpoints[1].NodeID)
+	assert.Equal(t, float64(1), checkpoints[1].Shared["counter"])
+}
+
+// TestDynamoDBExecutionCheckpointRetention tests that long executions keep
+// their latest checkpoints across query pages
+func TestDynamoDBExecutionCheckpointRetention(t *testing.T) {
+	// Get test client (mock by default, real with -real-dynamodb flag)
+	client, err := GetTestDynamoDBClient()
+	if err != nil {
+		t.Fatalf("Failed to get test DynamoDB client: %v", err)
+	}
+	if mock, ok := client.(*MockDynamoDBAPI); ok {
+		mock.queryPageSize = 2
+	}
+
+	store := NewDynamoDBExecutionStore(client, "test_checkpoint_retention_")
+	err = store.Initialize()
+	assert.NoError(t, err)
+	store.SetCheckpointRetention(5)
+
+	running := runtime.ExecutionStatus{ID: "exec-long", FlowID: "test-flow", Status: "running", StartTime: time.Now()}
+	assert.NoError(t, store.SaveExecution(running))
+	for step := 0; step < 12; step++ {
+		err := store.SaveCheckpoint(runtime.ExecutionCheckpoint{
+			ExecutionID: running.ID,
+			AccountID:   "test-account",
+			FlowID:      "test-flow",
+			Step:        step,
+			NodeID:      "loop",
+			Shared:      map[string]interface{}{"counter": float64(step)},
+			CreatedAt:   time.Now(),
+		})
+		assert.NoError(t, err)
+	}
+
+	checkpoints, err := store.GetExecutionCheckpoints(running.ID)
+	assert.NoError(t, err)
+	steps := make([]int, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		steps = append(steps, checkpoint.Step)
+	}
+	assert.Equal(t, []int{7, 8, 9, 10, 11}, steps)
+
+	interrupted, err := store.ListInterruptedExecutions()
+	assert.NoError(t, err)
+	if assert.Len(t, interrupted, 1) {
+		assert.Equal(t, 11, interrupted[0].Step)
+		assert.Equal(t, float64(11), interrupted[0].Shared["counter"])
+	}
+
+	// States beyond the item limit are rejected before they reach DynamoDB
+	err = store.SaveCheckpoint(runtime.ExecutionCheckpoint{
+		ExecutionID: running.ID,
+		Step:        12,
+		Shared:      map[string]interface{}{"document": strings.Repeat("x", maxDynamoDBItemSize)},
+		CreatedAt:   time.Now(),
+	})
+	assert.ErrorContains(t, err, "exceeds the DynamoDB item limit")
+}
+
+// TestDynamoDBExecutionLeases tests execution leases in the DynamoDB execution store
+func TestDynamoDBExecutionLeases(t *testing.T) {
+	// Get test client (mock by default, real with -real-dynamodb flag)
+	client, err := GetTestDynamoDBClient()
+	if err != nil {
+		t.Fatalf("Failed to get test DynamoDB client: %v", err)
+	}
+
+	store := NewDynamoDBExecutionStore(client, "test_leases_")
+	err = store.Initialize()
+	assert.NoError(t, err)
+
+	testExecutionLeases(t, store)
+}
+
+func TestDynamoDBExecutionAccounts(t *testing.T) {
+	// Get test client (mock by default, real with -real-dynamodb flag)
+	client, err := GetTestDynamoDBClient()
+	if err != nil {
+		t.Fatalf("Failed to get test DynamoDB client: %v", err)
+	}
+
+	store := NewDynamoDBExecutionStore(client, "test_accounts_")
+	err = store.Initialize()
+	assert.NoError(t, err)
+
+	testExecutionAccounts(t, store)
+}
+
+// TestDynamoDBExecutionIdempotencyKeys tests idempotency keys in the DynamoDB execution store
+func TestDynamoDBExecutionIdempotencyKeys(t *testing.T) {
+	// Get test client (mock by default, real with -real-dynamodb flag)
+	client, err := GetTestDynamoDBClient()
+	if err != nil {
+		t.Fatalf("Failed to get test DynamoDB client: %v", err)
+	}
+
+	store := NewDynamoDBExecutionStore(client, "test_idempotency_")
+	err = store.Initialize()
+	assert.NoError(t, err)
+
+	since := time.Now().Add(-time.Hour)
+	recorded, err := store.ClaimIdempotencyKey("account-1", "key-1", "exec-1", since)
+	assert.NoError(t, err)
+	assert.Equal(t, "exec-1", recorded)
+
+	recorded, err = store.ClaimIdempotencyKey("account-1", "key-1", "exec-2", since)
+	assert.NoError(t, err)
+	assert.Equal(t, "exec-1", recorded)
+
+	recorded, err = store.ClaimIdempotencyKey("account-2", "key-1", "exec-3", since)
+	assert.NoError(t, err)
+	assert.Equal(t, "exec-3", recorded)
+}
+
+// TestDynamoDBExecutionTrace tests node traces in the DynamoDB execution store
+func TestDynamoDBExecutionTrace(t *testing.T) {
+	// Get test client (mock by default, real with -real-dynamodb flag)
+	client, err := GetTestDynamoDBClient()
+	if err != nil {
+		t.Fatalf("Failed to get test DynamoDB client: %v", err)
+	}
+
+	store := NewDynamoDBExecutionStore(client, "test_traces_")
+	err = store.Initialize()
+	assert.NoError(t, err)
+
+	started := time.Now()
+	for _, sequence := range []int{2, 1} {
+		err := store.SaveNodeTrace(runtime.NodeTrace{
+			ExecutionID: "exec-1",
+			Sequence:    sequence,
+			NodeID:      "node",
+			NodeType:    "http.request",
+			Visit:       sequence,
+			StartTime:   started,
+			EndTime:     started.Add(time.Second),
+			Action:      "default",
+			Retries:     1,
+			Params:      map[string]interface{}{"url": "https://example.com"},
+			Output:      map[string]interface{}{"status": float64(200)},
+		})
+		assert.NoError(t, err)
+	}
+
+	trace, err := store.GetExecutionTrace("exec-1")
+	assert.NoError(t, err)
+	if assert.Len(t, trace, 2) {
+		assert.Equal(t, 1, trace[0].Sequence)
+		assert.Equal(t, 2, trace[1].Sequence)
+		assert.Equal(t, "http.request", trace[0].NodeType)
+		assert.Equal(t, 1, trace[0].Retries)
+		assert.True(t, trace[0].StartTime.Equal(started))
+		assert.Equal(t, "https://example.com", trace[0].Params["url"])
+		assert.Equal(t, map[string]interface{}{"status": float64(200)}, trace[0].Output)
+	}
+}
+
+// TestDynamoDBExecutionWaits tests waits in the DynamoDB execution store
+func TestDynamoDBExecutionWaits(t *testing.T) {
+	// Get test client (mock by default, real with -real-dynamodb flag)
+	client, err := GetTestDynamoDBClient()
+	if err != nil {
+		t.Fatalf("Failed to get test DynamoDB client: %v", err)
+	}
+
+	store := NewDynamoDBExecutionStore(client, "test_waits_")
+	err = store.Initialize()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	waits := []runtime.ExecutionWait{
+		{ExecutionID: "exec-late", AccountID: "account-1", FlowID: "flow-1", NodeID: "pause", Deadline: now.Add(-time.Minute), Action: "default", Result: map[string]interface{}{"waited_for": "72h"}, CreatedAt: now},
+		{ExecutionID: "exec-early", AccountID: "account-1", FlowID: "flow-1", NodeID: "approve", Signal: "approve", Deadline: now.Add(-time.Hour), CreatedAt: now},
+		{ExecutionID: "exec-forever", AccountID: "account-1", FlowID: "flow-1", NodeID: "approve", Signal: "approve", CreatedAt: now},
+		{ExecutionID: "exec-event", AccountID: "account-1", FlowID: "flow-1", NodeID: "payment", Event: "payment.completed", EventKey: "42", CreatedAt: now},
+	}
+	for _, wait := range waits {
+		assert.NoError(t, store.SaveWait(wait))
+	}
+
+	wait, err := store.GetWait("exec-forever")
+	assert.NoError(t, err)
+	assert.Equal(t, "account-1", wait.AccountID)
+	assert.True(t, wait.Deadline.IsZero())
+	assert.True(t, wait.CreatedAt.Equal(now))
+
+	due, err := store.ListDueWaits(now)
+	assert.NoError(t, err)
+	if assert.Len(t, due, 2) {
+		assert.Equal(t, "exec-early", due[0].ExecutionID)
+		assert.Equal(t, "exec-late", due[1].ExecutionID)
+		assert.True(t, due[0].Deadline.Equal(now.Add(-time.Hour)))
+	}
+
+	events, err := store.ListEventWaits("account-1", "payment.completed", "42")
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "exec-event", events[0].ExecutionID)
+		assert.Equal(t, "42", events[0].EventKey)
+	}
+	events, err = store.ListEventWaits("account-2", "payment.completed", "42")
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	// A wait is claimed only once
+	wait, err = store.ClaimWait("exec-late")
+	assert.NoError(t, err)
+	assert.Equal(t, "flow-1", wait.FlowID)
+	assert.Equal(t, "default", wait.Action)
+	assert.Equal(t, map[string]interface{}{"waited_for": "72h"}, wait.Result)
+	_, err = store.ClaimWait("exec-late")
+	assert.ErrorIs(t, err, runtime.ErrWaitNotFound)
+	_, err = store.GetWait("exec-late")
+	assert.ErrorIs(t, err, runtime.ErrWaitNotFound)
+}
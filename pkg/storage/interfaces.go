@@ -151,6 +151,13 @@ type ExecutionStore interface {
 
 	// GetExecutionLogs retrieves logs for an execution
 	GetExecutionLogs(executionID string) ([]runtime.ExecutionLog, error)
+
+	// SaveNodeTrace persists a node visit of an execution, replacing the
+	// visit with the same sequence
+	SaveNodeTrace(trace runtime.NodeTrace) error
+
+	// GetExecutionTrace retrieves the node visits of an execution ordered by sequence
+	GetExecutionTrace(executionID string) ([]runtime.NodeTrace, error)
 }
 
 // AccountStore manages account persistence
@@ -3,6 +3,7 @@ package storage
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -335,16 +336,38 @@ func (s *MemorySecretStore) DeleteSecret(accountID, key string) error {
 
 // MemoryExecutionStore implements the ExecutionStore interface using in-memory storage
 type MemoryExecutionStore struct {
-	executions map[string]ExecutionWrapper
-	logs       map[string][]runtime.ExecutionLog
-	mu         sync.RWMutex
+	executions  map[string]ExecutionWrapper
+	logs        map[string][]runtime.ExecutionLog
+	checkpoints map[string][]runtime.ExecutionCheckpoint
+	idempotency map[string]idempotencyRecord
+	traces      map[string][]runtime.NodeTrace
+	waits       map[string]runtime.ExecutionWait
+	leases      map[string]executionLease
+	mu          sync.RWMutex
+}
+
+// executionLease is the process that runs an execution, until when
+type executionLease struct {
+	owner string
+	until time.Time
+}
+
+// idempotencyRecord is the execution started for an idempotency key
+type idempotencyRecord struct {
+	executionID string
+	createdAt   time.Time
 }
 
 // NewMemoryExecutionStore creates a new in-memory execution store
 func NewMemoryExecutionStore() *MemoryExecutionStore {
 	return &MemoryExecutionStore{
-		executions: make(map[string]ExecutionWrapper),
-		logs:       make(map[string][]runtime.ExecutionLog),
+		executions:  make(map[string]ExecutionWrapper),
+		logs:        make(map[string][]runtime.ExecutionLog),
+		checkpoints: make(map[string][]runtime.ExecutionCheckpoint),
+		idempotency: make(map[string]idempotencyRecord),
+		traces:      make(map[string][]runtime.NodeTrace),
+		waits:       make(map[string]runtime.ExecutionWait),
+		leases:      make(map[string]executionLease),
 	}
 }
 
@@ -382,6 +405,32 @@ func (s *MemoryExecutionStore) GetExecution(executionID string) (runtime.Executi
 	return wrapper.ExecutionStatus, nil
 }
 
+// SetExecutionAccountID records the account an execution runs for
+func (s *MemoryExecutionStore) SetExecutionAccountID(executionID, accountID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wrapper, ok := s.executions[executionID]
+	if !ok {
+		return ErrExecutionNotFound
+	}
+	wrapper.AccountID = accountID
+	s.executions[executionID] = wrapper
+	return nil
+}
+
+// GetExecutionAccountID returns the account recorded for an execution
+func (s *MemoryExecutionStore) GetExecutionAccountID(executionID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wrapper, ok := s.executions[executionID]
+	if !ok {
+		return "", ErrExecutionNotFound
+	}
+	return wrapper.AccountID, nil
+}
+
 // ListExecutions returns all executions for an account
 func (s *MemoryExecutionStore) ListExecutions(accountID string) ([]runtime.ExecutionStatus, error) {
 	s.mu.RLock()
@@ -428,6 +477,208 @@ func (s *MemoryExecutionStore) GetExecutionLogs(executionID string) ([]runtime.E
 	return logs, nil
 }
 
+// SaveNodeTrace persists a node visit of an execution
+func (s *MemoryExecutionStore) SaveNodeTrace(trace runtime.NodeTrace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	traces := s.traces[trace.ExecutionID]
+	for i := range traces {
+		if traces[i].Sequence == trace.Sequence {
+			traces[i] = trace
+			return nil
+		}
+	}
+	s.traces[trace.ExecutionID] = append(traces, trace)
+
+	return nil
+}
+
+// GetExecutionTrace retrieves the node visits of an execution ordered by sequence
+func (s *MemoryExecutionStore) GetExecutionTrace(executionID string) ([]runtime.NodeTrace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	traces := append([]runtime.NodeTrace{}, s.traces[executionID]...)
+	sort.Slice(traces, func(i, j int) bool {
+		return traces[i].Sequence < traces[j].Sequence
+	})
+
+	return traces, nil
+}
+
+// SaveCheckpoint persists a checkpoint for an execution
+func (s *MemoryExecutionStore) SaveCheckpoint(checkpoint runtime.ExecutionCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A resumed execution checkpoints the same step again, so overwrite it
+	checkpoints := s.checkpoints[checkpoint.ExecutionID]
+	for i := range checkpoints {
+		if checkpoints[i].Step == checkpoint.Step {
+			checkpoints[i] = checkpoint
+			return nil
+		}
+	}
+	s.checkpoints[checkpoint.ExecutionID] = append(checkpoints, checkpoint)
+
+	return nil
+}
+
+// GetExecutionCheckpoints retrieves the checkpoints of an execution ordered by step
+func (s *MemoryExecutionStore) GetExecutionCheckpoints(executionID string) ([]runtime.ExecutionCheckpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	checkpoints := append([]runtime.ExecutionCheckpoint{}, s.checkpoints[executionID]...)
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Step < checkpoints[j].Step
+	})
+
+	return checkpoints, nil
+}
+
+// ListInterruptedExecutions returns the latest checkpoint of every running or queued execution
+func (s *MemoryExecutionStore) ListInterruptedExecutions() ([]runtime.ExecutionCheckpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	interrupted := make([]runtime.ExecutionCheckpoint, 0)
+	for executionID, wrapper := range s.executions {
+		if wrapper.Status != "running" && wrapper.Status != "queued" {
+			continue
+		}
+
+		checkpoints := s.checkpoints[executionID]
+		if len(checkpoints) == 0 {
+			continue
+		}
+		interrupted = append(interrupted, checkpoints[len(checkpoints)-1])
+	}
+
+	return interrupted, nil
+}
+
+// ClaimIdempotencyKey records executionID under the key of an account unless
+// the key was recorded after since, and returns the recorded execution ID
+func (s *MemoryExecutionStore) ClaimIdempotencyKey(accountID, key, executionID string, since time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recordKey := accountID + "/" + key
+	if record, ok := s.idempotency[recordKey]; ok && record.createdAt.After(since) {
+		return record.executionID, nil
+	}
+
+	s.idempotency[recordKey] = idempotencyRecord{executionID: executionID, createdAt: time.Now()}
+	return executionID, nil
+}
+
+// ReleaseIdempotencyKey removes the key of an account if it still records
+// executionID
+func (s *MemoryExecutionStore) ReleaseIdempotencyKey(accountID, key, executionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recordKey := accountID + "/" + key
+	if record, ok := s.idempotency[recordKey]; ok && record.executionID == executionID {
+		delete(s.idempotency, recordKey)
+	}
+	return nil
+}
+
+// ClaimExecution makes owner the owner of an execution until the given time,
+// unless another owner holds a lease that has not expired
+func (s *MemoryExecutionStore) ClaimExecution(executionID, owner string, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lease, ok := s.leases[executionID]; ok && lease.owner != owner && lease.until.After(time.Now()) {
+		return false, nil
+	}
+	s.leases[executionID] = executionLease{owner: owner, until: until}
+	return true, nil
+}
+
+// ReleaseExecution ends the lease of owner on an execution
+func (s *MemoryExecutionStore) ReleaseExecution(executionID, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lease, ok := s.leases[executionID]; ok && lease.owner == owner {
+		delete(s.leases, executionID)
+	}
+	return nil
+}
+
+// SaveWait records that an execution waits for a signal or timer
+func (s *MemoryExecutionStore) SaveWait(wait runtime.ExecutionWait) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.waits[wait.ExecutionID] = wait
+	return nil
+}
+
+// GetWait retrieves the wait of an execution
+func (s *MemoryExecutionStore) GetWait(executionID string) (runtime.ExecutionWait, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wait, ok := s.waits[executionID]
+	if !ok {
+		return runtime.ExecutionWait{}, runtime.ErrWaitNotFound
+	}
+	return wait, nil
+}
+
+// ClaimWait removes and returns the wait of an execution
+func (s *MemoryExecutionStore) ClaimWait(executionID string) (runtime.ExecutionWait, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wait, ok := s.waits[executionID]
+	if !ok {
+		return runtime.ExecutionWait{}, runtime.ErrWaitNotFound
+	}
+	delete(s.waits, executionID)
+	return wait, nil
+}
+
+// ListDueWaits returns the waits whose deadline is not after before, ordered by deadline
+func (s *MemoryExecutionStore) ListDueWaits(before time.Time) ([]runtime.ExecutionWait, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	due := make([]runtime.ExecutionWait, 0)
+	for _, wait := range s.waits {
+		if !wait.Deadline.IsZero() && !wait.Deadline.After(before) {
+			due = append(due, wait)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].Deadline.Before(due[j].Deadline)
+	})
+	return due, nil
+}
+
+// ListEventWaits returns the waits of an account for the event with the correlation key
+func (s *MemoryExecutionStore) ListEventWaits(accountID, event, key string) ([]runtime.ExecutionWait, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	waits := make([]runtime.ExecutionWait, 0)
+	for _, wait := range s.waits {
+		if wait.Event != "" && wait.AccountID == accountID && wait.Event == event && wait.EventKey == key {
+			waits = append(waits, wait)
+		}
+	}
+	sort.Slice(waits, func(i, j int) bool {
+		return waits[i].CreatedAt.Before(waits[j].CreatedAt)
+	})
+	return waits, nil
+}
+
 // MemoryAccountStore implements the AccountStore interface using in-memory storage
 type MemoryAccountStore struct {
 	accounts        map[string]auth.Account
@@ -190,6 +190,242 @@ func TestMemoryExecutionStore(t *testing.T) {
 	assert.Equal(t, ErrExecutionNotFound, err)
 }
 
+func TestMemoryExecutionCheckpoints(t *testing.T) {
+	store := NewMemoryExecutionStore()
+
+	running := runtime.ExecutionStatus{ID: "exec-running", FlowID: "test-flow", Status: "running", StartTime: time.Now()}
+	completed := runtime.ExecutionStatus{ID: "exec-completed", FlowID: "test-flow", Status: "completed", StartTime: time.Now()}
+	assert.NoError(t, store.SaveExecution(running))
+	assert.NoError(t, store.SaveExecution(completed))
+
+	// Executions without checkpoints cannot be resumed
+	checkpoints, err := store.ListInterruptedExecutions()
+	assert.NoError(t, err)
+	assert.Empty(t, checkpoints)
+
+	for step, nodeID := range []string{"first", "second"} {
+		for _, executionID := range []string{running.ID, completed.ID} {
+			err := store.SaveCheckpoint(runtime.ExecutionCheckpoint{
+				ExecutionID: executionID,
+				AccountID:   "test-account",
+				FlowID:      "test-flow",
+				Step:        step,
+				NodeID:      nodeID,
+				Shared:      map[string]interface{}{"counter": step},
+				CreatedAt:   time.Now(),
+			})
+			assert.NoError(t, err)
+		}
+	}
+
+	// Only the latest checkpoint of the running execution is returned
+	checkpoints, err = store.ListInterruptedExecutions()
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 1)
+	assert.Equal(t, running.ID, checkpoints[0].ExecutionID)
+	assert.Equal(t, 1, checkpoints[0].Step)
+	assert.Equal(t, "second", checkpoints[0].NodeID)
+
+	// Checkpointing a step again replaces it
+	err = store.SaveCheckpoint(runtime.ExecutionCheckpoint{ExecutionID: completed.ID, Step: 0, NodeID: "first", Shared: map[string]interface{}{"counter": 10}})
+	assert.NoError(t, err)
+
+	checkpoints, err = store.GetExecutionCheckpoints(completed.ID)
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+	assert.Equal(t, "first", checkpoints[0].NodeID)
+	assert.Equal(t, 10, checkpoints[0].Shared["counter"])
+	assert.Equal(t, "second", checkpoints[1].NodeID)
+
+	checkpoints, err = store.GetExecutionCheckpoints("non-existent")
+	assert.NoError(t, err)
+	assert.Empty(t, checkpoints)
+}
+
+func TestMemoryExecutionIdempotencyKeys(t *testing.T) {
+	store := NewMemoryExecutionStore()
+	since := time.Now().Add(-time.Hour)
+
+	recorded, err := store.ClaimIdempotencyKey("account-1", "key-1", "exec-1", since)
+	assert.NoError(t, err)
+	assert.Equal(t, "exec-1", recorded)
+
+	// A repeated key returns the first execution
+	recorded, err = store.ClaimIdempotencyKey("account-1", "key-1", "exec-2", since)
+	assert.NoError(t, err)
+	assert.Equal(t, "exec-1", recorded)
+
+	// Keys are scoped to an account
+	recorded, err = store.ClaimIdempotencyKey("account-2", "key-1", "exec-3", since)
+	assert.NoError(t, err)
+	assert.Equal(t, "exec-3", recorded)
+
+	// Expired keys are claimed again
+	recorded, err = store.ClaimIdempotencyKey("account-1", "key-1", "exec-4", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "exec-4", recorded)
+
+	// Releasing a key for another execution keeps it
+	assert.NoError(t, store.ReleaseIdempotencyKey("account-1", "key-1", "exec-1"))
+	recorded, err = store.ClaimIdempotencyKey("account-1", "key-1", "exec-5", since)
+	assert.NoError(t, err)
+	assert.Equal(t, "exec-4", recorded)
+
+	// A released key is claimed again
+	assert.NoError(t, store.ReleaseIdempotencyKey("account-1", "key-1", "exec-4"))
+	recorded, err = store.ClaimIdempotencyKey("account-1", "key-1", "exec-5", since)
+	assert.NoError(t, err)
+	assert.Equal(t, "exec-5", recorded)
+}
+
+func TestMemoryExecutionLeases(t *testing.T) {
+	testExecutionLeases(t, NewMemoryExecutionStore())
+}
+
+// testExecutionLeases checks that only one owner at a time holds the lease
+// of an execution
+func testExecutionLeases(t *testing.T, store runtime.ExecutionClaimer) {
+	until := time.Now().Add(time.Minute)
+
+	claimed, err := store.ClaimExecution("exec-1", "process-1", until)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	// The owner renews its lease, others cannot take it
+	claimed, err = store.ClaimExecution("exec-1", "process-1", until)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = store.ClaimExecution("exec-1", "process-2", until)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	// Leases are per execution
+	claimed, err = store.ClaimExecution("exec-2", "process-2", until)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	// An expired lease is taken over
+	claimed, err = store.ClaimExecution("exec-3", "process-1", time.Now().Add(-time.Second))
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = store.ClaimExecution("exec-3", "process-2", until)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	// A released lease is claimed again
+	assert.NoError(t, store.ReleaseExecution("exec-1", "process-1"))
+	claimed, err = store.ClaimExecution("exec-1", "process-2", until)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestMemoryExecutionAccounts(t *testing.T) {
+	testExecutionAccounts(t, NewMemoryExecutionStore())
+}
+
+// executionAccountStore is an execution store that records the account of
+// its executions
+type executionAccountStore interface {
+	runtime.ExecutionStore
+	runtime.ExecutionAccountStore
+	SetExecutionAccountID(executionID, accountID string) error
+}
+
+// testExecutionAccounts checks that the account of an execution is kept
+// when its status changes
+func testExecutionAccounts(t *testing.T, store executionAccountStore) {
+	_, err := store.GetExecutionAccountID("missing")
+	assert.ErrorIs(t, err, ErrExecutionNotFound)
+
+	execution := runtime.ExecutionStatus{ID: "exec-1", FlowID: "flow-1", Status: "running", StartTime: time.Now()}
+	assert.NoError(t, store.SaveExecution(execution))
+	assert.NoError(t, store.SetExecutionAccountID("exec-1", "account-1"))
+
+	execution.Status = "completed"
+	execution.EndTime = time.Now()
+	assert.NoError(t, store.SaveExecution(execution))
+
+	accountID, err := store.GetExecutionAccountID("exec-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "account-1", accountID)
+}
+
+func TestMemoryExecutionTrace(t *testing.T) {
+	store := NewMemoryExecutionStore()
+
+	for _, sequence := range []int{2, 1} {
+		err := store.SaveNodeTrace(runtime.NodeTrace{ExecutionID: "exec-1", Sequence: sequence, NodeID: "node", Visit: sequence})
+		assert.NoError(t, err)
+	}
+	// A resumed execution records the interrupted node again
+	err := store.SaveNodeTrace(runtime.NodeTrace{ExecutionID: "exec-1", Sequence: 2, NodeID: "node", Visit: 2, Action: "default"})
+	assert.NoError(t, err)
+
+	trace, err := store.GetExecutionTrace("exec-1")
+	assert.NoError(t, err)
+	assert.Len(t, trace, 2)
+	assert.Equal(t, 1, trace[0].Sequence)
+	assert.Equal(t, 2, trace[1].Sequence)
+	assert.Equal(t, "default", trace[1].Action)
+
+	trace, err = store.GetExecutionTrace("exec-2")
+	assert.NoError(t, err)
+	assert.Empty(t, trace)
+}
+
+func TestMemoryExecutionWaits(t *testing.T) {
+	store := NewMemoryExecutionStore()
+	now := time.Now()
+
+	waits := []runtime.ExecutionWait{
+		{ExecutionID: "exec-late", NodeID: "approve", Signal: "approve", Deadline: now.Add(-time.Minute), CreatedAt: now},
+		{ExecutionID: "exec-early", NodeID: "approve", Signal: "approve", Deadline: now.Add(-time.Hour), CreatedAt: now},
+		{ExecutionID: "exec-future", NodeID: "approve", Signal: "approve", Deadline: now.Add(time.Hour), CreatedAt: now},
+		{ExecutionID: "exec-forever", NodeID: "approve", Signal: "approve", CreatedAt: now},
+	}
+	for _, wait := range waits {
+		assert.NoError(t, store.SaveWait(wait))
+	}
+
+	wait, err := store.GetWait("exec-forever")
+	assert.NoError(t, err)
+	assert.Equal(t, "approve", wait.Signal)
+
+	// Waits without a deadline never become due
+	due, err := store.ListDueWaits(now)
+	assert.NoError(t, err)
+	assert.Len(t, due, 2)
+	assert.Equal(t, "exec-early", due[0].ExecutionID)
+	assert.Equal(t, "exec-late", due[1].ExecutionID)
+
+	// A wait is claimed only once
+	wait, err = store.ClaimWait("exec-early")
+	assert.NoError(t, err)
+	assert.Equal(t, "exec-early", wait.ExecutionID)
+	_, err = store.ClaimWait("exec-early")
+	assert.ErrorIs(t, err, runtime.ErrWaitNotFound)
+	_, err = store.GetWait("exec-early")
+	assert.ErrorIs(t, err, runtime.ErrWaitNotFound)
+
+	due, err = store.ListDueWaits(now)
+	assert.NoError(t, err)
+	assert.Len(t, due, 1)
+
+	// Event waits are found by account, event and correlation key
+	assert.NoError(t, store.SaveWait(runtime.ExecutionWait{ExecutionID: "exec-event", AccountID: "account-1", NodeID: "payment", Event: "payment.completed", EventKey: "42", CreatedAt: now}))
+	events, err := store.ListEventWaits("account-1", "payment.completed", "42")
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "exec-event", events[0].ExecutionID)
+	}
+	events, err = store.ListEventWaits("account-1", "payment.completed", "43")
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	events, err = store.ListEventWaits("account-2", "payment.completed", "42")
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
 func TestMemoryAccountStore(t *testing.T) {
 	store := NewMemoryAccountStore()
 
@@ -4,6 +4,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -24,6 +26,10 @@ type MockDynamoDBAPI struct {
 	dynamodbiface.DynamoDBAPI
 	mu     sync.RWMutex
 	tables map[string]*MockTable
+
+	// queryPageSize caps the items of a query page, like the 1 MB page limit
+	// of DynamoDB. Zero means no cap.
+	queryPageSize int
 }
 
 // MockTable represents a DynamoDB table in memory
@@ -166,6 +172,54 @@ func (m *MockDynamoDBAPI) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutIt
 	return &dynamodb.PutItemOutput{}, nil
 }
 
+// UpdateItem applies a "SET name = :value, ..." update expression to an
+// item of a mock table, creating the item if it does not exist
+func (m *MockDynamoDBAPI) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tableName := aws.StringValue(input.TableName)
+	table, exists := m.tables[tableName]
+	if !exists {
+		return nil, fmt.Errorf("table not found: %s", tableName)
+	}
+
+	expression := strings.TrimSpace(aws.StringValue(input.UpdateExpression))
+	if !strings.HasPrefix(expression, "SET ") {
+		return nil, fmt.Errorf("unsupported update expression: %s", expression)
+	}
+
+	key := m.generateKey(table.KeySchema, input.Key)
+	item := make(map[string]*dynamodb.AttributeValue)
+	for name, value := range table.Items[key] {
+		item[name] = value
+	}
+	for name, value := range input.Key {
+		item[name] = value
+	}
+	for _, assignment := range strings.Split(strings.TrimPrefix(expression, "SET "), ",") {
+		parts := strings.SplitN(assignment, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("unsupported update expression: %s", expression)
+		}
+		value, ok := input.ExpressionAttributeValues[strings.TrimSpace(parts[1])]
+		if !ok {
+			return nil, fmt.Errorf("missing value for %s", strings.TrimSpace(parts[1]))
+		}
+		item[strings.TrimSpace(parts[0])] = value
+	}
+	table.Items[key] = item
+
+	for _, gsi := range table.GSI {
+		indexName := aws.StringValue(gsi.IndexName)
+		if index, exists := table.Indexes[indexName]; exists {
+			index.Items[m.generateKey(gsi.KeySchema, item)] = item
+		}
+	}
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
 // GetItem gets an item from a mock table
 func (m *MockDynamoDBAPI) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
 	m.mu.RLock()
@@ -198,6 +252,7 @@ func (m *MockDynamoDBAPI) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutp
 	}
 
 	var items map[string]map[string]*dynamodb.AttributeValue
+	keySchema := table.KeySchema
 
 	// Determine if querying table or index
 	if input.IndexName != nil {
@@ -207,6 +262,7 @@ func (m *MockDynamoDBAPI) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutp
 			return nil, fmt.Errorf("index not found: %s", indexName)
 		}
 		items = index.Items
+		keySchema = index.KeySchema
 	} else {
 		items = table.Items
 	}
@@ -214,47 +270,96 @@ func (m *MockDynamoDBAPI) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutp
 	// Simple mock query logic with basic filtering
 	var resultItems []map[string]*dynamodb.AttributeValue
 
-	// If we have expression attribute values, try to extract the AccountID filter
-	var filterAccountID string
-	if input.ExpressionAttributeValues != nil {
-		if accountIDValue, exists := input.ExpressionAttributeValues[":0"]; exists && accountIDValue.S != nil {
-			filterAccountID = aws.StringValue(accountIDValue.S)
+	// Key conditions built with the expression package pair #N names with :N values
+	conditions := make(map[string]*dynamodb.AttributeValue)
+	for placeholder, attrName := range input.ExpressionAttributeNames {
+		if value, exists := input.ExpressionAttributeValues[":"+strings.TrimPrefix(placeholder, "#")]; exists {
+			conditions[aws.StringValue(attrName)] = value
 		}
-		// Also check for other common placeholders
-		if filterAccountID == "" {
-			if accountIDValue, exists := input.ExpressionAttributeValues[":v0"]; exists && accountIDValue.S != nil {
-				filterAccountID = aws.StringValue(accountIDValue.S)
+	}
+
+	for _, item := range items {
+		matches := true
+		for attrName, value := range conditions {
+			attr, exists := item[attrName]
+			if !exists || aws.StringValue(attr.S) != aws.StringValue(value.S) || aws.StringValue(attr.N) != aws.StringValue(value.N) {
+				matches = false
+				break
 			}
 		}
+		if matches {
+			resultItems = append(resultItems, item)
+		}
 	}
 
-	for _, item := range items {
-		// If we have an AccountID filter, apply it
-		if filterAccountID != "" {
-			if accountIDAttr, exists := item["AccountID"]; exists && accountIDAttr.S != nil {
-				if aws.StringValue(accountIDAttr.S) != filterAccountID {
-					continue // Skip items that don't match the account ID
-				}
+	// Items come in the order of the sort key
+	forward := input.ScanIndexForward == nil || aws.BoolValue(input.ScanIndexForward)
+	sortItems(resultItems, keySchema, forward)
+
+	// Continue after the last item of the previous page
+	if input.ExclusiveStartKey != nil {
+		startKey := m.generateKey(keySchema, input.ExclusiveStartKey)
+		for i, item := range resultItems {
+			if m.generateKey(keySchema, item) == startKey {
+				resultItems = resultItems[i+1:]
+				break
 			}
 		}
-		
-		resultItems = append(resultItems, item)
 	}
 
 	// Apply limit if specified
-	if input.Limit != nil {
-		limit := int(aws.Int64Value(input.Limit))
-		if limit < len(resultItems) {
-			resultItems = resultItems[:limit]
+	limit := m.queryPageSize
+	if input.Limit != nil && (limit == 0 || int(aws.Int64Value(input.Limit)) < limit) {
+		limit = int(aws.Int64Value(input.Limit))
+	}
+	var lastEvaluatedKey map[string]*dynamodb.AttributeValue
+	if limit > 0 && limit < len(resultItems) {
+		resultItems = resultItems[:limit]
+		lastEvaluatedKey = make(map[string]*dynamodb.AttributeValue)
+		for _, keyElement := range keySchema {
+			attrName := aws.StringValue(keyElement.AttributeName)
+			lastEvaluatedKey[attrName] = resultItems[limit-1][attrName]
 		}
 	}
 
 	return &dynamodb.QueryOutput{
-		Items: resultItems,
-		Count: aws.Int64(int64(len(resultItems))),
+		Items:            resultItems,
+		Count:            aws.Int64(int64(len(resultItems))),
+		LastEvaluatedKey: lastEvaluatedKey,
 	}, nil
 }
 
+// sortItems orders query results by the range key of a key schema
+func sortItems(items []map[string]*dynamodb.AttributeValue, keySchema []*dynamodb.KeySchemaElement, forward bool) {
+	var rangeKey string
+	for _, keyElement := range keySchema {
+		if aws.StringValue(keyElement.KeyType) == dynamodb.KeyTypeRange {
+			rangeKey = aws.StringValue(keyElement.AttributeName)
+		}
+	}
+	if rangeKey == "" {
+		return
+	}
+
+	less := func(a, b *dynamodb.AttributeValue) bool {
+		if a == nil || b == nil {
+			return b != nil
+		}
+		if a.N != nil && b.N != nil {
+			x, _ := strconv.ParseFloat(aws.StringValue(a.N), 64)
+			y, _ := strconv.ParseFloat(aws.StringValue(b.N), 64)
+			return x < y
+		}
+		return aws.StringValue(a.S) < aws.StringValue(b.S)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if forward {
+			return less(items[i][rangeKey], items[j][rangeKey])
+		}
+		return less(items[j][rangeKey], items[i][rangeKey])
+	})
+}
+
 // Scan scans a mock table
 func (m *MockDynamoDBAPI) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
 	m.mu.RLock()
@@ -351,7 +456,7 @@ func (m *MockDynamoDBAPI) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb
 	key := m.generateKey(table.KeySchema, input.Key)
 
 	// Check if item exists (for condition expressions)
-	_, exists = table.Items[key]
+	old, exists := table.Items[key]
 	if !exists && input.ConditionExpression != nil {
 		// Simple condition check - item must exist
 		return nil, fmt.Errorf("conditional check failed")
@@ -367,6 +472,9 @@ func (m *MockDynamoDBAPI) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb
 		}
 	}
 
+	if exists && aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld {
+		return &dynamodb.DeleteItemOutput{Attributes: old}, nil
+	}
 	return &dynamodb.DeleteItemOutput{}, nil
 }
 
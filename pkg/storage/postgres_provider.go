@@ -559,8 +559,10 @@ func (s *PostgreSQLExecutionStore) Initialize() error {
 			error TEXT,
 			results JSONB,
 			progress FLOAT,
-			current_node TEXT
+			current_node TEXT,
+			metadata JSONB
 		);
+		ALTER TABLE executions ADD COLUMN IF NOT EXISTS metadata JSONB;
 		CREATE INDEX IF NOT EXISTS executions_account_id_idx ON executions (account_id);
 		CREATE INDEX IF NOT EXISTS executions_flow_id_idx ON executions (flow_id);
 	`)
@@ -586,6 +588,98 @@ func (s *PostgreSQLExecutionStore) Initialize() error {
 		return fmt.Errorf("failed to create execution logs table: %w", err)
 	}
 
+	// Create execution checkpoints table
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS execution_checkpoints (
+			execution_id TEXT NOT NULL,
+			step INTEGER NOT NULL,
+			account_id TEXT NOT NULL,
+			flow_id TEXT NOT NULL,
+			node_id TEXT NOT NULL,
+			shared JSONB,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (execution_id, step)
+		);
+	`)
+
+	if err != nil {
+		return fmt.Errorf("failed to create execution checkpoints table: %w", err)
+	}
+
+	// Create idempotency keys table
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS execution_idempotency_keys (
+			account_id TEXT NOT NULL,
+			idempotency_key TEXT NOT NULL,
+			execution_id TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (account_id, idempotency_key)
+		);
+	`)
+
+	if err != nil {
+		return fmt.Errorf("failed to create idempotency keys table: %w", err)
+	}
+
+	// Create execution leases table
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS execution_leases (
+			execution_id TEXT PRIMARY KEY,
+			owner TEXT NOT NULL,
+			lease_until TIMESTAMP NOT NULL
+		);
+	`)
+
+	if err != nil {
+		return fmt.Errorf("failed to create execution leases table: %w", err)
+	}
+
+	// Create execution traces table
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS execution_traces (
+			execution_id TEXT NOT NULL,
+			sequence INTEGER NOT NULL,
+			node_id TEXT NOT NULL,
+			node_type TEXT,
+			visit INTEGER NOT NULL,
+			start_time TIMESTAMP NOT NULL,
+			end_time TIMESTAMP NOT NULL,
+			action TEXT,
+			retries INTEGER NOT NULL,
+			params JSONB,
+			output JSONB,
+			error TEXT,
+			PRIMARY KEY (execution_id, sequence)
+		);
+	`)
+
+	if err != nil {
+		return fmt.Errorf("failed to create execution traces table: %w", err)
+	}
+
+	// Create execution waits table
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS execution_waits (
+			execution_id TEXT PRIMARY KEY,
+			account_id TEXT NOT NULL,
+			flow_id TEXT NOT NULL,
+			node_id TEXT NOT NULL,
+			signal TEXT NOT NULL,
+			event TEXT NOT NULL DEFAULT '',
+			event_key TEXT NOT NULL DEFAULT '',
+			deadline TIMESTAMP,
+			action TEXT NOT NULL DEFAULT '',
+			result JSONB,
+			created_at TIMESTAMP NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS execution_waits_deadline_idx ON execution_waits (deadline);
+		CREATE INDEX IF NOT EXISTS execution_waits_event_idx ON execution_waits (account_id, event, event_key);
+	`)
+
+	if err != nil {
+		return fmt.Errorf("failed to create execution waits table: %w", err)
+	}
+
 	return nil
 }
 
@@ -601,6 +695,15 @@ func (s *PostgreSQLExecutionStore) SaveExecution(execution runtime.ExecutionStat
 		}
 	}
 
+	// Marshal metadata to JSON
+	var metadataJSON []byte
+	if execution.Metadata != nil {
+		metadataJSON, err = json.Marshal(execution.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal execution metadata: %w", err)
+		}
+	}
+
 	// Check if execution already exists and get the account ID
 	var exists bool
 	var accountID sql.NullString
@@ -620,8 +723,9 @@ func (s *PostgreSQLExecutionStore) SaveExecution(execution runtime.ExecutionStat
 				error = $5, 
 				results = $6, 
 				progress = $7, 
-				current_node = $8 
-			WHERE id = $9`,
+				current_node = $8,
+				metadata = $9
+			WHERE id = $10`,
 			execution.FlowID,
 			execution.Status,
 			execution.StartTime,
@@ -630,6 +734,7 @@ func (s *PostgreSQLExecutionStore) SaveExecution(execution runtime.ExecutionStat
 			resultsJSON,
 			execution.Progress,
 			execution.CurrentNode,
+			metadataJSON,
 			execution.ID,
 		)
 		if err != nil {
@@ -652,8 +757,9 @@ func (s *PostgreSQLExecutionStore) SaveExecution(execution runtime.ExecutionStat
 				error, 
 				results, 
 				progress, 
-				current_node
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+				current_node,
+				metadata
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
 			execution.ID,
 			execution.FlowID,
 			placeholderAccountID,
@@ -664,6 +770,7 @@ func (s *PostgreSQLExecutionStore) SaveExecution(execution runtime.ExecutionStat
 			resultsJSON,
 			execution.Progress,
 			execution.CurrentNode,
+			metadataJSON,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert execution: %w", err)
@@ -687,10 +794,24 @@ func (s *PostgreSQLExecutionStore) SetExecutionAccountID(executionID, accountID
 	return nil
 }
 
+// GetExecutionAccountID returns the account recorded for an execution
+func (s *PostgreSQLExecutionStore) GetExecutionAccountID(executionID string) (string, error) {
+	var accountID sql.NullString
+	err := s.db.QueryRow("SELECT account_id FROM executions WHERE id = $1", executionID).Scan(&accountID)
+	if err == sql.ErrNoRows {
+		return "", ErrExecutionNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get execution account ID: %w", err)
+	}
+	return accountID.String, nil
+}
+
 // GetExecution retrieves execution data
 func (s *PostgreSQLExecutionStore) GetExecution(executionID string) (runtime.ExecutionStatus, error) {
 	var execution runtime.ExecutionStatus
 	var resultsJSON []byte
+	var metadataJSON []byte
 	var endTime sql.NullTime
 
 	var accountID string         // We'll ignore this since ExecutionStatus doesn't have AccountID
@@ -708,7 +829,8 @@ func (s *PostgreSQLExecutionStore) GetExecution(executionID string) (runtime.Exe
 			error, 
 			results, 
 			progress, 
-			current_node 
+			current_node,
+			metadata
 		FROM executions WHERE id = $1`,
 		executionID,
 	).Scan(
@@ -722,6 +844,7 @@ func (s *PostgreSQLExecutionStore) GetExecution(executionID string) (runtime.Exe
 		&resultsJSON,
 		&progress,
 		&currentNode,
+		&metadataJSON,
 	)
 
 	// Handle nullable fields
@@ -760,6 +883,13 @@ func (s *PostgreSQLExecutionStore) GetExecution(executionID string) (runtime.Exe
 		}
 	}
 
+	// Unmarshal metadata if present
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &execution.Metadata); err != nil {
+			return runtime.ExecutionStatus{}, fmt.Errorf("failed to unmarshal execution metadata: %w", err)
+		}
+	}
+
 	return execution, nil
 }
 
@@ -776,7 +906,8 @@ func (s *PostgreSQLExecutionStore) ListExecutions(accountID string) ([]runtime.E
 			error, 
 			results, 
 			progress, 
-			current_node 
+			current_node,
+			metadata
 		FROM executions WHERE account_id = $1
 		ORDER BY start_time DESC`,
 		accountID,
@@ -790,6 +921,7 @@ func (s *PostgreSQLExecutionStore) ListExecutions(accountID string) ([]runtime.E
 	for rows.Next() {
 		var execution runtime.ExecutionStatus
 		var resultsJSON []byte
+		var metadataJSON []byte
 		var endTime sql.NullTime
 
 		var accountID string         // Local variable for account ID
@@ -807,6 +939,7 @@ func (s *PostgreSQLExecutionStore) ListExecutions(accountID string) ([]runtime.E
 			&resultsJSON,
 			&progress,
 			&currentNode,
+			&metadataJSON,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan execution: %w", err)
 		}
@@ -823,6 +956,13 @@ func (s *PostgreSQLExecutionStore) ListExecutions(accountID string) ([]runtime.E
 			}
 		}
 
+		// Unmarshal metadata if present
+		if len(metadataJSON) > 0 {
+			if err := json.Unmarshal(metadataJSON, &execution.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal execution metadata: %w", err)
+			}
+		}
+
 		executions = append(executions, execution)
 	}
 
@@ -905,6 +1045,491 @@ func (s *PostgreSQLExecutionStore) GetExecutionLogs(executionID string) ([]runti
 	return logs, nil
 }
 
+// SaveNodeTrace persists a node visit of an execution
+func (s *PostgreSQLExecutionStore) SaveNodeTrace(trace runtime.NodeTrace) error {
+	var paramsJSON, outputJSON []byte
+	var err error
+	if trace.Params != nil {
+		paramsJSON, err = json.Marshal(trace.Params)
+		if err != nil {
+			return fmt.Errorf("failed to marshal trace params: %w", err)
+		}
+	}
+	if trace.Output != nil {
+		outputJSON, err = json.Marshal(trace.Output)
+		if err != nil {
+			return fmt.Errorf("failed to marshal trace output: %w", err)
+		}
+	}
+
+	// A resumed execution runs the interrupted node again, so overwrite it
+	_, err = s.db.Exec(
+		`INSERT INTO execution_traces (execution_id, sequence, node_id, node_type, visit, start_time, end_time, action, retries, params, output, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (execution_id, sequence) DO UPDATE SET
+			node_id = EXCLUDED.node_id,
+			node_type = EXCLUDED.node_type,
+			visit = EXCLUDED.visit,
+			start_time = EXCLUDED.start_time,
+			end_time = EXCLUDED.end_time,
+			action = EXCLUDED.action,
+			retries = EXCLUDED.retries,
+			params = EXCLUDED.params,
+			output = EXCLUDED.output,
+			error = EXCLUDED.error`,
+		trace.ExecutionID,
+		trace.Sequence,
+		trace.NodeID,
+		trace.NodeType,
+		trace.Visit,
+		trace.StartTime,
+		trace.EndTime,
+		trace.Action,
+		trace.Retries,
+		paramsJSON,
+		outputJSON,
+		trace.Error,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save node trace: %w", err)
+	}
+
+	return nil
+}
+
+// GetExecutionTrace retrieves the node visits of an execution ordered by sequence
+func (s *PostgreSQLExecutionStore) GetExecutionTrace(executionID string) ([]runtime.NodeTrace, error) {
+	rows, err := s.db.Query(
+		`SELECT sequence, node_id, node_type, visit, start_time, end_time, action, retries, params, output, error
+		FROM execution_traces WHERE execution_id = $1 ORDER BY sequence ASC`,
+		executionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get execution trace: %w", err)
+	}
+	defer rows.Close()
+
+	traces := make([]runtime.NodeTrace, 0)
+	for rows.Next() {
+		trace := runtime.NodeTrace{ExecutionID: executionID}
+		var nodeType, action, traceError sql.NullString
+		var paramsJSON, outputJSON []byte
+
+		if err := rows.Scan(
+			&trace.Sequence,
+			&trace.NodeID,
+			&nodeType,
+			&trace.Visit,
+			&trace.StartTime,
+			&trace.EndTime,
+			&action,
+			&trace.Retries,
+			&paramsJSON,
+			&outputJSON,
+			&traceError,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan node trace: %w", err)
+		}
+		trace.NodeType = nodeType.String
+		trace.Action = action.String
+		trace.Error = traceError.String
+
+		if len(paramsJSON) > 0 {
+			if err := json.Unmarshal(paramsJSON, &trace.Params); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal trace params: %w", err)
+			}
+		}
+		if len(outputJSON) > 0 {
+			if err := json.Unmarshal(outputJSON, &trace.Output); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal trace output: %w", err)
+			}
+		}
+
+		traces = append(traces, trace)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating node trace rows: %w", err)
+	}
+
+	return traces, nil
+}
+
+// SaveWait records that an execution waits for a signal or timer
+func (s *PostgreSQLExecutionStore) SaveWait(wait runtime.ExecutionWait) error {
+	var deadline sql.NullTime
+	if !wait.Deadline.IsZero() {
+		deadline = sql.NullTime{Time: wait.Deadline, Valid: true}
+	}
+
+	// Marshal result to JSON
+	var resultJSON []byte
+	if wait.Result != nil {
+		var err error
+		resultJSON, err = json.Marshal(wait.Result)
+		if err != nil {
+			return fmt.Errorf("failed to marshal wait result: %w", err)
+		}
+	}
+
+	_, err := s.db.Exec(
+		`INSERT INTO execution_waits (execution_id, account_id, flow_id, node_id, signal, event, event_key, deadline, action, result, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (execution_id) DO UPDATE SET
+			account_id = EXCLUDED.account_id,
+			flow_id = EXCLUDED.flow_id,
+			node_id = EXCLUDED.node_id,
+			signal = EXCLUDED.signal,
+			event = EXCLUDED.event,
+			event_key = EXCLUDED.event_key,
+			deadline = EXCLUDED.deadline,
+			action = EXCLUDED.action,
+			result = EXCLUDED.result,
+			created_at = EXCLUDED.created_at`,
+		wait.ExecutionID,
+		wait.AccountID,
+		wait.FlowID,
+		wait.NodeID,
+		wait.Signal,
+		wait.Event,
+		wait.EventKey,
+		deadline,
+		wait.Action,
+		resultJSON,
+		wait.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save wait: %w", err)
+	}
+
+	return nil
+}
+
+// GetWait retrieves the wait of an execution
+func (s *PostgreSQLExecutionStore) GetWait(executionID string) (runtime.ExecutionWait, error) {
+	row := s.db.QueryRow(
+		`SELECT execution_id, account_id, flow_id, node_id, signal, event, event_key, deadline, action, result, created_at
+		FROM execution_waits WHERE execution_id = $1`,
+		executionID,
+	)
+	return scanExecutionWait(row)
+}
+
+// ClaimWait removes and returns the wait of an execution
+func (s *PostgreSQLExecutionStore) ClaimWait(executionID string) (runtime.ExecutionWait, error) {
+	row := s.db.QueryRow(
+		`DELETE FROM execution_waits WHERE execution_id = $1
+		RETURNING execution_id, account_id, flow_id, node_id, signal, event, event_key, deadline, action, result, created_at`,
+		executionID,
+	)
+	return scanExecutionWait(row)
+}
+
+// ListDueWaits returns the waits whose deadline is not after before, ordered by deadline
+func (s *PostgreSQLExecutionStore) ListDueWaits(before time.Time) ([]runtime.ExecutionWait, error) {
+	rows, err := s.db.Query(
+		`SELECT execution_id, account_id, flow_id, node_id, signal, event, event_key, deadline, action, result, created_at
+		FROM execution_waits WHERE deadline IS NOT NULL AND deadline <= $1 ORDER BY deadline ASC`,
+		before,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due waits: %w", err)
+	}
+	defer rows.Close()
+
+	waits := make([]runtime.ExecutionWait, 0)
+	for rows.Next() {
+		wait, err := scanExecutionWait(rows)
+		if err != nil {
+			return nil, err
+		}
+		waits = append(waits, wait)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating wait rows: %w", err)
+	}
+
+	return waits, nil
+}
+
+// ListEventWaits returns the waits of an account for the event with the correlation key
+func (s *PostgreSQLExecutionStore) ListEventWaits(accountID, event, key string) ([]runtime.ExecutionWait, error) {
+	rows, err := s.db.Query(
+		`SELECT execution_id, account_id, flow_id, node_id, signal, event, event_key, deadline, action, result, created_at
+		FROM execution_waits WHERE account_id = $1 AND event = $2 AND event_key = $3 AND event <> '' ORDER BY created_at ASC`,
+		accountID,
+		event,
+		key,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event waits: %w", err)
+	}
+	defer rows.Close()
+
+	waits := make([]runtime.ExecutionWait, 0)
+	for rows.Next() {
+		wait, err := scanExecutionWait(rows)
+		if err != nil {
+			return nil, err
+		}
+		waits = append(waits, wait)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating wait rows: %w", err)
+	}
+
+	return waits, nil
+}
+
+// scanExecutionWait reads a wait from a row of the execution waits table
+func scanExecutionWait(row interface{ Scan(dest ...any) error }) (runtime.ExecutionWait, error) {
+	var wait runtime.ExecutionWait
+	var deadline sql.NullTime
+	var resultJSON []byte
+	err := row.Scan(
+		&wait.ExecutionID,
+		&wait.AccountID,
+		&wait.FlowID,
+		&wait.NodeID,
+		&wait.Signal,
+		&wait.Event,
+		&wait.EventKey,
+		&deadline,
+		&wait.Action,
+		&resultJSON,
+		&wait.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return runtime.ExecutionWait{}, runtime.ErrWaitNotFound
+	}
+	if err != nil {
+		return runtime.ExecutionWait{}, fmt.Errorf("failed to scan wait: %w", err)
+	}
+	if deadline.Valid {
+		wait.Deadline = deadline.Time
+	}
+	if len(resultJSON) > 0 {
+		if err := json.Unmarshal(resultJSON, &wait.Result); err != nil {
+			return runtime.ExecutionWait{}, fmt.Errorf("failed to unmarshal wait result: %w", err)
+		}
+	}
+	return wait, nil
+}
+
+// SaveCheckpoint persists a checkpoint for an execution
+func (s *PostgreSQLExecutionStore) SaveCheckpoint(checkpoint runtime.ExecutionCheckpoint) error {
+	sharedJSON, err := json.Marshal(checkpoint.Shared)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint state: %w", err)
+	}
+
+	// A resumed execution checkpoints the same step again, so overwrite it
+	_, err = s.db.Exec(
+		`INSERT INTO execution_checkpoints (execution_id, step, account_id, flow_id, node_id, shared, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (execution_id, step) DO UPDATE SET
+			node_id = EXCLUDED.node_id,
+			shared = EXCLUDED.shared,
+			created_at = EXCLUDED.created_at`,
+		checkpoint.ExecutionID,
+		checkpoint.Step,
+		checkpoint.AccountID,
+		checkpoint.FlowID,
+		checkpoint.NodeID,
+		sharedJSON,
+		checkpoint.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// ListInterruptedExecutions returns the latest checkpoint of every running or queued execution
+func (s *PostgreSQLExecutionStore) ListInterruptedExecutions() ([]runtime.ExecutionCheckpoint, error) {
+	rows, err := s.db.Query(
+		`SELECT DISTINCT ON (c.execution_id)
+			c.execution_id,
+			c.step,
+			c.account_id,
+			c.flow_id,
+			c.node_id,
+			c.shared,
+			c.created_at
+		FROM execution_checkpoints c
+		JOIN executions e ON e.id = c.execution_id
+		WHERE e.status IN ('running', 'queued')
+		ORDER BY c.execution_id, c.step DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interrupted executions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanCheckpoints(rows)
+}
+
+// GetExecutionCheckpoints retrieves the checkpoints of an execution ordered by step
+func (s *PostgreSQLExecutionStore) GetExecutionCheckpoints(executionID string) ([]runtime.ExecutionCheckpoint, error) {
+	rows, err := s.db.Query(
+		`SELECT execution_id, step, account_id, flow_id, node_id, shared, created_at
+		FROM execution_checkpoints
+		WHERE execution_id = $1
+		ORDER BY step`,
+		executionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get execution checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	checkpoints, err := scanCheckpoints(rows)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoints == nil {
+		checkpoints = []runtime.ExecutionCheckpoint{}
+	}
+	return checkpoints, nil
+}
+
+// scanCheckpoints reads checkpoint rows selected in the column order of the
+// execution_checkpoints table
+func scanCheckpoints(rows *sql.Rows) ([]runtime.ExecutionCheckpoint, error) {
+	var checkpoints []runtime.ExecutionCheckpoint
+	for rows.Next() {
+		var checkpoint runtime.ExecutionCheckpoint
+		var sharedJSON []byte
+
+		if err := rows.Scan(
+			&checkpoint.ExecutionID,
+			&checkpoint.Step,
+			&checkpoint.AccountID,
+			&checkpoint.FlowID,
+			&checkpoint.NodeID,
+			&sharedJSON,
+			&checkpoint.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan checkpoint: %w", err)
+		}
+
+		if len(sharedJSON) > 0 {
+			if err := json.Unmarshal(sharedJSON, &checkpoint.Shared); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal checkpoint state: %w", err)
+			}
+		}
+
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating checkpoint rows: %w", err)
+	}
+
+	return checkpoints, nil
+}
+
+// ClaimIdempotencyKey records executionID under the key of an account unless
+// the key was recorded after since, and returns the recorded execution ID
+func (s *PostgreSQLExecutionStore) ClaimIdempotencyKey(accountID, key, executionID string, since time.Time) (string, error) {
+	// Concurrent requests with the same key race on the primary key, only
+	// one of them inserts or replaces an expired record
+	var recordedID string
+	err := s.db.QueryRow(
+		`INSERT INTO execution_idempotency_keys (account_id, idempotency_key, execution_id, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (account_id, idempotency_key) DO UPDATE SET
+			execution_id = EXCLUDED.execution_id,
+			created_at = EXCLUDED.created_at
+		WHERE execution_idempotency_keys.created_at <= $5
+		RETURNING execution_id`,
+		accountID,
+		key,
+		executionID,
+		time.Now(),
+		since,
+	).Scan(&recordedID)
+	if err == nil {
+		return recordedID, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	// The key is recorded and still within its window
+	err = s.db.QueryRow(
+		`SELECT execution_id FROM execution_idempotency_keys WHERE account_id = $1 AND idempotency_key = $2`,
+		accountID,
+		key,
+	).Scan(&recordedID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	return recordedID, nil
+}
+
+// ReleaseIdempotencyKey removes the key of an account if it still records
+// executionID
+func (s *PostgreSQLExecutionStore) ReleaseIdempotencyKey(accountID, key, executionID string) error {
+	_, err := s.db.Exec(
+		`DELETE FROM execution_idempotency_keys WHERE account_id = $1 AND idempotency_key = $2 AND execution_id = $3`,
+		accountID,
+		key,
+		executionID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimExecution makes owner the owner of an execution until the given time,
+// unless another owner holds a lease that has not expired
+func (s *PostgreSQLExecutionStore) ClaimExecution(executionID, owner string, until time.Time) (bool, error) {
+	// Concurrent claims race on the primary key, only one of them inserts or
+	// takes over an expired lease
+	result, err := s.db.Exec(
+		`INSERT INTO execution_leases (execution_id, owner, lease_until)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (execution_id) DO UPDATE SET
+			owner = EXCLUDED.owner,
+			lease_until = EXCLUDED.lease_until
+		WHERE execution_leases.owner = EXCLUDED.owner OR execution_leases.lease_until < $4`,
+		executionID,
+		owner,
+		until,
+		time.Now(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim execution: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim execution: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// ReleaseExecution ends the lease of owner on an execution
+func (s *PostgreSQLExecutionStore) ReleaseExecution(executionID, owner string) error {
+	_, err := s.db.Exec(
+		`DELETE FROM execution_leases WHERE execution_id = $1 AND owner = $2`,
+		executionID,
+		owner,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release execution: %w", err)
+	}
+
+	return nil
+}
+
 // PostgreSQLAccountStore implements the AccountStore interface using PostgreSQL
 type PostgreSQLAccountStore struct {
 	db *sql.DB
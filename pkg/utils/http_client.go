@@ -3,6 +3,7 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -52,6 +53,11 @@ func (c *HTTPClient) SetTimeout(timeout time.Duration) {
 
 // Do executes an HTTP request
 func (c *HTTPClient) Do(req *HTTPRequest) (*HTTPResponse, error) {
+	return c.DoWithContext(context.Background(), req)
+}
+
+// DoWithContext executes an HTTP request that is aborted when ctx is canceled
+func (c *HTTPClient) DoWithContext(ctx context.Context, req *HTTPRequest) (*HTTPResponse, error) {
 	// Set default method if not provided
 	if req.Method == "" {
 		req.Method = "GET"
@@ -76,7 +82,7 @@ func (c *HTTPClient) Do(req *HTTPRequest) (*HTTPResponse, error) {
 	}
 
 	// Create HTTP request
-	httpReq, err := http.NewRequest(req.Method, req.URL, bodyReader)
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -113,6 +113,23 @@ type ErrorInfo struct {
 	Message string `json:"message"`
 	Type    string `json:"type"`
 	Code    string `json:"code"`
+	Status  int    `json:"status,omitempty"`
+}
+
+// APIError is returned when an LLM provider answers with an error status
+type APIError struct {
+	Provider string
+	Status   int
+	Message  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error (status %d): %s", e.Provider, e.Status, e.Message)
+}
+
+// StatusCode returns the HTTP status the provider answered with
+func (e *APIError) StatusCode() int {
+	return e.Status
 }
 
 // NewLLMClient creates a new LLM client
@@ -232,7 +249,7 @@ func (c *LLMClient) completeOpenAI(ctx context.Context, request LLMRequest) (*LL
 			} `json:"error"`
 		}
 		if err := json.Unmarshal(resp.RawBody, &errorResp); err != nil {
-			return nil, fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(resp.RawBody))
+			return nil, &APIError{Provider: "OpenAI", Status: resp.StatusCode, Message: string(resp.RawBody)}
 		}
 		fmt.Printf("[DEBUG] OpenAI API Error: %s (Type: %s, Code: %s)\n", errorResp.Error.Message, errorResp.Error.Type, errorResp.Error.Code)
 		return &LLMResponse{
@@ -240,6 +257,7 @@ func (c *LLMClient) completeOpenAI(ctx context.Context, request LLMRequest) (*LL
 				Message: errorResp.Error.Message,
 				Type:    errorResp.Error.Type,
 				Code:    errorResp.Error.Code,
+				Status:  resp.StatusCode,
 			},
 		}, nil
 	}
@@ -318,7 +336,7 @@ func (c *LLMClient) completeAnthropicMessages(ctx context.Context, request LLMRe
 
 	// Check for errors
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("Anthropic API error (status %d): %s", resp.StatusCode, string(resp.RawBody))
+		return nil, &APIError{Provider: "Anthropic", Status: resp.StatusCode, Message: string(resp.RawBody)}
 	}
 
 	// Parse response
@@ -456,7 +474,7 @@ func (c *LLMClient) completeAnthropic(ctx context.Context, request LLMRequest) (
 
 	// Check for errors
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("Anthropic API error (status %d): %s", resp.StatusCode, string(resp.RawBody))
+		return nil, &APIError{Provider: "Anthropic", Status: resp.StatusCode, Message: string(resp.RawBody)}
 	}
 
 	// Parse response
@@ -520,7 +538,7 @@ func (c *LLMClient) completeGeneric(ctx context.Context, request LLMRequest) (*L
 
 	// Check for errors
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("LLM API error (status %d): %s", resp.StatusCode, string(resp.RawBody))
+		return nil, &APIError{Provider: "LLM", Status: resp.StatusCode, Message: string(resp.RawBody)}
 	}
 
 	// Parse response